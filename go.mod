@@ -3,6 +3,8 @@ module zipper
 go 1.16
 
 require (
+	github.com/klauspost/compress v1.15.15
+	github.com/ulikunitz/xz v0.5.11
 	github.com/zeebo/assert v1.3.1 // indirect
 	github.com/zeebo/errs/v2 v2.0.3
 )
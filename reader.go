@@ -179,8 +179,7 @@ func (fi *FileInfo) OpenAsGzipOrUncompressed(ctx context.Context, allowGzip bool
 		if err != nil {
 			return nil, false, 0, err
 		}
-		gzipSize := int64(fi.file.CompressedSize64) + 18 // wrapper adds 18 bytes to deflate
-		return &File{FileInfo: fi, ReadCloser: rc}, true, gzipSize, nil
+		return &File{FileInfo: fi, ReadCloser: rc}, true, fi.file.GzipSize(), nil
 	} else if isDeflate || fi.file.Method == zipread.Store {
 		rc, err = fi.file.Open()
 		if err != nil {
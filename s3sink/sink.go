@@ -0,0 +1,161 @@
+// Package s3sink writes objects to S3-compatible object stores as
+// multipart uploads, implementing zipread.Sink.
+package s3sink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// MaxParts is the largest part number S3 accepts. Every part but the last
+// must hold at least 5 MiB.
+const MaxParts = 10000
+
+// A Part is an uploaded part of a multipart upload.
+type Part struct {
+	Number int
+	ETag   string
+}
+
+// Client is the subset of the S3 API a Sink needs. It maps one to one onto
+// the multipart upload calls of the AWS SDKs and of S3-compatible stores,
+// so an adapter is a few lines of code and keeps this package free of any
+// SDK dependency.
+type Client interface {
+	CreateMultipartUpload(ctx context.Context, bucket, key string) (uploadID string, err error)
+	// UploadPart uploads body, of size bytes, as the numbered part. The
+	// body may be rewound for retries.
+	UploadPart(ctx context.Context, bucket, key, uploadID string, number int, body io.ReadSeeker, size int64) (etag string, err error)
+	// ListParts returns the parts uploaded so far.
+	ListParts(ctx context.Context, bucket, key, uploadID string) ([]Part, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) error
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+}
+
+// A Sink writes an object as a multipart upload. The upload is started by
+// the first part written, and its ID can be persisted with UploadID to
+// resume it later with Resume, for instance along with a
+// zipcopy.Checkpoint.
+type Sink struct {
+	client      Client
+	bucket, key string
+
+	mu       sync.Mutex
+	uploadID string
+	listed   bool           // whether parts holds the parts of a resumed upload
+	parts    map[int]string // part number -> ETag
+}
+
+var _ zipread.Sink = (*Sink)(nil)
+
+// New returns a Sink writing the object key in bucket through client.
+func New(client Client, bucket, key string) *Sink {
+	return &Sink{client: client, bucket: bucket, key: key, listed: true, parts: make(map[int]string)}
+}
+
+// Resume returns a Sink continuing the multipart upload uploadID, whose
+// parts already uploaded are kept.
+func Resume(client Client, bucket, key, uploadID string) *Sink {
+	return &Sink{client: client, bucket: bucket, key: key, uploadID: uploadID, parts: make(map[int]string)}
+}
+
+// UploadID returns the ID of the multipart upload, or "" if no part has
+// been written yet.
+func (s *Sink) UploadID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.uploadID
+}
+
+// start creates the upload, or lists the parts of a resumed one. It must
+// be called with s.mu held.
+func (s *Sink) start(ctx context.Context) error {
+	if s.uploadID == "" {
+		id, err := s.client.CreateMultipartUpload(ctx, s.bucket, s.key)
+		if err != nil {
+			return err
+		}
+		s.uploadID = id
+	}
+	if !s.listed {
+		parts, err := s.client.ListParts(ctx, s.bucket, s.key, s.uploadID)
+		if err != nil {
+			return err
+		}
+		for _, p := range parts {
+			s.parts[p.Number] = p.ETag
+		}
+		s.listed = true
+	}
+	return nil
+}
+
+// WritePart uploads data as the numbered part. The offset is implied by
+// the part number in S3 and not used. Parts are read into memory first,
+// since S3 needs their length up front and the SDKs rewind them to retry.
+func (s *Sink) WritePart(ctx context.Context, number int, offset int64, data io.Reader) error {
+	if number < 1 || number > MaxParts {
+		return errs.Errorf("s3sink: part number %d out of range", number)
+	}
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	err = s.start(ctx)
+	uploadID := s.uploadID
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	etag, err := s.client.UploadPart(ctx, s.bucket, s.key, uploadID, number, bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.parts[number] = etag
+	s.mu.Unlock()
+	return nil
+}
+
+// Commit completes the upload with the parts written, in order. An
+// object without parts is completed with a single empty one.
+func (s *Sink) Commit(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.start(ctx); err != nil {
+		return err
+	}
+	if len(s.parts) == 0 {
+		etag, err := s.client.UploadPart(ctx, s.bucket, s.key, s.uploadID, 1, bytes.NewReader(nil), 0)
+		if err != nil {
+			return err
+		}
+		s.parts[1] = etag
+	}
+	parts := make([]Part, 0, len(s.parts))
+	for number, etag := range s.parts {
+		parts = append(parts, Part{Number: number, ETag: etag})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return s.client.CompleteMultipartUpload(ctx, s.bucket, s.key, s.uploadID, parts)
+}
+
+// Abort aborts the upload, discarding the parts written.
+func (s *Sink) Abort(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.uploadID == "" {
+		return nil
+	}
+	return s.client.AbortMultipartUpload(ctx, s.bucket, s.key, s.uploadID)
+}
@@ -0,0 +1,155 @@
+package s3sink
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipcopy"
+	"zipper/zipread"
+)
+
+// memStore is an in-memory S3 bucket.
+type memStore struct {
+	uploads map[string]map[int][]byte
+	objects map[string][]byte
+	next    int
+}
+
+func newMemStore() *memStore {
+	return &memStore{uploads: make(map[string]map[int][]byte), objects: make(map[string][]byte)}
+}
+
+func (m *memStore) CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	m.next++
+	id := fmt.Sprintf("upload%d", m.next)
+	m.uploads[id] = make(map[int][]byte)
+	return id, nil
+}
+
+func (m *memStore) UploadPart(ctx context.Context, bucket, key, uploadID string, number int, body io.ReadSeeker, size int64) (string, error) {
+	parts, ok := m.uploads[uploadID]
+	if !ok {
+		return "", errs.Errorf("no upload %q", uploadID)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(b)) != size {
+		return "", errs.Errorf("part of %d bytes sent as %d", len(b), size)
+	}
+	parts[number] = b
+	return fmt.Sprintf("etag-%d-%d", number, len(b)), nil
+}
+
+func (m *memStore) ListParts(ctx context.Context, bucket, key, uploadID string) ([]Part, error) {
+	var parts []Part
+	for number, b := range m.uploads[uploadID] {
+		parts = append(parts, Part{Number: number, ETag: fmt.Sprintf("etag-%d-%d", number, len(b))})
+	}
+	return parts, nil
+}
+
+func (m *memStore) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) error {
+	var object []byte
+	for _, p := range parts {
+		b, ok := m.uploads[uploadID][p.Number]
+		if !ok || p.ETag != fmt.Sprintf("etag-%d-%d", p.Number, len(b)) {
+			return errs.Errorf("bad part %+v", p)
+		}
+		object = append(object, b...)
+	}
+	m.objects[bucket+"/"+key] = object
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+func (m *memStore) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+func testArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := 0; i < 20; i++ {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("file%d", i), Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(bytes.Repeat([]byte{byte(i)}, 1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSinkResume(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	data := testArchive(t)
+	src := zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))
+
+	dst := New(store, "bucket", "copy.zip")
+	interrupted := errors.New("interrupted")
+	var last zipcopy.Checkpoint
+	err := zipcopy.Copy(ctx, src, dst, &zipcopy.Options{
+		PartSize: 5000,
+		Checkpoint: func(cp zipcopy.Checkpoint) error {
+			last = cp
+			if cp.Parts == 2 {
+				return interrupted
+			}
+			return nil
+		},
+	})
+	if err != interrupted {
+		t.Fatalf("got %v, want %v", err, interrupted)
+	}
+
+	// Another process picks the upload up by its ID.
+	dst = Resume(store, "bucket", "copy.zip", dst.UploadID())
+	if err := zipcopy.Copy(ctx, src, dst, &zipcopy.Options{Resume: &last}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(store.objects["bucket/copy.zip"], data) {
+		t.Fatal("uploaded object differs from source")
+	}
+}
+
+func TestSinkEdgeCases(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+
+	empty := New(store, "bucket", "empty")
+	if err := empty.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := store.objects["bucket/empty"]; !ok || len(b) != 0 {
+		t.Errorf("empty object: got %q, %v", b, ok)
+	}
+
+	s := New(store, "bucket", "aborted")
+	if err := s.WritePart(ctx, 0, 0, bytes.NewReader(nil)); err == nil {
+		t.Error("part number 0 accepted")
+	}
+	if err := s.WritePart(ctx, 1, 0, bytes.NewReader([]byte("x"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.uploads) != 0 {
+		t.Errorf("%d uploads left after abort", len(store.uploads))
+	}
+}
@@ -0,0 +1,214 @@
+// Package zipcopy copies ZIP archives between storage backends.
+package zipcopy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// ErrVerification is returned, wrapped, when the copied archive does not
+// match the source.
+var ErrVerification = errors.New("zipcopy: verification failed")
+
+const defaultPartSize = 64 << 20
+
+// Options configures Copy. The zero value copies in 64 MiB parts without
+// resuming or verifying the result.
+type Options struct {
+	// PartSize is the size of every part handed to the Sink but the last.
+	PartSize int64
+
+	// Resume, if non-nil, continues an interrupted copy from the given
+	// checkpoint. The Sink must still hold the parts the checkpoint
+	// reports as written.
+	Resume *Checkpoint
+
+	// Checkpoint, if non-nil, is called after each part has been written.
+	// Persisting the checkpoint allows an interrupted copy to be resumed.
+	Checkpoint func(Checkpoint) error
+
+	// Verify, if non-nil, gives read access to the destination. After
+	// committing, Copy opens it and checks that its end of central
+	// directory and entry headers match the source, and reads SpotChecks
+	// entries in full to verify their CRCs.
+	Verify     zipread.Source
+	SpotChecks int
+}
+
+// tailLen is the length of the end of the source hashed into
+// Checkpoint.Tail. It covers the end of central directory record and, for
+// most archives, the whole central directory, which changes along with
+// any entry.
+const tailLen = 64 << 10
+
+// Checkpoint records the progress of a Copy.
+type Checkpoint struct {
+	Size     int64  // size of the source archive
+	Tail     []byte // SHA-256 of the last 64 KiB of the source archive
+	PartSize int64
+	Parts    int // number of parts written
+}
+
+// Copy streams the raw bytes of the archive served by src into dst and
+// commits it. Copy never aborts dst: after a failure, the caller either
+// resumes from the last checkpoint or calls dst.Abort.
+func Copy(ctx context.Context, src zipread.Source, dst zipread.Sink, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	// Opening the source up front rejects anything that is not an
+	// archive before a single byte is copied.
+	srcZip, err := zipread.Open(src)
+	if err != nil {
+		return err
+	}
+	size, tail, err := identify(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	cp := Checkpoint{Size: size, Tail: tail, PartSize: opts.PartSize}
+	if cp.PartSize <= 0 {
+		cp.PartSize = defaultPartSize
+	}
+	if opts.Resume != nil {
+		// The parts written must have come from the same archive, not just
+		// one of the same size.
+		if opts.Resume.Size != size || !bytes.Equal(opts.Resume.Tail, tail) || opts.Resume.PartSize <= 0 {
+			return errs.Errorf("zipcopy: checkpoint does not match source")
+		}
+		cp = *opts.Resume
+	}
+
+	for offset := int64(cp.Parts) * cp.PartSize; offset < size; offset += cp.PartSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		length := cp.PartSize
+		if offset+length > size {
+			length = size - offset
+		}
+		if err := copyPart(ctx, src, dst, cp.Parts+1, offset, length); err != nil {
+			return err
+		}
+		cp.Parts++
+		if opts.Checkpoint != nil {
+			if err := opts.Checkpoint(cp); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := dst.Commit(ctx); err != nil {
+		return err
+	}
+	if opts.Verify == nil {
+		return nil
+	}
+	return verify(ctx, srcZip, size, opts.Verify, opts.SpotChecks)
+}
+
+func copyPart(ctx context.Context, src zipread.Source, dst zipread.Sink, number int, offset, length int64) (err error) {
+	rc, err := src.Range(ctx, offset, length)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+
+	cr := &countingReader{r: rc}
+	if err := dst.WritePart(ctx, number, offset, cr); err != nil {
+		return err
+	}
+	if cr.n != length {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// identify returns the size of src and the hash of its tail recorded in
+// checkpoints.
+func identify(ctx context.Context, src zipread.Source) (size int64, tail []byte, err error) {
+	rc, size, err := src.RangeFromEnd(ctx, tailLen)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return 0, nil, err
+	}
+	return size, h.Sum(nil), nil
+}
+
+func sourceSize(ctx context.Context, src zipread.Source) (int64, error) {
+	rc, size, err := src.RangeFromEnd(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+	return size, rc.Close()
+}
+
+// verify checks the copy served by dst against the source archive.
+func verify(ctx context.Context, srcZip *zipread.Reader, size int64, dst zipread.Source, spotChecks int) error {
+	dstSize, err := sourceSize(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if dstSize != size {
+		return errs.Errorf("%w: size %d, want %d", ErrVerification, dstSize, size)
+	}
+	dstZip, err := zipread.Open(dst)
+	if err != nil {
+		return errs.Errorf("%w: %v", ErrVerification, err)
+	}
+	if dstZip.Comment != srcZip.Comment || len(dstZip.File) != len(srcZip.File) {
+		return errs.Errorf("%w: end of central directory differs", ErrVerification)
+	}
+	for i, f := range dstZip.File {
+		s := srcZip.File[i]
+		if f.Name != s.Name || f.CRC32 != s.CRC32 ||
+			f.CompressedSize64 != s.CompressedSize64 || f.UncompressedSize64 != s.UncompressedSize64 {
+			return errs.Errorf("%w: entry %q differs", ErrVerification, f.Name)
+		}
+	}
+
+	if spotChecks > len(dstZip.File) {
+		spotChecks = len(dstZip.File)
+	}
+	for i := 0; i < spotChecks; i++ {
+		// Spread the checks evenly over the archive.
+		f := dstZip.File[i*len(dstZip.File)/spotChecks]
+		if err := readEntry(f); err != nil {
+			return errs.Errorf("%w: entry %q: %v", ErrVerification, f.Name, err)
+		}
+	}
+	return nil
+}
+
+func readEntry(f *zipread.File) (err error) {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
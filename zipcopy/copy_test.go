@@ -0,0 +1,165 @@
+package zipcopy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"zipper/zipread"
+)
+
+// memSink collects parts in memory.
+type memSink struct {
+	parts     map[int][]byte
+	committed []byte
+}
+
+func (s *memSink) WritePart(ctx context.Context, number int, offset int64, data io.Reader) error {
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	if s.parts == nil {
+		s.parts = make(map[int][]byte)
+	}
+	s.parts[number] = b
+	return nil
+}
+
+func (s *memSink) Commit(ctx context.Context) error {
+	var numbers []int
+	for n := range s.parts {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		s.committed = append(s.committed, s.parts[n]...)
+	}
+	return nil
+}
+
+func (s *memSink) Abort(ctx context.Context) error {
+	s.parts = nil
+	return nil
+}
+
+// committedSource serves whatever the sink has committed at call time.
+type committedSource struct{ s *memSink }
+
+func (c committedSource) source() zipread.Source {
+	return zipread.SourceFromReaderAt(bytes.NewReader(c.s.committed), int64(len(c.s.committed)))
+}
+
+func (c committedSource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	return c.source().Range(ctx, offset, length)
+}
+
+func (c committedSource) RangeFromEnd(ctx context.Context, length int64) (io.ReadCloser, int64, error) {
+	return c.source().RangeFromEnd(ctx, length)
+}
+
+func testArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range []string{"a", "b", "c", "d"} {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 4096)
+		rng.Read(data)
+		if _, err := fw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCopyResume(t *testing.T) {
+	ctx := context.Background()
+	data := testArchive(t)
+	src := zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	dst := &memSink{}
+
+	interrupted := errors.New("interrupted")
+	var last Checkpoint
+	err := Copy(ctx, src, dst, &Options{
+		PartSize: 4000,
+		Checkpoint: func(cp Checkpoint) error {
+			last = cp
+			if cp.Parts == 2 {
+				return interrupted
+			}
+			return nil
+		},
+	})
+	if err != interrupted {
+		t.Fatalf("got %v, want %v", err, interrupted)
+	}
+
+	dst.parts[3] = []byte("stale part from the interrupted run")
+	err = Copy(ctx, src, dst, &Options{
+		Resume:     &last,
+		Verify:     committedSource{dst},
+		SpotChecks: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst.committed, data) {
+		t.Fatal("copied archive differs from source")
+	}
+}
+
+func TestCopyVerifyMismatch(t *testing.T) {
+	ctx := context.Background()
+	data := testArchive(t)
+	src := zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[100] ^= 0xff // inside the first entry's body
+	err := Copy(ctx, src, &memSink{}, &Options{
+		Verify:     zipread.SourceFromReaderAt(bytes.NewReader(corrupt), int64(len(corrupt))),
+		SpotChecks: 4,
+	})
+	if !errors.Is(err, ErrVerification) {
+		t.Fatalf("got %v, want %v", err, ErrVerification)
+	}
+}
+
+func TestCopyResumeChangedSource(t *testing.T) {
+	ctx := context.Background()
+	data := testArchive(t)
+	src := zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))
+
+	var last Checkpoint
+	err := Copy(ctx, src, &memSink{}, &Options{
+		PartSize: 4000,
+		Checkpoint: func(cp Checkpoint) error {
+			last = cp
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same size, but a different archive.
+	changed := append([]byte(nil), data...)
+	changed[len(changed)-30] ^= 0xff // in the central directory
+	last.Parts = 1
+	err = Copy(ctx, zipread.SourceFromReaderAt(bytes.NewReader(changed), int64(len(changed))), &memSink{}, &Options{Resume: &last})
+	if err == nil || !strings.Contains(err.Error(), "checkpoint") {
+		t.Fatalf("resuming a copy of a different archive: got %v", err)
+	}
+}
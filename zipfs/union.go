@@ -0,0 +1,180 @@
+// Package zipfs combines the file system views of ZIP archives.
+package zipfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+
+	"zipper/zipread"
+)
+
+// FS presents several archives as a single file system, see Union.
+type FS struct {
+	layers []*zipread.Reader
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+)
+
+// Union returns a file system presenting the contents of readers as one
+// tree, like layered file systems do. Readers listed first take
+// precedence: a name resolves to the first reader holding it, and hides
+// the same name in the others. Directories present in several readers
+// are merged, unless a reader of higher precedence holds a file of the
+// same name. To let patch archives override a base archive, list them
+// before it.
+func Union(readers ...*zipread.Reader) *FS {
+	return &FS{layers: append([]*zipread.Reader(nil), readers...)}
+}
+
+// resolve returns the index of the layer holding name and its FileInfo.
+func (u *FS) resolve(op, name string) (int, fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return 0, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	for i, layer := range u.layers {
+		if fileAbove(layer, name) {
+			break
+		}
+		info, err := layer.Stat(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		return i, info, nil
+	}
+	return 0, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// Open opens the named file or directory from the layer holding it.
+// Directories list the merged contents of all layers.
+func (u *FS) Open(name string) (fs.File, error) {
+	i, info, err := u.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return u.layers[i].Open(name)
+	}
+	entries, err := u.readDir(i, name)
+	if err != nil {
+		return nil, err
+	}
+	return &dir{info: info, name: name, entries: entries}, nil
+}
+
+// Stat returns a FileInfo describing the named file from the layer
+// holding it.
+func (u *FS) Stat(name string) (fs.FileInfo, error) {
+	_, info, err := u.resolve("stat", name)
+	return info, err
+}
+
+// ReadFile reads the named file from the layer holding it.
+func (u *FS) ReadFile(name string) ([]byte, error) {
+	i, info, err := u.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return u.layers[i].ReadFile(name)
+}
+
+// ReadDir returns the merged contents of the named directory, sorted by
+// filename.
+func (u *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	i, info, err := u.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return u.readDir(i, name)
+}
+
+// readDir merges the listings of the directory name in the layers from
+// top on, stopping at the first layer holding a file of that name.
+func (u *FS) readDir(top int, name string) ([]fs.DirEntry, error) {
+	seen := make(map[string]bool)
+	var merged []fs.DirEntry
+	for _, layer := range u.layers[top:] {
+		if fileAbove(layer, name) {
+			break
+		}
+		info, err := layer.Stat(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			break
+		}
+		entries, err := layer.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				merged = append(merged, e)
+			}
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+	return merged, nil
+}
+
+// fileAbove reports whether layer holds a file where one of the parent
+// directories of name would be, hiding name in the layers below.
+func fileAbove(layer *zipread.Reader, name string) bool {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if info, err := layer.Stat(dir); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// dir is an open directory of the union.
+type dir struct {
+	info    fs.FileInfo
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	n := len(d.entries) - d.offset
+	if count > 0 && n > count {
+		n = count
+	}
+	if n == 0 {
+		if count <= 0 {
+			return nil, nil
+		}
+		return nil, io.EOF
+	}
+	list := d.entries[d.offset : d.offset+n]
+	d.offset += n
+	return list, nil
+}
@@ -0,0 +1,74 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"zipper/zipread"
+)
+
+func buildReader(t *testing.T, files map[string]string) *zipread.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+func TestUnion(t *testing.T) {
+	patch := buildReader(t, map[string]string{
+		"assets/logo.png": "patched logo",
+		"assets/new.txt":  "new",
+		"config":          "config file",
+	})
+	base := buildReader(t, map[string]string{
+		"assets/logo.png": "original logo",
+		"assets/old.txt":  "old",
+		"config/app.yaml": "hidden by the patch",
+		"readme.txt":      "readme",
+	})
+	u := Union(patch, base)
+
+	if err := fstest.TestFS(u, "assets/logo.png", "assets/new.txt", "assets/old.txt", "config", "readme.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"assets/logo.png": "patched logo",
+		"assets/old.txt":  "old",
+		"config":          "config file",
+	} {
+		if got, err := fs.ReadFile(u, name); err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q)=%q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := fs.Stat(u, "config/app.yaml"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("entry below a file of a higher layer: got %v", err)
+	}
+	entries, err := fs.ReadDir(u, "assets")
+	if err != nil || len(entries) != 3 {
+		t.Errorf("ReadDir: got %v, %v", entries, err)
+	}
+
+	reversed := Union(base, patch)
+	if got, err := fs.ReadFile(reversed, "assets/logo.png"); err != nil || string(got) != "original logo" {
+		t.Errorf("reversed precedence: got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,56 @@
+package zipread
+
+import "sync"
+
+// annotations holds per-entry values attached with Reader.Annotate.
+type annotations struct {
+	mu     sync.Mutex
+	values map[annotationKey]interface{}
+}
+
+type annotationKey struct {
+	f   *File
+	key interface{}
+}
+
+// Annotate attaches value to the entry f under key, replacing any value
+// previously stored under the same key. Like context keys, key must be
+// comparable and should be of an unexported type to avoid collisions.
+// Annotations are scoped to z and are safe for concurrent use.
+func (z *Reader) Annotate(f *File, key, value interface{}) {
+	z.annotations.mu.Lock()
+	defer z.annotations.mu.Unlock()
+	if z.annotations.values == nil {
+		z.annotations.values = make(map[annotationKey]interface{})
+	}
+	z.annotations.values[annotationKey{f: f, key: key}] = value
+}
+
+// Annotation returns the value attached to f under key, if any.
+func (z *Reader) Annotation(f *File, key interface{}) (value interface{}, ok bool) {
+	z.annotations.mu.Lock()
+	defer z.annotations.mu.Unlock()
+	value, ok = z.annotations.values[annotationKey{f: f, key: key}]
+	return value, ok
+}
+
+// DeleteAnnotation removes the value attached to f under key.
+func (z *Reader) DeleteAnnotation(f *File, key interface{}) {
+	z.annotations.mu.Lock()
+	defer z.annotations.mu.Unlock()
+	delete(z.annotations.values, annotationKey{f: f, key: key})
+}
+
+// Annotated returns the entries that have a value attached under key,
+// in directory order.
+func (z *Reader) Annotated(key interface{}) []*File {
+	z.annotations.mu.Lock()
+	defer z.annotations.mu.Unlock()
+	var files []*File
+	for _, f := range z.File {
+		if _, ok := z.annotations.values[annotationKey{f: f, key: key}]; ok {
+			files = append(files, f)
+		}
+	}
+	return files
+}
@@ -0,0 +1,43 @@
+package zipread
+
+import (
+	"sync"
+	"testing"
+)
+
+type annotationTestKey string
+
+func TestAnnotations(t *testing.T) {
+	z := openTestZip(t, buildTestZip(t,
+		testZipFile{Name: "a", Method: Store},
+		testZipFile{Name: "b", Method: Store},
+		testZipFile{Name: "c", Method: Store}), nil)
+	const scanned = annotationTestKey("scanned")
+
+	var wg sync.WaitGroup
+	for i, f := range z.File {
+		wg.Add(1)
+		go func(i int, f *File) {
+			defer wg.Done()
+			if i != 1 {
+				z.Annotate(f, scanned, i)
+			}
+		}(i, f)
+	}
+	wg.Wait()
+
+	if v, ok := z.Annotation(z.File[2], scanned); !ok || v != 2 {
+		t.Errorf("Annotation=%v, %v; want 2, true", v, ok)
+	}
+	if _, ok := z.Annotation(z.File[1], scanned); ok {
+		t.Error("unexpected annotation on unannotated entry")
+	}
+	if got := z.Annotated(scanned); len(got) != 2 || got[0] != z.File[0] || got[1] != z.File[2] {
+		t.Errorf("Annotated returned %d entries, want a and c", len(got))
+	}
+
+	z.DeleteAnnotation(z.File[0], scanned)
+	if _, ok := z.Annotation(z.File[0], scanned); ok {
+		t.Error("annotation survived DeleteAnnotation")
+	}
+}
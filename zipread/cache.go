@@ -0,0 +1,179 @@
+package zipread
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// An IdentifiedSource is a Source that can tell cheaply which version of
+// an archive it serves, like an object store reporting the ETag of an
+// object.
+type IdentifiedSource interface {
+	Source
+	// Identity returns a string naming the archive, which changes
+	// whenever its contents do.
+	Identity(ctx context.Context) (string, error)
+}
+
+// Identity names the file by its absolute path, size and modification
+// time.
+func (fs *FileSource) Identity(ctx context.Context) (string, error) {
+	abs, err := filepath.Abs(fs.name)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(fs.name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("file:%s:%d:%d", abs, fi.Size(), fi.ModTime().UnixNano()), nil
+}
+
+// cacheTailLen is the length of the end of sources without an identity
+// that is hashed to identify them.
+const cacheTailLen = 64 << 10
+
+// OpenCached is like Open but keeps the central directory of the archive
+// in cacheDir, so that opening the same archive again, in this process or
+// a later one, parses the cached copy instead of downloading it again.
+// Archives are identified by Identity if source is an IdentifiedSource,
+// and otherwise by their size and a hash of their last 64 KiB, which
+// costs one small request. A cached copy that fails to parse is fetched
+// again.
+func OpenCached(ctx context.Context, source Source, cacheDir string) (*Reader, error) {
+	key, err := cacheKey(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Join(cacheDir, key+".zipdir")
+
+	if offset, tail, err := readCachedDirectory(name); err == nil {
+		if z, err := openFromTail(source, offset, tail); err == nil {
+			return z.checkPaths()
+		}
+	}
+
+	offset, tail, err := fetchDirectory(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	z, err := openFromTail(source, offset, tail)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCachedDirectory(cacheDir, name, offset, tail); err != nil {
+		return nil, err
+	}
+	return z.checkPaths()
+}
+
+// cacheKey returns the name of the cached directory of the archive served
+// by source.
+func cacheKey(ctx context.Context, source Source) (string, error) {
+	h := sha256.New()
+	if is, ok := source.(IdentifiedSource); ok {
+		id, err := is.Identity(ctx)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "id:%s", id)
+	} else {
+		rc, size, err := source.RangeFromEnd(ctx, cacheTailLen)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "tail:%d:", size)
+		_, err = io.Copy(h, rc)
+		if err := errs.Combine(err, rc.Close()); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fetchDirectory returns the end of the archive served by source, from
+// the start of its central directory on, and the offset it starts at.
+func fetchDirectory(ctx context.Context, source Source) (offset int64, tail []byte, err error) {
+	end, baseOffset, size, err := readDirectoryEnd(source, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	offset = baseOffset + int64(end.directoryOffset)
+	if offset < 0 || offset > size {
+		return 0, nil, formatError("end of central directory", size, "directory offset out of range")
+	}
+	rc, err := source.Range(ctx, offset, size-offset)
+	if err != nil {
+		return 0, nil, err
+	}
+	tail = make([]byte, size-offset)
+	_, err = io.ReadFull(rc, tail)
+	if err := errs.Combine(err, rc.Close()); err != nil {
+		return 0, nil, err
+	}
+	return offset, tail, nil
+}
+
+// openFromTail opens the archive served by source, reading its directory
+// from tail, which holds the archive from offset to its end.
+func openFromTail(source Source, offset int64, tail []byte) (*Reader, error) {
+	z := &Reader{}
+	dir := &prefetchedTailSource{s: source, size: offset + int64(len(tail)), offset: offset, tail: tail}
+	if err := z.init(source, dir); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// readCachedDirectory reads a cached directory, stored as the offset it
+// starts at in the archive, as 8 little-endian bytes, followed by the
+// archive from there on.
+func readCachedDirectory(name string) (offset int64, tail []byte, err error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 8 {
+		return 0, nil, errs.Errorf("cached directory %q is truncated", name)
+	}
+	offset = int64(binary.LittleEndian.Uint64(data))
+	if offset < 0 {
+		return 0, nil, errs.Errorf("cached directory %q is corrupt", name)
+	}
+	return offset, data[8:], nil
+}
+
+// writeCachedDirectory stores a cached directory under name, which
+// concurrent readers only see once it is complete.
+func writeCachedDirectory(dir, name string, offset int64, tail []byte) (err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	fh, err := os.CreateTemp(dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errs.Combine(err, os.Remove(fh.Name()))
+		}
+	}()
+	var header [8]byte
+	binary.LittleEndian.PutUint64(header[:], uint64(offset))
+	_, err = fh.Write(header[:])
+	if err == nil {
+		_, err = fh.Write(tail)
+	}
+	if err := errs.Combine(err, fh.Close()); err != nil {
+		return err
+	}
+	return os.Rename(fh.Name(), name)
+}
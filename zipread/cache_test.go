@@ -0,0 +1,82 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// versionedSource is a countingSource identified by version.
+type versionedSource struct {
+	countingSource
+	version string
+}
+
+func (s *versionedSource) Identity(ctx context.Context) (string, error) { return s.version, nil }
+
+func TestOpenCached(t *testing.T) {
+	ctx := context.Background()
+	var files []testZipFile
+	for i := 0; i < 200; i++ {
+		files = append(files, testZipFile{Name: fmt.Sprintf("dir/entry%03d", i), Method: Deflate, Data: []byte(fmt.Sprint(i))})
+	}
+	data := buildTestZip(t, files...)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	open := func(src Source) *Reader {
+		t.Helper()
+		z, err := OpenCached(ctx, src, cacheDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(z.File) != len(files) {
+			t.Fatalf("got %d entries, want %d", len(z.File), len(files))
+		}
+		if got, err := z.ReadFile("dir/entry123"); err != nil || string(got) != "123" {
+			t.Fatalf("ReadFile: got %q, %v", got, err)
+		}
+		return z
+	}
+
+	src := &versionedSource{countingSource: countingSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}, version: "v1"}
+	open(src)
+	cached, err := filepath.Glob(filepath.Join(cacheDir, "*.zipdir"))
+	if err != nil || len(cached) != 1 {
+		t.Fatalf("cached directories: %v, %v", cached, err)
+	}
+
+	// Only the entry read is requested once the directory is cached.
+	src.calls = 0
+	open(src)
+	if src.calls != 1 {
+		t.Errorf("cached: got %d requests, want 1", src.calls)
+	}
+
+	// A damaged copy is replaced, and a new version fetched again.
+	if err := os.WriteFile(cached[0], []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src.calls = 0
+	open(src)
+	if src.calls < 2 {
+		t.Errorf("damaged cache: got %d requests, want the directory fetched", src.calls)
+	}
+	src.version = "v2"
+	src.calls = 0
+	open(src)
+	if src.calls < 2 {
+		t.Errorf("new version: got %d requests, want the directory fetched", src.calls)
+	}
+
+	// Without an identity, the tail of the archive identifies it.
+	plain := &countingSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}
+	open(plain)
+	plain.calls = 0
+	open(plain)
+	if plain.calls != 1 {
+		t.Errorf("cached by tail: got %d requests, want 1", plain.calls)
+	}
+}
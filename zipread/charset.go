@@ -0,0 +1,32 @@
+package zipread
+
+import "strings"
+
+// cp437 maps the upper half of code page 437 to Unicode.
+const cp437 = "" +
+	"ÇüéâäàåçêëèïîìÄÅ" +
+	"ÉæÆôöòûùÿÖÜ¢£¥₧ƒ" +
+	"áíóúñÑªº¿⌐¬½¼¡«»" +
+	"░▒▓│┤╡╢╖╕╣║╗╝╜╛┐" +
+	"└┴┬├─┼╞╟╚╔╩╦╠═╬╧" +
+	"╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀" +
+	"αßΓπΣσµτΦΘΩδ∞φε∩" +
+	"≡±≥≤⌠⌡÷≈°∙·√ⁿ²■ "
+
+var cp437High = []rune(cp437)
+
+// DecodeCP437 decodes raw as IBM code page 437, the character set the ZIP
+// specification prescribes for names without the UTF-8 flag. It is
+// suitable for Options.NameDecoder and never fails.
+func DecodeCP437(raw []byte) (string, error) {
+	var b strings.Builder
+	b.Grow(len(raw))
+	for _, c := range raw {
+		if c < 0x80 {
+			b.WriteByte(c)
+		} else {
+			b.WriteRune(cp437High[c-0x80])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,83 @@
+package zipread
+
+import "sync/atomic"
+
+// Stats counts what a Reader has served.
+type Stats struct {
+	Opens          int64 // calls to File.Open
+	ChecksumErrors int64 // entries read in full whose checksum did not match
+}
+
+// Stats returns the counts for z so far. Clones count separately.
+func (z *Reader) Stats() Stats {
+	return Stats{
+		Opens:          atomic.LoadInt64(&z.stats.Opens),
+		ChecksumErrors: atomic.LoadInt64(&z.stats.ChecksumErrors),
+	}
+}
+
+// SetCRCPolicy changes how entries opened from now on verify their
+// checksums, see Options.CRCPolicy. It must not be called concurrently
+// with File.Open; it is meant for configuring a Clone before serving it.
+func (z *Reader) SetCRCPolicy(policy CRCPolicy) {
+	z.opts.CRCPolicy = policy
+}
+
+// Clone returns a Reader serving the same archive from the central
+// directory z has already parsed, so one archive can be served with
+// different settings without reading its directory again. The clone starts
+// with the options and decompressors of z, but keeps its own from then on:
+// RegisterDecompressor and SetCRCPolicy on one do not affect the other,
+// and each counts its own Stats. The entries are copied, since each File
+// refers to its Reader, but they share their names, comments and extra
+// fields with those of z, along with the content offsets and indexes
+// resolved so far. Annotations are not carried over.
+func (z *Reader) Clone() *Reader {
+	c := &Reader{
+		source:     z.source,
+		size:       z.size,
+		baseOffset: z.baseOffset,
+		dirOffset:  z.dirOffset,
+		dirLen:     z.dirLen,
+		disks:      z.disks,
+		end:        z.end,
+		Comment:    z.Comment,
+		opts:       z.opts,
+	}
+
+	z.decompressorsMu.RLock()
+	if z.decompressors != nil {
+		c.decompressors = make(map[uint16]Decompressor, len(z.decompressors))
+		for method, dcomp := range z.decompressors {
+			c.decompressors[method] = dcomp
+		}
+	}
+	z.decompressorsMu.RUnlock()
+
+	// The entries go into a single block, like the parser allocates them.
+	files := make([]File, len(z.File))
+	c.File = make([]*File, len(z.File))
+	for i, f := range z.File {
+		f.mu.Lock()
+		files[i] = File{
+			FileHeader:   f.FileHeader,
+			Accessed:     f.Accessed,
+			Created:      f.Created,
+			SHA256:       f.SHA256,
+			RawName:      f.RawName,
+			RawComment:   f.RawComment,
+			zip:          c,
+			zips:         f.zips,
+			zipsize:      f.zipsize,
+			headerOffset: f.headerOffset,
+			dataOffset:   f.dataOffset,
+			disk:         f.disk,
+			sozip:        f.sozip,
+			sozipLoaded:  f.sozipLoaded,
+			deflateIndex: f.deflateIndex,
+		}
+		f.mu.Unlock()
+		c.File[i] = &files[i]
+	}
+	return c
+}
@@ -0,0 +1,57 @@
+package zipread
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	content := []byte("checksummed content")
+	data := buildTestZip(t,
+		testZipFile{Name: "bad", Method: Store, Data: content},
+		testZipFile{Name: "ok", Method: Deflate, Data: []byte("fine")})
+	// Flip a content byte; the stored entry keeps its original CRC.
+	data[bytes.Index(data, content)] ^= 0xff
+	z := openTestZip(t, data, nil)
+
+	c := z.Clone()
+	c.SetCRCPolicy(CRCOff)
+	c.RegisterDecompressor(Deflate, func(r io.Reader) io.ReadCloser {
+		return io.NopCloser(upperReader{flate.NewReader(r)})
+	})
+
+	if len(c.File) != len(z.File) || c.File[0] == z.File[0] || c.File[0].Name != z.File[0].Name {
+		t.Fatal("clone does not hold its own copies of the entries")
+	}
+	if _, err := readAllFile(z.File[0]); !errors.Is(err, ErrChecksum) {
+		t.Errorf("original: got %v, want %v", err, ErrChecksum)
+	}
+	if _, err := readAllFile(c.File[0]); err != nil {
+		t.Errorf("clone with CRCOff: %v", err)
+	}
+	if got, err := readAllFile(z.File[1]); err != nil || string(got) != "fine" {
+		t.Errorf("original: got %q, %v", got, err)
+	}
+	if got, err := readAllFile(c.File[1]); err != nil || string(got) != "FINE" {
+		t.Errorf("clone: got %q, %v", got, err)
+	}
+
+	if got, want := z.Stats(), (Stats{Opens: 2, ChecksumErrors: 1}); got != want {
+		t.Errorf("original: got %+v, want %+v", got, want)
+	}
+	if got, want := c.Stats(), (Stats{Opens: 2}); got != want {
+		t.Errorf("clone: got %+v, want %+v", got, want)
+	}
+}
+
+// upperReader upper-cases the ASCII text read from r.
+type upperReader struct{ r io.Reader }
+
+func (u upperReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	copy(p, bytes.ToUpper(p[:n]))
+	return n, err
+}
@@ -0,0 +1,109 @@
+package zipread
+
+import "strings"
+
+// compactFiles moves the names and comments of all entries into a single
+// string and drops the Extra fields that are no longer needed, see
+// Options.CompactDirectory.
+func (z *Reader) compactFiles() {
+	var a nameArena
+	n := 0
+	for _, f := range z.File {
+		n += a.sizeString(f.Name) + a.sizeString(f.Comment)
+		if f.decodedNames() {
+			n += a.sizeString(f.RawName) + a.sizeString(f.RawComment)
+		}
+	}
+	a.reset(n)
+	for _, f := range z.File {
+		decoded := f.decodedNames()
+		f.Name = a.addString(f.Name)
+		f.Comment = a.addString(f.Comment)
+		if decoded {
+			f.RawName = a.addString(f.RawName)
+			f.RawComment = a.addString(f.RawComment)
+		} else {
+			f.RawName, f.RawComment = f.Name, f.Comment
+		}
+		if !f.IsEncrypted() {
+			f.Extra = nil
+		}
+	}
+}
+
+// decodedNames reports whether the name or comment of f differs from the
+// one stored in the central directory, so that both need keeping.
+func (f *File) decodedNames() bool {
+	return f.Name != f.RawName || f.Comment != f.RawComment
+}
+
+// A nameArena lays out strings one after the other in a block. The bytes
+// written to a strings.Builder never change, so every string can share the
+// string of the block. A string starting with the one added just before
+// it, as the name of the first entry in a directory does with the name of
+// the directory's own entry, only adds the rest and shares the bytes of
+// the one before.
+type nameArena struct {
+	b    strings.Builder
+	last string // the string added last, which ends the block
+}
+
+// reset starts a new block with room for n bytes.
+func (a *nameArena) reset(n int) {
+	a.b = strings.Builder{}
+	a.b.Grow(n)
+	a.last = ""
+}
+
+// free returns the number of bytes left in the block.
+func (a *nameArena) free() int { return a.b.Cap() - a.b.Len() }
+
+// size returns the number of bytes adding b takes.
+func (a *nameArena) size(b []byte) int {
+	if n := len(a.last); len(b) >= n && string(b[:n]) == a.last {
+		return len(b) - n
+	}
+	return len(b)
+}
+
+// add adds b, which must fit in the block, and returns it as a string.
+func (a *nameArena) add(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	start := a.b.Len()
+	if n := len(a.last); len(b) >= n && string(b[:n]) == a.last {
+		start -= n
+		b = b[n:]
+	}
+	a.b.Write(b)
+	a.last = a.b.String()[start:]
+	return a.last
+}
+
+// sizeString is like size for a string, and accounts for adding it.
+func (a *nameArena) sizeString(s string) int {
+	n := len(s)
+	if strings.HasPrefix(s, a.last) {
+		n -= len(a.last)
+	}
+	if s != "" {
+		a.last = s
+	}
+	return n
+}
+
+// addString is like add for a string.
+func (a *nameArena) addString(s string) string {
+	if s == "" {
+		return ""
+	}
+	start := a.b.Len()
+	if strings.HasPrefix(s, a.last) {
+		start -= len(a.last)
+		s = s[len(a.last):]
+	}
+	a.b.WriteString(s)
+	a.last = a.b.String()[start:]
+	return a.last
+}
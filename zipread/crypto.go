@@ -0,0 +1,248 @@
+package zipread
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	// ErrEncrypted is returned when opening an encrypted entry without a
+	// password, and by the methods that read raw entry data, like
+	// ReaderAt, for encrypted entries.
+	ErrEncrypted = errors.New("zip: entry is encrypted")
+
+	// ErrPassword is returned when the password does not decrypt an entry.
+	ErrPassword = errors.New("zip: invalid password")
+)
+
+// An EncryptionError reports that an entry, or the central directory, is
+// encrypted with a scheme the package cannot decrypt. It matches
+// ErrEncrypted with errors.Is.
+type EncryptionError struct {
+	Name   string // name of the entry, empty for the central directory
+	Scheme string // see File.EncryptionScheme
+}
+
+func (e *EncryptionError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("zip: central directory is encrypted with unsupported scheme %s", e.Scheme)
+	}
+	return fmt.Sprintf("zip: %q is encrypted with unsupported scheme %s", e.Name, e.Scheme)
+}
+
+func (e *EncryptionError) Unwrap() error { return ErrEncrypted }
+
+const (
+	flagEncrypted  = 0x1
+	flagDescriptor = 0x8
+	flagStrong     = 0x40
+
+	methodWinZipAES = 99
+
+	zipCryptoHeaderLen = 12
+
+	// schemeZipCrypto is the only scheme the package decrypts.
+	schemeZipCrypto = "ZipCrypto"
+)
+
+// IsEncrypted reports whether the contents of f are encrypted.
+func (f *File) IsEncrypted() bool {
+	return f.Flags&flagEncrypted != 0
+}
+
+// EncryptionScheme describes how the contents of f are encrypted: empty
+// if they are not, "ZipCrypto" for the traditional PKWARE scheme, which
+// Options.Password decrypts, and otherwise a description of the scheme,
+// like "WinZip AES-256" or "PKWARE strong encryption 3DES-168", which
+// File.Open reports in an *EncryptionError.
+func (f *File) EncryptionScheme() string {
+	switch {
+	case !f.IsEncrypted():
+		return ""
+	case f.Method == methodWinZipAES:
+		scheme := "WinZip AES"
+		if b := findExtra(f.Extra, winZipAESExtraID); len(b) >= 7 && b[4] >= 1 && b[4] <= 3 {
+			scheme = fmt.Sprintf("%s-%d", scheme, 64+64*int(b[4]))
+		}
+		return scheme
+	case f.Flags&flagStrong != 0:
+		return strongEncryptionScheme(findExtra(f.Extra, strongEncExtraID))
+	}
+	return schemeZipCrypto
+}
+
+// encryptionError returns an *EncryptionError if f is encrypted with a
+// scheme other than ZipCrypto.
+func (f *File) encryptionError() error {
+	if scheme := f.EncryptionScheme(); scheme != "" && scheme != schemeZipCrypto {
+		return &EncryptionError{Name: f.Name, Scheme: scheme}
+	}
+	return nil
+}
+
+// strongEncryptionScheme describes the scheme of a strong encryption
+// header extra field, which starts with a format and an algorithm ID.
+func strongEncryptionScheme(field []byte) string {
+	const scheme = "PKWARE strong encryption"
+	if len(field) < 4 {
+		return scheme
+	}
+	algorithms := map[uint16]string{
+		0x6601: "DES",
+		0x6602: "RC2",
+		0x6603: "3DES-168",
+		0x6609: "3DES-112",
+		0x660e: "AES-128",
+		0x660f: "AES-192",
+		0x6610: "AES-256",
+		0x6702: "RC2",
+		0x6720: "Blowfish",
+		0x6721: "Twofish",
+		0x6801: "RC4",
+	}
+	b := readBuf(field[2:])
+	alg, ok := algorithms[b.uint16()]
+	if !ok {
+		return scheme
+	}
+	return scheme + " " + alg
+}
+
+// findExtra returns the data of the first extra field with the given ID,
+// or nil.
+func findExtra(extra []byte, id uint16) []byte {
+	for b := readBuf(extra); len(b) >= 4; {
+		tag := b.uint16()
+		size := int(b.uint16())
+		if len(b) < size {
+			break
+		}
+		data := b.sub(size)
+		if tag == id {
+			return data
+		}
+	}
+	return nil
+}
+
+// checkDirectoryEncryption returns an *EncryptionError if the central
+// directory read from buf is encrypted, which is announced by an archive
+// extra data record in its place. The record carries the strong
+// encryption header of the directory.
+func checkDirectoryEncryption(buf *bufio.Reader) error {
+	head, err := buf.Peek(8)
+	if err != nil {
+		return nil
+	}
+	b := readBuf(head)
+	if b.uint32() != archiveExtraSignature {
+		return nil
+	}
+	var extra []byte
+	if n := b.uint32(); n <= 1024 {
+		if record, err := buf.Peek(8 + int(n)); err == nil {
+			extra = record[8:]
+		}
+	}
+	return &EncryptionError{Scheme: strongEncryptionScheme(findExtra(extra, strongEncExtraID))}
+}
+
+// decrypt returns a reader decrypting the entry contents read from body,
+// positioned after the encryption header, or body itself if f is not
+// encrypted.
+func (f *File) decrypt(ctx context.Context, body io.Reader) (io.Reader, error) {
+	if !f.IsEncrypted() {
+		return body, nil
+	}
+	password, err := f.password(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, ErrEncrypted
+	}
+	return newZipCryptoReader(body, []byte(password), f.zipCryptoCheck())
+}
+
+// password returns the password to decrypt f with.
+func (f *File) password(ctx context.Context) (string, error) {
+	if provider := f.zip.opts.PasswordProvider; provider != nil {
+		return provider(ctx, &f.FileHeader)
+	}
+	return f.zip.opts.Password, nil
+}
+
+// zipCryptoCheck returns the value the last byte of the decrypted
+// encryption header must have: the high byte of the checksum, or of the
+// modification time for entries whose checksum follows the data.
+func (f *File) zipCryptoCheck() byte {
+	if f.Flags&flagDescriptor != 0 {
+		return byte(f.ModifiedTime >> 8)
+	}
+	return byte(f.CRC32 >> 24)
+}
+
+// zipCryptoKeys is the state of the traditional PKWARE stream cipher.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, c := range password {
+		k.update(c)
+	}
+	return k
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+func (k *zipCryptoKeys) update(c byte) {
+	k[0] = crc32Update(k[0], c)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decryptByte(c byte) byte {
+	t := k[2] | 2
+	c ^= byte(t * (t ^ 1) >> 8)
+	k.update(c)
+	return c
+}
+
+// zipCryptoReader decrypts a ZipCrypto stream.
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+// newZipCryptoReader reads and decrypts the encryption header from r,
+// checking its last byte against check, and returns a reader decrypting
+// the rest of r. The check only has a one in 256 chance of letting a wrong
+// password through; the checksum of the contents catches the rest.
+func newZipCryptoReader(r io.Reader, password []byte, check byte) (*zipCryptoReader, error) {
+	z := &zipCryptoReader{r: r, keys: newZipCryptoKeys(password)}
+	var header [zipCryptoHeaderLen]byte
+	if _, err := io.ReadFull(z, header[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if header[zipCryptoHeaderLen-1] != check {
+		return nil, ErrPassword
+	}
+	return z, nil
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	for i := range p[:n] {
+		p[i] = z.keys.decryptByte(p[i])
+	}
+	return n, err
+}
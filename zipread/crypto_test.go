@@ -0,0 +1,242 @@
+package zipread
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestZipCrypto(t *testing.T) {
+	// testdata/crypto.zip was written by Info-ZIP's zip with password
+	// "secret", which sets the data descriptor flag on encrypted entries.
+	want := map[string]string{
+		"stored.txt":   "stored and encrypted\n",
+		"deflated.txt": strings.Repeat("deflated and encrypted\n", 100),
+		"-":            "streamed and encrypted\n",
+	}
+	open := func(password string) *Reader {
+		z, err := OpenWithOptions(SourceFromFile("testdata/crypto.zip"), &Options{Password: password})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return z
+	}
+
+	z := open("secret")
+	for _, f := range z.File {
+		if !f.IsEncrypted() {
+			t.Errorf("%s: not reported as encrypted", f.Name)
+		}
+		got, err := readAllFile(f)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if string(got) != want[f.Name] {
+			t.Errorf("%s: got %q, want %q", f.Name, got, want[f.Name])
+		}
+	}
+
+	rc, err := z.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.(io.Seeker).Seek(7, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(rc)
+	if err != nil || string(rest) != want["stored.txt"][7:] {
+		t.Errorf("after Seek: got %q, %v", rest, err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := z.File[0].ReaderAt(context.Background()); err != ErrEncrypted {
+		t.Errorf("ReaderAt: got %v, want %v", err, ErrEncrypted)
+	}
+	if _, err := z.File[1].OpenAsGzip(); err != ErrEncrypted {
+		t.Errorf("OpenAsGzip: got %v, want %v", err, ErrEncrypted)
+	}
+	if _, err := open("").File[0].Open(); err != ErrEncrypted {
+		t.Errorf("without password: got %v, want %v", err, ErrEncrypted)
+	}
+	if _, err := open("wrong").File[1].Open(); err != ErrPassword {
+		t.Errorf("wrong password: got %v, want %v", err, ErrPassword)
+	}
+
+	rcs, err := z.OpenMany(context.Background(), []string{"deflated.txt", "stored.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"deflated.txt", "stored.txt"} {
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, rcs[i]); err != nil {
+			t.Fatalf("OpenMany %s: %v", name, err)
+		}
+		if buf.String() != want[name] {
+			t.Errorf("OpenMany %s: got %q", name, buf.String())
+		}
+		if err := rcs[i].Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEncryptionSchemes(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	w.RegisterCompressor(methodWinZipAES, func(w io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+	for _, fh := range []*zip.FileHeader{
+		{Name: "aes", Method: methodWinZipAES, Flags: flagEncrypted,
+			Extra: []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E', 3, 8, 0}},
+		{Name: "strong", Method: Store, Flags: flagEncrypted | flagStrong,
+			Extra: []byte{0x17, 0x00, 8, 0, 2, 0, 0x03, 0x66, 168, 0, 1, 0}},
+		{Name: "zipcrypto", Method: Store, Flags: flagEncrypted},
+		{Name: "plain", Method: Store},
+	} {
+		fw, err := w.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z := openTestZip(t, buf.Bytes(), &Options{Password: "secret"})
+
+	want := []string{"WinZip AES-256", "PKWARE strong encryption 3DES-168", "ZipCrypto", ""}
+	for i, f := range z.File {
+		if got := f.EncryptionScheme(); got != want[i] {
+			t.Errorf("%s: got scheme %q, want %q", f.Name, got, want[i])
+		}
+	}
+	for _, f := range z.File[:2] {
+		_, err := f.Open()
+		var ee *EncryptionError
+		if !errors.As(err, &ee) || ee.Name != f.Name || !errors.Is(err, ErrEncrypted) {
+			t.Errorf("%s: got %v, want *EncryptionError", f.Name, err)
+		}
+	}
+}
+
+func TestEncryptedDirectory(t *testing.T) {
+	// An archive extra data record holding the strong encryption header
+	// of the directory, in place of the directory.
+	record := []byte{
+		0x50, 0x4b, 0x06, 0x08, 12, 0, 0, 0,
+		0x17, 0x00, 8, 0, 2, 0, 0x10, 0x66, 0, 1, 1, 0,
+	}
+	end := []byte{0x50, 0x4b, 0x05, 0x06, 0, 0, 0, 0, 1, 0, 1, 0, byte(len(record)), 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	data := append(record, end...)
+
+	_, err := Open(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+	var ee *EncryptionError
+	if !errors.As(err, &ee) || ee.Name != "" || ee.Scheme != "PKWARE strong encryption AES-256" {
+		t.Fatalf("got %v, want *EncryptionError for the central directory", err)
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestPasswordProvider(t *testing.T) {
+	errLocked := errors.New("secret store locked")
+	var asked []string
+	provider := func(ctx context.Context, fh *FileHeader) (string, error) {
+		asked = append(asked, fh.Name)
+		switch fh.Name {
+		case "stored.txt":
+			return "secret", nil
+		case "-":
+			return "", errLocked
+		}
+		return "", nil
+	}
+	z, err := OpenWithOptions(SourceFromFile("testdata/crypto.zip"), &Options{
+		Password:         "ignored",
+		PasswordProvider: provider,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := readAllFile(z.File[0]); err != nil || string(got) != "stored and encrypted\n" {
+		t.Errorf("stored.txt: got %q, %v", got, err)
+	}
+	if _, err := z.File[1].Open(); err != ErrEncrypted {
+		t.Errorf("deflated.txt: got %v, want %v", err, ErrEncrypted)
+	}
+	if _, err := z.File[2].Open(); err != errLocked {
+		t.Errorf("-: got %v, want %v", err, errLocked)
+	}
+	if want := "[stored.txt deflated.txt -]"; fmt.Sprint(asked) != want {
+		t.Errorf("provider asked for %v, want %v", asked, want)
+	}
+}
+
+func TestCheckSupported(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	w.RegisterCompressor(methodWinZipAES, func(w io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+	w.RegisterCompressor(200, func(w io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+	for _, fh := range []*zip.FileHeader{
+		{Name: "plain", Method: Deflate},
+		{Name: "zipcrypto", Method: Store, Flags: flagEncrypted},
+		{Name: "aes", Method: methodWinZipAES, Flags: flagEncrypted},
+		{Name: "unknown method", Method: 200},
+		{Name: "patch", Method: Store, Flags: flagPatched},
+		{Name: "masked", Method: Store, Flags: flagMaskedHeader},
+	} {
+		if _, err := w.CreateHeader(fh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	source := SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	for _, opts := range []*Options{nil, {Password: "secret"}} {
+		z, err := OpenWithOptions(source, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range z.File {
+			err := f.CheckSupported()
+			var want error
+			switch f.Name {
+			case "zipcrypto":
+				if opts == nil {
+					want = ErrEncrypted
+				}
+			case "aes":
+				want = ErrEncrypted
+			case "unknown method", "patch", "masked":
+				want = ErrAlgorithm
+			}
+			if !errors.Is(err, want) {
+				t.Errorf("%s with %+v: got %v, want %v", f.Name, opts, err, want)
+			}
+			if want != nil && f.Name != "zipcrypto" {
+				if _, err := f.Open(); !errors.Is(err, want) {
+					t.Errorf("%s: Open: got %v, want %v", f.Name, err, want)
+				}
+			}
+		}
+	}
+}
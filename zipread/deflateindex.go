@@ -0,0 +1,245 @@
+package zipread
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// deflateIndexMagic and deflateIndexVersion identify a serialized
+// DeflateIndex.
+const (
+	deflateIndexMagic   = "zdix"
+	deflateIndexVersion = 1
+)
+
+// A DeflateIndex records checkpoints in the compressed stream of a deflate
+// entry, allowing random access into entries that were not written with a
+// SOZip index. Each checkpoint sits on a deflate block boundary and keeps
+// the 32 KiB of output preceding it, which is all a decompressor needs to
+// resume there.
+//
+// An index is built once with File.BuildDeflateIndex, can be stored with
+// MarshalBinary, and is attached to a File with SetDeflateIndex.
+type DeflateIndex struct {
+	CRC32            uint32
+	UncompressedSize uint64
+	CompressedSize   uint64
+	Checkpoints      []DeflateCheckpoint
+}
+
+// A DeflateCheckpoint is a position where decompression can resume.
+type DeflateCheckpoint struct {
+	Uncompressed int64  // offset in the uncompressed contents
+	Bit          int64  // offset in the compressed body, in bits
+	Window       []byte // up to 32 KiB of output preceding Uncompressed
+}
+
+func (idx *DeflateIndex) restartPoint(off int64) restartPoint {
+	i := sort.Search(len(idx.Checkpoints), func(i int) bool {
+		return idx.Checkpoints[i].Uncompressed > off
+	})
+	if i == 0 {
+		return restartPoint{}
+	}
+	cp := &idx.Checkpoints[i-1]
+	return restartPoint{
+		uncompressed: cp.Uncompressed,
+		compressed:   cp.Bit / 8,
+		bit:          uint(cp.Bit % 8),
+		dict:         cp.Window,
+	}
+}
+
+// BuildDeflateIndex decompresses a deflate entry once and records a
+// checkpoint at the first block boundary after every span bytes of
+// output. Smaller spans make random access cheaper at the cost of 32 KiB
+// of window per checkpoint. The entry's checksum is verified.
+func (f *File) BuildDeflateIndex(ctx context.Context, span int64) (_ *DeflateIndex, err error) {
+	if f.Method != Deflate {
+		return nil, ErrAlgorithm
+	}
+	if f.IsEncrypted() {
+		return nil, ErrEncrypted
+	}
+	if span <= 0 {
+		return nil, errs.Errorf("invalid checkpoint span %d", span)
+	}
+
+	rr, data, err := f.openValidated(false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errs.Combine(err, rr.Close()) }()
+
+	idx := &DeflateIndex{
+		CRC32:            f.CRC32,
+		UncompressedSize: f.UncompressedSize64,
+		CompressedSize:   f.CompressedSize64,
+	}
+	fl := newInflater(bufio.NewReader(io.LimitReader(data, int64(f.CompressedSize64))), 0, nil)
+	var last int64
+	fl.onBlock = func() {
+		if fl.out-last < span || fl.out >= int64(f.UncompressedSize64) {
+			return
+		}
+		idx.Checkpoints = append(idx.Checkpoints, DeflateCheckpoint{
+			Uncompressed: fl.out,
+			Bit:          fl.bitPos(),
+			Window:       fl.windowBytes(),
+		})
+		last = fl.out
+	}
+
+	hash := crc32.NewIEEE()
+	buf := make([]byte, 32*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := fl.Read(buf)
+		_, _ = hash.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fl.out != int64(f.UncompressedSize64) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if f.CRC32 != 0 && hash.Sum32() != f.CRC32 {
+		return nil, &ChecksumError{
+			Name:      f.Name,
+			Expected:  f.CRC32,
+			Computed:  hash.Sum32(),
+			BytesRead: uint64(fl.out),
+		}
+	}
+	return idx, nil
+}
+
+// SetDeflateIndex attaches an index built by BuildDeflateIndex, so that
+// OpenRange and Seek resume at its checkpoints. It fails if the index
+// does not match the entry. A nil index detaches the current one.
+func (f *File) SetDeflateIndex(idx *DeflateIndex) error {
+	if idx != nil {
+		if f.Method != Deflate {
+			return ErrAlgorithm
+		}
+		if f.IsEncrypted() {
+			return ErrEncrypted
+		}
+		if idx.CRC32 != f.CRC32 ||
+			idx.UncompressedSize != f.UncompressedSize64 ||
+			idx.CompressedSize != f.CompressedSize64 {
+			return errs.Errorf("deflate index does not match %q", f.Name)
+		}
+	}
+	f.mu.Lock()
+	f.deflateIndex = idx
+	f.mu.Unlock()
+	return nil
+}
+
+// MarshalBinary encodes the index. Windows are stored compressed.
+func (idx *DeflateIndex) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(deflateIndexMagic)
+	b := make([]byte, 32)
+	binary.LittleEndian.PutUint32(b[0:], deflateIndexVersion)
+	binary.LittleEndian.PutUint32(b[4:], idx.CRC32)
+	binary.LittleEndian.PutUint64(b[8:], idx.UncompressedSize)
+	binary.LittleEndian.PutUint64(b[16:], idx.CompressedSize)
+	binary.LittleEndian.PutUint32(b[24:], uint32(len(idx.Checkpoints)))
+	buf.Write(b[:28])
+
+	var window bytes.Buffer
+	w, err := flate.NewWriter(&window, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range idx.Checkpoints {
+		window.Reset()
+		w.Reset(&window)
+		if _, err := w.Write(cp.Window); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint64(b[0:], uint64(cp.Uncompressed))
+		binary.LittleEndian.PutUint64(b[8:], uint64(cp.Bit))
+		binary.LittleEndian.PutUint32(b[16:], uint32(window.Len()))
+		buf.Write(b[:20])
+		buf.Write(window.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes an index encoded by MarshalBinary.
+func (idx *DeflateIndex) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var hdr [32]byte
+	if _, err := io.ReadFull(r, hdr[:32]); err != nil || string(hdr[:4]) != deflateIndexMagic {
+		return ErrFormat
+	}
+	b := readBuf(hdr[4:])
+	if b.uint32() != deflateIndexVersion {
+		return ErrFormat
+	}
+	next := DeflateIndex{
+		CRC32:            b.uint32(),
+		UncompressedSize: b.uint64(),
+		CompressedSize:   b.uint64(),
+	}
+	count := b.uint32()
+	if uint64(count) > uint64(r.Len())/20 {
+		return ErrFormat
+	}
+
+	next.Checkpoints = make([]DeflateCheckpoint, count)
+	var prev int64
+	for i := range next.Checkpoints {
+		var rec [20]byte
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			return ErrFormat
+		}
+		b := readBuf(rec[:])
+		cp := DeflateCheckpoint{
+			Uncompressed: int64(b.uint64()),
+			Bit:          int64(b.uint64()),
+		}
+		n := int64(b.uint32())
+		if n > int64(r.Len()) || cp.Uncompressed <= prev ||
+			uint64(cp.Uncompressed) >= next.UncompressedSize ||
+			cp.Bit < 0 || uint64(cp.Bit/8) >= next.CompressedSize {
+			return ErrFormat
+		}
+		compressed := make([]byte, n)
+		if _, err := io.ReadFull(r, compressed); err != nil {
+			return ErrFormat
+		}
+		fr := flate.NewReader(bytes.NewReader(compressed))
+		window, err := io.ReadAll(io.LimitReader(fr, inflateWindowSize+1))
+		if err != nil || len(window) > inflateWindowSize {
+			return ErrFormat
+		}
+		cp.Window = window
+		next.Checkpoints[i] = cp
+		prev = cp.Uncompressed
+	}
+	if r.Len() != 0 {
+		return ErrFormat
+	}
+	*idx = next
+	return nil
+}
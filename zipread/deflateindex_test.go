@@ -0,0 +1,152 @@
+package zipread
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"testing"
+)
+
+type flushingWriter struct {
+	w       *flate.Writer
+	every   int
+	pending int
+}
+
+func (w *flushingWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > w.every-w.pending {
+			chunk = chunk[:w.every-w.pending]
+		}
+		m, err := w.w.Write(chunk)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+		if w.pending += m; w.pending == w.every {
+			if err := w.w.Flush(); err != nil {
+				return n, err
+			}
+			w.pending = 0
+		}
+	}
+	return n, nil
+}
+
+func (w *flushingWriter) Close() error { return w.w.Close() }
+
+func TestDeflateIndex(t *testing.T) {
+	ctx := context.Background()
+	content := deflateTestData(1 << 20)
+
+	// Flush regularly so the stream has block boundaries to index no
+	// matter how large the compressor makes its blocks.
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.RegisterCompressor(Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		fw, err := flate.NewWriter(out, flate.DefaultCompression)
+		return &flushingWriter{w: fw, every: 50000}, err
+	})
+	for _, name := range []string{"deflated", "stored"} {
+		method := Deflate
+		if name == "stored" {
+			method = Store
+		}
+		fw, err := w.CreateHeader(&FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z := openTestZip(t, buf.Bytes(), nil)
+	f := z.File[0]
+
+	if _, err := z.File[1].BuildDeflateIndex(ctx, 1<<16); err != ErrAlgorithm {
+		t.Fatalf("stored entry: err=%v, want %v", err, ErrAlgorithm)
+	}
+
+	built, err := f.BuildDeflateIndex(ctx, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(built.Checkpoints) < 4 {
+		t.Fatalf("got %d checkpoints, want at least 4", len(built.Checkpoints))
+	}
+
+	raw, err := built.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idx DeflateIndex
+	if err := idx.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Checkpoints) != len(built.Checkpoints) {
+		t.Fatalf("round trip: got %d checkpoints, want %d", len(idx.Checkpoints), len(built.Checkpoints))
+	}
+	for i, cp := range idx.Checkpoints {
+		want := built.Checkpoints[i]
+		if cp.Uncompressed != want.Uncompressed || cp.Bit != want.Bit || !bytes.Equal(cp.Window, want.Window) {
+			t.Fatalf("round trip: checkpoint %d differs", i)
+		}
+	}
+	if err := idx.UnmarshalBinary(raw[:len(raw)-1]); err != ErrFormat {
+		t.Fatalf("truncated index: err=%v, want %v", err, ErrFormat)
+	}
+
+	if err := f.SetDeflateIndex(&idx); err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []int64{0, 1, idx.Checkpoints[0].Uncompressed, idx.Checkpoints[2].Uncompressed + 12345, int64(len(content)) - 10} {
+		rc, err := f.OpenRange(ctx, off, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("offset %d: %v", off, err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		end := off + 5000
+		if end > int64(len(content)) {
+			end = int64(len(content))
+		}
+		if !bytes.Equal(got, content[off:end]) {
+			t.Fatalf("offset %d: content mismatch", off)
+		}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := rc.(io.ReadSeeker)
+	if _, err := s.Seek(-300000, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content[len(content)-300000:]) {
+		t.Fatal("content mismatch after seek")
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx.CRC32++
+	if err := f.SetDeflateIndex(&idx); err == nil {
+		t.Fatal("expected mismatched index to be rejected")
+	}
+}
@@ -0,0 +1,142 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// opensDirect reports whether Open can hand out the source range of f as
+// is: it is stored, so there is nothing to decompress, and with CRCOff
+// there is nothing to verify either.
+func (f *File) opensDirect() bool {
+	opts := &f.zip.opts
+	return f.Method == Store && opts.CRCPolicy == CRCOff && !f.IsEncrypted() &&
+		f.CompressedSize64 == f.UncompressedSize64 &&
+		opts.ReadAhead <= 0 && f.parallelChunk() == 0
+}
+
+// openDirect returns a reader serving the contents of f straight from the
+// source range, without buffering, hashing or decompressing them. If the
+// offset of the content is not known yet, the local header is validated
+// on the way, in the same request.
+func (f *File) openDirect() (io.ReadCloser, error) {
+	ctx := context.TODO()
+	f.mu.Lock()
+	dataOffset := f.dataOffset
+	f.mu.Unlock()
+
+	var (
+		rr  io.ReadCloser
+		err error
+	)
+	if dataOffset != 0 {
+		rr, err = f.zips.Range(ctx, dataOffset, int64(f.UncompressedSize64))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rr, err = f.zips.Range(ctx, f.headerOffset, f.headerRangeLength())
+		if err != nil {
+			return nil, err
+		}
+		if err := f.validateFileHeader(rr); err != nil {
+			return nil, errs.Combine(err, rr.Close())
+		}
+	}
+	return &directReader{f: f, rc: rr}, nil
+}
+
+// directReader reads a stored entry from its source range. The range may
+// extend past the entry, so reads are limited to its size.
+type directReader struct {
+	f      *File
+	rc     io.ReadCloser
+	pos    int64 // position in the contents
+	err    error // sticky error
+	closed bool
+}
+
+func (r *directReader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	remaining := int64(r.f.UncompressedSize64) - r.pos
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err = r.rc.Read(p)
+	r.pos += int64(n)
+	if err == io.EOF && r.pos < int64(r.f.UncompressedSize64) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker by requesting the new position from the
+// source, like it does for stored entries read through a checksumReader.
+func (r *directReader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, errReadAfterClose
+	}
+	size := int64(r.f.UncompressedSize64)
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = size + offset
+	default:
+		return 0, errs.Errorf("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errs.Errorf("negative position")
+	}
+	if abs == r.pos {
+		return abs, nil
+	}
+
+	var rc io.ReadCloser = io.NopCloser(bytes.NewReader(nil))
+	if abs < size {
+		dataOffset, err := r.f.resolveDataOffset(context.TODO())
+		if err == nil {
+			rc, err = r.f.zips.Range(context.TODO(), dataOffset+abs, size-abs)
+		}
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+	}
+	err := r.rc.Close()
+	r.rc, r.pos, r.err = rc, abs, nil
+	if err != nil {
+		r.err = err
+		return 0, err
+	}
+	return abs, nil
+}
+
+func (r *directReader) Stat() (fs.FileInfo, error) {
+	return r.f.stat(path.Base(r.f.zip.fsName(r.f.Name))), nil
+}
+
+func (r *directReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.err = errReadAfterClose
+	return r.rc.Close()
+}
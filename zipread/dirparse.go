@@ -0,0 +1,108 @@
+package zipread
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// maxDirectoryRecordLen is the length of the largest possible central
+// directory record.
+const maxDirectoryRecordLen = directoryHeaderLen + 3*math.MaxUint16
+
+// parseDirectory reads up to n bytes of central directory from r, splits
+// them into records and decodes the records on workers goroutines. It
+// returns a function yielding the records in order, like calling
+// readDirectoryHeader in sequence would, ending with the error for the
+// record that ends the directory.
+//
+// The limits of z.opts are applied on the way, so that a directory
+// exceeding them is not decoded in full: splitting stops at the first
+// record exceeding a limit its fixed part tells, and decoding at the first
+// exceeding one on the decoded name. The caller applies the limits again,
+// in order, and so fails on the same record as parsing in sequence does.
+func (z *Reader) parseDirectory(r io.Reader, n int64, workers int, newParser func() *directoryParser) (func() (*File, error), error) {
+	data, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+
+	// Splitting only needs the signature, the sizes and the three
+	// variable lengths.
+	var (
+		records           [][]byte
+		totalUncompressed int64
+	)
+	rest := data
+	for len(rest) >= directoryHeaderLen && binary.LittleEndian.Uint32(rest) == directoryHeaderSignature {
+		b := readBuf(rest[24:34])
+		size := b.uint32()
+		nameLen := int(b.uint16())
+		l := directoryHeaderLen + nameLen + int(b.uint16()) + int(b.uint16())
+		if l > len(rest) {
+			break
+		}
+		records = append(records, rest[:l])
+		rest = rest[l:]
+
+		// Sizes that do not fit are in the Zip64 extra field; leaving
+		// them out keeps the total a lower bound.
+		if size != ^uint32(0) {
+			totalUncompressed += int64(size)
+		}
+		if checkLimit("entries", int64(len(records)), int64(z.opts.MaxEntries)) != nil ||
+			checkLimit("name length", int64(nameLen), int64(z.opts.MaxNameLength)) != nil ||
+			checkLimit("total uncompressed size", totalUncompressed, z.opts.MaxTotalUncompressed) != nil {
+			break
+		}
+	}
+
+	files := make([]*File, len(records))
+	failed := make([]error, len(records))
+	stop := int64(len(records)) // the first record exceeding a name limit
+	per := (len(records) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(records); start += per {
+		end := start + per
+		if end > len(records) {
+			end = len(records)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			p := newParser()
+			for i := start; i < end && int64(i) < atomic.LoadInt64(&stop); i++ {
+				files[i] = p.newFile()
+				failed[i] = p.readDirectoryHeader(files[i], bytes.NewReader(records[i]))
+				if failed[i] == nil && z.checkNameLimits(files[i]) != nil {
+					for s := atomic.LoadInt64(&stop); int64(i) < s; s = atomic.LoadInt64(&stop) {
+						if atomic.CompareAndSwapInt64(&stop, s, int64(i)) {
+							break
+						}
+					}
+				}
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	if stop < int64(len(files)) {
+		// The caller fails on the record at stop at the latest.
+		files, failed = files[:stop+1], failed[:stop+1]
+	}
+
+	i := 0
+	return func() (*File, error) {
+		if i == len(files) {
+			// Whatever follows the last record fails to decode just as
+			// it would have in sequence.
+			p := newParser()
+			f := p.newFile()
+			return f, p.readDirectoryHeader(f, bytes.NewReader(rest))
+		}
+		i++
+		return files[i-1], failed[i-1]
+	}, nil
+}
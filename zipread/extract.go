@@ -0,0 +1,351 @@
+package zipread
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// ErrWindowsName is returned by Extract for entry names that cannot be
+// created on Windows when WindowsNamesReject is selected.
+var ErrWindowsName = errors.New("zip: name not valid on Windows")
+
+// WindowsNamePolicy selects how Extract treats entry names that cannot be
+// created on Windows, see IsWindowsSafeName.
+type WindowsNamePolicy int
+
+const (
+	// WindowsNamesKeep uses names as they are.
+	WindowsNamesKeep WindowsNamePolicy = iota
+	// WindowsNamesRemap rewrites names with SanitizeWindowsName, and adds
+	// a "~N" suffix to the stem of any remapped path that collides
+	// (case-insensitively) with one already extracted.
+	WindowsNamesRemap
+	// WindowsNamesReject fails the extraction with ErrWindowsName.
+	WindowsNamesReject
+)
+
+// ErrSymlink is returned by Extract for symbolic link entries when
+// SymlinksReject is selected.
+var ErrSymlink = errors.New("zip: symbolic link")
+
+// SymlinkPolicy selects how Extract treats symbolic link entries.
+type SymlinkPolicy int
+
+const (
+	// SymlinksAsFiles writes links as regular files holding their target.
+	SymlinksAsFiles SymlinkPolicy = iota
+	// SymlinksSkip leaves links out.
+	SymlinksSkip
+	// SymlinksCreate creates links whose target is relative and stays
+	// below the destination directory, and fails with ErrInsecurePath for
+	// any other. Links are created after all other entries, so that no
+	// entry is written through one.
+	SymlinksCreate
+	// SymlinksReject fails the extraction with ErrSymlink.
+	SymlinksReject
+)
+
+// ExtractOptions configures Reader.Extract.
+type ExtractOptions struct {
+	// WindowsNames selects how names that cannot be created on Windows
+	// are handled. It applies regardless of the platform extracted on, so
+	// the result is portable.
+	WindowsNames WindowsNamePolicy
+
+	// LongPaths prefixes destination paths with \\?\ when extracting on
+	// Windows, lifting the 260 character MAX_PATH limit.
+	LongPaths bool
+
+	// Symlinks selects how symbolic links are handled. The default writes
+	// them as regular files, as if they were not links.
+	Symlinks SymlinkPolicy
+}
+
+// Extract writes the archive's directories and files below dir, which is
+// created if needed. Entry names are normalized the same way as for the
+// fs.FS view, so entries can never escape dir.
+func (z *Reader) Extract(ctx context.Context, dir string, opts *ExtractOptions) error {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
+	var mapper *windowsNameMapper
+	if opts.WindowsNames == WindowsNamesRemap {
+		mapper = newWindowsNameMapper()
+	}
+	type link struct {
+		f            *File
+		name, target string
+	}
+	var links []link
+
+	for _, f := range z.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		isDir := strings.HasSuffix(f.Name, "/")
+		name := toValidName(f.Name)
+		if name == "." {
+			continue
+		}
+		if !fs.ValidPath(name) {
+			return &fs.PathError{Op: "extract", Path: f.Name, Err: fs.ErrInvalid}
+		}
+		switch {
+		case mapper != nil:
+			name = mapper.mapName(name, isDir)
+		case opts.WindowsNames == WindowsNamesReject && !IsWindowsSafeName(name):
+			return &fs.PathError{Op: "extract", Path: f.Name, Err: ErrWindowsName}
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if opts.LongPaths && runtime.GOOS == "windows" {
+			target = longPathName(target)
+		}
+
+		if isDir {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.Mode()&fs.ModeSymlink != 0 {
+			switch opts.Symlinks {
+			case SymlinksSkip:
+				continue
+			case SymlinksReject:
+				return &fs.PathError{Op: "extract", Path: f.Name, Err: ErrSymlink}
+			case SymlinksCreate:
+				links = append(links, link{f: f, name: name, target: target})
+				continue
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractFile(f, target); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range links {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := extractSymlink(l.f, dir, l.name, l.target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractSymlink creates the link f, named name below dir, at target,
+// provided neither its parent directories nor its destination lead out of
+// dir.
+func extractSymlink(f *File, dir, name, target string) error {
+	dest, err := f.readLink()
+	if err != nil {
+		return err
+	}
+	dest, ok := containedLink(name, dest)
+	if !ok {
+		return &fs.PathError{Op: "extract", Path: f.Name, Err: ErrInsecurePath}
+	}
+	// A link created earlier may have replaced a parent directory.
+	parent := dir
+	for _, elem := range strings.Split(path.Dir(name), "/") {
+		if elem == "." {
+			break
+		}
+		parent = filepath.Join(parent, elem)
+		if fi, err := os.Lstat(parent); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			return &fs.PathError{Op: "extract", Path: f.Name, Err: ErrInsecurePath}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(filepath.FromSlash(dest), target)
+}
+
+// containedLink reports whether dest, the destination of the link name, is
+// relative and stays within the tree name is part of, and returns it
+// cleaned. Since the cleaned destination only ever climbs up through the
+// real directories holding the link, and any link it descends through is
+// contained itself, resolving it cannot leave the tree.
+func containedLink(name, dest string) (string, bool) {
+	if dest == "" || strings.ContainsAny(dest, `\:`) || path.IsAbs(dest) {
+		return "", false
+	}
+	dest = path.Clean(dest)
+	resolved := path.Join(path.Dir(name), dest)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+	return dest, true
+}
+
+func extractFile(f *File, target string) (err error) {
+	perm := f.Mode().Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+
+	fh, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fh, rc); err != nil {
+		return errs.Combine(err, fh.Close())
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	if !f.Modified.IsZero() {
+		return os.Chtimes(target, f.Modified, f.Modified)
+	}
+	return nil
+}
+
+// longPathName turns p into an extended-length Windows path.
+func longPathName(p string) string {
+	if strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	p = strings.ReplaceAll(p, "/", `\`)
+	if strings.HasPrefix(p, `\\`) {
+		return `\\?\UNC\` + p[2:]
+	}
+	return `\\?\` + p
+}
+
+// windowsNameMapper remaps slash-separated names so that they are valid
+// on Windows, handing out each remapped path at most once.
+type windowsNameMapper struct {
+	dirs map[string]string // original directory name -> remapped name
+	used map[string]bool   // lower-cased remapped names already handed out
+}
+
+func newWindowsNameMapper() *windowsNameMapper {
+	return &windowsNameMapper{
+		dirs: map[string]string{".": "."},
+		used: make(map[string]bool),
+	}
+}
+
+func (m *windowsNameMapper) mapName(name string, isDir bool) string {
+	if isDir {
+		return m.mapDir(name)
+	}
+	dir, elem := path.Split(name)
+	return m.claim(m.mapDir(path.Clean(dir)), windowsNameElem(elem))
+}
+
+func (m *windowsNameMapper) mapDir(dir string) string {
+	if mapped, ok := m.dirs[dir]; ok {
+		return mapped
+	}
+	parent, elem := path.Split(dir)
+	mapped := m.claim(m.mapDir(path.Clean(parent)), windowsNameElem(elem))
+	m.dirs[dir] = mapped
+	return mapped
+}
+
+// claim joins elem onto the remapped directory dir, adding a "~N" suffix
+// to the stem if the result was already handed out.
+func (m *windowsNameMapper) claim(dir, elem string) string {
+	stem, ext := elem, ""
+	if i := strings.LastIndexByte(elem, '.'); i > 0 {
+		stem, ext = elem[:i], elem[i:]
+	}
+	candidate := path.Join(dir, elem)
+	for n := 1; m.used[strings.ToLower(candidate)]; n++ {
+		candidate = path.Join(dir, stem+"~"+strconv.Itoa(n)+ext)
+	}
+	m.used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// IsWindowsSafeName reports whether every element of the slash-separated
+// name can be created on Windows: it is not a reserved device name such as
+// CON or LPT1 (with or without an extension), does not end in a dot or a
+// space, and contains no control characters or any of <>:"|?*\.
+func IsWindowsSafeName(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if windowsNameElem(elem) != elem {
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizeWindowsName rewrites each element of the slash-separated name
+// so that Windows accepts it: reserved device names get an underscore
+// appended to their stem, and forbidden characters and trailing dots or
+// spaces are replaced with underscores. Distinct names may map to the same
+// result.
+func SanitizeWindowsName(name string) string {
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		elems[i] = windowsNameElem(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+// windowsNameElem rewrites a single path element so Windows accepts it.
+func windowsNameElem(elem string) string {
+	b := []byte(elem)
+	for i, c := range b {
+		if c < 0x20 || strings.IndexByte(`<>:"|?*\`, c) >= 0 {
+			b[i] = '_'
+		}
+	}
+	// Windows silently drops trailing dots and spaces.
+	for i := len(b) - 1; i >= 0 && (b[i] == '.' || b[i] == ' '); i-- {
+		b[i] = '_'
+	}
+	elem = string(b)
+
+	if isReservedWindowsName(elem) {
+		stem, ext := elem, ""
+		if i := strings.IndexByte(elem, '.'); i >= 0 {
+			stem, ext = elem[:i], elem[i:]
+		}
+		elem = stem + "_" + ext
+	}
+	return elem
+}
+
+// isReservedWindowsName reports whether elem names a DOS device, which
+// Windows reserves regardless of any extension.
+func isReservedWindowsName(elem string) bool {
+	stem := elem
+	if i := strings.IndexByte(stem, '.'); i >= 0 {
+		stem = stem[:i]
+	}
+	stem = strings.ToUpper(strings.TrimRight(stem, " "))
+	switch stem {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(stem) == 4 && (strings.HasPrefix(stem, "COM") || strings.HasPrefix(stem, "LPT")) {
+		return stem[3] >= '1' && stem[3] <= '9'
+	}
+	return false
+}
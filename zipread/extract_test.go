@@ -0,0 +1,187 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWindowsNameMapper(t *testing.T) {
+	m := newWindowsNameMapper()
+	for _, test := range []struct {
+		name  string
+		isDir bool
+		want  string
+	}{
+		{"CON", false, "CON_"},
+		{"dir/nul.txt", false, "dir/nul_.txt"},
+		{"dir/a:b?.txt", false, "dir/a_b_.txt"},
+		{"trailing. ", true, "trailing__"},
+		{"trailing. /x", false, "trailing__/x"},
+		{"Readme.md", false, "Readme.md"},
+		{"README.md", false, "README~1.md"},
+		{"dir/a_b_.txt", false, "dir/a_b_~1.txt"},
+		{"COM10", false, "COM10"},
+		{"lpt1", false, "lpt1_"},
+	} {
+		if got := m.mapName(test.name, test.isDir); got != test.want {
+			t.Errorf("mapName(%q)=%q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	content := []byte("extracted")
+	data := buildTestZip(t,
+		testZipFile{Name: "dir/", Method: Store},
+		testZipFile{Name: "dir/aux.txt", Method: Deflate, Data: content},
+		testZipFile{Name: "../escape", Method: Store, Data: content})
+	z := openTestZip(t, data, nil)
+
+	dir := t.TempDir()
+	if err := z.Extract(context.Background(), dir, &ExtractOptions{WindowsNames: WindowsNamesRemap}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir/aux_.txt", "escape"} {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("%s: got %q, want %q", name, got, content)
+		}
+	}
+
+	err := z.Extract(context.Background(), t.TempDir(), &ExtractOptions{WindowsNames: WindowsNamesReject})
+	if !errors.Is(err, ErrWindowsName) {
+		t.Errorf("WindowsNamesReject: got %v, want %v", err, ErrWindowsName)
+	}
+}
+
+func TestWindowsSafeName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		safe bool
+		want string
+	}{
+		{"dir/file.txt", true, "dir/file.txt"},
+		{"con.txt", false, "con_.txt"},
+		{"dir/Aux/x", false, "dir/Aux_/x"},
+		{"a:b", false, "a_b"},
+		{"dots.../x", false, "dots___/x"},
+		{"COM0", true, "COM0"},
+	} {
+		if got := IsWindowsSafeName(test.name); got != test.safe {
+			t.Errorf("IsWindowsSafeName(%q)=%v, want %v", test.name, got, test.safe)
+		}
+		if got := SanitizeWindowsName(test.name); got != test.want {
+			t.Errorf("SanitizeWindowsName(%q)=%q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func buildSymlinkZip(t *testing.T, links map[string]string) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	fw, err := w.Create("dir/target.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write([]byte("target")); err != nil {
+		t.Fatal(err)
+	}
+	for name, dest := range links {
+		fh := &FileHeader{Name: name}
+		fh.SetMode(fs.ModeSymlink | 0777)
+		fw, err := w.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(dest)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSymlinks(t *testing.T) {
+	ctx := context.Background()
+	z := openTestZip(t, buildSymlinkZip(t, map[string]string{"link": "dir/target.txt"}), nil)
+
+	if fi, err := z.Lstat("link"); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("Lstat: got %v, %v", fi, err)
+	}
+	if dest, err := z.ReadLink("link"); err != nil || dest != "dir/target.txt" {
+		t.Fatalf("ReadLink: got %q, %v", dest, err)
+	}
+	if _, err := z.ReadLink("dir/target.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("ReadLink of a regular file: got %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := z.Extract(ctx, dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "link")); err != nil || string(got) != "dir/target.txt" {
+		t.Errorf("SymlinksAsFiles: got %q, %v", got, err)
+	}
+
+	dir = t.TempDir()
+	if err := z.Extract(ctx, dir, &ExtractOptions{Symlinks: SymlinksSkip}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "link")); !os.IsNotExist(err) {
+		t.Errorf("SymlinksSkip: got %v", err)
+	}
+
+	if err := z.Extract(ctx, t.TempDir(), &ExtractOptions{Symlinks: SymlinksReject}); !errors.Is(err, ErrSymlink) {
+		t.Errorf("SymlinksReject: got %v, want %v", err, ErrSymlink)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links may need privileges")
+	}
+	dir = t.TempDir()
+	if err := z.Extract(ctx, dir, &ExtractOptions{Symlinks: SymlinksCreate}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "link")); err != nil || string(got) != "target" {
+		t.Errorf("SymlinksCreate: read through link got %q, %v", got, err)
+	}
+
+	escaping := openTestZip(t, buildSymlinkZip(t, map[string]string{"dir/up": "../../outside"}), nil)
+	if err := escaping.Extract(ctx, t.TempDir(), &ExtractOptions{Symlinks: SymlinksCreate}); !errors.Is(err, ErrInsecurePath) {
+		t.Errorf("SymlinksCreate with an escaping link: got %v, want %v", err, ErrInsecurePath)
+	}
+}
+
+func TestContainedLink(t *testing.T) {
+	for _, test := range []struct {
+		name, dest string
+		want       string
+		ok         bool
+	}{
+		{"link", "dir/file", "dir/file", true},
+		{"dir/link", "../file", "../file", true},
+		{"dir/link", "../../file", "", false},
+		{"dir/link", "x/../../..", "", false},
+		{"dir/link", "x/../y", "y", true},
+		{"link", "/etc/passwd", "", false},
+		{"link", `..\file`, "", false},
+		{"link", "C:file", "", false},
+		{"link", "", "", false},
+	} {
+		got, ok := containedLink(test.name, test.dest)
+		if got != test.want || ok != test.ok {
+			t.Errorf("containedLink(%q, %q)=%q, %v, want %q, %v", test.name, test.dest, got, ok, test.want, test.ok)
+		}
+	}
+}
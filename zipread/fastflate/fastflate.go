@@ -0,0 +1,65 @@
+// Package fastflate makes zipread decompress Deflate entries with the
+// optimized decoder of github.com/klauspost/compress instead of
+// compress/flate, which speeds up bulk extraction. It is a separate package
+// to keep its dependency out of programs that do not need it; import it
+// for its side effect:
+//
+//	import _ "zipper/zipread/fastflate"
+package fastflate
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/flate"
+
+	"zipper/zipread"
+)
+
+func init() {
+	zipread.SetDeflateDecompressor(NewReader)
+}
+
+var readerPool sync.Pool
+
+// NewReader returns a reader decompressing the DEFLATE stream r, reusing
+// decoder state released by closing earlier readers. It is the
+// zipread.Decompressor installed for zipread.Deflate.
+func NewReader(r io.Reader) io.ReadCloser {
+	fr, ok := readerPool.Get().(io.ReadCloser)
+	if ok {
+		if err := fr.(flate.Resetter).Reset(r, nil); err != nil {
+			fr = flate.NewReader(r)
+		}
+	} else {
+		fr = flate.NewReader(r)
+	}
+	return &pooledReader{fr: fr}
+}
+
+type pooledReader struct {
+	mu sync.Mutex // guards Close and Read
+	fr io.ReadCloser
+}
+
+func (r *pooledReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fr == nil {
+		return 0, errors.New("Read after Close")
+	}
+	return r.fr.Read(p)
+}
+
+func (r *pooledReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	if r.fr != nil {
+		err = r.fr.Close()
+		readerPool.Put(r.fr)
+		r.fr = nil
+	}
+	return err
+}
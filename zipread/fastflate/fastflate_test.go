@@ -0,0 +1,47 @@
+package fastflate
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestDecompress(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	var want [][]byte
+	for i := 0; i < 3; i++ {
+		content := bytes.Repeat([]byte(fmt.Sprintf("entry %d ", i)), 1000*(i+1))
+		want = append(want, content)
+		fw, err := w.Create(fmt.Sprintf("%d.txt", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Twice, so that the second round reuses pooled decoders.
+	for round := 0; round < 2; round++ {
+		for i, f := range z.File {
+			got, err := z.ReadFile(f.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want[i]) {
+				t.Fatalf("%s: content mismatch", f.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package zipread
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// Reader implements the optional io/fs interfaces directly, so that
+// helpers like fs.ReadFile and fs.WalkDir use the file list instead of
+// falling back to opening every file and directory.
+var (
+	_ fs.ReadDirFS  = (*Reader)(nil)
+	_ fs.ReadFileFS = (*Reader)(nil)
+	_ fs.StatFS     = (*Reader)(nil)
+	_ fs.GlobFS     = (*Reader)(nil)
+	_ fs.SubFS      = (*Reader)(nil)
+)
+
+// readFilePrealloc bounds how much ReadFile allocates up front based on
+// the declared size of an entry, which may be forged.
+const readFilePrealloc = 16 << 20
+
+// lookup resolves name using the semantics of fs.FS, reporting a missing
+// entry as an *fs.PathError for op.
+func (r *Reader) lookup(op, name string) (*fileListEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	r.initFileList()
+	e := r.openLookup(name)
+	if e == nil && (r.opts.CaseInsensitive || r.opts.NameNormalizer != nil) {
+		r.initKeyed()
+		e = r.keyed[r.lookupKey(name)]
+	}
+	if e == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// lookupKey returns the key under which name is found when names are
+// matched after normalization or case folding.
+func (r *Reader) lookupKey(name string) string {
+	if r.opts.NameNormalizer != nil {
+		name = r.opts.NameNormalizer(name)
+	}
+	if r.opts.CaseInsensitive {
+		name = strings.ToLower(name)
+	}
+	return name
+}
+
+// dirName returns the name of the directory e under which openReadDir
+// finds its contents, which may differ from the name it was looked up by.
+func (e *fileListEntry) dirName() string {
+	return path.Clean(e.name)
+}
+
+// Stat returns a FileInfo describing the named file or directory without
+// opening it. Symbolic links are not followed, see ReadLink.
+func (r *Reader) Stat(name string) (fs.FileInfo, error) {
+	e, err := r.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.stat(), nil
+}
+
+// Lstat returns a FileInfo describing the named file. If the file is a
+// symbolic link, its mode includes fs.ModeSymlink. It is the same as Stat,
+// and provided to match the interfaces of file systems with links.
+func (r *Reader) Lstat(name string) (fs.FileInfo, error) {
+	e, err := r.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.stat(), nil
+}
+
+// ReadLink returns the destination of the named symbolic link, which is
+// recorded as the contents of its entry. Opening a link through Open reads
+// the same destination rather than following it.
+func (r *Reader) ReadLink(name string) (string, error) {
+	e, err := r.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if e.isDir || e.file.Mode()&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	dest, err := e.file.readLink()
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return dest, nil
+}
+
+// maxLinkLen bounds the length of a symbolic link destination.
+const maxLinkLen = 4096
+
+// readLink reads the destination of a symbolic link entry.
+func (f *File) readLink() (_ string, err error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+	dest, err := io.ReadAll(io.LimitReader(rc, maxLinkLen+1))
+	if err != nil {
+		return "", err
+	}
+	if len(dest) > maxLinkLen {
+		return "", errs.Errorf("symbolic link destination of %q too long", f.Name)
+	}
+	return string(dest), nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by
+// filename.
+func (r *Reader) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := r.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	files := r.openReadDir(e.dirName())
+	list := make([]fs.DirEntry, len(files))
+	for i := range files {
+		list[i] = files[i].stat()
+	}
+	return list, nil
+}
+
+// ReadFile reads the named file and returns its contents. The entry is
+// fetched with a single request, and its contents are read into a buffer
+// sized from the central directory.
+func (r *Reader) ReadFile(name string) (_ []byte, err error) {
+	e, err := r.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.isDir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	rc, err := e.file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+
+	size := e.file.UncompressedSize64
+	if size > readFilePrealloc {
+		size = readFilePrealloc
+	}
+	// One spare byte lets the final read observe io.EOF without growing.
+	data := make([]byte, 0, size+1)
+	for {
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+		n, err := rc.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Glob returns the names of all files and directories matching pattern,
+// with the semantics of fs.Glob, by matching against the file list.
+func (r *Reader) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		if _, err := r.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	r.initFileList()
+	var matches []string
+	r.walkDirs(func(entries []fileListEntry) {
+		for _, e := range entries {
+			if ok, _ := path.Match(pattern, e.name); ok {
+				matches = append(matches, e.name)
+			}
+		}
+	})
+	// fs.Glob lists each directory in turn, so order by path element.
+	sort.Slice(matches, func(i, j int) bool {
+		return lessPathElements(matches[i], matches[j])
+	})
+	return matches, nil
+}
+
+func lessPathElements(x, y string) bool {
+	for x != "" && y != "" {
+		var xelem, yelem string
+		if i := strings.IndexByte(x, '/'); i >= 0 {
+			xelem, x = x[:i], x[i+1:]
+		} else {
+			xelem, x = x, ""
+		}
+		if i := strings.IndexByte(y, '/'); i >= 0 {
+			yelem, y = y[:i], y[i+1:]
+		} else {
+			yelem, y = y, ""
+		}
+		if xelem != yelem {
+			return xelem < yelem
+		}
+	}
+	return x == "" && y != ""
+}
+
+// Sub returns an fs.FS corresponding to the subtree rooted at dir, which
+// implements the same io/fs interfaces as Reader.
+func (r *Reader) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return r, nil
+	}
+	return &subFS{r: r, dir: dir}, nil
+}
+
+// subFS is a Reader rooted at dir.
+type subFS struct {
+	r   *Reader
+	dir string
+}
+
+var (
+	_ fs.ReadDirFS  = (*subFS)(nil)
+	_ fs.ReadFileFS = (*subFS)(nil)
+	_ fs.StatFS     = (*subFS)(nil)
+	_ fs.GlobFS     = (*subFS)(nil)
+	_ fs.SubFS      = (*subFS)(nil)
+)
+
+// fullName maps name within the subtree to a name in the archive.
+func (s *subFS) fullName(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(s.dir, name), nil
+}
+
+// shorten maps a name in the archive to a name within the subtree.
+func (s *subFS) shorten(name string) (string, bool) {
+	if name == s.dir {
+		return ".", true
+	}
+	if strings.HasPrefix(name, s.dir+"/") {
+		return name[len(s.dir)+1:], true
+	}
+	return "", false
+}
+
+// fixErr rewrites the path of a *fs.PathError to be relative to the
+// subtree.
+func (s *subFS) fixErr(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		if short, ok := s.shorten(pe.Path); ok {
+			pe.Path = short
+		}
+	}
+	return err
+}
+
+func (s *subFS) Open(name string) (fs.File, error) {
+	full, err := s.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.r.Open(full)
+	return f, s.fixErr(err)
+}
+
+func (s *subFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := s.fullName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.r.Stat(full)
+	return info, s.fixErr(err)
+}
+
+func (s *subFS) Lstat(name string) (fs.FileInfo, error) {
+	full, err := s.fullName("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.r.Lstat(full)
+	return info, s.fixErr(err)
+}
+
+func (s *subFS) ReadLink(name string) (string, error) {
+	full, err := s.fullName("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	dest, err := s.r.ReadLink(full)
+	return dest, s.fixErr(err)
+}
+
+func (s *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := s.fullName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.r.ReadDir(full)
+	return list, s.fixErr(err)
+}
+
+func (s *subFS) ReadFile(name string) ([]byte, error) {
+	full, err := s.fullName("read", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.r.ReadFile(full)
+	return data, s.fixErr(err)
+}
+
+func (s *subFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if pattern == "." {
+		return []string{"."}, nil
+	}
+	full, err := s.r.Glob(path.Join(s.dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	matches := full[:0]
+	for _, name := range full {
+		if short, ok := s.shorten(name); ok {
+			matches = append(matches, short)
+		}
+	}
+	return matches, nil
+}
+
+func (s *subFS) Sub(dir string) (fs.FS, error) {
+	if dir == "." {
+		return s, nil
+	}
+	full, err := s.fullName("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return &subFS{r: s.r, dir: full}, nil
+}
+
+// EscapeName returns the path under which the fs.FS view lists an entry
+// named name when Options.EscapeNames is set. Names that are valid paths
+// once normalized are returned normalized, unless they start with "%".
+// Others are escaped in full, slashes included, into a single element at
+// the root of the tree: a "%" followed by the name with NUL and other
+// control characters, bytes that are not valid UTF-8, "%", "/" and
+// backslashes written as "%XX". UnescapeName reverses the escaping.
+func EscapeName(name string) string {
+	if valid := toValidName(name); valid != "." && fs.ValidPath(valid) && !strings.ContainsRune(valid, 0) &&
+		!strings.HasPrefix(valid, "%") {
+		return valid
+	}
+	var b strings.Builder
+	b.WriteByte('%')
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		if r == utf8.RuneError && size == 1 || r < 0x20 || r == 0x7f || r == '%' || r == '/' || r == '\\' {
+			fmt.Fprintf(&b, "%%%02X", name[i])
+			i++
+			continue
+		}
+		b.WriteString(name[i : i+size])
+		i += size
+	}
+	return b.String()
+}
+
+// UnescapeName returns the entry name that EscapeName escaped into
+// escaped.
+func UnescapeName(escaped string) (string, error) {
+	if !strings.HasPrefix(escaped, "%") {
+		return "", errs.Errorf("not an escaped name: %q", escaped)
+	}
+	var b strings.Builder
+	for i := 1; i < len(escaped); i++ {
+		if escaped[i] != '%' {
+			b.WriteByte(escaped[i])
+			continue
+		}
+		if i+3 > len(escaped) {
+			return "", errs.Errorf("invalid escape in %q", escaped)
+		}
+		c, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", errs.Errorf("invalid escape in %q", escaped)
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
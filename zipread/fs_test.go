@@ -0,0 +1,330 @@
+package zipread
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// plainFS hides the optional interfaces of an fs.FS, forcing the io/fs
+// helpers onto their generic paths.
+type plainFS struct{ fsys fs.FS }
+
+func (p plainFS) Open(name string) (fs.File, error) { return p.fsys.Open(name) }
+
+func TestFSInterfaces(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789"), 10000)
+	z := openTestZip(t, buildTestZip(t,
+		testZipFile{Name: "a.txt", Method: Store, Data: []byte("a")},
+		testZipFile{Name: "dir/b.txt", Method: Deflate, Data: big},
+		testZipFile{Name: "dir/sub/c.txt", Method: Store, Data: []byte("c")},
+		testZipFile{Name: "dir-x/d.txt", Method: Store, Data: []byte("d")},
+		testZipFile{Name: "empty/", Method: Store}), nil)
+
+	if err := fstest.TestFS(z, "a.txt", "dir/b.txt", "dir/sub/c.txt", "dir-x/d.txt", "empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := z.ReadFile("dir/b.txt")
+	if err != nil || !bytes.Equal(data, big) {
+		t.Fatalf("ReadFile: got %d bytes, %v", len(data), err)
+	}
+	if _, err := z.ReadFile("dir"); err == nil {
+		t.Error("ReadFile of a directory succeeded")
+	}
+	if _, err := z.ReadDir("a.txt"); err == nil {
+		t.Error("ReadDir of a file succeeded")
+	}
+	if _, err := z.Stat(""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of an invalid name: got %v", err)
+	}
+
+	for _, pattern := range []string{"*", "*/*.txt", "d*/*", "*/sub/*", "a.txt", "missing", "dir/[bc].txt"} {
+		got, err := z.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := fs.Glob(plainFS{z}, pattern)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Glob(%q)=%q, want %q", pattern, got, want)
+		}
+	}
+	if _, err := z.Glob("["); err == nil {
+		t.Error("Glob of a bad pattern succeeded")
+	}
+
+	sub, err := z.Sub("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "b.txt", "sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(sub, "missing"); !errors.As(err, new(*fs.PathError)) || err.(*fs.PathError).Path != "missing" {
+		t.Errorf("Stat in Sub: got %v", err)
+	}
+}
+
+func TestSynthesizedDirs(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	times := map[string]time.Time{
+		"a/b/old":   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		"a/b/new":   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		"a/c/other": time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for name, modified := range times {
+		if _, err := w.CreateHeader(&FileHeader{Name: name, Modified: modified}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A directory with an entry of its own keeps the entry's mode.
+	dir := &FileHeader{Name: "a/d/", Modified: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+	dir.SetMode(fs.ModeDir | 0700)
+	if _, err := w.CreateHeader(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z := openTestZip(t, buf.Bytes(), &Options{DirMode: 0750})
+
+	for name, want := range map[string]time.Time{
+		".":   times["a/b/new"],
+		"a":   times["a/b/new"],
+		"a/b": times["a/b/new"],
+		"a/c": times["a/c/other"],
+	} {
+		fi, err := z.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != fs.ModeDir|0750 || !fi.ModTime().Equal(want) {
+			t.Errorf("%s: got %v %v, want %v %v", name, fi.Mode(), fi.ModTime(), fs.ModeDir|0750, want)
+		}
+	}
+
+	fsEntries, err := fs.ReadDir(z, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entries := range [][]fs.DirEntry{fsEntries, z.ReadDirPrefix("a/")} {
+		for _, e := range entries {
+			want := fs.ModeDir | 0750
+			if e.Name() == "d" {
+				want = fs.ModeDir | 0700
+			}
+			if fi, err := e.Info(); err != nil || fi.Mode() != want {
+				t.Errorf("%s: got %v, %v, want %v", e.Name(), fi.Mode(), err, want)
+			}
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "Dir/Readme.TXT", Method: Store, Data: []byte("readme")},
+		testZipFile{Name: "Dir/sub/b", Method: Store, Data: []byte("b")},
+		testZipFile{Name: "dup", Method: Store, Data: []byte("lower")},
+		testZipFile{Name: "DUP", Method: Store, Data: []byte("upper")})
+
+	if _, err := openTestZip(t, data, nil).Stat("dir/readme.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("case sensitive by default: got %v", err)
+	}
+
+	z := openTestZip(t, data, &Options{CaseInsensitive: true})
+	for name, want := range map[string]string{
+		"dir/readme.txt": "readme",
+		"DIR/README.txt": "readme",
+		"dup":            "lower",
+		"DUP":            "upper",
+		"Dup":            "upper",
+	} {
+		got, err := z.ReadFile(name)
+		if err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q)=%q, %v, want %q", name, got, err, want)
+		}
+	}
+	entries, err := z.ReadDir("DIR")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("ReadDir: got %v, %v", entries, err)
+	}
+	if f, err := z.OpenLookup("dir/SUB/B"); err != nil || f.Name != "Dir/sub/b" {
+		t.Errorf("OpenLookup: got %v, %v", f, err)
+	}
+}
+
+func TestNameNormalizer(t *testing.T) {
+	const (
+		nfd = "mu\u0301sica.txt"
+		nfc = "m\u00fasica.txt"
+	)
+	// A stand-in for norm.NFC.String covering the name used here.
+	toNFC := func(s string) string { return strings.ReplaceAll(s, "u\u0301", "\u00fa") }
+
+	data := buildTestZip(t, testZipFile{Name: "Dir/" + nfd, Method: Store, Data: []byte("song")})
+	if _, err := openTestZip(t, data, nil).Stat("Dir/" + nfc); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("without normalization: got %v", err)
+	}
+
+	z := openTestZip(t, data, &Options{NameNormalizer: toNFC})
+	for _, name := range []string{"Dir/" + nfc, "Dir/" + nfd} {
+		if got, err := z.ReadFile(name); err != nil || string(got) != "song" {
+			t.Errorf("ReadFile(%q)=%q, %v", name, got, err)
+		}
+	}
+	if _, err := z.Stat("dir/" + nfc); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("normalization alone matched a different case: %v", err)
+	}
+
+	z = openTestZip(t, data, &Options{NameNormalizer: toNFC, CaseInsensitive: true})
+	if _, err := z.Stat("DIR/M\u00dasica.txt"); err != nil {
+		t.Errorf("normalization with CaseInsensitive: %v", err)
+	}
+}
+
+func TestEscapeNames(t *testing.T) {
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"dir/file", "dir/file"},
+		{"/abs/../file", "file"},
+		{"", "%"},
+		{"/", "%%2F"},
+		{"bad\xffutf8", "%bad%FFutf8"},
+		{"nul\x00/x", "%nul%00%2Fx"},
+		{`dir\100%`, "dir/100%"},
+		{"\xff\\%", "%%FF%5C%25"},
+		{"%%00", "%%25%2500"},
+		{"%dir/file", "%%25dir%2Ffile"},
+	} {
+		got := EscapeName(test.name)
+		if got != test.want {
+			t.Errorf("EscapeName(%q)=%q, want %q", test.name, got, test.want)
+		}
+		if got != toValidName(test.name) {
+			if raw, err := UnescapeName(got); err != nil || raw != test.name {
+				t.Errorf("UnescapeName(%q)=%q, %v, want %q", got, raw, err, test.name)
+			}
+		}
+	}
+	for _, bad := range []string{"plain", "%%4", "%%zz"} {
+		if _, err := UnescapeName(bad); err == nil {
+			t.Errorf("UnescapeName(%q) succeeded", bad)
+		}
+	}
+
+	data := buildTestZip(t,
+		testZipFile{Name: "ok.txt", Method: Store, Data: []byte("ok")},
+		testZipFile{Name: "", Method: Store, Data: []byte("empty")},
+		testZipFile{Name: "bad\xff", Method: Deflate, Data: []byte("latin1")})
+	z := openTestZip(t, data, &Options{EscapeNames: true})
+	for name, want := range map[string]string{"ok.txt": "ok", "%": "empty", "%bad%FF": "latin1"} {
+		if got, err := z.ReadFile(name); err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q)=%q, %v, want %q", name, got, err, want)
+		}
+	}
+	if err := fstest.TestFS(z, "ok.txt", "%", "%bad%FF"); err != nil {
+		t.Error(err)
+	}
+
+	// A valid name starting with "%" must not collide with an escaped one.
+	data = buildTestZip(t,
+		testZipFile{Name: "%%00", Method: Store, Data: []byte("percent")},
+		testZipFile{Name: "\x00", Method: Store, Data: []byte("nul")})
+	z = openTestZip(t, data, &Options{EscapeNames: true})
+	for name, want := range map[string]string{"%%25%2500": "percent", "%%00": "nul"} {
+		if got, err := z.ReadFile(name); err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q)=%q, %v, want %q", name, got, err, want)
+		}
+	}
+	if err := fstest.TestFS(z, "%%25%2500", "%%00"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHideJunk(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "docs/", Method: Store},
+		testZipFile{Name: "docs/readme.txt", Method: Store, Data: []byte("readme")},
+		testZipFile{Name: "docs/.DS_Store", Method: Store, Data: []byte("junk")},
+		testZipFile{Name: "__MACOSX/", Method: Store},
+		testZipFile{Name: "__MACOSX/docs/._readme.txt", Method: Store, Data: []byte("fork")},
+		testZipFile{Name: "pics/Thumbs.db", Method: Store, Data: []byte("junk")},
+		testZipFile{Name: "empty/", Method: Store},
+		testZipFile{Name: "bucket_$folder$", Method: Store})
+
+	z := openTestZip(t, data, &Options{HideJunk: true})
+	if err := fstest.TestFS(z, "docs/readme.txt"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := z.ReadDir(".")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "docs" {
+		t.Errorf("ReadDir: got %v, %v, want only docs", entries, err)
+	}
+	for _, name := range []string{"docs/.DS_Store", "__MACOSX", "pics", "empty", "bucket_$folder$"} {
+		if _, err := z.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(%q): got %v, want not found", name, err)
+		}
+	}
+	if len(z.File) != 8 {
+		t.Errorf("got %d files, want all 8", len(z.File))
+	}
+}
+
+func TestFSListsDirsOnDemand(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "a/b/c/deep.txt", Method: Store, Data: []byte("deep")},
+		testZipFile{Name: "a/b-c/x.txt", Method: Store, Data: []byte("x")},
+		testZipFile{Name: "a/top.txt", Method: Store, Data: []byte("top")},
+		testZipFile{Name: "z/y.txt", Method: Store, Data: []byte("y")})
+	z := openTestZip(t, data, nil)
+
+	entries, err := z.ReadDir("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got, want := fmt.Sprint(names), "[b b-c top.txt]"; got != want {
+		t.Errorf("ReadDir: got %s, want %s", got, want)
+	}
+	// Finding a lists the top level, which holds it.
+	if len(z.fileList) != 2 {
+		t.Errorf("listed %d directories, want 2", len(z.fileList))
+	}
+	if _, err := z.Stat("a/missing/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat: got %v, want not found", err)
+	}
+	if len(z.fileList) != 2 {
+		t.Errorf("failed lookup kept a directory: listed %d, want 2", len(z.fileList))
+	}
+	if err := fstest.TestFS(z, "a/b/c/deep.txt", "a/b-c/x.txt", "a/top.txt", "z/y.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkFSFirstOpen(b *testing.B) {
+	var files []testZipFile
+	for i := 0; i < 100000; i++ {
+		files = append(files, testZipFile{Name: fmt.Sprintf("dir%d/file%d", i%1000, i), Method: Store})
+	}
+	data := buildTestZip(b, files...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		z := openTestZip(b, data, nil)
+		b.StartTimer()
+		if _, err := z.ReadDir("dir7"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package zipread
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// gzipHeader is the fixed member header emitted by GzipWrapper: deflate,
+// no flags or timestamp, maximum compression hint, unknown OS.
+var gzipHeader = [10]byte{0: 0x1f, 1: 0x8b, 2: 8, 8: 2, 9: 255}
+
+// gzipOverhead is the number of bytes GzipWrapper adds to the deflate stream.
+const gzipOverhead = int64(len(gzipHeader)) + 8
+
+const (
+	gzipWrapHeader = iota
+	gzipWrapBody
+	gzipWrapFooter
+	gzipWrapDone
+)
+
+// gzipWrapper emits a gzip header, the raw deflate stream read from r, and
+// a gzip footer, without any intermediate buffering.
+type gzipWrapper struct {
+	r      io.Reader
+	footer [8]byte
+	state  int
+	off    int // position within the header or footer
+}
+
+var gzipWrapperPool = sync.Pool{
+	New: func() interface{} { return new(gzipWrapper) },
+}
+
+func newGzipWrapper(r io.Reader, digest, decompressedSize uint32) *gzipWrapper {
+	g := gzipWrapperPool.Get().(*gzipWrapper)
+	g.r = r
+	g.state, g.off = gzipWrapHeader, 0
+	binary.LittleEndian.PutUint32(g.footer[:4], digest)
+	binary.LittleEndian.PutUint32(g.footer[4:8], decompressedSize)
+	return g
+}
+
+// GzipWrapper wraps a reader with gzip headers and footers.
+func GzipWrapper(r io.Reader, digest, decompressedSize uint32) io.Reader {
+	return newGzipWrapper(r, digest, decompressedSize)
+}
+
+func (g *gzipWrapper) Read(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		var m int
+		switch g.state {
+		case gzipWrapHeader:
+			m = copy(p, gzipHeader[g.off:])
+			if g.off += m; g.off == len(gzipHeader) {
+				g.state, g.off = gzipWrapBody, 0
+			}
+		case gzipWrapBody:
+			m, err = g.r.Read(p)
+			if err == io.EOF {
+				g.state, err = gzipWrapFooter, nil
+			}
+		case gzipWrapFooter:
+			m = copy(p, g.footer[g.off:])
+			if g.off += m; g.off == len(g.footer) {
+				g.state, g.off = gzipWrapDone, 0
+			}
+		default:
+			if n == 0 {
+				err = io.EOF
+			}
+			return n, err
+		}
+		n += m
+		p = p[m:]
+		if err != nil || (m > 0 && g.state == gzipWrapBody) {
+			// Hand body data back as soon as it arrives rather than
+			// blocking for more.
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// WriteTo writes the remainder of the gzip stream to w, copying the body
+// directly from the underlying reader.
+func (g *gzipWrapper) WriteTo(w io.Writer) (n int64, err error) {
+	if g.state == gzipWrapHeader {
+		m, err := w.Write(gzipHeader[g.off:])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		g.state, g.off = gzipWrapBody, 0
+	}
+	if g.state == gzipWrapBody {
+		m, err := io.Copy(w, g.r)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		g.state = gzipWrapFooter
+	}
+	if g.state == gzipWrapFooter {
+		m, err := w.Write(g.footer[g.off:])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		g.state, g.off = gzipWrapDone, 0
+	}
+	return n, nil
+}
+
+// maxStoredBlock is the largest payload of a non-compressed deflate block.
+const maxStoredBlock = 1<<16 - 1
+
+// storedDeflateSize returns the length of size bytes framed by
+// storedDeflater.
+func storedDeflateSize(size int64) int64 {
+	blocks := (size + maxStoredBlock - 1) / maxStoredBlock
+	if blocks == 0 {
+		blocks = 1 // an empty final block
+	}
+	return size + 5*blocks
+}
+
+// storedDeflater frames the size bytes read from r as a valid deflate
+// stream made of non-compressed blocks.
+type storedDeflater struct {
+	r         io.Reader
+	remaining int64 // data bytes not yet assigned to a block
+	block     int   // data bytes left in the current block
+	hdr       [5]byte
+	hdrOff    int // position within hdr; len(hdr) once written
+	started   bool
+}
+
+func newStoredDeflater(r io.Reader, size int64) *storedDeflater {
+	d := &storedDeflater{r: r, remaining: size}
+	d.hdrOff = len(d.hdr)
+	return d
+}
+
+func (d *storedDeflater) Read(p []byte) (n int, err error) {
+	for n == 0 {
+		switch {
+		case d.hdrOff < len(d.hdr):
+			m := copy(p, d.hdr[d.hdrOff:])
+			d.hdrOff += m
+			return m, nil
+		case d.block > 0:
+			if len(p) > d.block {
+				p = p[:d.block]
+			}
+			n, err = d.r.Read(p)
+			d.block -= n
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		case d.started && d.remaining == 0:
+			return 0, io.EOF
+		}
+
+		size := d.remaining
+		if size > maxStoredBlock {
+			size = maxStoredBlock
+		}
+		d.remaining -= size
+		d.block = int(size)
+		d.started = true
+		if d.remaining == 0 {
+			d.hdr[0] = 1 // BFINAL, BTYPE=00
+		} else {
+			d.hdr[0] = 0
+		}
+		binary.LittleEndian.PutUint16(d.hdr[1:3], uint16(size))
+		binary.LittleEndian.PutUint16(d.hdr[3:5], ^uint16(size))
+		d.hdrOff = 0
+	}
+	return n, err
+}
+
+// gzipReadCloser is returned by File.OpenAsGzip. Close releases the
+// source range and returns the wrapper to the pool.
+type gzipReadCloser struct {
+	g  *gzipWrapper
+	rr io.ReadCloser
+}
+
+var errReadAfterClose = errors.New("Read after Close")
+
+func (rc *gzipReadCloser) Read(p []byte) (int, error) {
+	if rc.g == nil {
+		return 0, errReadAfterClose
+	}
+	return rc.g.Read(p)
+}
+
+func (rc *gzipReadCloser) WriteTo(w io.Writer) (int64, error) {
+	if rc.g == nil {
+		return 0, errReadAfterClose
+	}
+	return rc.g.WriteTo(w)
+}
+
+func (rc *gzipReadCloser) Close() error {
+	if rc.g == nil {
+		return nil
+	}
+	rc.g.r = nil
+	gzipWrapperPool.Put(rc.g)
+	rc.g = nil
+	return rc.rr.Close()
+}
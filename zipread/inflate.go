@@ -0,0 +1,509 @@
+package zipread
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// errCorruptDeflate is returned by inflater for malformed input.
+var errCorruptDeflate = errors.New("zip: corrupt deflate stream")
+
+const (
+	inflateWindowSize = 1 << 15
+	deflate64Window   = 1 << 16
+	maxCodeLen        = 15
+	numLitCodes       = 288
+	numDistCodes      = 32
+)
+
+var (
+	lengthBase  = [...]uint16{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	lengthExtra = [...]uint8{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	distBase    = [...]uint32{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577, 32769, 49153}
+	distExtra   = [...]uint8{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13, 14, 14}
+
+	// codeLenOrder is the order code length code lengths are sent in.
+	codeLenOrder = [...]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+)
+
+var fixedLit, fixedDist huffTable
+
+func init() {
+	var lengths [numLitCodes]uint8
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	if err := fixedLit.init(lengths[:]); err != nil {
+		panic(err)
+	}
+	var dist [numDistCodes]uint8
+	for i := range dist {
+		dist[i] = 5
+	}
+	if err := fixedDist.init(dist[:]); err != nil {
+		panic(err)
+	}
+}
+
+// huffTable decodes a canonical Huffman code with a single lookup of
+// maxLen bits. Each entry holds symbol<<4 | code length; a zero entry
+// marks an unused code.
+type huffTable struct {
+	table  []uint16
+	maxLen uint
+}
+
+func (h *huffTable) init(lengths []uint8) error {
+	var count [maxCodeLen + 1]int
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	h.maxLen = 0
+	for l := maxCodeLen; l > 0; l-- {
+		if count[l] > 0 {
+			h.maxLen = uint(l)
+			break
+		}
+	}
+	if h.maxLen == 0 {
+		// No codes at all, e.g. the distance code of a block with only
+		// literals. Any attempt to decode with it fails.
+		h.maxLen = 1
+	}
+
+	left := 1
+	var next [maxCodeLen + 1]int
+	code := 0
+	for l := 1; l <= maxCodeLen; l++ {
+		left <<= 1
+		left -= count[l]
+		if left < 0 {
+			return errCorruptDeflate // over-subscribed
+		}
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+
+	size := 1 << h.maxLen
+	if cap(h.table) >= size {
+		h.table = h.table[:size]
+		for i := range h.table {
+			h.table[i] = 0
+		}
+	} else {
+		h.table = make([]uint16, size)
+	}
+	for sym, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		rev := 0
+		for i := 0; i < int(l); i++ {
+			rev |= (c >> i & 1) << (int(l) - 1 - i)
+		}
+		for i := rev; i < size; i += 1 << l {
+			h.table[i] = uint16(sym<<4 | int(l))
+		}
+	}
+	return nil
+}
+
+const (
+	inflateHeader = iota
+	inflateStored
+	inflateHuffman
+	inflateDone
+)
+
+// inflater is a small DEFLATE (RFC 1951) decoder. Unlike compress/flate it
+// exposes the block boundaries, its bit position in the compressed stream
+// and its window, which is what building and using checkpoint indexes
+// requires. It is also able to start in the middle of a stream.
+type inflater struct {
+	r     *bufio.Reader
+	bits  uint64
+	nbits uint
+	read  int64 // bytes taken from r
+	err   error
+
+	window []byte // the last len(window) bytes of output, as a ring
+	wpos   int
+	wfull  bool
+	out    int64 // bytes produced so far
+
+	state     int
+	final     bool
+	stored    int // bytes left in the current stored block
+	lit, dist huffTable
+	cur       struct{ lit, dist *huffTable }
+	copyLen   int // pending back-reference
+	copyDist  int
+
+	// deflate64 selects the Deflate64 variant, see newDeflate64Reader.
+	deflate64 bool
+
+	// onBlock, if non-nil, is called before each block header is read.
+	onBlock func()
+}
+
+// newDeflate64Reader returns a decoder of Deflate64, the variant of DEFLATE
+// used by method 9. It has a 64 KiB window, distance codes 30 and 31
+// reaching into it, and length code 285 taking 16 extra bits.
+func newDeflate64Reader(r io.Reader) io.ReadCloser {
+	return &inflater{r: bufio.NewReader(r), window: make([]byte, deflate64Window), deflate64: true}
+}
+
+// newInflater returns an inflater reading from r that starts at bit skip
+// of the first byte, with dict as the preceding output.
+func newInflater(r *bufio.Reader, skip uint, dict []byte) *inflater {
+	f := &inflater{r: r, window: make([]byte, inflateWindowSize)}
+	if len(dict) > len(f.window) {
+		dict = dict[len(dict)-len(f.window):]
+	}
+	f.wpos = copy(f.window, dict) & (len(f.window) - 1)
+	f.wfull = len(dict) == len(f.window)
+	if skip > 0 {
+		f.getBits(skip)
+	}
+	return f
+}
+
+// bitPos returns the position of the next unread bit in the stream.
+func (f *inflater) bitPos() int64 { return f.read*8 - int64(f.nbits) }
+
+// windowBytes returns a copy of the window in output order.
+func (f *inflater) windowBytes() []byte {
+	if !f.wfull {
+		return append([]byte(nil), f.window[:f.wpos]...)
+	}
+	w := make([]byte, 0, len(f.window))
+	w = append(w, f.window[f.wpos:]...)
+	return append(w, f.window[:f.wpos]...)
+}
+
+func (f *inflater) Close() error { return nil }
+
+func (f *inflater) Read(p []byte) (n int, err error) {
+	for n < len(p) && f.err == nil {
+		switch f.state {
+		case inflateHeader:
+			if f.final {
+				f.state = inflateDone
+				continue
+			}
+			if f.onBlock != nil {
+				f.onBlock()
+			}
+			f.readHeader()
+		case inflateStored:
+			n += f.readStored(p[n:])
+		case inflateHuffman:
+			n += f.decodeHuffman(p[n:])
+		case inflateDone:
+			if n == 0 {
+				return 0, io.EOF
+			}
+			return n, nil
+		}
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, f.err
+}
+
+func (f *inflater) fill(n uint) bool {
+	for f.nbits < n {
+		c, err := f.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			f.err = err
+			return false
+		}
+		f.read++
+		f.bits |= uint64(c) << f.nbits
+		f.nbits += 8
+	}
+	return true
+}
+
+func (f *inflater) getBits(n uint) (uint32, bool) {
+	if !f.fill(n) {
+		return 0, false
+	}
+	v := uint32(f.bits & (1<<n - 1))
+	f.bits >>= n
+	f.nbits -= n
+	return v, true
+}
+
+func (f *inflater) decodeSym(h *huffTable) (int, bool) {
+	var readErr error
+	for f.nbits < h.maxLen {
+		c, err := f.r.ReadByte()
+		if err != nil {
+			// The last code of the stream may need fewer bits than
+			// the lookup, so try with what we have.
+			readErr = err
+			break
+		}
+		f.read++
+		f.bits |= uint64(c) << f.nbits
+		f.nbits += 8
+	}
+	e := h.table[f.bits&(1<<h.maxLen-1)]
+	l := uint(e & 15)
+	switch {
+	case l == 0:
+		f.err = errCorruptDeflate
+		return 0, false
+	case l > f.nbits:
+		if readErr == nil || readErr == io.EOF {
+			readErr = io.ErrUnexpectedEOF
+		}
+		f.err = readErr
+		return 0, false
+	}
+	f.bits >>= l
+	f.nbits -= l
+	return int(e >> 4), true
+}
+
+func (f *inflater) put(b byte) {
+	f.window[f.wpos] = b
+	f.wpos = (f.wpos + 1) & (len(f.window) - 1)
+	if f.wpos == 0 {
+		f.wfull = true
+	}
+	f.out++
+}
+
+func (f *inflater) readHeader() {
+	hdr, ok := f.getBits(3)
+	if !ok {
+		return
+	}
+	f.final = hdr&1 != 0
+	switch hdr >> 1 {
+	case 0:
+		// Stored blocks start at a byte boundary.
+		f.getBits(f.nbits % 8)
+		length, ok1 := f.getBits(16)
+		nlength, ok2 := f.getBits(16)
+		if !ok1 || !ok2 {
+			return
+		}
+		if uint16(length) != ^uint16(nlength) {
+			f.err = errCorruptDeflate
+			return
+		}
+		f.stored = int(length)
+		f.state = inflateStored
+	case 1:
+		f.cur.lit, f.cur.dist = &fixedLit, &fixedDist
+		f.state = inflateHuffman
+	case 2:
+		if f.readDynamicTables() {
+			f.cur.lit, f.cur.dist = &f.lit, &f.dist
+			f.state = inflateHuffman
+		}
+	default:
+		f.err = errCorruptDeflate
+	}
+}
+
+func (f *inflater) readDynamicTables() bool {
+	hlit, ok1 := f.getBits(5)
+	hdist, ok2 := f.getBits(5)
+	hclen, ok3 := f.getBits(4)
+	if !ok1 || !ok2 || !ok3 {
+		return false
+	}
+	nlit, ndist := int(hlit)+257, int(hdist)+1
+	if nlit > 286 || ndist > 30 && !f.deflate64 {
+		f.err = errCorruptDeflate
+		return false
+	}
+
+	var clens [19]uint8
+	for i := 0; i < int(hclen)+4; i++ {
+		v, ok := f.getBits(3)
+		if !ok {
+			return false
+		}
+		clens[codeLenOrder[i]] = uint8(v)
+	}
+	var clen huffTable
+	if err := clen.init(clens[:]); err != nil {
+		f.err = err
+		return false
+	}
+
+	var lengths [numLitCodes + numDistCodes]uint8
+	for i := 0; i < nlit+ndist; {
+		sym, ok := f.decodeSym(&clen)
+		if !ok {
+			return false
+		}
+		if sym < 16 {
+			lengths[i] = uint8(sym)
+			i++
+			continue
+		}
+		var rep uint32
+		var val uint8
+		switch sym {
+		case 16:
+			if i == 0 {
+				f.err = errCorruptDeflate
+				return false
+			}
+			val = lengths[i-1]
+			rep, ok = f.getBits(2)
+			rep += 3
+		case 17:
+			rep, ok = f.getBits(3)
+			rep += 3
+		default:
+			rep, ok = f.getBits(7)
+			rep += 11
+		}
+		if !ok {
+			return false
+		}
+		if i+int(rep) > nlit+ndist {
+			f.err = errCorruptDeflate
+			return false
+		}
+		for ; rep > 0; rep-- {
+			lengths[i] = val
+			i++
+		}
+	}
+	if lengths[256] == 0 {
+		f.err = errCorruptDeflate // no end of block code
+		return false
+	}
+	if err := f.lit.init(lengths[:nlit]); err != nil {
+		f.err = err
+		return false
+	}
+	if err := f.dist.init(lengths[nlit : nlit+ndist]); err != nil {
+		f.err = err
+		return false
+	}
+	return true
+}
+
+func (f *inflater) readStored(p []byte) int {
+	if f.stored == 0 {
+		f.state = inflateHeader
+		return 0
+	}
+	if len(p) > f.stored {
+		p = p[:f.stored]
+	}
+	n := 0
+	// Drain whole bytes left in the bit buffer first.
+	for n < len(p) && f.nbits >= 8 {
+		p[n] = byte(f.bits)
+		f.bits >>= 8
+		f.nbits -= 8
+		n++
+	}
+	if n < len(p) {
+		m, err := f.r.Read(p[n:])
+		f.read += int64(m)
+		n += m
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			f.err = err
+		}
+	}
+	for _, b := range p[:n] {
+		f.put(b)
+	}
+	f.stored -= n
+	return n
+}
+
+func (f *inflater) decodeHuffman(p []byte) int {
+	n := 0
+	for n < len(p) {
+		if f.copyLen > 0 {
+			mask := len(f.window) - 1
+			for f.copyLen > 0 && n < len(p) {
+				b := f.window[(f.wpos-f.copyDist)&mask]
+				p[n] = b
+				f.put(b)
+				n++
+				f.copyLen--
+			}
+			continue
+		}
+
+		sym, ok := f.decodeSym(f.cur.lit)
+		if !ok {
+			return n
+		}
+		switch {
+		case sym < 256:
+			p[n] = byte(sym)
+			f.put(byte(sym))
+			n++
+			continue
+		case sym == 256:
+			f.state = inflateHeader
+			return n
+		case sym-257 >= len(lengthBase):
+			f.err = errCorruptDeflate
+			return n
+		}
+		base, extraBits := int(lengthBase[sym-257]), uint(lengthExtra[sym-257])
+		if sym == 285 && f.deflate64 {
+			base, extraBits = 3, 16
+		}
+		extra, ok := f.getBits(extraBits)
+		if !ok {
+			return n
+		}
+		length := base + int(extra)
+
+		dsym, ok := f.decodeSym(f.cur.dist)
+		if !ok {
+			return n
+		}
+		if dsym >= 30 && !f.deflate64 {
+			f.err = errCorruptDeflate
+			return n
+		}
+		extra, ok = f.getBits(uint(distExtra[dsym]))
+		if !ok {
+			return n
+		}
+		dist := int(distBase[dsym]) + int(extra)
+		if !f.wfull && dist > f.wpos {
+			f.err = errCorruptDeflate // reaches before the start of the stream
+			return n
+		}
+		f.copyLen, f.copyDist = length, dist
+	}
+	return n
+}
@@ -0,0 +1,147 @@
+package zipread
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// deflateTestData returns n bytes mixing runs, text and noise, so that
+// compressors emit stored, fixed and dynamic blocks.
+func deflateTestData(n int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"zip ", "archive ", "deflate ", "entry ", "\n", "header "}
+	var buf bytes.Buffer
+	for buf.Len() < n {
+		switch rnd.Intn(4) {
+		case 0:
+			for i := rnd.Intn(200); i > 0; i-- {
+				buf.WriteByte(byte(rnd.Intn(256)))
+			}
+		case 1:
+			for i := rnd.Intn(2000); i > 0; i-- {
+				buf.WriteByte(byte('a' + rnd.Intn(26)))
+			}
+		case 2:
+			for i := rnd.Intn(100); i > 0; i-- {
+				buf.WriteString(words[rnd.Intn(len(words))])
+			}
+		default:
+			buf.Write(bytes.Repeat([]byte{byte(rnd.Intn(256))}, rnd.Intn(300)))
+		}
+	}
+	return buf.Bytes()[:n]
+}
+
+func TestInflater(t *testing.T) {
+	content := deflateTestData(300000)
+	for _, level := range []int{flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression, flate.HuffmanOnly} {
+		var compressed bytes.Buffer
+		w, err := flate.NewWriter(&compressed, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Small writes with flushes force empty stored blocks too.
+		for i := 0; i < len(content); i += 70000 {
+			end := i + 70000
+			if end > len(content) {
+				end = len(content)
+			}
+			if _, err := w.Write(content[i:end]); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(newInflater(bufio.NewReader(bytes.NewReader(compressed.Bytes())), 0, nil))
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("level %d: content mismatch", level)
+		}
+
+		truncated := compressed.Bytes()[:compressed.Len()/2]
+		_, err = io.ReadAll(newInflater(bufio.NewReader(bytes.NewReader(truncated)), 0, nil))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("level %d: truncated stream: err=%v, want %v", level, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+// bitWriter writes a DEFLATE bit stream.
+type bitWriter struct {
+	buf   bytes.Buffer
+	bits  uint64
+	nbits uint
+}
+
+// writeBits writes the n low bits of v, least significant first.
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	w.bits |= v << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf.WriteByte(byte(w.bits))
+		w.bits >>= 8
+		w.nbits -= 8
+	}
+}
+
+// writeCode writes the n bit Huffman code c, most significant bit first.
+func (w *bitWriter) writeCode(c uint64, n uint) {
+	var rev uint64
+	for i := uint(0); i < n; i++ {
+		rev |= (c >> i & 1) << (n - 1 - i)
+	}
+	w.writeBits(rev, n)
+}
+
+func (w *bitWriter) flush() []byte {
+	if w.nbits > 0 {
+		w.writeBits(0, 8-w.nbits)
+	}
+	return w.buf.Bytes()
+}
+
+func TestDeflate64(t *testing.T) {
+	literals := deflateTestData(40000)
+	const length, dist = 5003, 40000
+
+	var w bitWriter
+	// A stored block holding the literals.
+	w.writeBits(0, 3)
+	w.flush()
+	w.writeBits(uint64(len(literals)), 16)
+	w.writeBits(uint64(^uint16(len(literals))), 16)
+	w.buf.Write(literals)
+	// A final block with fixed codes holding a single match out of reach
+	// of DEFLATE: length code 285 with 16 extra bits and distance code 30.
+	w.writeBits(1|1<<1, 3)
+	w.writeCode(0xc0+285-280, 8)
+	w.writeBits(length-3, 16)
+	w.writeCode(30, 5)
+	w.writeBits(dist-32769, 14)
+	w.writeCode(0, 7) // end of block
+	stream := w.flush()
+
+	want := append(append([]byte(nil), literals...), literals[:length]...)
+	got, err := io.ReadAll(newDeflate64Reader(bytes.NewReader(stream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+
+	if _, err := io.ReadAll(newInflater(bufio.NewReader(bytes.NewReader(stream)), 0, nil)); err != errCorruptDeflate {
+		t.Fatalf("DEFLATE decoder: err=%v, want %v", err, errCorruptDeflate)
+	}
+}
@@ -0,0 +1,150 @@
+package zipread
+
+import (
+	"encoding/base64"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// sortedFiles returns the entries sorted by name, ties kept in directory
+// order. The order only depends on the archive, so positions in it are
+// stable across Readers opened on the same archive.
+func (z *Reader) sortedFiles() []*File {
+	z.sortedOnce.Do(func() {
+		z.sorted = make([]*File, len(z.File))
+		copy(z.sorted, z.File)
+		sort.SliceStable(z.sorted, func(i, j int) bool { return z.sorted[i].Name < z.sorted[j].Name })
+	})
+	return z.sorted
+}
+
+// ListPage returns up to limit entries whose names start with prefix, in
+// name order, starting after the position described by token. An empty
+// token starts at the beginning. The returned next token continues the
+// listing and is empty once it is exhausted. Tokens are self-contained, so
+// a server can hand them to clients without keeping per-client state. A
+// limit of zero or less returns all remaining entries.
+func (z *Reader) ListPage(prefix, token string, limit int) (files []*File, next string, err error) {
+	sorted := z.sortedFiles()
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Name >= prefix })
+	if token != "" {
+		pos, err := decodeListToken(sorted, token)
+		if err != nil {
+			return nil, "", err
+		}
+		if pos > i {
+			i = pos
+		}
+	}
+
+	files, next = listFrom(sorted, i, prefix, limit)
+	return files, next, nil
+}
+
+// List is like ListPage but starts after skipping the first offset entries
+// whose names start with prefix, for APIs paging by offset. The returned
+// next token continues the listing with ListPage.
+func (z *Reader) List(offset, limit int, prefix string) (files []*File, next string, err error) {
+	if offset < 0 {
+		return nil, "", errs.Errorf("negative list offset %d", offset)
+	}
+	sorted := z.sortedFiles()
+	lo := sort.Search(len(sorted), func(i int) bool { return sorted[i].Name >= prefix })
+	hi := lo + sort.Search(len(sorted)-lo, func(i int) bool {
+		return !strings.HasPrefix(sorted[lo+i].Name, prefix)
+	})
+	i := hi
+	if offset < hi-lo {
+		i = lo + offset
+	}
+	files, next = listFrom(sorted, i, prefix, limit)
+	return files, next, nil
+}
+
+// listFrom returns up to limit entries of sorted from position i on, as
+// long as their names start with prefix, and the token for the next one.
+func listFrom(sorted []*File, i int, prefix string, limit int) (files []*File, next string) {
+	for ; i < len(sorted) && strings.HasPrefix(sorted[i].Name, prefix); i++ {
+		if limit > 0 && len(files) == limit {
+			return files, encodeListToken(i, sorted[i].Name)
+		}
+		files = append(files, sorted[i])
+	}
+	return files, ""
+}
+
+// ReadDirPrefix returns the entries directly under the directory prefix,
+// using the names as stored in the archive, sorted by name. The empty
+// prefix lists the top level. Directories are listed once, whether they
+// have an entry of their own or only hold deeper entries. Entries are found
+// by binary search over the entries sorted by name, skipping over the
+// contents of each subdirectory, so the cost does not grow with the size
+// of the tree below prefix, and the index behind the fs.FS view is not
+// built. Directories report the permissions of their entry, or without an
+// entry of their own a zero modification time and the permissions set by
+// Options.DirMode.
+func (z *Reader) ReadDirPrefix(prefix string) []fs.DirEntry {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	dirMode := z.dirMode()
+
+	sorted := z.sortedFiles()
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Name >= prefix })
+	var list []fs.DirEntry
+	for i < len(sorted) && strings.HasPrefix(sorted[i].Name, prefix) {
+		f := sorted[i]
+		rest := f.Name[len(prefix):]
+		slash := strings.IndexByte(rest, '/')
+		switch {
+		case rest == "" || len(list) > 0 && list[len(list)-1].Name() == rest:
+			// The directory itself, or a duplicate name.
+			i++
+			continue
+		case slash < 0:
+			list = append(list, f.stat(rest))
+			i++
+			continue
+		}
+
+		dir := prefix + rest[:slash+1]
+		e := &fileListEntry{name: dir, isDir: true, mode: dirMode}
+		if f.Name == dir {
+			e.file, e.mode = f, f.Mode().Perm()
+		}
+		list = append(list, e)
+		i += sort.Search(len(sorted)-i, func(j int) bool {
+			return !strings.HasPrefix(sorted[i+j].Name, dir)
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// encodeListToken encodes the position of the next entry to list along
+// with its name, which guards against tokens being replayed on a
+// different archive.
+func encodeListToken(pos int, name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(pos) + ":" + name))
+}
+
+func decodeListToken(sorted []*File, token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errs.Errorf("invalid list token")
+	}
+	s := string(raw)
+	colon := strings.IndexByte(s, ':')
+	if colon < 0 {
+		return 0, errs.Errorf("invalid list token")
+	}
+	pos, err := strconv.Atoi(s[:colon])
+	if err != nil || pos < 0 || pos >= len(sorted) || sorted[pos].Name != s[colon+1:] {
+		return 0, errs.Errorf("invalid list token")
+	}
+	return pos, nil
+}
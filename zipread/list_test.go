@@ -0,0 +1,186 @@
+package zipread
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestListPage(t *testing.T) {
+	var files []testZipFile
+	for _, name := range []string{"b/2", "a/1", "b/1", "c", "b/3", "b/4", "b/5"} {
+		files = append(files, testZipFile{Name: name, Method: Store})
+	}
+	z := openTestZip(t, buildTestZip(t, files...), nil)
+
+	var (
+		got   []string
+		token string
+		pages int
+	)
+	for {
+		page, next, err := z.ListPage("b/", token, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range page {
+			got = append(got, f.Name)
+		}
+		pages++
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	want := []string{"b/1", "b/2", "b/3", "b/4", "b/5"}
+	if len(got) != len(want) || pages != 3 {
+		t.Fatalf("got %v in %d pages, want %v in 3 pages", got, pages, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	if _, _, err := z.ListPage("", "bogus", 2); err == nil {
+		t.Error("expected error for invalid token")
+	}
+}
+
+func TestList(t *testing.T) {
+	var files []testZipFile
+	for _, name := range []string{"b/2", "a/1", "b/1", "c", "b/3", "b/4", "b/5"} {
+		files = append(files, testZipFile{Name: name, Method: Store})
+	}
+	z := openTestZip(t, buildTestZip(t, files...), nil)
+
+	for _, test := range []struct {
+		offset, limit int
+		want          []string
+		more          bool
+	}{
+		{0, 2, []string{"b/1", "b/2"}, true},
+		{3, 2, []string{"b/4", "b/5"}, false},
+		{4, 0, []string{"b/5"}, false},
+		{5, 2, nil, false},
+		{100, 2, nil, false},
+	} {
+		page, next, err := z.List(test.offset, test.limit, "b/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range page {
+			got = append(got, f.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") || (next != "") != test.more {
+			t.Errorf("List(%d, %d): got %v, %q, want %v", test.offset, test.limit, got, next, test.want)
+		}
+		if next != "" {
+			rest, _, err := z.ListPage("b/", next, 1)
+			if err != nil || len(rest) != 1 || rest[0].Name != "b/3" {
+				t.Errorf("continuing List(%d, %d): got %v, %v", test.offset, test.limit, rest, err)
+			}
+		}
+	}
+
+	if _, _, err := z.List(-1, 2, ""); err == nil {
+		t.Error("expected error for negative offset")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "b/c", Method: Store, Data: []byte("first")},
+		testZipFile{Name: "a", Method: Store, Data: []byte("a")},
+		testZipFile{Name: "b/c", Method: Store, Data: []byte("second")},
+		testZipFile{Name: "./d", Method: Store, Data: []byte("d")})
+	z := openTestZip(t, data, nil)
+
+	for name, want := range map[string]*File{
+		"a":   z.File[1],
+		"b/c": z.File[0],
+		"./d": z.File[3],
+		"d":   nil,
+		"b":   nil,
+	} {
+		if got := z.Lookup(name); got != want {
+			t.Errorf("Lookup(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if z.fileList != nil {
+		t.Error("Lookup built the fs index")
+	}
+
+	// What File.Open returns is an fs.File, whether or not it reads the
+	// source directly.
+	for _, opts := range []*Options{nil, {CRCPolicy: CRCOff}} {
+		rc, err := openTestZip(t, data, opts).Lookup("b/c").Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, ok := rc.(fs.File)
+		if !ok {
+			t.Fatalf("%T is not an fs.File", rc)
+		}
+		if fi, err := f.Stat(); err != nil || fi.Name() != "c" || fi.Size() != 5 {
+			t.Errorf("%T.Stat: got %v, %v", rc, fi, err)
+		}
+		rc.Close()
+	}
+}
+
+func TestReadDirPrefix(t *testing.T) {
+	var files []testZipFile
+	for _, name := range []string{
+		"assets/img/b.png", "assets/img/a.png", "assets/img/icons/x.svg",
+		"assets/img/icons/y.svg", "assets/img/sub/", "assets/img/sub/z",
+		"assets/img/a.png", "assets/css/site.css", "readme",
+	} {
+		files = append(files, testZipFile{Name: name, Method: Store})
+	}
+	z := openTestZip(t, buildTestZip(t, files...), &Options{DirMode: 0750})
+
+	for _, test := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"assets/img/", []string{"a.png", "b.png", "icons/", "sub/"}},
+		{"assets/img", []string{"a.png", "b.png", "icons/", "sub/"}},
+		{"assets/", []string{"css/", "img/"}},
+		{"", []string{"assets/", "readme"}},
+		{"missing/", nil},
+	} {
+		var got []string
+		for _, e := range z.ReadDirPrefix(test.prefix) {
+			name := e.Name()
+			if e.IsDir() {
+				name += "/"
+			}
+			got = append(got, name)
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("ReadDirPrefix(%q) = %v, want %v", test.prefix, got, test.want)
+		}
+	}
+
+	for _, e := range z.ReadDirPrefix("assets/img/") {
+		info, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch e.Name() {
+		case "icons":
+			if info.Mode() != fs.ModeDir|0750 || !info.ModTime().IsZero() {
+				t.Errorf("icons: got %v %v", info.Mode(), info.ModTime())
+			}
+		case "sub":
+			if !info.ModTime().Equal(z.Lookup("assets/img/sub/").Modified) {
+				t.Errorf("sub: got %v", info.ModTime())
+			}
+		}
+	}
+	if z.fileList != nil {
+		t.Error("ReadDirPrefix built the fs index")
+	}
+}
@@ -0,0 +1,91 @@
+// Package lzma registers a decompressor for LZMA compressed entries,
+// method 14, with zipread. It is a separate package to keep its dependency
+// out of programs that do not need it; import it for its side effect:
+//
+//	import _ "zipper/zipread/lzma"
+package lzma
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/ulikunitz/xz/lzma"
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// Method is the compression method of LZMA compressed entries.
+const Method uint16 = 14
+
+// propsLen is the length of the LZMA properties: the lc/lp/pb byte and
+// the little-endian dictionary size.
+const propsLen = 5
+
+func init() {
+	zipread.RegisterDecompressor(Method, NewReader)
+}
+
+// NewReader returns a reader decompressing the LZMA entry contents r. It
+// is the zipread.Decompressor registered for Method, and can be registered
+// with a single Reader instead where the package level registration is
+// not wanted.
+//
+// The contents start with a header holding the version of the encoder and
+// the LZMA properties, followed by the compressed stream. The stream is
+// decoded until its end marker, or until r is exhausted for encoders that
+// omit the marker, in which case zipread detects truncated contents by
+// their size.
+func NewReader(r io.Reader) io.ReadCloser {
+	lr, err := newReader(r)
+	if err != nil {
+		return errReader{err}
+	}
+	return io.NopCloser(&eofReader{r: lr})
+}
+
+func newReader(r io.Reader) (io.Reader, error) {
+	var header [4 + propsLen]byte
+	if _, err := io.ReadFull(r, header[:4]); err != nil {
+		return nil, errs.Errorf("lzma: reading header: %w", err)
+	}
+	if n := binary.LittleEndian.Uint16(header[2:4]); n != propsLen {
+		return nil, errs.Errorf("lzma: unsupported properties length %d", n)
+	}
+	if _, err := io.ReadFull(r, header[4:]); err != nil {
+		return nil, errs.Errorf("lzma: reading properties: %w", err)
+	}
+
+	// Rebuild the header of the .lzma format, which adds the uncompressed
+	// size, unknown here and so all ones.
+	var classic [propsLen + 8]byte
+	copy(classic[:], header[4:])
+	for i := propsLen; i < len(classic); i++ {
+		classic[i] = 0xff
+	}
+	lr, err := lzma.NewReader(io.MultiReader(bytes.NewReader(classic[:]), r))
+	if err != nil {
+		return nil, errs.Errorf("lzma: %w", err)
+	}
+	return lr, nil
+}
+
+// eofReader reports running out of input as the end of the stream, for
+// streams without an end marker.
+type eofReader struct{ r io.Reader }
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// errReader fails every Read with err.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+func (r errReader) Close() error             { return nil }
@@ -0,0 +1,37 @@
+package lzma
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestDecompress(t *testing.T) {
+	// testdata/lzma.zip was written by Python's zipfile, which emits the
+	// end marker.
+	var want bytes.Buffer
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&want, "line %d of an LZMA compressed entry\n", i)
+	}
+
+	z, err := zipread.Open(zipread.SourceFromFile("testdata/lzma.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := z.ReadFile("lzma.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("got %q, want %q", got, want.Bytes())
+	}
+}
+
+func TestBadHeader(t *testing.T) {
+	rc := NewReader(bytes.NewReader([]byte{9, 20, 4, 0, 1, 2, 3, 4}))
+	if _, err := rc.Read(make([]byte, 1)); err == nil {
+		t.Error("expected error for unsupported properties length")
+	}
+}
@@ -0,0 +1,224 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// AnalyzeOptions configures AnalyzeNesting. Zero fields select defaults.
+type AnalyzeOptions struct {
+	// MaxDepth bounds how deep nested archives are inspected.
+	// The default is 8.
+	MaxDepth int
+
+	// MaxArchives bounds the number of distinct nested archives opened.
+	// The default is 1000.
+	MaxArchives int
+
+	// MaxEntrySize bounds the uncompressed size of a compressed nested
+	// archive, which has to be decompressed into memory to be inspected.
+	// Stored nested archives are read in place regardless of their size.
+	// The default is 64 MiB.
+	MaxEntrySize int64
+}
+
+// A NestedArchive describes an archive and the archives found inside it.
+type NestedArchive struct {
+	Name    string // entry name in the parent archive, empty for the outermost
+	Entries int
+
+	// Size is the sum of the declared uncompressed sizes of the entries.
+	Size uint64
+
+	// ExpandedSize estimates the size of the archive's contents when it
+	// and every archive nested inside it are extracted recursively. Nested
+	// archives that were not inspected count with their declared size, so
+	// it is a lower bound if Truncated is set anywhere in the tree.
+	ExpandedSize uint64
+
+	Nested []*NestedArchive
+
+	// Truncated is set if some entries looking like archives were not
+	// inspected because a limit was reached.
+	Truncated bool
+}
+
+// MaxDepth returns the deepest level of nesting below a, zero if a
+// contains no archives.
+func (a *NestedArchive) MaxDepth() int {
+	depth := 0
+	for _, n := range a.Nested {
+		if d := n.MaxDepth() + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// AnalyzeNesting walks z looking for entries that are themselves ZIP
+// archives, identified by their leading signature, and recursively
+// reports their nesting and how far they would expand, within the limits
+// of opts. Identical nested archives, as recognized by their checksum and
+// sizes, are inspected once, so archives built from many copies of the
+// same member, like 42.zip, are analyzed quickly.
+func AnalyzeNesting(ctx context.Context, z *Reader, opts *AnalyzeOptions) (*NestedArchive, error) {
+	a := &analyzer{
+		maxDepth:     8,
+		maxArchives:  1000,
+		maxEntrySize: 64 << 20,
+		seen:         make(map[nestedKey]*NestedArchive),
+	}
+	if opts != nil {
+		if opts.MaxDepth > 0 {
+			a.maxDepth = opts.MaxDepth
+		}
+		if opts.MaxArchives > 0 {
+			a.maxArchives = opts.MaxArchives
+		}
+		if opts.MaxEntrySize > 0 {
+			a.maxEntrySize = opts.MaxEntrySize
+		}
+	}
+	return a.analyze(ctx, z, "", 0)
+}
+
+type nestedKey struct {
+	method           uint16
+	crc32            uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+}
+
+type analyzer struct {
+	maxDepth     int
+	maxArchives  int
+	maxEntrySize int64
+
+	opened int
+	seen   map[nestedKey]*NestedArchive
+}
+
+func (a *analyzer) analyze(ctx context.Context, z *Reader, name string, depth int) (*NestedArchive, error) {
+	result := &NestedArchive{Name: name, Entries: len(z.File)}
+	for _, f := range z.File {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.Size = addSaturating(result.Size, f.UncompressedSize64)
+
+		nested, truncated, err := a.nested(ctx, f, depth)
+		if err != nil {
+			return nil, err
+		}
+		if nested == nil {
+			result.Truncated = result.Truncated || truncated
+			result.ExpandedSize = addSaturating(result.ExpandedSize, f.UncompressedSize64)
+			continue
+		}
+		result.Nested = append(result.Nested, nested)
+		result.ExpandedSize = addSaturating(result.ExpandedSize, nested.ExpandedSize)
+	}
+	return result, nil
+}
+
+// nested returns the analysis of f if it is an archive, or reports
+// whether it looks like one but could not be inspected within the limits.
+func (a *analyzer) nested(ctx context.Context, f *File, depth int) (_ *NestedArchive, truncated bool, err error) {
+	if f.UncompressedSize64 < directoryEndLen || f.FileInfo().IsDir() {
+		return nil, false, nil
+	}
+	key := nestedKey{f.Method, f.CRC32, f.CompressedSize64, f.UncompressedSize64}
+	if known, ok := a.seen[key]; ok {
+		copied := *known
+		copied.Name = f.Name
+		return &copied, false, nil
+	}
+
+	source, isZip, err := a.open(ctx, f)
+	if err != nil || !isZip {
+		return nil, false, err
+	}
+	if source == nil || depth+1 > a.maxDepth || a.opened >= a.maxArchives {
+		return nil, true, nil
+	}
+	a.opened++
+
+	inner, err := Open(source)
+	if err != nil {
+		// Something that merely starts like an archive.
+		return nil, false, nil
+	}
+	result, err := a.analyze(ctx, inner, f.Name, depth+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if f.CRC32 != 0 {
+		a.seen[key] = result
+	}
+	return result, false, nil
+}
+
+// open checks whether f starts with a ZIP signature and, if so, returns a
+// Source serving its contents, or a nil Source if it is too large to
+// decompress.
+func (a *analyzer) open(ctx context.Context, f *File) (_ Source, isZip bool, err error) {
+	if f.Method == Store {
+		ra, err := f.ReaderAt(ctx)
+		if err == ErrEncrypted {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		var magic [4]byte
+		if _, err := ra.ReadAt(magic[:], 0); err != nil && err != io.EOF {
+			return nil, false, err
+		}
+		if !isZipMagic(magic[:]) {
+			return nil, false, nil
+		}
+		return SourceFromReaderAt(ra, ra.Size()), true, nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		if err == ErrAlgorithm || err == ErrEncrypted || err == ErrPassword {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+	var magic [4]byte
+	if _, err := io.ReadFull(rc, magic[:]); err != nil {
+		return nil, false, nil
+	}
+	if !isZipMagic(magic[:]) {
+		return nil, false, nil
+	}
+	if f.UncompressedSize64 > uint64(a.maxEntrySize) {
+		return nil, true, nil
+	}
+	data := make([]byte, 4, f.UncompressedSize64)
+	copy(data, magic[:])
+	buf := bytes.NewBuffer(data)
+	if _, err := io.Copy(buf, io.LimitReader(rc, a.maxEntrySize)); err != nil {
+		// Corrupt content is not an archive we can look into.
+		return nil, false, nil
+	}
+	return SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())), true, nil
+}
+
+func isZipMagic(b []byte) bool {
+	return bytes.Equal(b, []byte("PK\x03\x04")) || bytes.Equal(b, []byte("PK\x05\x06"))
+}
+
+func addSaturating(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
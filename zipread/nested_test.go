@@ -0,0 +1,44 @@
+package zipread
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAnalyzeNesting(t *testing.T) {
+	// Build a three level bomb in the style of 42.zip: each level holds
+	// four copies of the level below.
+	level := buildTestZip(t, testZipFile{Name: "leaf", Method: Deflate, Data: make([]byte, 1<<20)})
+	for i := 0; i < 2; i++ {
+		var files []testZipFile
+		for j, method := range []uint16{Deflate, Deflate, Store, Store} {
+			files = append(files, testZipFile{Name: string(rune('a' + j)), Method: method, Data: level})
+		}
+		level = buildTestZip(t, files...)
+	}
+	z := openTestZip(t, buildTestZip(t,
+		testZipFile{Name: "bomb.zip", Method: Deflate, Data: level},
+		testZipFile{Name: "plain", Method: Store, Data: []byte("not an archive")}), nil)
+
+	got, err := AnalyzeNesting(context.Background(), z, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MaxDepth() != 3 {
+		t.Errorf("MaxDepth()=%d, want 3", got.MaxDepth())
+	}
+	if want := uint64(16<<20 + len("not an archive")); got.ExpandedSize != want {
+		t.Errorf("ExpandedSize=%d, want %d", got.ExpandedSize, want)
+	}
+	if got.Truncated {
+		t.Error("unexpectedly truncated")
+	}
+
+	limited, err := AnalyzeNesting(context.Background(), z, &AnalyzeOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limited.MaxDepth() != 1 || !limited.Nested[0].Truncated {
+		t.Errorf("MaxDepth 1: got depth %d, truncated %v", limited.MaxDepth(), limited.Nested[0].Truncated)
+	}
+}
@@ -0,0 +1,221 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/zeebo/errs/v2"
+)
+
+const (
+	// coalesceGap is the largest gap between two entries that OpenMany
+	// fetches along with them rather than issuing another request.
+	coalesceGap = 64 << 10
+
+	// coalesceMax bounds the size of a single coalesced request.
+	coalesceMax = 16 << 20
+)
+
+// OpenMany opens the named files for reading, returning a ReadCloser for
+// each name in order. Rather than issuing a ranged request per entry like
+// File.Open, the entries are sorted by offset and neighbouring ones are
+// fetched together: each group is requested from the source once, on the
+// first read from any of its entries, and held in memory until all of its
+// readers are closed. Entries larger than the group size limit are opened
+// individually.
+func (z *Reader) OpenMany(ctx context.Context, names []string) (_ []io.ReadCloser, err error) {
+	files := make([]*File, len(names))
+	for i, name := range names {
+		f, err := z.OpenLookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.checkFeatures(); err != nil {
+			return nil, err
+		}
+		if z.decompressor(f.Method) == nil {
+			return nil, ErrAlgorithm
+		}
+		files[i] = f
+	}
+
+	rcs := make([]io.ReadCloser, len(names))
+	defer func() {
+		if err != nil {
+			for _, rc := range rcs {
+				if rc != nil {
+					err = errs.Combine(err, rc.Close())
+				}
+			}
+		}
+	}()
+
+	type extent struct {
+		i          int
+		start, end int64
+	}
+	bounds := z.entryBounds()
+	extents := make([]extent, 0, len(files))
+	for i, f := range files {
+		start := f.headerOffset
+		end := start + fileHeaderLen + int64(len(f.RawName)) + math.MaxUint16 + int64(f.CompressedSize64)
+		if j := sort.Search(len(bounds), func(j int) bool { return bounds[j] > start }); j < len(bounds) && bounds[j] < end {
+			end = bounds[j]
+		}
+		if end-start > coalesceMax {
+			if rcs[i], err = f.Open(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		extents = append(extents, extent{i: i, start: start, end: end})
+	}
+	sort.Slice(extents, func(a, b int) bool { return extents[a].start < extents[b].start })
+
+	var region *sharedRegion
+	for _, e := range extents {
+		if region == nil || e.start-(region.off+region.length) > coalesceGap || e.end-region.off > coalesceMax {
+			region = &sharedRegion{ctx: ctx, source: z.source, off: e.start}
+		}
+		if end := e.end - region.off; end > region.length {
+			region.length = end
+		}
+		region.refs++
+		rcs[e.i] = files[e.i].openShared(region)
+	}
+	return rcs, nil
+}
+
+// entryBounds returns the sorted offsets at which entries start, followed
+// by the archive size, bounding how far each entry can extend.
+func (z *Reader) entryBounds() []int64 {
+	bounds := make([]int64, 0, len(z.File)+1)
+	for _, f := range z.File {
+		bounds = append(bounds, f.headerOffset)
+	}
+	bounds = append(bounds, z.size)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return bounds
+}
+
+// openShared returns a reader for f whose local file header and body are
+// served from region.
+func (f *File) openShared(region *sharedRegion) io.ReadCloser {
+	var once sync.Once
+	lazy := &lazyReadCloser{open: func() (io.ReadCloser, error) {
+		buf, err := region.load()
+		if err != nil {
+			return nil, err
+		}
+		if f.headerOffset-region.off > int64(len(buf)) {
+			return nil, f.localHeaderError("beyond the end of the archive")
+		}
+		br := bytes.NewReader(buf[f.headerOffset-region.off:])
+		if err := f.validateFileHeader(br); err != nil {
+			return nil, err
+		}
+		if int64(br.Len()) < int64(f.CompressedSize64) {
+			return nil, f.localHeaderError("entry data extends into the next entry")
+		}
+		plain, err := f.decrypt(region.ctx, io.LimitReader(br, int64(f.CompressedSize64)))
+		if err != nil {
+			return nil, err
+		}
+		return f.zip.decompressor(f.Method)(plain), nil
+	}}
+	return &checksumReader{
+		rc: struct {
+			io.Reader
+			io.Closer
+		}{
+			Reader: lazy,
+			Closer: closerFunc(func() error {
+				err := lazy.Close()
+				once.Do(region.release)
+				return err
+			}),
+		},
+		hash:   crc32.NewIEEE(),
+		f:      f,
+		policy: f.zip.opts.CRCPolicy,
+	}
+}
+
+// sharedRegion is a range of the source fetched once on behalf of several
+// readers.
+type sharedRegion struct {
+	ctx    context.Context
+	source Source
+	off    int64
+	length int64
+
+	mu   sync.Mutex
+	refs int
+	done bool
+	buf  []byte
+	err  error
+}
+
+func (g *sharedRegion) load() ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.done {
+		g.done = true
+		g.buf, g.err = g.fetch()
+	}
+	return g.buf, g.err
+}
+
+func (g *sharedRegion) fetch() (_ []byte, err error) {
+	rr, err := g.source.Range(g.ctx, g.off, g.length)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errs.Combine(err, rr.Close()) }()
+	buf := make([]byte, g.length)
+	n, err := io.ReadFull(rr, buf)
+	if err == io.ErrUnexpectedEOF {
+		// The region may extend past the end of a short source; the
+		// entries themselves are checked when they are opened.
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// release drops a reference, freeing the fetched data with the last one.
+func (g *sharedRegion) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.refs--; g.refs == 0 {
+		g.done, g.buf, g.err = true, nil, errReadAfterClose
+	}
+}
+
+// lazyReadCloser opens the underlying reader on first use.
+type lazyReadCloser struct {
+	open func() (io.ReadCloser, error)
+	rc   io.ReadCloser
+	err  error
+}
+
+func (l *lazyReadCloser) Read(p []byte) (int, error) {
+	if l.rc == nil && l.err == nil {
+		l.rc, l.err = l.open()
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.rc.Read(p)
+}
+
+func (l *lazyReadCloser) Close() error {
+	if l.rc == nil {
+		return nil
+	}
+	return l.rc.Close()
+}
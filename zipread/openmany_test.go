@@ -0,0 +1,143 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestOpenMany(t *testing.T) {
+	var files []testZipFile
+	for i := 0; i < 50; i++ {
+		method := Deflate
+		if i%3 == 0 {
+			method = Store
+		}
+		files = append(files, testZipFile{
+			Name:   fmt.Sprintf("f%02d", i),
+			Method: method,
+			Data:   bytes.Repeat([]byte(fmt.Sprintf("entry %d\n", i)), i*10+1),
+		})
+	}
+	data := buildTestZip(t, files...)
+	src := &countingSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}
+	z, err := Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"f40", "f03", "f17", "f18", "f03", "f49"}
+	src.calls = 0
+	rcs, err := z.OpenMany(context.Background(), names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rc := range rcs {
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("%s: %v", names[i], err)
+		}
+		var n int
+		fmt.Sscanf(names[i], "f%d", &n)
+		if !bytes.Equal(got, files[n].Data) {
+			t.Fatalf("%s: content mismatch", names[i])
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if src.calls != 1 {
+		t.Fatalf("got %d requests, want 1", src.calls)
+	}
+
+	if _, err := z.OpenMany(context.Background(), []string{"f01", "missing"}); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing entry: err=%v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestSequentialReader(t *testing.T) {
+	var files []testZipFile
+	for i := 0; i < 20; i++ {
+		method := Deflate
+		if i%2 == 0 {
+			method = Store
+		}
+		files = append(files, testZipFile{
+			Name:   fmt.Sprintf("f%02d", i),
+			Method: method,
+			Data:   bytes.Repeat([]byte(fmt.Sprintf("entry %d\n", i)), i*20+1),
+		})
+	}
+	files = append(files, testZipFile{Name: "gap", Method: Store, Data: bytes.Repeat([]byte{0}, 2*coalesceGap)})
+	files = append(files, testZipFile{Name: "last", Method: Deflate, Data: []byte("last")})
+	data := buildTestZip(t, files...)
+	src := &countingSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}
+	z, err := Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string][]byte)
+	for _, f := range files {
+		byName[f.Name] = f.Data
+	}
+
+	read := func(sr *SequentialReader, partial bool) (names []string) {
+		t.Helper()
+		for {
+			f, err := sr.Next()
+			if err == io.EOF {
+				return names
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, f.Name)
+			if partial && len(names)%3 == 1 {
+				// Leave the rest for Next to skip.
+				if _, err := io.ReadFull(sr, make([]byte, 1)); err != nil {
+					t.Fatalf("%s: %v", f.Name, err)
+				}
+				continue
+			}
+			got, err := io.ReadAll(sr)
+			if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+			if !bytes.Equal(got, byName[f.Name]) {
+				t.Fatalf("%s: content mismatch", f.Name)
+			}
+		}
+	}
+
+	src.calls = 0
+	sr := z.NewSequentialReader(context.Background(), nil)
+	if got := read(sr, true); len(got) != len(files) {
+		t.Fatalf("read %d entries, want %d", len(got), len(files))
+	}
+	if err := sr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if src.calls != 1 {
+		t.Fatalf("all entries: got %d requests, want 1", src.calls)
+	}
+
+	// Skipping the large entry leaves a gap too wide to read through.
+	var subset []*File
+	for _, name := range []string{"last", "f03", "f01", "f02"} {
+		subset = append(subset, z.Lookup(name))
+	}
+	src.calls = 0
+	sr = z.NewSequentialReader(context.Background(), subset)
+	if got, want := read(sr, false), []string{"f01", "f02", "f03", "last"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got order %v, want %v", got, want)
+	}
+	if src.calls != 2 {
+		t.Fatalf("subset: got %d requests, want 2", src.calls)
+	}
+	if _, err := sr.Read(make([]byte, 1)); err == nil {
+		t.Fatal("read after the last entry succeeded")
+	}
+}
@@ -0,0 +1,226 @@
+package zipread
+
+import (
+	"context"
+	"io/fs"
+)
+
+// Options configures how a Reader opens and serves an archive.
+// The zero value selects the default behavior.
+type Options struct {
+	// OverlapHeaderValidation, when set, makes File.Open validate again
+	// the local file header of entries whose header length is already
+	// known from a previous open, for sources whose contents may change,
+	// without delaying the first byte: the header and the body are
+	// requested in one exact range, and the body is decompressed while the
+	// header is checked in the background. A mismatch is reported by Read
+	// at the end of the entry, or by Close if the entry is closed before.
+	// Without it, only the body of such entries is requested.
+	OverlapHeaderValidation bool
+
+	// ParseWorkers, when at least 2, makes Open read the whole central
+	// directory into memory, split it into records and decode them on that
+	// many goroutines, which shortens opening archives with millions of
+	// entries. The result is the same as when parsing in sequence.
+	ParseWorkers int
+
+	// CompactDirectory reduces the memory held per entry, for archives
+	// with millions of them: the names and comments of all entries share
+	// a single allocation, and File.Extra is dropped once parsed, except
+	// for encrypted entries, whose encryption scheme is described there.
+	CompactDirectory bool
+
+	// OpenProgress, if non-nil, is called by Open after each central
+	// directory record has been parsed, so interactive tools can render
+	// listings progressively instead of waiting for Open to return.
+	OpenProgress func(DirectoryProgress)
+
+	// NameDecoder, if non-nil, is applied to the name and comment of every
+	// entry detected as NonUTF8, turning them into UTF-8. The undecoded
+	// values remain available as File.RawName and File.RawComment. If the
+	// decoder fails, the raw values are kept. DecodeCP437 decodes the
+	// encoding mandated by the ZIP specification; decoders for other
+	// legacy encodings can be built from golang.org/x/text.
+	NameDecoder func(raw []byte) (string, error)
+
+	// CRCPolicy selects how File.Open verifies entry checksums. With
+	// CRCOff, File.Open serves stored entries straight from the source
+	// range, unless ReadAhead or ParallelFetch apply to them.
+	CRCPolicy CRCPolicy
+
+	// RetryChecksum, when set, makes a checksum mismatch trigger one fresh
+	// fetch and decompression of the entry, bypassing source caches (see
+	// UncachedSource). The resulting *ChecksumError then tells transient
+	// transport corruption apart from a corrupt archive.
+	RetryChecksum bool
+
+	// ReadAhead, when positive, makes File.Open fetch the entry from the
+	// source in a separate goroutine, in chunks of this many bytes, one
+	// chunk ahead of decompression. On high-latency sources decompression
+	// then no longer stalls on every refill. Each open entry holds up to
+	// two chunks.
+	ReadAhead int64
+
+	// BufferSize sets the size of the buffer each open entry reads the
+	// source through, 4096 bytes by default. Larger buffers mean fewer,
+	// larger reads from sources where each call is costly. Buffers are
+	// pooled by the Reader and reused once an entry is closed.
+	BufferSize int
+
+	// TailWindow, when positive, makes Open fetch this many bytes from the
+	// end of the archive in a single request and locate the end of central
+	// directory record, the zip64 records and as much of the central
+	// directory as fits from that buffer, instead of issuing a round trip
+	// for each. 128 KiB covers the largest possible comment and the
+	// directories of most small archives. The buffer is released once Open
+	// returns; entries are always read from the source.
+	TailWindow int64
+
+	// ParallelFetch, when at least 2, makes File.Open fetch entries larger
+	// than ParallelChunkSize, 8 MiB by default, as that many concurrent
+	// ranges of that size, reassembled in order ahead of decompression.
+	// Sources limiting the bandwidth of each connection, like object
+	// stores, then deliver large entries much faster. Up to ParallelFetch
+	// chunks are held in memory per open entry.
+	ParallelFetch     int
+	ParallelChunkSize int64
+
+	// SOZip, when set, makes File.OpenRange and Seek look for a SOZip
+	// index accompanying deflate entries and use it to start decompressing
+	// at the chunk containing the requested offset, instead of at the
+	// beginning of the entry. Looking for the index may cost a round trip
+	// per entry; the result is cached.
+	SOZip bool
+
+	// MaxEntries, MaxDirectorySize and MaxTotalUncompressed, when
+	// positive, bound the number of entries, the size of the central
+	// directory and the sum of the entries' declared uncompressed sizes.
+	// Open fails with a *LimitError as soon as an archive exceeds one, so
+	// untrusted archives cannot make it allocate unbounded memory.
+	MaxEntries           int
+	MaxDirectorySize     int64
+	MaxTotalUncompressed int64
+
+	// MaxNameLength and MaxPathDepth, when positive, bound the length in
+	// bytes of each entry name and the number of elements in its path.
+	// Open fails with a *LimitError naming the first offending entry.
+	MaxNameLength int
+	MaxPathDepth  int
+
+	// MaxCompressionRatio, when positive, makes reading an entry fail with
+	// ErrBomb once it has produced more than this many bytes per
+	// compressed byte consumed.
+	MaxCompressionRatio float64
+
+	// UncompressedSizeMargin, when positive, makes reading an entry fail
+	// with ErrBomb once it has produced more than this many bytes beyond
+	// its declared UncompressedSize64, rather than only noticing the
+	// mismatch at the end of the stream.
+	UncompressedSizeMargin int64
+
+	// RejectOverlaps makes Open fail with an *OverlapError if entries'
+	// data regions overlap each other or the central directory, see
+	// Reader.CheckOverlaps.
+	RejectOverlaps bool
+
+	// VerifyLocalName makes File.Open compare the name in each entry's
+	// local file header byte for byte with the central directory, rather
+	// than only its length, so that an archive cannot present different
+	// names to tools reading either structure. VerifyLocalMethod compares
+	// the compression method and general purpose flags as well as the
+	// name. A mismatch fails with a *FormatError.
+	VerifyLocalName   bool
+	VerifyLocalMethod bool
+
+	// DirMode sets the permission bits the fs.FS view reports for
+	// directories that have no entry of their own in the archive and are
+	// inferred from the names below them. The default is 0555. Their
+	// modification time is that of the newest entry below them.
+	// Directories with an entry report the entry's mode.
+	DirMode fs.FileMode
+
+	// CaseInsensitive makes the fs.FS view and OpenLookup resolve names
+	// that differ from an entry's only in case, as archives created on
+	// Windows and macOS are often addressed. An exact match is always
+	// preferred; among entries whose names differ only in case, the one
+	// that sorts first by its exact name is found.
+	CaseInsensitive bool
+
+	// NameNormalizer, if non-nil, is applied to entry names and to the
+	// names looked up through the fs.FS view and OpenLookup before they
+	// are compared, so that names match regardless of their Unicode
+	// normalization form. Archives created on macOS use NFD, while names
+	// typed by users are usually NFC; norm.NFC.String from
+	// golang.org/x/text/unicode/norm is the usual choice. An exact match
+	// is always preferred, and conflicts are resolved as for
+	// CaseInsensitive.
+	NameNormalizer func(string) string
+
+	// EscapeNames makes the fs.FS view list entries whose names are not
+	// valid paths even after normalization, such as empty names, names
+	// containing NUL or names that are not valid UTF-8, under the escaped
+	// names returned by EscapeName instead of leaving them unreachable.
+	EscapeNames bool
+
+	// HideJunk hides entries that are platform metadata rather than
+	// content from the fs.FS view: __MACOSX directories, .DS_Store,
+	// Thumbs.db and desktop.ini files, "_$folder$" markers, and directory
+	// entries with nothing below them. They remain in Reader.File.
+	HideJunk bool
+
+	// Password decrypts entries encrypted with the traditional PKWARE
+	// scheme, known as ZipCrypto. Without it, or a PasswordProvider,
+	// File.Open fails with ErrEncrypted for encrypted entries; with a wrong
+	// one, it fails with ErrPassword, or rarely, from Read with ErrChecksum.
+	Password string
+
+	// PasswordProvider, if non-nil, is called for each encrypted entry
+	// being opened to get its password, taking precedence over Password,
+	// so that entries can use different passwords, looked up from a
+	// secret store or asked for interactively. An empty password makes
+	// File.Open fail with ErrEncrypted, and an error is returned as is.
+	// It may be called concurrently, and again for every Open of an entry.
+	PasswordProvider func(ctx context.Context, fh *FileHeader) (string, error)
+
+	// InsecurePaths selects what Open does about entries with absolute
+	// names, ".." traversal, backslashes or other names that are unsafe
+	// to use as file system paths, see Reader.InsecurePaths.
+	InsecurePaths PathPolicy
+}
+
+// CRCPolicy controls checksum verification of entry contents.
+type CRCPolicy int
+
+const (
+	// CRCStrict fails the final Read with ErrChecksum on a mismatch.
+	CRCStrict CRCPolicy = iota
+	// CRCOff skips hashing entirely, for callers that verify content
+	// some other way.
+	CRCOff
+	// CRCReport delivers the whole content and reports a mismatch as
+	// ErrChecksum from Close instead.
+	CRCReport
+)
+
+// PathPolicy controls how Open treats insecure entry names.
+type PathPolicy int
+
+const (
+	// PathsAllow accepts insecure names. The fs.FS view and Extract
+	// normalize them into the archive root.
+	PathsAllow PathPolicy = iota
+	// PathsReport makes Open return the Reader along with
+	// ErrInsecurePath, like archive/zip does.
+	PathsReport
+	// PathsReject makes Open fail with ErrInsecurePath.
+	PathsReject
+)
+
+// DirectoryProgress reports how far the central directory parse has got.
+type DirectoryProgress struct {
+	Entries      int    // records parsed so far
+	TotalEntries uint64 // records declared by the end of directory record
+	Bytes        int64  // directory bytes parsed so far
+	TotalBytes   int64  // directory size declared by the end of directory record
+	Name         string // name of the most recently parsed entry
+}
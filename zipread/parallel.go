@@ -0,0 +1,123 @@
+package zipread
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// defaultParallelChunk is the size of the ranges requested concurrently
+// when Options.ParallelFetch is set without Options.ParallelChunkSize.
+const defaultParallelChunk = 8 << 20
+
+// parallelChunk returns the size of the ranges f is fetched in, or zero if
+// it is fetched as a single range.
+func (f *File) parallelChunk() int64 {
+	if f.zip.opts.ParallelFetch < 2 {
+		return 0
+	}
+	chunk := f.zip.opts.ParallelChunkSize
+	if chunk <= 0 {
+		chunk = defaultParallelChunk
+	}
+	if int64(f.CompressedSize64) <= chunk {
+		return 0
+	}
+	return chunk
+}
+
+// openParallel resolves the offset of the content body of f, validating
+// its local header, and returns a reader fetching the body as concurrent
+// ranges of chunk bytes.
+func (f *File) openParallel(chunk int64) (rr io.ReadCloser, data *bufio.Reader, err error) {
+	ctx := context.TODO()
+	dataOffset, err := f.resolveDataOffset(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	rr = newParallelRange(ctx, f.zips, dataOffset, int64(f.CompressedSize64), chunk, f.zip.opts.ParallelFetch)
+	data, rr = f.zip.bufferedRange(rr)
+	return rr, data, nil
+}
+
+// parallelRange reads a range of a source as consecutive chunks, keeping
+// up to n of them in flight and delivering them in order.
+type parallelRange struct {
+	ctx    context.Context
+	cancel func()
+	source Source
+	chunk  int64
+	n      int
+
+	next, end int64          // range left to request
+	pending   []chan fetched // in flight, in order
+
+	cur []byte
+	err error
+}
+
+type fetched struct {
+	data []byte
+	err  error
+}
+
+func newParallelRange(ctx context.Context, source Source, offset, length, chunk int64, n int) *parallelRange {
+	ctx, cancel := context.WithCancel(ctx)
+	return &parallelRange{
+		ctx:    ctx,
+		cancel: cancel,
+		source: source,
+		chunk:  chunk,
+		n:      n,
+		next:   offset,
+		end:    offset + length,
+	}
+}
+
+// fill starts requests until n are in flight or the range is exhausted.
+func (p *parallelRange) fill() {
+	for len(p.pending) < p.n && p.next < p.end {
+		length := p.chunk
+		if p.end-p.next < length {
+			length = p.end - p.next
+		}
+		ch := make(chan fetched, 1)
+		go func(offset, length int64) {
+			data, err := readRange(p.ctx, p.source, offset, length)
+			ch <- fetched{data: data, err: err}
+		}(p.next, length)
+		p.pending = append(p.pending, ch)
+		p.next += length
+	}
+}
+
+func (p *parallelRange) Read(b []byte) (int, error) {
+	for len(p.cur) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		p.fill()
+		if len(p.pending) == 0 {
+			p.err = io.EOF
+			continue
+		}
+		r := <-p.pending[0]
+		p.pending = p.pending[1:]
+		p.cur, p.err = r.data, r.err
+	}
+	n := copy(b, p.cur)
+	p.cur = p.cur[n:]
+	return n, nil
+}
+
+// Close cancels the requests in flight and waits for them to finish.
+// Their results, errors included, are of no interest anymore.
+func (p *parallelRange) Close() error {
+	p.cancel()
+	for _, ch := range p.pending {
+		<-ch
+	}
+	p.pending = nil
+	p.cur, p.err = nil, errReadAfterClose
+	return nil
+}
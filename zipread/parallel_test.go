@@ -0,0 +1,101 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+)
+
+// concurrencySource records the most ranges it served at once.
+type concurrencySource struct {
+	Source
+
+	mu           sync.Mutex
+	active, most int
+	release      chan struct{}
+}
+
+func (s *concurrencySource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	s.mu.Lock()
+	s.active++
+	if s.active > s.most {
+		s.most = s.active
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return s.Source.Range(ctx, offset, length)
+}
+
+func TestParallelFetch(t *testing.T) {
+	content := deflateTestData(100000)
+	data := buildTestZip(t,
+		testZipFile{Name: "stored", Method: Store, Data: content},
+		testZipFile{Name: "deflated", Method: Deflate, Data: content},
+		testZipFile{Name: "small", Method: Store, Data: []byte("small")})
+	source := &concurrencySource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}
+	z, err := OpenWithOptions(source, &Options{ParallelFetch: 4, ParallelChunkSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range z.File[:2] {
+		got, err := readAllFile(f)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("%s: content mismatch", f.Name)
+		}
+	}
+	if got, err := readAllFile(z.File[2]); err != nil || string(got) != "small" {
+		t.Fatalf("small: got %q, %v", got, err)
+	}
+
+	// Chunks are requested together, and closing early cancels them.
+	source.mu.Lock()
+	source.most = 0
+	source.release = make(chan struct{})
+	source.mu.Unlock()
+	rc, err := z.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := rc.Read(make([]byte, 1))
+		done <- err
+	}()
+	for {
+		source.mu.Lock()
+		active := source.active
+		source.mu.Unlock()
+		if active == 4 {
+			break
+		}
+	}
+	close(source.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if source.active != 0 || source.most != 4 {
+		t.Errorf("got %d requests active after Close, at most %d at once, want 0 and 4", source.active, source.most)
+	}
+}
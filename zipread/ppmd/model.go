@@ -0,0 +1,1039 @@
+package ppmd
+
+import "encoding/binary"
+
+// The model is PPMd variant I revision 1 as written by Dmitry Shkarin and
+// adapted by Igor Pavlov for 7-Zip. The encoder and decoder must evolve
+// the model identically, including when its memory runs out, so the model
+// lives in a single byte slice laid out like the C structures, with the
+// same allocator carving contexts and states out of it. References are
+// offsets into that slice; offset zero is never used.
+//
+// A context is 12 bytes: the number of states minus one, flags, the sum
+// of the frequencies, the offset of the states and the offset of the
+// suffix context. A context with a single state holds it in place of the
+// sum and the states offset. A state is 6 bytes: the symbol, its
+// frequency and the offset of its successor, which is either a context or
+// a position in the text area.
+
+const (
+	maxOrder   = 16
+	unitSize   = 12
+	stateSize  = 6
+	maxFreq    = 124
+	intBits    = 7
+	periodBits = 7
+	binScale   = 1 << (intBits + periodBits)
+	numIndexes = 4 + 4 + 4 + 26
+	emptyNode  = 0xFFFFFFFF
+
+	// restoreRestart restarts the model once its memory runs out. The
+	// other methods cut off the model instead; 7-Zip does not implement
+	// freezing it, method 2, and cuts it off as well.
+	restoreRestart = 0
+)
+
+var (
+	expEscape  = [16]byte{25, 14, 9, 7, 5, 5, 4, 4, 4, 3, 3, 3, 2, 2, 2, 2}
+	initBinEsc = [8]uint16{0x3CDD, 0x1F3F, 0x59BF, 0x48F3, 0x64A1, 0x5ABC, 0x6632, 0x6051}
+)
+
+// see is a secondary escape estimation context.
+type see struct {
+	summ  uint16
+	shift byte
+	count byte
+}
+
+func (s *see) update() {
+	if s.shift < periodBits {
+		s.count--
+		if s.count == 0 {
+			s.summ <<= 1
+			s.count = byte(3 << s.shift)
+			s.shift++
+		}
+	}
+}
+
+type model struct {
+	mem         []byte
+	size        uint32
+	alignOffset uint32
+
+	minContext, maxContext uint32
+	foundState             uint32
+	orderFall              uint32
+	initEsc                uint32
+	prevSuccess            uint32
+	maxOrder               uint32
+	restoreMethod          int
+	runLength, initRL      int32
+
+	glueCount                        uint32
+	text, unitsStart, loUnit, hiUnit uint32
+
+	indx2Units [numIndexes]byte
+	units2Indx [128]byte
+	freeList   [numIndexes]uint32
+	stamps     [numIndexes]uint32
+	ns2BSIndx  [256]byte
+	ns2Indx    [260]byte
+	dummySee   see
+	see        [24][32]see
+	binSumm    [25][64]uint16
+}
+
+func newModel(p Params) *model {
+	m := new(model)
+	k := 0
+	for i := 0; i < numIndexes; i++ {
+		step := 4
+		if i < 12 {
+			step = i>>2 + 1
+		}
+		for ; step > 0; step-- {
+			m.units2Indx[k] = byte(i)
+			k++
+		}
+		m.indx2Units[i] = byte(k)
+	}
+	m.ns2BSIndx[0] = 0 << 1
+	m.ns2BSIndx[1] = 1 << 1
+	for i := 2; i < 11; i++ {
+		m.ns2BSIndx[i] = 2 << 1
+	}
+	for i := 11; i < 256; i++ {
+		m.ns2BSIndx[i] = 3 << 1
+	}
+	for i := 0; i < 5; i++ {
+		m.ns2Indx[i] = byte(i)
+	}
+	for i, n, step := 5, 5, 1; i < 260; i++ {
+		m.ns2Indx[i] = byte(n)
+		if step--; step == 0 {
+			n++
+			step = n - 4
+		}
+	}
+
+	m.size = p.MemSize
+	m.alignOffset = 4 - p.MemSize&3
+	m.mem = make([]byte, m.alignOffset+m.size+unitSize)
+	m.maxOrder = uint32(p.Order)
+	m.restoreMethod = p.Restore
+	m.restartModel()
+	m.dummySee = see{shift: periodBits, count: 64}
+	return m
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (m *model) u16(o uint32) uint16       { return binary.LittleEndian.Uint16(m.mem[o:]) }
+func (m *model) setU16(o uint32, v uint16) { binary.LittleEndian.PutUint16(m.mem[o:], v) }
+func (m *model) u32(o uint32) uint32       { return binary.LittleEndian.Uint32(m.mem[o:]) }
+func (m *model) setU32(o uint32, v uint32) { binary.LittleEndian.PutUint32(m.mem[o:], v) }
+
+// Context fields.
+
+func (m *model) numStats(c uint32) uint32       { return uint32(m.mem[c]) }
+func (m *model) setNumStats(c uint32, v uint32) { m.mem[c] = byte(v) }
+func (m *model) flags(c uint32) uint32          { return uint32(m.mem[c+1]) }
+func (m *model) setFlags(c uint32, v uint32)    { m.mem[c+1] = byte(v) }
+func (m *model) summFreq(c uint32) uint32       { return uint32(m.u16(c + 2)) }
+func (m *model) setSummFreq(c uint32, v uint32) { m.setU16(c+2, uint16(v)) }
+func (m *model) stats(c uint32) uint32          { return m.u32(c + 4) }
+func (m *model) setStats(c uint32, v uint32)    { m.setU32(c+4, v) }
+func (m *model) suffix(c uint32) uint32         { return m.u32(c + 8) }
+func (m *model) setSuffix(c uint32, v uint32)   { m.setU32(c+8, v) }
+func oneState(c uint32) uint32                  { return c + 2 }
+
+// State fields.
+
+func (m *model) symbol(s uint32) uint32          { return uint32(m.mem[s]) }
+func (m *model) freq(s uint32) uint32            { return uint32(m.mem[s+1]) }
+func (m *model) setFreq(s uint32, v uint32)      { m.mem[s+1] = byte(v) }
+func (m *model) successor(s uint32) uint32       { return m.u32(s + 2) }
+func (m *model) setSuccessor(s uint32, v uint32) { m.setU32(s+2, v) }
+func (m *model) copyState(dst, src uint32)       { copy(m.mem[dst:dst+stateSize], m.mem[src:src+stateSize]) }
+
+func (m *model) swapStates(a, b uint32) {
+	var tmp [stateSize]byte
+	copy(tmp[:], m.mem[a:])
+	copy(m.mem[a:a+stateSize], m.mem[b:b+stateSize])
+	copy(m.mem[b:b+stateSize], tmp[:])
+}
+
+// The allocator hands out blocks of units from free lists indexed by
+// size. Free blocks are nodes of 12 bytes: a stamp marking them free, the
+// next node in their list and their number of units.
+
+func (m *model) u2i(nu uint32) int    { return int(m.units2Indx[nu-1]) }
+func (m *model) i2u(indx int) uint32  { return uint32(m.indx2Units[indx]) }
+func (m *model) u2b(nu uint32) uint32 { return nu * unitSize }
+
+func (m *model) copyUnits(dst, src, nu uint32) {
+	copy(m.mem[dst:dst+m.u2b(nu)], m.mem[src:src+m.u2b(nu)])
+}
+
+func (m *model) insertNode(node uint32, indx int) {
+	m.setU32(node, emptyNode)
+	m.setU32(node+4, m.freeList[indx])
+	m.setU32(node+8, m.i2u(indx))
+	m.freeList[indx] = node
+	m.stamps[indx]++
+}
+
+func (m *model) removeNode(indx int) uint32 {
+	node := m.freeList[indx]
+	m.freeList[indx] = m.u32(node + 4)
+	m.stamps[indx]--
+	return node
+}
+
+func (m *model) splitBlock(ptr uint32, oldIndx, newIndx int) {
+	nu := m.i2u(oldIndx) - m.i2u(newIndx)
+	ptr += m.u2b(m.i2u(newIndx))
+	i := m.u2i(nu)
+	if m.i2u(i) != nu {
+		i--
+		k := m.i2u(i)
+		m.insertNode(ptr+m.u2b(k), int(nu-k-1))
+	}
+	m.insertNode(ptr, i)
+}
+
+func (m *model) glueFreeBlocks() {
+	m.glueCount = 1 << 13
+	m.stamps = [numIndexes]uint32{}
+
+	// The root context is always at the top unit, so no guard is needed
+	// at the end, but all units up to loUnit can be free.
+	if m.loUnit != m.hiUnit {
+		m.setU32(m.loUnit, 0)
+	}
+
+	// Glue neighbouring free blocks, collecting them in a single list.
+	// prev is the next field to link the following block into, zero for
+	// head.
+	var head, prev uint32
+	link := func(v uint32) {
+		if prev == 0 {
+			head = v
+		} else {
+			m.setU32(prev, v)
+		}
+	}
+	for i := 0; i < numIndexes; i++ {
+		next := m.freeList[i]
+		m.freeList[i] = 0
+		for next != 0 {
+			node := next
+			if m.u32(node+8) != 0 {
+				link(next)
+				prev = node + 4
+				for {
+					node2 := node + m.u2b(m.u32(node+8))
+					if m.u32(node2) != emptyNode {
+						break
+					}
+					m.setU32(node+8, m.u32(node+8)+m.u32(node2+8))
+					m.setU32(node2+8, 0)
+				}
+			}
+			next = m.u32(node + 4)
+		}
+	}
+	link(0)
+
+	// Sort the glued blocks back into the free lists.
+	for head != 0 {
+		node := head
+		head = m.u32(node + 4)
+		nu := m.u32(node + 8)
+		if nu == 0 {
+			continue
+		}
+		for ; nu > 128; nu, node = nu-128, node+m.u2b(128) {
+			m.insertNode(node, numIndexes-1)
+		}
+		i := m.u2i(nu)
+		if m.i2u(i) != nu {
+			i--
+			k := m.i2u(i)
+			m.insertNode(node+m.u2b(k), int(nu-k-1))
+		}
+		m.insertNode(node, i)
+	}
+}
+
+// allocUnitsRare returns a block of units from the free lists, or from
+// the text area once they are exhausted, or zero if there is no memory
+// left.
+func (m *model) allocUnitsRare(indx int) uint32 {
+	if m.glueCount == 0 {
+		m.glueFreeBlocks()
+		if m.freeList[indx] != 0 {
+			return m.removeNode(indx)
+		}
+	}
+	i := indx
+	for {
+		i++
+		if i == numIndexes {
+			numBytes := m.u2b(m.i2u(indx))
+			m.glueCount--
+			if m.unitsStart-m.text > numBytes {
+				m.unitsStart -= numBytes
+				return m.unitsStart
+			}
+			return 0
+		}
+		if m.freeList[i] != 0 {
+			break
+		}
+	}
+	block := m.removeNode(i)
+	m.splitBlock(block, i, indx)
+	return block
+}
+
+func (m *model) allocUnits(indx int) uint32 {
+	if m.freeList[indx] != 0 {
+		return m.removeNode(indx)
+	}
+	numBytes := m.u2b(m.i2u(indx))
+	if numBytes <= m.hiUnit-m.loUnit {
+		block := m.loUnit
+		m.loUnit += numBytes
+		return block
+	}
+	return m.allocUnitsRare(indx)
+}
+
+func (m *model) shrinkUnits(oldPtr, oldNU, newNU uint32) uint32 {
+	i0, i1 := m.u2i(oldNU), m.u2i(newNU)
+	if i0 == i1 {
+		return oldPtr
+	}
+	if m.freeList[i1] != 0 {
+		ptr := m.removeNode(i1)
+		m.copyUnits(ptr, oldPtr, newNU)
+		m.insertNode(oldPtr, i0)
+		return ptr
+	}
+	m.splitBlock(oldPtr, i0, i1)
+	return oldPtr
+}
+
+func (m *model) freeUnits(ptr, nu uint32) {
+	m.insertNode(ptr, m.u2i(nu))
+}
+
+func (m *model) specialFreeUnit(ptr uint32) {
+	if ptr != m.unitsStart {
+		m.insertNode(ptr, 0)
+	} else {
+		m.unitsStart += unitSize
+	}
+}
+
+func (m *model) moveUnitsUp(oldPtr, nu uint32) uint32 {
+	indx := m.u2i(nu)
+	if oldPtr > m.unitsStart+16*1024 || oldPtr > m.freeList[indx] {
+		return oldPtr
+	}
+	ptr := m.removeNode(indx)
+	m.copyUnits(ptr, oldPtr, nu)
+	if oldPtr != m.unitsStart {
+		m.insertNode(oldPtr, indx)
+	} else {
+		m.unitsStart += m.u2b(m.i2u(indx))
+	}
+	return ptr
+}
+
+// expandTextArea returns the free blocks at the start of the units area
+// to the text area.
+func (m *model) expandTextArea() {
+	var count [numIndexes]uint32
+	if m.loUnit != m.hiUnit {
+		m.setU32(m.loUnit, 0)
+	}
+	node := m.unitsStart
+	for m.u32(node) == emptyNode {
+		m.setU32(node, 0)
+		count[m.u2i(m.u32(node+8))]++
+		node += m.u2b(m.u32(node + 8))
+	}
+	m.unitsStart = node
+
+	for i := 0; i < numIndexes; i++ {
+		// loc is the field holding the next node, zero for the list head.
+		var loc uint32
+		next := func() uint32 {
+			if loc == 0 {
+				return m.freeList[i]
+			}
+			return m.u32(loc)
+		}
+		setNext := func(v uint32) {
+			if loc == 0 {
+				m.freeList[i] = v
+			} else {
+				m.setU32(loc, v)
+			}
+		}
+		for count[i] != 0 {
+			node := next()
+			for m.u32(node) == 0 {
+				setNext(m.u32(node + 4))
+				node = next()
+				m.stamps[i]--
+				if count[i]--; count[i] == 0 {
+					break
+				}
+			}
+			loc = node + 4
+		}
+	}
+}
+
+func (m *model) usedMemory() uint32 {
+	var v uint32
+	for i := range m.stamps {
+		v += m.stamps[i] * m.i2u(i)
+	}
+	return m.size - (m.hiUnit - m.loUnit) - (m.unitsStart - m.text) - m.u2b(v)
+}
+
+func (m *model) restartModel() {
+	m.freeList = [numIndexes]uint32{}
+	m.stamps = [numIndexes]uint32{}
+	m.text = m.alignOffset
+	m.hiUnit = m.text + m.size
+	m.loUnit = m.hiUnit - m.size/8/unitSize*7*unitSize
+	m.unitsStart = m.loUnit
+	m.glueCount = 0
+
+	m.orderFall = m.maxOrder
+	rl := m.maxOrder
+	if rl > 12 {
+		rl = 12
+	}
+	m.initRL = -int32(rl) - 1
+	m.runLength = m.initRL
+	m.prevSuccess = 0
+
+	m.hiUnit -= unitSize
+	m.minContext, m.maxContext = m.hiUnit, m.hiUnit
+	m.setSuffix(m.minContext, 0)
+	m.setNumStats(m.minContext, 255)
+	m.setFlags(m.minContext, 0)
+	m.setSummFreq(m.minContext, 256+1)
+	m.foundState = m.loUnit
+	m.setStats(m.minContext, m.foundState)
+	m.loUnit += m.u2b(256 / 2)
+	for i := uint32(0); i < 256; i++ {
+		s := m.foundState + i*stateSize
+		m.mem[s] = byte(i)
+		m.setFreq(s, 1)
+		m.setSuccessor(s, 0)
+	}
+
+	n := 0
+	for i := range m.binSumm {
+		for int(m.ns2Indx[n]) == i {
+			n++
+		}
+		for k := 0; k < 8; k++ {
+			val := uint16(binScale - uint32(initBinEsc[k])/uint32(n+1))
+			for j := 0; j < 64; j += 8 {
+				m.binSumm[i][k+j] = val
+			}
+		}
+	}
+	n = 0
+	for i := range m.see {
+		for int(m.ns2Indx[n+3]) == i+3 {
+			n++
+		}
+		for k := range m.see[i] {
+			m.see[i][k] = see{summ: uint16((2*n + 5) << (periodBits - 4)), shift: periodBits - 4, count: 7}
+		}
+	}
+}
+
+func (m *model) refresh(c, oldNU, scale uint32) {
+	i := m.numStats(c)
+	s := m.shrinkUnits(m.stats(c), oldNU, (i+2)>>1)
+	m.setStats(c, s)
+	flags := m.flags(c)&(0x10+0x04*scale) + 0x08*b2u(m.symbol(s) >= 0x40)
+	escFreq := m.summFreq(c) - m.freq(s)
+	m.setFreq(s, (m.freq(s)+scale)>>scale)
+	sumFreq := m.freq(s)
+	for ; i > 0; i-- {
+		s += stateSize
+		escFreq -= m.freq(s)
+		m.setFreq(s, (m.freq(s)+scale)>>scale)
+		sumFreq += m.freq(s)
+		flags |= 0x08 * b2u(m.symbol(s) >= 0x40)
+	}
+	m.setSummFreq(c, sumFreq+(escFreq+scale)>>scale)
+	m.setFlags(c, flags)
+}
+
+// cutOff drops the parts of the model rooted at c that reference the text
+// area, returning c or zero if it was freed.
+func (m *model) cutOff(c, order uint32) uint32 {
+	if m.numStats(c) == 0 {
+		s := oneState(c)
+		if m.successor(s) >= m.unitsStart {
+			if order < m.maxOrder {
+				m.setSuccessor(s, m.cutOff(m.successor(s), order+1))
+			} else {
+				m.setSuccessor(s, 0)
+			}
+			if m.successor(s) != 0 || order <= 9 {
+				return c
+			}
+		}
+		m.specialFreeUnit(c)
+		return 0
+	}
+
+	tmp := (m.numStats(c) + 2) >> 1
+	m.setStats(c, m.moveUnitsUp(m.stats(c), tmp))
+	stats := m.stats(c)
+	i := int(m.numStats(c))
+	for j := i; j >= 0; j-- {
+		s := stats + uint32(j)*stateSize
+		switch {
+		case m.successor(s) < m.unitsStart:
+			s2 := stats + uint32(i)*stateSize
+			i--
+			m.setSuccessor(s, 0)
+			m.swapStates(s, s2)
+		case order < m.maxOrder:
+			m.setSuccessor(s, m.cutOff(m.successor(s), order+1))
+		default:
+			m.setSuccessor(s, 0)
+		}
+	}
+
+	if i != int(m.numStats(c)) && order != 0 {
+		m.setNumStats(c, uint32(i))
+		s := stats
+		switch {
+		case i < 0:
+			m.freeUnits(s, tmp)
+			m.specialFreeUnit(c)
+			return 0
+		case i == 0:
+			m.setFlags(c, m.flags(c)&0x10+0x08*b2u(m.symbol(s) >= 0x40))
+			m.copyState(oneState(c), s)
+			m.freeUnits(s, tmp)
+			m.setFreq(oneState(c), (m.freq(oneState(c))+11)>>3)
+		default:
+			m.refresh(c, tmp, b2u(m.summFreq(c) > 16*uint32(i)))
+		}
+	}
+	return c
+}
+
+// restoreModel recovers from running out of memory, after the contexts
+// from the maximum one up to c1 were updated, by restarting or cutting
+// off the model.
+func (m *model) restoreModel(c1 uint32) {
+	m.text = m.alignOffset
+	c := m.maxContext
+	for ; c != c1; c = m.suffix(c) {
+		m.setNumStats(c, m.numStats(c)-1)
+		if m.numStats(c) == 0 {
+			s := m.stats(c)
+			m.setFlags(c, m.flags(c)&0x10+0x08*b2u(m.symbol(s) >= 0x40))
+			m.copyState(oneState(c), s)
+			m.specialFreeUnit(s)
+			m.setFreq(oneState(c), (m.freq(oneState(c))+11)>>3)
+		} else {
+			m.refresh(c, (m.numStats(c)+3)>>1, 0)
+		}
+	}
+	for ; c != m.minContext; c = m.suffix(c) {
+		if m.numStats(c) == 0 {
+			s := oneState(c)
+			m.setFreq(s, m.freq(s)-m.freq(s)>>1)
+		} else {
+			m.setSummFreq(c, m.summFreq(c)+4)
+			if m.summFreq(c) > 128+4*m.numStats(c) {
+				m.refresh(c, (m.numStats(c)+2)>>1, 1)
+			}
+		}
+	}
+
+	if m.restoreMethod == restoreRestart || m.usedMemory() < m.size>>1 {
+		m.restartModel()
+		return
+	}
+	for m.suffix(m.maxContext) != 0 {
+		m.maxContext = m.suffix(m.maxContext)
+	}
+	for {
+		m.cutOff(m.maxContext, 0)
+		m.expandTextArea()
+		if m.usedMemory() <= 3*(m.size>>2) {
+			break
+		}
+	}
+	m.glueCount = 0
+	m.orderFall = m.maxOrder
+}
+
+func (m *model) createSuccessors(skip bool, s1, c uint32) uint32 {
+	upBranch := m.successor(m.foundState)
+	fSymbol := m.symbol(m.foundState)
+	var ps [maxOrder + 1]uint32
+	numPs := 0
+	if !skip {
+		ps[numPs] = m.foundState
+		numPs++
+	}
+
+	for m.suffix(c) != 0 {
+		var s uint32
+		c = m.suffix(c)
+		switch {
+		case s1 != 0:
+			s, s1 = s1, 0
+		case m.numStats(c) != 0:
+			for s = m.stats(c); m.symbol(s) != fSymbol; s += stateSize {
+			}
+			if m.freq(s) < maxFreq-9 {
+				m.setFreq(s, m.freq(s)+1)
+				m.setSummFreq(c, m.summFreq(c)+1)
+			}
+		default:
+			s = oneState(c)
+			m.setFreq(s, m.freq(s)+b2u(m.numStats(m.suffix(c)) == 0 && m.freq(s) < 24))
+		}
+		if succ := m.successor(s); succ != upBranch {
+			c = succ
+			if numPs == 0 {
+				return c
+			}
+			break
+		}
+		ps[numPs] = s
+		numPs++
+	}
+
+	upSymbol := uint32(m.mem[upBranch])
+	flags := 0x10*b2u(fSymbol >= 0x40) + 0x08*b2u(upSymbol >= 0x40)
+	var upFreq uint32
+	if m.numStats(c) == 0 {
+		upFreq = m.freq(oneState(c))
+	} else {
+		var s uint32
+		for s = m.stats(c); m.symbol(s) != upSymbol; s += stateSize {
+		}
+		cf := m.freq(s) - 1
+		s0 := m.summFreq(c) - m.numStats(c) - cf
+		if 2*cf <= s0 {
+			upFreq = 1 + b2u(5*cf > s0)
+		} else {
+			upFreq = 1 + (cf+2*s0-3)/s0
+		}
+	}
+
+	for numPs != 0 {
+		var c1 uint32
+		switch {
+		case m.hiUnit != m.loUnit:
+			m.hiUnit -= unitSize
+			c1 = m.hiUnit
+		case m.freeList[0] != 0:
+			c1 = m.removeNode(0)
+		default:
+			if c1 = m.allocUnitsRare(0); c1 == 0 {
+				return 0
+			}
+		}
+		m.setNumStats(c1, 0)
+		m.setFlags(c1, flags)
+		s := oneState(c1)
+		m.mem[s] = byte(upSymbol)
+		m.setFreq(s, upFreq)
+		m.setSuccessor(s, upBranch+1)
+		m.setSuffix(c1, c)
+		numPs--
+		m.setSuccessor(ps[numPs], c1)
+		c = c1
+	}
+	return c
+}
+
+func (m *model) reduceOrder(s1, c uint32) uint32 {
+	var s uint32
+	c1 := c
+	upBranch := m.text
+	fSymbol := m.symbol(m.foundState)
+
+	m.setSuccessor(m.foundState, upBranch)
+	m.orderFall++
+	for {
+		if s1 != 0 {
+			c = m.suffix(c)
+			s, s1 = s1, 0
+		} else {
+			if m.suffix(c) == 0 {
+				return c
+			}
+			c = m.suffix(c)
+			if m.numStats(c) != 0 {
+				for s = m.stats(c); m.symbol(s) != fSymbol; s += stateSize {
+				}
+				if m.freq(s) < maxFreq-9 {
+					m.setFreq(s, m.freq(s)+2)
+					m.setSummFreq(c, m.summFreq(c)+2)
+				}
+			} else {
+				s = oneState(c)
+				m.setFreq(s, m.freq(s)+b2u(m.freq(s) < 32))
+			}
+		}
+		if m.successor(s) != 0 {
+			break
+		}
+		m.setSuccessor(s, upBranch)
+		m.orderFall++
+	}
+
+	if m.successor(s) <= upBranch {
+		s2 := m.foundState
+		m.foundState = s
+		m.setSuccessor(s, m.createSuccessors(false, 0, c))
+		m.foundState = s2
+	}
+	if m.orderFall == 1 && c1 == m.maxContext {
+		m.setSuccessor(m.foundState, m.successor(s))
+		m.text--
+	}
+	return m.successor(s)
+}
+
+func (m *model) updateModel() {
+	fs := m.foundState
+	fSuccessor := m.successor(fs)
+	fFreq := m.freq(fs)
+	fSymbol := m.symbol(fs)
+	var s uint32
+
+	if fFreq < maxFreq/4 && m.suffix(m.minContext) != 0 {
+		c := m.suffix(m.minContext)
+		if m.numStats(c) == 0 {
+			s = oneState(c)
+			if m.freq(s) < 32 {
+				m.setFreq(s, m.freq(s)+1)
+			}
+		} else {
+			s = m.stats(c)
+			if m.symbol(s) != fSymbol {
+				for s += stateSize; m.symbol(s) != fSymbol; s += stateSize {
+				}
+				if m.freq(s) >= m.freq(s-stateSize) {
+					m.swapStates(s, s-stateSize)
+					s -= stateSize
+				}
+			}
+			if m.freq(s) < maxFreq-9 {
+				m.setFreq(s, m.freq(s)+2)
+				m.setSummFreq(c, m.summFreq(c)+2)
+			}
+		}
+	}
+
+	c := m.maxContext
+	if m.orderFall == 0 && fSuccessor != 0 {
+		cs := m.createSuccessors(true, s, m.minContext)
+		m.setSuccessor(m.foundState, cs)
+		if cs == 0 {
+			m.restoreModel(c)
+		} else {
+			m.maxContext = cs
+		}
+		return
+	}
+
+	m.mem[m.text] = byte(m.symbol(m.foundState))
+	m.text++
+	successor := m.text
+	if m.text >= m.unitsStart {
+		m.restoreModel(c)
+		return
+	}
+
+	if fSuccessor == 0 {
+		cs := m.reduceOrder(s, m.minContext)
+		if cs == 0 {
+			m.restoreModel(c)
+			return
+		}
+		fSuccessor = cs
+	} else if fSuccessor < m.unitsStart {
+		cs := m.createSuccessors(false, s, m.minContext)
+		if cs == 0 {
+			m.restoreModel(c)
+			return
+		}
+		fSuccessor = cs
+	}
+
+	m.orderFall--
+	if m.orderFall == 0 {
+		successor = fSuccessor
+		if m.maxContext != m.minContext {
+			m.text--
+		}
+	}
+
+	ns := m.numStats(m.minContext)
+	s0 := m.summFreq(m.minContext) - ns - fFreq
+	flag := 0x08 * b2u(fSymbol >= 0x40)
+	for ; c != m.minContext; c = m.suffix(c) {
+		ns1 := m.numStats(c)
+		if ns1 != 0 {
+			if ns1&1 != 0 {
+				// The states fill their units, grow them by one.
+				oldNU := (ns1 + 1) >> 1
+				i := m.u2i(oldNU)
+				if i != m.u2i(oldNU+1) {
+					ptr := m.allocUnits(i + 1)
+					if ptr == 0 {
+						m.restoreModel(c)
+						return
+					}
+					oldPtr := m.stats(c)
+					m.copyUnits(ptr, oldPtr, oldNU)
+					m.insertNode(oldPtr, i)
+					m.setStats(c, ptr)
+				}
+			}
+			m.setSummFreq(c, m.summFreq(c)+b2u(3*ns1+1 < ns))
+		} else {
+			s2 := m.allocUnits(0)
+			if s2 == 0 {
+				m.restoreModel(c)
+				return
+			}
+			m.copyState(s2, oneState(c))
+			m.setStats(c, s2)
+			if m.freq(s2) < maxFreq/4-1 {
+				m.setFreq(s2, m.freq(s2)<<1)
+			} else {
+				m.setFreq(s2, maxFreq-4)
+			}
+			m.setSummFreq(c, m.freq(s2)+m.initEsc+b2u(ns > 2))
+		}
+
+		cf := 2 * fFreq * (m.summFreq(c) + 6)
+		sf := s0 + m.summFreq(c)
+		if cf < 6*sf {
+			cf = 1 + b2u(cf > sf) + b2u(cf >= 4*sf)
+			m.setSummFreq(c, m.summFreq(c)+4)
+		} else {
+			cf = 4 + b2u(cf > 9*sf) + b2u(cf > 12*sf) + b2u(cf > 15*sf)
+			m.setSummFreq(c, m.summFreq(c)+cf)
+		}
+		s2 := m.stats(c) + (ns1+1)*stateSize
+		m.setSuccessor(s2, successor)
+		m.mem[s2] = byte(fSymbol)
+		m.setFreq(s2, cf)
+		m.setFlags(c, m.flags(c)|flag)
+		m.setNumStats(c, ns1+1)
+	}
+	m.maxContext, m.minContext = fSuccessor, fSuccessor
+}
+
+func (m *model) rescale() {
+	mc := m.minContext
+	stats := m.stats(mc)
+	s := m.foundState
+
+	// Move the found state to the front.
+	if s != stats {
+		var tmp [stateSize]byte
+		copy(tmp[:], m.mem[s:])
+		for ; s != stats; s -= stateSize {
+			m.copyState(s, s-stateSize)
+		}
+		copy(m.mem[s:], tmp[:])
+	}
+	escFreq := m.summFreq(mc) - m.freq(s)
+	m.setFreq(s, m.freq(s)+4)
+	adder := b2u(m.orderFall != 0)
+	m.setFreq(s, (m.freq(s)+adder)>>1)
+	sumFreq := m.freq(s)
+
+	// Halve the frequencies, keeping the states sorted by them.
+	for i := m.numStats(mc); i > 0; i-- {
+		s += stateSize
+		escFreq -= m.freq(s)
+		m.setFreq(s, (m.freq(s)+adder)>>1)
+		sumFreq += m.freq(s)
+		if m.freq(s) > m.freq(s-stateSize) {
+			var tmp [stateSize]byte
+			copy(tmp[:], m.mem[s:])
+			s1 := s
+			for {
+				m.copyState(s1, s1-stateSize)
+				s1 -= stateSize
+				if s1 == stats || uint32(tmp[1]) <= m.freq(s1-stateSize) {
+					break
+				}
+			}
+			copy(m.mem[s1:], tmp[:])
+		}
+	}
+
+	if m.freq(s) == 0 {
+		// Drop the states whose frequency fell to zero.
+		numStats := m.numStats(mc)
+		var i uint32
+		for {
+			i++
+			s -= stateSize
+			if m.freq(s) != 0 {
+				break
+			}
+		}
+		escFreq += i
+		m.setNumStats(mc, numStats-i)
+		if m.numStats(mc) == 0 {
+			var tmp [stateSize]byte
+			copy(tmp[:], m.mem[stats:])
+			freq := byte((2*uint32(tmp[1]) + escFreq - 1) / escFreq)
+			if freq > maxFreq/3 {
+				freq = maxFreq / 3
+			}
+			tmp[1] = freq
+			m.insertNode(stats, m.u2i((numStats+2)>>1))
+			m.setFlags(mc, m.flags(mc)&0x10+0x08*b2u(tmp[0] >= 0x40))
+			m.foundState = oneState(mc)
+			copy(m.mem[m.foundState:], tmp[:])
+			return
+		}
+		n0, n1 := (numStats+2)>>1, (m.numStats(mc)+2)>>1
+		if n0 != n1 {
+			m.setStats(mc, m.shrinkUnits(stats, n0, n1))
+		}
+		flags := m.flags(mc) &^ 0x08
+		s = m.stats(mc)
+		flags |= 0x08 * b2u(m.symbol(s) >= 0x40)
+		for i := m.numStats(mc); i > 0; i-- {
+			s += stateSize
+			flags |= 0x08 * b2u(m.symbol(s) >= 0x40)
+		}
+		m.setFlags(mc, flags)
+	}
+	m.setSummFreq(mc, sumFreq+escFreq-escFreq>>1)
+	m.setFlags(mc, m.flags(mc)|0x04)
+	m.foundState = m.stats(mc)
+}
+
+func (m *model) nextContext() {
+	c := m.successor(m.foundState)
+	if m.orderFall == 0 && c >= m.unitsStart {
+		m.minContext, m.maxContext = c, c
+	} else {
+		m.updateModel()
+		m.minContext = m.maxContext
+	}
+}
+
+// update1 updates the model after a symbol other than the first of a
+// context was coded.
+func (m *model) update1() {
+	s := m.foundState
+	m.setFreq(s, m.freq(s)+4)
+	m.setSummFreq(m.minContext, m.summFreq(m.minContext)+4)
+	if m.freq(s) > m.freq(s-stateSize) {
+		m.swapStates(s, s-stateSize)
+		s -= stateSize
+		m.foundState = s
+		if m.freq(s) > maxFreq {
+			m.rescale()
+		}
+	}
+	m.nextContext()
+}
+
+// update1First updates the model after the first symbol of a context was
+// coded.
+func (m *model) update1First() {
+	m.prevSuccess = b2u(2*m.freq(m.foundState) >= m.summFreq(m.minContext))
+	m.runLength += int32(m.prevSuccess)
+	m.setSummFreq(m.minContext, m.summFreq(m.minContext)+4)
+	m.setFreq(m.foundState, m.freq(m.foundState)+4)
+	if m.freq(m.foundState) > maxFreq {
+		m.rescale()
+	}
+	m.nextContext()
+}
+
+// updateBin updates the model after the symbol of a binary context was
+// coded.
+func (m *model) updateBin() {
+	m.setFreq(m.foundState, m.freq(m.foundState)+b2u(m.freq(m.foundState) < 196))
+	m.prevSuccess = 1
+	m.runLength++
+	m.nextContext()
+}
+
+// update2 updates the model after a symbol was coded following escapes.
+func (m *model) update2() {
+	m.setSummFreq(m.minContext, m.summFreq(m.minContext)+4)
+	m.setFreq(m.foundState, m.freq(m.foundState)+4)
+	if m.freq(m.foundState) > maxFreq {
+		m.rescale()
+	}
+	m.runLength = m.initRL
+	m.updateModel()
+	m.minContext = m.maxContext
+}
+
+// binProb returns the probability of the symbol of the current binary
+// context.
+func (m *model) binProb() *uint16 {
+	mc := m.minContext
+	i := m.ns2Indx[m.freq(oneState(mc))-1]
+	j := uint32(m.ns2BSIndx[m.numStats(m.suffix(mc))]) + m.prevSuccess + m.flags(mc) + uint32((m.runLength>>26)&0x20)
+	return &m.binSumm[i][j]
+}
+
+// makeEscFreq returns the secondary escape estimation context of the
+// current context after numMasked+1 symbols were masked, and the
+// frequency of the escape.
+func (m *model) makeEscFreq(numMasked uint32) (*see, uint32) {
+	mc := m.minContext
+	ns := m.numStats(mc)
+	if ns == 0xFF {
+		return &m.dummySee, 1
+	}
+	i := uint32(m.ns2Indx[ns+2]) - 3
+	j := b2u(m.summFreq(mc) > 11*(ns+1)) +
+		2*b2u(2*ns < m.numStats(m.suffix(mc))+numMasked) +
+		m.flags(mc)
+	se := &m.see[i][j]
+	r := uint32(se.summ >> se.shift)
+	se.summ -= uint16(r)
+	return se, r + b2u(r == 0)
+}
+
+func probMean(prob uint16) uint16 {
+	return (prob + 1<<(periodBits-2)) >> periodBits
+}
@@ -0,0 +1,309 @@
+// Package ppmd registers a decompressor for PPMd compressed entries,
+// method 98, with zipread. The entries are compressed with PPMd variant I
+// revision 1, which 7-Zip and WinZip can write into ZIP archives. Import
+// the package for its side effect:
+//
+//	import _ "zipper/zipread/ppmd"
+package ppmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// Method is the compression method of PPMd compressed entries.
+const Method uint16 = 98
+
+// HeaderLen is the length of the header preceding the compressed data.
+const HeaderLen = 2
+
+// Params are the model parameters of an entry.
+type Params struct {
+	Order   int    // model order, 2 to 16
+	MemSize uint32 // model memory in bytes, 1 to 256 MiB
+	Restore int    // model restoration method, 0 (restart) to 2
+}
+
+// ParseHeader reads the header at the start of the contents of a PPMd
+// entry. The header is a little-endian 16-bit value holding the order
+// minus one in bits 0-3, the memory size in MiB minus one in bits 4-11,
+// and the restoration method in bits 12-15.
+func ParseHeader(r io.Reader) (Params, error) {
+	var b [HeaderLen]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return Params{}, errs.Errorf("ppmd: reading header: %w", err)
+	}
+	v := binary.LittleEndian.Uint16(b[:])
+	p := Params{
+		Order:   int(v&0xf) + 1,
+		MemSize: (uint32(v>>4&0xff) + 1) << 20,
+		Restore: int(v >> 12),
+	}
+	if p.Order < 2 || p.Restore > 2 {
+		return Params{}, errs.Errorf("ppmd: invalid parameters: order %d, restoration method %d", p.Order, p.Restore)
+	}
+	return p, nil
+}
+
+func init() {
+	zipread.RegisterDecompressor(Method, NewReader)
+}
+
+// NewReader returns a reader decompressing the PPMd entry contents r. It
+// is the zipread.Decompressor registered for Method, and can be registered
+// with a single Reader instead where the package level registration is
+// not wanted.
+//
+// The contents are decoded until the end marker the encoder writes after
+// the last symbol. The model takes as much memory as the header of the
+// entry asks for, up to 256 MiB, which is released on Close.
+func NewReader(r io.Reader) io.ReadCloser {
+	return &reader{r: r}
+}
+
+type reader struct {
+	r   io.Reader
+	m   *model
+	d   rangeDecoder
+	err error // sticky error
+}
+
+func (z *reader) init() error {
+	p, err := ParseHeader(z.r)
+	if err != nil {
+		return err
+	}
+	br, ok := z.r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(z.r)
+	}
+	z.d.r = br
+	if !z.d.init() {
+		return z.d.error()
+	}
+	z.m = newModel(p)
+	return nil
+}
+
+func (z *reader) Read(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.m == nil {
+		if z.err = z.init(); z.err != nil {
+			return 0, z.err
+		}
+	}
+	for n := range p {
+		sym := z.m.decodeSymbol(&z.d)
+		switch {
+		case z.d.err != nil:
+			z.err = z.d.err
+		case sym == -1:
+			z.err = io.EOF
+		case sym < 0:
+			z.err = errCorrupt
+		default:
+			p[n] = byte(sym)
+			continue
+		}
+		return n, z.err
+	}
+	return len(p), nil
+}
+
+func (z *reader) Close() error {
+	z.m = nil
+	z.err = errs.Errorf("ppmd: read after close")
+	return nil
+}
+
+var errCorrupt = errs.Errorf("ppmd: corrupt data")
+
+// rangeDecoder is the carryless range decoder of PPMd variant I.
+type rangeDecoder struct {
+	r              io.ByteReader
+	low, rng, code uint32
+	err            error
+}
+
+const (
+	rangeTop = 1 << 24
+	rangeBot = 1 << 15
+)
+
+func (d *rangeDecoder) init() bool {
+	d.low, d.rng, d.code = 0, 0xFFFFFFFF, 0
+	for i := 0; i < 4; i++ {
+		d.code = d.code<<8 | d.readByte()
+	}
+	return d.err == nil && d.code < 0xFFFFFFFF
+}
+
+// error returns the error that stopped decoding.
+func (d *rangeDecoder) error() error {
+	if d.err == nil {
+		d.err = errCorrupt
+	}
+	return d.err
+}
+
+func (d *rangeDecoder) readByte() uint32 {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if d.err == nil {
+			d.err = errs.Errorf("ppmd: %w", err)
+		}
+		return 0
+	}
+	return uint32(b)
+}
+
+// threshold scales the range to total and returns the count the code
+// falls on.
+func (d *rangeDecoder) threshold(total uint32) uint32 {
+	d.rng /= total
+	if d.rng == 0 {
+		// Only corrupt data gets the range this low; keep going without
+		// dividing by zero until the caller sees the error.
+		d.error()
+		d.rng = 1
+	}
+	return d.code / d.rng
+}
+
+func (d *rangeDecoder) decode(start, size uint32) {
+	start *= d.rng
+	d.low += start
+	d.code -= start
+	d.rng *= size
+	for {
+		if d.low^(d.low+d.rng) >= rangeTop {
+			if d.rng >= rangeBot {
+				return
+			}
+			if d.rng = -d.low & (rangeBot - 1); d.rng == 0 {
+				d.error()
+				d.rng = 1
+				return
+			}
+		}
+		d.code = d.code<<8 | d.readByte()
+		d.rng <<= 8
+		d.low <<= 8
+	}
+}
+
+// decodeSymbol decodes the next symbol, returning -1 at the end marker
+// and -2 if the data is corrupt.
+func (m *model) decodeSymbol(d *rangeDecoder) int {
+	var masked [256]bool
+	mc := m.minContext
+	if m.numStats(mc) != 0 {
+		s := m.stats(mc)
+		count := d.threshold(m.summFreq(mc))
+		hiCnt := m.freq(s)
+		if count < hiCnt {
+			d.decode(0, hiCnt)
+			m.foundState = s
+			sym := m.symbol(s)
+			m.update1First()
+			return int(sym)
+		}
+		m.prevSuccess = 0
+		for i := m.numStats(mc); i > 0; i-- {
+			s += stateSize
+			if hiCnt += m.freq(s); hiCnt > count {
+				d.decode(hiCnt-m.freq(s), m.freq(s))
+				m.foundState = s
+				sym := m.symbol(s)
+				m.update1()
+				return int(sym)
+			}
+		}
+		if count >= m.summFreq(mc) {
+			return -2
+		}
+		d.decode(hiCnt, m.summFreq(mc)-hiCnt)
+		masked[m.symbol(s)] = true
+		for i := m.numStats(mc); i > 0; i-- {
+			s -= stateSize
+			masked[m.symbol(s)] = true
+		}
+	} else {
+		prob := m.binProb()
+		if d.threshold(binScale) < uint32(*prob) {
+			d.decode(0, uint32(*prob))
+			*prob = *prob + 1<<intBits - probMean(*prob)
+			m.foundState = oneState(mc)
+			sym := m.symbol(m.foundState)
+			m.updateBin()
+			return int(sym)
+		}
+		d.decode(uint32(*prob), binScale-uint32(*prob))
+		*prob -= probMean(*prob)
+		m.initEsc = uint32(expEscape[*prob>>10])
+		masked[m.symbol(oneState(mc))] = true
+		m.prevSuccess = 0
+	}
+
+	var ps [256]uint32
+	for {
+		numMasked := m.numStats(m.minContext)
+		for {
+			m.orderFall++
+			if m.suffix(m.minContext) == 0 {
+				return -1
+			}
+			m.minContext = m.suffix(m.minContext)
+			if m.numStats(m.minContext) != numMasked {
+				break
+			}
+		}
+		mc := m.minContext
+		var hiCnt uint32
+		s := m.stats(mc)
+		num := m.numStats(mc) - numMasked
+		var i uint32
+		for ; i != num; s += stateSize {
+			if !masked[m.symbol(s)] {
+				hiCnt += m.freq(s)
+				ps[i] = s
+				i++
+			}
+		}
+
+		se, freqSum := m.makeEscFreq(numMasked)
+		freqSum += hiCnt
+		count := d.threshold(freqSum)
+		if count < hiCnt {
+			k := 0
+			for hiCnt = m.freq(ps[0]); hiCnt <= count; hiCnt += m.freq(ps[k]) {
+				k++
+			}
+			s = ps[k]
+			d.decode(hiCnt-m.freq(s), m.freq(s))
+			se.update()
+			m.foundState = s
+			sym := m.symbol(s)
+			m.update2()
+			return int(sym)
+		}
+		if count >= freqSum {
+			return -2
+		}
+		d.decode(hiCnt, freqSum-hiCnt)
+		se.summ += uint16(freqSum)
+		for ; i > 0; i-- {
+			masked[m.symbol(ps[i-1])] = true
+		}
+	}
+}
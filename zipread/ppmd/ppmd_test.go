@@ -0,0 +1,305 @@
+package ppmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestParseHeader(t *testing.T) {
+	// Order 6, 16 MiB, restart: the defaults of 7-Zip.
+	p, err := ParseHeader(bytes.NewReader([]byte{0xf5, 0x00}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Params{Order: 6, MemSize: 16 << 20}); p != want {
+		t.Errorf("got %+v, want %+v", p, want)
+	}
+
+	for _, b := range [][]byte{{0x00, 0x00}, {0x05, 0x30}, {0x05}} {
+		if _, err := ParseHeader(bytes.NewReader(b)); err == nil {
+			t.Errorf("ParseHeader(%x): expected error", b)
+		}
+	}
+}
+
+// rangeEncoder and encodeSymbol mirror the decoder, to produce streams
+// like 7-Zip's encoder does.
+type rangeEncoder struct {
+	low, rng uint32
+	out      []byte
+}
+
+func (e *rangeEncoder) normalize() {
+	for {
+		if e.low^(e.low+e.rng) >= rangeTop {
+			if e.rng >= rangeBot {
+				return
+			}
+			e.rng = -e.low & (rangeBot - 1)
+		}
+		e.out = append(e.out, byte(e.low>>24))
+		e.rng <<= 8
+		e.low <<= 8
+	}
+}
+
+func (e *rangeEncoder) encode(start, size, total uint32) {
+	e.rng /= total
+	e.low += start * e.rng
+	e.rng *= size
+	e.normalize()
+}
+
+func (e *rangeEncoder) flush() {
+	for i := 0; i < 4; i++ {
+		e.out = append(e.out, byte(e.low>>24))
+		e.low <<= 8
+	}
+}
+
+func (m *model) encodeSymbol(e *rangeEncoder, symbol int) {
+	var masked [256]bool
+	mc := m.minContext
+	if m.numStats(mc) != 0 {
+		s := m.stats(mc)
+		if int(m.symbol(s)) == symbol {
+			e.encode(0, m.freq(s), m.summFreq(mc))
+			m.foundState = s
+			m.update1First()
+			return
+		}
+		m.prevSuccess = 0
+		sum := m.freq(s)
+		for i := m.numStats(mc); i > 0; i-- {
+			s += stateSize
+			if int(m.symbol(s)) == symbol {
+				e.encode(sum, m.freq(s), m.summFreq(mc))
+				m.foundState = s
+				m.update1()
+				return
+			}
+			sum += m.freq(s)
+		}
+		masked[m.symbol(s)] = true
+		for i := m.numStats(mc); i > 0; i-- {
+			s -= stateSize
+			masked[m.symbol(s)] = true
+		}
+		e.encode(sum, m.summFreq(mc)-sum, m.summFreq(mc))
+	} else {
+		prob := m.binProb()
+		s := oneState(mc)
+		if int(m.symbol(s)) == symbol {
+			e.encode(0, uint32(*prob), binScale)
+			*prob = *prob + 1<<intBits - probMean(*prob)
+			m.foundState = s
+			m.updateBin()
+			return
+		}
+		e.encode(uint32(*prob), binScale-uint32(*prob), binScale)
+		*prob -= probMean(*prob)
+		m.initEsc = uint32(expEscape[*prob>>10])
+		masked[m.symbol(s)] = true
+		m.prevSuccess = 0
+	}
+	for {
+		numMasked := m.numStats(m.minContext)
+		for {
+			m.orderFall++
+			if m.suffix(m.minContext) == 0 {
+				return // the end marker
+			}
+			m.minContext = m.suffix(m.minContext)
+			if m.numStats(m.minContext) != numMasked {
+				break
+			}
+		}
+		mc := m.minContext
+		se, escFreq := m.makeEscFreq(numMasked)
+		s := m.stats(mc)
+		var sum, low uint32
+		found := uint32(0)
+		for i := m.numStats(mc) + 1; i > 0; i, s = i-1, s+stateSize {
+			if masked[m.symbol(s)] {
+				continue
+			}
+			if int(m.symbol(s)) == symbol {
+				found, low = s, sum
+			}
+			sum += m.freq(s)
+			masked[m.symbol(s)] = true
+		}
+		if found != 0 {
+			e.encode(low, m.freq(found), sum+escFreq)
+			se.update()
+			m.foundState = found
+			m.update2()
+			return
+		}
+		e.encode(sum, escFreq, sum+escFreq)
+		se.summ += uint16(sum + escFreq)
+	}
+}
+
+func compress(p Params, data []byte) []byte {
+	v := uint16(p.Order-1) | uint16(p.MemSize>>20-1)<<4 | uint16(p.Restore)<<12
+	e := &rangeEncoder{rng: 0xFFFFFFFF, out: []byte{byte(v), byte(v >> 8)}}
+	m := newModel(p)
+	for _, b := range data {
+		m.encodeSymbol(e, int(b))
+	}
+	m.encodeSymbol(e, -1)
+	e.flush()
+	return e.out
+}
+
+func testData(n int) []byte {
+	// Text-like data with repetition at several distances, plus some
+	// noise, to exercise contexts of all orders.
+	rng := rand.New(rand.NewSource(1))
+	words := strings.Fields("the quick brown fox jumps over a lazy dog while seven zebras quietly graze near x-ray tubes")
+	var b bytes.Buffer
+	for b.Len() < n {
+		switch rng.Intn(10) {
+		case 0:
+			for i := 0; i < 8; i++ {
+				b.WriteByte(byte(rng.Intn(256)))
+			}
+		case 1:
+			fmt.Fprintf(&b, "%d ", rng.Intn(1000000))
+		default:
+			b.WriteString(words[rng.Intn(len(words))])
+			b.WriteByte(" \n"[rng.Intn(2)])
+		}
+	}
+	return b.Bytes()[:n]
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		p Params
+		n int
+	}{
+		{Params{Order: 6, MemSize: 16 << 20}, 200 << 10},
+		{Params{Order: 2, MemSize: 1 << 20}, 100 << 10},
+		{Params{Order: 16, MemSize: 1 << 20, Restore: 0}, 3 << 20},
+		{Params{Order: 16, MemSize: 1 << 20, Restore: 1}, 3 << 20},
+		{Params{Order: 8, MemSize: 1 << 20, Restore: 2}, 2 << 20},
+		{Params{Order: 4, MemSize: 2 << 20}, 0},
+	} {
+		data := testData(tc.n)
+		t.Run(fmt.Sprintf("%d-%d-%d", tc.p.Order, tc.p.MemSize>>20, tc.p.Restore), func(t *testing.T) {
+			compressed := compress(tc.p, data)
+			rc := NewReader(bytes.NewReader(compressed))
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("got %d bytes differing from the %d compressed", len(got), len(data))
+			}
+			if len(data) > 0 && len(compressed) >= len(data)/2 {
+				t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+			}
+			if err := rc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Truncated data is an error, not a short read.
+			rc = NewReader(bytes.NewReader(compressed[:len(compressed)-3]))
+			if _, err := io.ReadAll(rc); err == nil {
+				t.Error("no error reading truncated data")
+			}
+		})
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	compressed := compress(Params{Order: 6, MemSize: 1 << 20}, testData(10<<10))
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		b := append([]byte(nil), compressed...)
+		for j := 0; j < 4; j++ {
+			b[HeaderLen+rng.Intn(len(b)-HeaderLen)] = byte(rng.Intn(256))
+		}
+		// Corrupt data decodes to garbage or fails, but must not hang or
+		// panic.
+		_, _ = io.Copy(io.Discard, io.LimitReader(NewReader(bytes.NewReader(b)), 1<<20))
+	}
+}
+
+// testWriter compresses what is written to it on Close.
+type testWriter struct {
+	w   io.Writer
+	p   Params
+	buf bytes.Buffer
+}
+
+func (w *testWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *testWriter) Close() error {
+	_, err := w.w.Write(compress(w.p, w.buf.Bytes()))
+	return err
+}
+
+func TestDecompress(t *testing.T) {
+	content := testData(64 << 10)
+	p := Params{Order: 6, MemSize: 16 << 20}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	w.RegisterCompressor(Method, func(w io.Writer) (io.WriteCloser, error) {
+		return &testWriter{w: w, p: p}, nil
+	})
+	fw, err := w.CreateHeader(&zip.FileHeader{Name: "a.txt", Method: Method})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := z.ReadFile("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+}
+
+func TestDecompressFile(t *testing.T) {
+	// testdata/ppmd.zip was written with the test encoder and extracts with
+	// bsdtar, whose PPMd decoder is a port of 7-Zip's, so the model here
+	// matches the one 7-Zip compresses with.
+	var want bytes.Buffer
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&want, "line %d of a PPMd compressed entry\n", i)
+	}
+
+	z, err := zipread.Open(zipread.SourceFromFile("testdata/ppmd.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := z.ReadFile("ppmd.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("got %q, want %q", got, want.Bytes())
+	}
+}
@@ -75,3 +75,9 @@ func (s *prefetchedTailSource) RangeFromEnd(ctx context.Context, length int64) (
 	rc, err = s.Range(ctx, s.size-length, length)
 	return rc, s.size, err
 }
+
+// RangeUncached serves the range from the underlying source, ignoring the
+// prefetched tail.
+func (s *prefetchedTailSource) RangeUncached(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	return rangeUncached(ctx, s.s, offset, length)
+}
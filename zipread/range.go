@@ -0,0 +1,236 @@
+package zipread
+
+import (
+	"context"
+	"io"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// OpenRange returns a ReadCloser for length bytes of the File's
+// uncompressed contents, starting at off. The range is clamped to the end
+// of the contents. Stored entries fetch exactly the requested bytes from
+// the source; other methods decompress and discard everything before off,
+// starting at the nearest checkpoint of an index attached with
+// SetDeflateIndex or, with Options.SOZip, the nearest indexed chunk.
+// Checksums are only verified when the whole entry is decompressed.
+func (f *File) OpenRange(ctx context.Context, off, length int64) (io.ReadCloser, error) {
+	if off < 0 || length < 0 {
+		return nil, errs.Errorf("negative offset or length")
+	}
+	size := int64(f.UncompressedSize64)
+	if off > size {
+		off = size
+	}
+	if off+length > size {
+		length = size - off
+	}
+
+	if f.Method == Store && !f.IsEncrypted() {
+		dataOffset, err := f.resolveDataOffset(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return f.zips.Range(ctx, dataOffset+off, length)
+	}
+
+	var (
+		rc  io.ReadCloser
+		pos int64
+	)
+	idx, err := f.seekIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if idx != nil {
+		rc, pos, err = f.openAt(ctx, idx.restartPoint(off))
+	} else {
+		rc, err = f.Open()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, rc, off-pos); err != nil {
+		return nil, errs.Combine(err, rc.Close())
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.LimitReader(rc, length),
+		Closer: rc,
+	}, nil
+}
+
+// OpenRaw returns a ReadCloser for the File's contents exactly as stored
+// in the archive, still compressed and, for encrypted entries, encrypted,
+// fetched with a single ranged request. Nothing is verified; the
+// CompressedSize64, CRC32 and Flags of the entry describe the bytes read.
+// zipwrite copies entries between archives with it.
+func (f *File) OpenRaw(ctx context.Context) (io.ReadCloser, error) {
+	dataOffset, err := f.resolveDataOffset(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.zips.Range(ctx, dataOffset, int64(f.CompressedSize64))
+}
+
+// A restartPoint is a position in an entry's compressed stream where
+// decompression can start without any of the preceding data.
+type restartPoint struct {
+	uncompressed int64 // offset in the uncompressed contents
+	compressed   int64 // offset in the compressed body
+
+	// For restart points within a deflate stream rather than at the
+	// start of an independent chunk, bit is the bit within the first
+	// byte to start at and dict holds the preceding output.
+	bit  uint
+	dict []byte
+}
+
+// A seekIndex locates restart points in an entry's compressed stream.
+type seekIndex interface {
+	// restartPoint returns the last restart point at or before the
+	// uncompressed offset off.
+	restartPoint(off int64) restartPoint
+}
+
+// seekIndex returns the index to use for random access into f, or nil.
+func (f *File) seekIndex(ctx context.Context) (seekIndex, error) {
+	f.mu.Lock()
+	di := f.deflateIndex
+	f.mu.Unlock()
+	if di != nil {
+		return di, nil
+	}
+	if f.Method != Deflate || !f.zip.opts.SOZip || f.IsEncrypted() {
+		return nil, nil
+	}
+	idx, err := f.SOZipIndex(ctx)
+	if idx == nil || err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// openAt returns a decompressing reader that starts at the restart point
+// rp, along with the uncompressed position it starts at. Checksums are not
+// verified.
+func (f *File) openAt(ctx context.Context, rp restartPoint) (io.ReadCloser, int64, error) {
+	dcomp := f.zip.decompressor(f.Method)
+	if dcomp == nil {
+		return nil, 0, ErrAlgorithm
+	}
+	dataOffset, err := f.resolveDataOffset(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	rr, err := f.zips.Range(ctx, dataOffset+rp.compressed, int64(f.CompressedSize64)-rp.compressed)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, rr := f.zip.bufferedRange(rr)
+	var rc io.ReadCloser
+	if rp.bit != 0 || rp.dict != nil {
+		rc = newInflater(data, rp.bit, rp.dict)
+	} else {
+		rc = dcomp(data)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.LimitReader(rc, int64(f.UncompressedSize64)-rp.uncompressed),
+		Closer: closerFunc(func() error {
+			return errs.Combine(rc.Close(), rr.Close())
+		}),
+	}, rp.uncompressed, nil
+}
+
+// resolveDataOffset returns the offset of the entry's content body,
+// reading just the fixed part of the local file header and the name if it
+// is not known yet.
+func (f *File) resolveDataOffset(ctx context.Context) (_ int64, err error) {
+	f.mu.Lock()
+	dataOffset := f.dataOffset
+	f.mu.Unlock()
+	if dataOffset != 0 {
+		return dataOffset, nil
+	}
+
+	rr, err := f.zips.Range(ctx, f.headerOffset, fileHeaderLen+int64(len(f.RawName)))
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errs.Combine(err, rr.Close()) }()
+	extraLen, err := f.readLocalHeader(rr)
+	if err != nil {
+		return 0, err
+	}
+	return f.headerOffset + fileHeaderLen + int64(len(f.RawName)) + int64(extraLen), nil
+}
+
+// ReaderAt returns an io.ReaderAt over the contents of a stored entry,
+// translating each ReadAt into a ranged request on the archive's source.
+// This allows efficient random access to large uncompressed members, and
+// nesting: an archive stored inside an archive can be opened with
+//
+//	ra, err := f.ReaderAt(ctx)
+//	...
+//	inner, err := Open(SourceFromReaderAt(ra, int64(f.UncompressedSize64)))
+//
+// ReaderAt returns ErrAlgorithm for entries that are not stored, and
+// ErrEncrypted for encrypted ones. Checksums are not verified.
+func (f *File) ReaderAt(ctx context.Context) (*StoredReaderAt, error) {
+	if f.Method != Store {
+		return nil, ErrAlgorithm
+	}
+	if f.IsEncrypted() {
+		return nil, ErrEncrypted
+	}
+	dataOffset, err := f.resolveDataOffset(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &StoredReaderAt{
+		ctx:        ctx,
+		source:     f.zips,
+		dataOffset: dataOffset,
+		size:       int64(f.UncompressedSize64),
+	}, nil
+}
+
+// StoredReaderAt provides random access to a stored entry's contents.
+// It is safe for concurrent use.
+type StoredReaderAt struct {
+	ctx        context.Context
+	source     Source
+	dataOffset int64
+	size       int64
+}
+
+// Size returns the size of the entry's contents.
+func (ra *StoredReaderAt) Size() int64 { return ra.size }
+
+func (ra *StoredReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errs.Errorf("negative offset")
+	}
+	if off >= ra.size {
+		return 0, io.EOF
+	}
+	want := p
+	if remaining := ra.size - off; int64(len(want)) > remaining {
+		want = want[:remaining]
+	}
+	rr, err := ra.source.Range(ra.ctx, ra.dataOffset+off, int64(len(want)))
+	if err != nil {
+		return 0, err
+	}
+	n, err = io.ReadFull(rr, want)
+	err = errs.Combine(err, rr.Close())
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,78 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestOpenRange(t *testing.T) {
+	content := make([]byte, 100000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	z := openTestZip(t, buildTestZip(t,
+		testZipFile{Name: "stored", Method: Store, Data: content},
+		testZipFile{Name: "deflated", Method: Deflate, Data: content}), nil)
+
+	for _, f := range z.File {
+		for _, r := range []struct{ off, length, wantEnd int64 }{
+			{0, 10, 10},
+			{12345, 1000, 13345},
+			{99990, 100, 100000},
+			{200000, 10, 100000},
+			{0, 100000, 100000},
+		} {
+			rc, err := f.OpenRange(context.Background(), r.off, r.length)
+			if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+			rc.Close()
+
+			start := r.off
+			if start > int64(len(content)) {
+				start = int64(len(content))
+			}
+			if want := content[start:r.wantEnd]; !bytes.Equal(got, want) {
+				t.Errorf("%s: range %d+%d: got %d bytes, want %d", f.Name, r.off, r.length, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestStoredReaderAt(t *testing.T) {
+	inner := buildTestZip(t, testZipFile{Name: "inner.txt", Method: Deflate, Data: []byte("nested content")})
+	z := openTestZip(t, buildTestZip(t,
+		testZipFile{Name: "inner.zip", Method: Store, Data: inner},
+		testZipFile{Name: "deflated", Method: Deflate, Data: inner}), nil)
+
+	if _, err := z.File[1].ReaderAt(context.Background()); err != ErrAlgorithm {
+		t.Errorf("deflated entry: got %v, want %v", err, ErrAlgorithm)
+	}
+
+	ra, err := z.File[0].ReaderAt(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 10)
+	if n, err := ra.ReadAt(buf, ra.Size()-5); n != 5 || err != io.EOF {
+		t.Errorf("ReadAt at end: got %d, %v; want 5, EOF", n, err)
+	}
+
+	nested, err := Open(SourceFromReaderAt(ra, ra.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readAllFile(nested.File[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "nested content" {
+		t.Errorf("nested content=%q", got)
+	}
+}
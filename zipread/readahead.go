@@ -0,0 +1,112 @@
+package zipread
+
+import (
+	"io"
+)
+
+// readAhead reads from rc in a goroutine, filling the next chunk while the
+// previous one is consumed, so that fetching compressed data from a slow
+// source overlaps with decompressing it. See Options.ReadAhead.
+type readAhead struct {
+	rc io.ReadCloser
+
+	free    chan []byte // empty buffers for the goroutine to fill
+	full    chan chunk  // filled buffers, closed when the goroutine exits
+	done    chan struct{}
+	started bool
+
+	buf []byte // buffer cur belongs to, returned to free once consumed
+	cur []byte
+	err error
+}
+
+type chunk struct {
+	data []byte
+	err  error
+}
+
+// newReadAhead returns a reader of rc fetching ahead in chunks of up to
+// size bytes, but no more than length bytes overall.
+func newReadAhead(rc io.ReadCloser, size, length int64) *readAhead {
+	if size > length && length > 0 {
+		size = length
+	}
+	r := &readAhead{
+		rc:   rc,
+		free: make(chan []byte, 2),
+		full: make(chan chunk, 1),
+		done: make(chan struct{}),
+	}
+	r.free <- make([]byte, size)
+	r.free <- make([]byte, size)
+	return r
+}
+
+func (r *readAhead) run() {
+	defer close(r.full)
+	for {
+		var buf []byte
+		select {
+		case buf = <-r.free:
+		case <-r.done:
+			return
+		}
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+		n, err := io.ReadFull(r.rc, buf)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		select {
+		case r.full <- chunk{data: buf[:n], err: err}:
+		case <-r.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *readAhead) Read(p []byte) (int, error) {
+	if !r.started && r.err == nil {
+		r.started = true
+		go r.run()
+	}
+	for len(r.cur) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.buf != nil {
+			r.free <- r.buf[:cap(r.buf)]
+			r.buf = nil
+		}
+		c, ok := <-r.full
+		if !ok {
+			return 0, errReadAfterClose
+		}
+		r.buf, r.cur, r.err = c.data, c.data, c.err
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// Close stops the goroutine, waiting for a read in progress to return, and
+// then closes the underlying reader, which need not support being closed
+// while it is read from.
+func (r *readAhead) Close() error {
+	if r.err == errReadAfterClose {
+		return nil
+	}
+	r.cur, r.err = nil, errReadAfterClose
+	if r.started {
+		close(r.done)
+		for range r.full {
+		}
+	}
+	return r.rc.Close()
+}
@@ -0,0 +1,118 @@
+package zipread
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestReadAhead(t *testing.T) {
+	content := deflateTestData(100000)
+	data := buildTestZip(t,
+		testZipFile{Name: "stored", Method: Store, Data: content},
+		testZipFile{Name: "deflated", Method: Deflate, Data: content})
+
+	for _, ahead := range []int64{1, 1000, 1 << 20} {
+		z := openTestZip(t, data, &Options{ReadAhead: ahead})
+		for _, f := range z.File {
+			got, err := readAllFile(f)
+			if err != nil {
+				t.Fatalf("ReadAhead %d, %s: %v", ahead, f.Name, err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("ReadAhead %d, %s: content mismatch", ahead, f.Name)
+			}
+
+			// Closing early, before and after reading, stops the goroutine.
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := rc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			rc, err = f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.ReadFull(rc, make([]byte, 10)); err != nil {
+				t.Fatal(err)
+			}
+			if err := rc.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestReadAheadErrors(t *testing.T) {
+	errTestRead := errors.New("test read error")
+	r := newReadAhead(io.NopCloser(io.MultiReader(bytes.NewReader([]byte("abc")), iotest.ErrReader(errTestRead))), 2, 100)
+	got, err := io.ReadAll(r)
+	if string(got) != "abc" || err != errTestRead {
+		t.Errorf("got %q, %v, want %q, %v", got, err, "abc", errTestRead)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(make([]byte, 1)); err != errReadAfterClose {
+		t.Errorf("Read after Close: got %v", err)
+	}
+}
+
+// blockingReader returns one byte, then blocks further reads until release
+// is closed, and records whether Close was called during a read.
+type blockingReader struct {
+	calls   int32
+	reading int32
+	entered chan struct{}
+	release chan struct{}
+	overlap int32
+}
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	atomic.StoreInt32(&b.reading, 1)
+	defer atomic.StoreInt32(&b.reading, 0)
+	if atomic.AddInt32(&b.calls, 1) == 1 {
+		p[0] = 'a'
+		return 1, nil
+	}
+	close(b.entered)
+	<-b.release
+	return 0, io.EOF
+}
+
+func (b *blockingReader) Close() error {
+	if atomic.LoadInt32(&b.reading) != 0 {
+		atomic.StoreInt32(&b.overlap, 1)
+	}
+	return nil
+}
+
+func TestReadAheadCloseWaitsForRead(t *testing.T) {
+	src := &blockingReader{entered: make(chan struct{}), release: make(chan struct{})}
+	r := newReadAhead(src, 1, 100)
+	if _, err := r.Read(make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	<-src.entered
+
+	closed := make(chan error)
+	go func() { closed <- r.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the goroutine was reading")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(src.release)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&src.overlap) != 0 {
+		t.Fatal("source closed while being read")
+	}
+}
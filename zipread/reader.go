@@ -9,18 +9,20 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
 	"io/fs"
-	"io/ioutil"
 	"math"
 	"path"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zeebo/errs/v2"
@@ -30,21 +32,52 @@ var (
 	ErrFormat    = zip.ErrFormat
 	ErrAlgorithm = zip.ErrAlgorithm
 	ErrChecksum  = zip.ErrChecksum
+	ErrLimit     = errors.New("zip: archive exceeds a configured limit")
+	ErrBomb      = errors.New("zip: entry exceeds decompression limits")
+
+	ErrInsecurePath = errors.New("zip: insecure file path")
 )
 
 // A Reader serves content from a ZIP archive.
 type Reader struct {
-	source Source
-	size   int64
+	stats Stats // first, for the alignment of its atomically updated counts
+
+	source     Source
+	size       int64
+	baseOffset int64
+	dirOffset  int64   // offset of the central directory
+	dirLen     int64   // length of the central directory records parsed
+	disks      []int64 // offsets of the disks of a spanned archive, or nil
+	end        *directoryEnd
+
+	File    []*File
+	Comment string
+
+	decompressorsMu sync.RWMutex
+	decompressors   map[uint16]Decompressor
 
-	File          []*File
-	Comment       string
-	decompressors map[uint16]Decompressor
+	// buffers and headers pool the read buffers and local header scratch
+	// space of opened entries, see Options.BufferSize.
+	buffers sync.Pool
+	headers sync.Pool
 
-	// fileList is a list of files sorted by ename,
-	// for use by the Open method.
+	opts        Options
+	annotations annotations
+
+	// dirFiles and dirNames index the file list by directory, for use by
+	// the Open method. fileList holds the directories listed so far.
 	fileListOnce sync.Once
-	fileList     []fileListEntry
+	dirFiles     map[string][]indexEntry
+	dirNames     []string
+	fileListMu   sync.Mutex
+	fileList     map[string]*dirShard
+	dot          fileListEntry
+	keyedOnce    sync.Once
+	keyed        map[string]*fileListEntry // lookupKey -> entry, if keys are used
+
+	// sorted is File sorted by name, for use by the listing methods.
+	sortedOnce sync.Once
+	sorted     []*File
 }
 
 // A File is a single file in a ZIP archive.
@@ -52,115 +85,371 @@ type Reader struct {
 // The file content can be accessed by calling Open.
 type File struct {
 	FileHeader
+
+	// Accessed and Created are the last access and creation times
+	// recorded in the extended timestamp extra field, if present.
+	// They are zero otherwise.
+	Accessed time.Time
+	Created  time.Time
+
+	// SHA256 is the SHA-256 digest of the uncompressed contents recorded
+	// in the extra field with ID 0x6873, whose data is the 32 bytes of the
+	// digest, as zipwrite writes it with Options.SHA256, and nil if there
+	// is none. Reading the whole entry verifies it along with the CRC-32,
+	// as Options.CRCPolicy says.
+	SHA256 []byte
+
+	// RawName and RawComment are the name and comment exactly as stored
+	// in the central directory, before any Options.NameDecoder was applied.
+	RawName    string
+	RawComment string
+
 	zip          *Reader
 	zips         Source
 	zipsize      int64
 	headerOffset int64
+
+	// dataOffset is the offset of the entry's content body, once it has
+	// been resolved by reading the local file header. Zero means unknown.
+	mu         sync.Mutex
+	dataOffset int64
+
+	disk uint32 // number of the disk the entry starts on
+
+	sozip       *SOZipIndex // guarded by mu, valid once sozipLoaded
+	sozipLoaded bool
+
+	deflateIndex *DeflateIndex // guarded by mu
 }
 
+// Open reads the central directory of the ZIP archive served by source.
 func Open(source Source) (*Reader, error) {
+	return OpenWithOptions(source, nil)
+}
+
+// OpenWithOptions is like Open but configures the Reader with opts.
+// A nil opts is equivalent to the zero Options.
+func OpenWithOptions(source Source, opts *Options) (*Reader, error) {
 	zr := &Reader{}
-	if err := zr.init(source); err != nil {
+	if opts != nil {
+		zr.opts = *opts
+	}
+	if err := zr.init(source, nil); err != nil {
 		return nil, err
 	}
-	return zr, nil
+	return zr.checkPaths()
 }
 
-func (z *Reader) init(source Source) (err error) {
-	end, size, err := readDirectoryEnd(source)
+// checkPaths applies Options.InsecurePaths to the opened Reader.
+func (z *Reader) checkPaths() (*Reader, error) {
+	if z.opts.InsecurePaths == PathsAllow || len(z.InsecurePaths()) == 0 {
+		return z, nil
+	}
+	if z.opts.InsecurePaths == PathsReject {
+		return nil, ErrInsecurePath
+	}
+	return z, ErrInsecurePath
+}
+
+func (z *Reader) init(source, dir Source) (err error) {
+	// The directory end records and the central directory are read through
+	// dir, which with Options.TailWindow serves them from a single fetch,
+	// unless the caller has them at hand already.
+	if dir == nil {
+		dir = source
+	}
+	if dir == source && z.opts.TailWindow > 0 {
+		dir, err = PrefetchTail(context.TODO(), source, z.opts.TailWindow)
+		if err != nil {
+			return err
+		}
+	}
+	end, baseOffset, size, err := readDirectoryEnd(dir, z.disks)
 	if err != nil {
 		return err
 	}
+	if err := checkLimit("entries", int64(end.directoryRecords), int64(z.opts.MaxEntries)); err != nil {
+		return err
+	}
+	if err := checkLimit("directory size", int64(end.directorySize), z.opts.MaxDirectorySize); err != nil {
+		return err
+	}
 	z.source = source
 	z.size = size
-	z.File = make([]*File, 0, end.directoryRecords)
+	z.baseOffset = baseOffset
+	z.end = end
+	// Don't let the declared record count alone size an allocation.
+	records := end.directoryRecords
+	if max := uint64(size) / directoryHeaderLen; records > max {
+		records = max
+	}
+	z.File = make([]*File, 0, records)
 	z.Comment = end.comment
-	rs, err := source.Range(context.TODO(), int64(end.directoryOffset), size-int64(end.directoryOffset))
+	dirOffset := baseOffset + int64(end.directoryOffset)
+	z.dirOffset = dirOffset
+	rs, err := dir.Range(context.TODO(), dirOffset, size-dirOffset)
 	if err != nil {
 		return err
 	}
 	defer func() { err = errs.Combine(err, rs.Close()) }()
 	buf := bufio.NewReader(rs)
+	if err := checkDirectoryEncryption(buf); err != nil {
+		return err
+	}
+	newParser := func() *directoryParser {
+		return &directoryParser{file: File{zip: z, zips: source, zipsize: size}}
+	}
+	parser := newParser()
+	next := func() (*File, error) {
+		f := parser.newFile()
+		return f, parser.readDirectoryHeader(f, buf)
+	}
+	if workers := z.opts.ParseWorkers; workers > 1 {
+		n := size - dirOffset
+		if max := z.opts.MaxDirectorySize; max > 0 && n > max+maxDirectoryRecordLen {
+			n = max + maxDirectoryRecordLen
+		}
+		next, err = z.parseDirectory(buf, n, workers, newParser)
+		if err != nil {
+			return err
+		}
+	}
 
 	// The count of files inside a zip is truncated to fit in a uint16.
 	// Gloss over this by reading headers until we encounter
 	// a bad one, and then only report an ErrFormat or UnexpectedEOF if
 	// the file count modulo 65536 is incorrect.
+	offset := dirOffset
+	var totalUncompressed int64
 	for {
-		f := &File{zip: z, zips: source, zipsize: size}
-		err = readDirectoryHeader(f, buf)
+		var f *File
+		f, err = next()
+		if err != nil {
+			var fe *FormatError
+			if errors.As(err, &fe) {
+				fe.Structure = fmt.Sprintf("%s %d", fe.Structure, len(z.File))
+				fe.Offset = offset
+			}
+		}
 		if errors.Is(err, ErrFormat) || errors.Is(err, io.ErrUnexpectedEOF) {
 			break
 		}
 		if err != nil {
 			return err
 		}
+		if z.disks != nil {
+			if int(f.disk) >= len(z.disks) {
+				return formatError(fmt.Sprintf("central directory record %d", len(z.File)), offset,
+					fmt.Sprintf("disk %d out of range", f.disk))
+			}
+			f.headerOffset += z.disks[f.disk]
+		} else {
+			f.headerOffset += baseOffset
+		}
+		offset += directoryHeaderLen + int64(len(f.RawName)+len(f.Extra)+len(f.RawComment))
+		if err := checkLimit("entries", int64(len(z.File)+1), int64(z.opts.MaxEntries)); err != nil {
+			return err
+		}
+		if err := checkLimit("directory size", offset-dirOffset, z.opts.MaxDirectorySize); err != nil {
+			return err
+		}
+		if f.UncompressedSize64 > math.MaxInt64-uint64(totalUncompressed) {
+			totalUncompressed = math.MaxInt64
+		} else {
+			totalUncompressed += int64(f.UncompressedSize64)
+		}
+		if err := checkLimit("total uncompressed size", totalUncompressed, z.opts.MaxTotalUncompressed); err != nil {
+			return err
+		}
+		if err := z.checkNameLimits(f); err != nil {
+			return err
+		}
+		if f.NonUTF8 && z.opts.NameDecoder != nil {
+			f.decodeNames(z.opts.NameDecoder)
+		}
 		z.File = append(z.File, f)
+
+		if z.opts.OpenProgress != nil {
+			z.opts.OpenProgress(DirectoryProgress{
+				Entries:      len(z.File),
+				TotalEntries: end.directoryRecords,
+				Bytes:        offset - dirOffset,
+				TotalBytes:   int64(end.directorySize),
+				Name:         f.Name,
+			})
+		}
 	}
 
+	z.dirLen = offset - dirOffset
+
 	if uint16(len(z.File)) != uint16(end.directoryRecords) { // only compare 16 bits here
 		// Return the readDirectoryHeader error if we read
 		// the wrong number of directory entries.
 		return err
 	}
+	if z.opts.CompactDirectory {
+		z.compactFiles()
+	}
+	if z.opts.RejectOverlaps {
+		return z.CheckOverlaps()
+	}
 	return nil
 }
 
+// BaseOffset returns the length of any data prepended to the archive, such
+// as a self-extractor stub. Offsets recorded in the archive are relative
+// to it.
+func (z *Reader) BaseOffset() int64 { return z.baseOffset }
+
+// DirectoryOffset returns the offset of the central directory in the
+// source, which is where an archive ends that entries are appended to.
+func (z *Reader) DirectoryOffset() int64 { return z.dirOffset }
+
+// EndOffset returns the offset in the source of the end of central
+// directory record, which follows any Zip64 records and holds the archive
+// comment.
+func (z *Reader) EndOffset() int64 { return z.end.offset }
+
+// HeaderOffset returns the offset of the local file header of the entry in
+// the source, including the BaseOffset of the archive.
+func (f *File) HeaderOffset() int64 { return f.headerOffset }
+
 // RegisterDecompressor registers or overrides a custom decompressor for a
 // specific method ID. If a decompressor for a given method is not found,
 // Reader will default to looking up the decompressor at the package level.
+// It is safe to call concurrently with opening files.
 func (z *Reader) RegisterDecompressor(method uint16, dcomp Decompressor) {
+	z.decompressorsMu.Lock()
+	defer z.decompressorsMu.Unlock()
 	if z.decompressors == nil {
 		z.decompressors = make(map[uint16]Decompressor)
 	}
 	z.decompressors[method] = dcomp
 }
 
+// SupportedMethods returns the sorted compression methods the Reader can
+// decompress, including those registered at the package level.
+func (z *Reader) SupportedMethods() []uint16 {
+	z.decompressorsMu.RLock()
+	defer z.decompressorsMu.RUnlock()
+	methods := SupportedMethods()
+	for method, dcomp := range z.decompressors {
+		if dcomp != nil && decompressor(method) == nil {
+			methods = append(methods, method)
+		}
+	}
+	sortMethods(methods)
+	return methods
+}
+
 func (z *Reader) decompressor(method uint16) Decompressor {
+	z.decompressorsMu.RLock()
 	dcomp := z.decompressors[method]
+	z.decompressorsMu.RUnlock()
 	if dcomp == nil {
 		dcomp = decompressor(method)
 	}
 	return dcomp
 }
 
+// decodeNames replaces the entry's name and comment with their decoded
+// forms, leaving the originals in place if decoding fails.
+func (f *File) decodeNames(decode func([]byte) (string, error)) {
+	name, err := decode([]byte(f.RawName))
+	if err != nil {
+		return
+	}
+	comment, err := decode([]byte(f.RawComment))
+	if err != nil {
+		return
+	}
+	f.Name, f.Comment = name, comment
+}
+
 type closerFunc func() error
 
 func (f closerFunc) Close() error { return f() }
 
+// CheckSupported reports, without any I/O, whether Open can be expected to
+// read f: it fails with ErrAlgorithm if f uses a compression method or a
+// feature the Reader does not support, with an *EncryptionError if f is
+// encrypted with an unsupported scheme, and with ErrEncrypted if it is
+// encrypted with ZipCrypto but the Reader has no password configured.
+// Whether a password is right is only known once f is opened.
+func (f *File) CheckSupported() error {
+	if err := f.checkFeatures(); err != nil {
+		return err
+	}
+	if f.zip.decompressor(f.Method) == nil {
+		return ErrAlgorithm
+	}
+	if f.IsEncrypted() && f.zip.opts.Password == "" && f.zip.opts.PasswordProvider == nil {
+		return ErrEncrypted
+	}
+	return nil
+}
+
+const (
+	flagPatched      = 0x20   // contents are a patch against another file
+	flagMaskedHeader = 0x2000 // local header values are masked
+)
+
+// checkFeatures returns an error if f uses encryption or other features,
+// announced in its flags, that Open cannot handle.
+func (f *File) checkFeatures() error {
+	if err := f.encryptionError(); err != nil {
+		return err
+	}
+	switch {
+	case f.Flags&flagPatched != 0:
+		return fmt.Errorf("%w: %q holds patch data", ErrAlgorithm, f.Name)
+	case f.Flags&flagMaskedHeader != 0:
+		return fmt.Errorf("%w: %q has a masked local header", ErrAlgorithm, f.Name)
+	}
+	return nil
+}
+
 // Open returns a ReadCloser that provides access to the File's contents.
-// Multiple files may be read concurrently.
+// Multiple files may be read concurrently. CheckSupported tells in advance
+// whether it is bound to fail.
 func (f *File) Open() (io.ReadCloser, error) {
+	atomic.AddInt64(&f.zip.stats.Opens, 1)
 	size := int64(f.CompressedSize64)
 
+	if err := f.checkFeatures(); err != nil {
+		return nil, err
+	}
 	dcomp := f.zip.decompressor(f.Method)
 	if dcomp == nil {
 		return nil, ErrAlgorithm
 	}
+	if f.opensDirect() {
+		return f.openDirect()
+	}
 
-	// This sucks. The zip central directory entry doesn't have
-	// enough information to actually figure out the exact body offset,
-	// specifically due to the Extra field, which apparently does not
-	// always match in the CEN and LOC headers.
-	// We could either do an additional round trip to read the local
-	// file header, or we could just assume the worst (64KB) and
-	// request extra, limiting it when we find out. We do this
-	// second thing since round trips are the worse outcome.
-	// This is one of the areas where ZIPs don't make a good
-	// remote pack format.
-	const worstCaseExtra = math.MaxUint16 // 64 KB
-
-	rr, err := f.zips.Range(context.TODO(), f.headerOffset, size+fileHeaderLen+int64(len(f.Name))+worstCaseExtra)
+	var (
+		rr     io.ReadCloser
+		data   *bufio.Reader
+		header <-chan error
+		err    error
+	)
+	if chunk := f.parallelChunk(); chunk > 0 {
+		rr, data, err = f.openParallel(chunk)
+	} else if headerLen := f.knownHeaderLen(); headerLen > 0 && f.zip.opts.OverlapHeaderValidation {
+		rr, data, header, err = f.openOverlapped(headerLen)
+	} else {
+		rr, data, err = f.openValidated(false)
+	}
 	if err != nil {
 		return nil, err
 	}
-	data := bufio.NewReader(rr)
-	err = f.validateFileHeader(data)
+
+	body := &byteCounter{r: io.LimitReader(data, size)}
+	plain, err := f.decrypt(context.TODO(), body)
 	if err != nil {
 		return nil, errs.Combine(err, rr.Close())
 	}
-
-	rc := dcomp(io.LimitReader(data, size))
+	rc := dcomp(plain)
 
 	return &checksumReader{
 		rc: struct {
@@ -173,47 +462,192 @@ func (f *File) Open() (io.ReadCloser, error) {
 				return errs.Combine(err1, rr.Close())
 			}),
 		},
-		hash: crc32.NewIEEE(),
-		f:    f,
+		hash:       crc32.NewIEEE(),
+		f:          f,
+		header:     header,
+		policy:     f.zip.opts.CRCPolicy,
+		compressed: body,
 	}, nil
 }
 
-// OpenAsGzip returns a ReadCloser that provides access to the File's compressed contents.
-// This method returns an ErrAlgorithm error if the zip is not compressed using deflate.
-func (f *File) OpenAsGzip() (io.ReadCloser, error) {
-	size := int64(f.CompressedSize64)
+// byteCounter counts the bytes read through it.
+type byteCounter struct {
+	r io.Reader
+	n int64
+}
 
-	if f.Method != Deflate {
-		return nil, ErrAlgorithm
+func (c *byteCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// openValidated requests the local file header and the content body from
+// the source, and returns the body once the header has been validated.
+// Once a previous open has validated the header, only the body is
+// requested. If fresh is set, the request bypasses any caching in the
+// source, and the header is validated again.
+func (f *File) openValidated(fresh bool) (rr io.ReadCloser, data *bufio.Reader, err error) {
+	offset, length := f.headerOffset, f.headerRangeLength()
+	if headerLen := f.knownHeaderLen(); headerLen > 0 && !fresh {
+		offset, length = f.headerOffset+headerLen, int64(f.CompressedSize64)
+	}
+	if fresh {
+		rr, err = rangeUncached(context.TODO(), f.zips, offset, length)
+	} else {
+		rr, err = f.zips.Range(context.TODO(), offset, length)
 	}
-	const worstCaseExtra = math.MaxUint16 // 64 KB
-	rr, err := f.zips.Range(context.TODO(), f.headerOffset, size+fileHeaderLen+int64(len(f.Name))+worstCaseExtra)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if ahead := f.zip.opts.ReadAhead; ahead > 0 {
+		rr = newReadAhead(rr, ahead, length)
+	}
+	data, rr = f.zip.bufferedRange(rr)
+	if offset == f.headerOffset {
+		if err := f.validateFileHeader(data); err != nil {
+			return nil, nil, errs.Combine(err, rr.Close())
+		}
 	}
-	data := bufio.NewReader(rr)
-	err = f.validateFileHeader(data)
+	return rr, data, nil
+}
+
+// headerRangeLength returns the length of the range to request to read
+// the local file header of f and the content body following it.
+func (f *File) headerRangeLength() int64 {
+	// This sucks. The zip central directory entry doesn't have
+	// enough information to actually figure out the exact body offset,
+	// specifically due to the Extra field, which apparently does not
+	// always match in the CEN and LOC headers.
+	// We could either do an additional round trip to read the local
+	// file header, or we could just assume the worst (64KB) and
+	// request extra, limiting it when we find out. We do this
+	// second thing since round trips are the worse outcome.
+	// This is one of the areas where ZIPs don't make a good
+	// remote pack format.
+	const worstCaseExtra = math.MaxUint16 // 64 KB
+
+	length := int64(f.CompressedSize64) + fileHeaderLen + int64(len(f.RawName)) + worstCaseExtra
+	// Strict servers reject ranges reaching past the end of the archive.
+	if remaining := f.zipsize - f.headerOffset; length > remaining {
+		length = remaining
+	}
+	return length
+}
+
+// defaultBufferSize is the size of the buffers entry contents are read
+// through unless Options.BufferSize says otherwise.
+const defaultBufferSize = 4096
+
+// bufferedRange returns a buffered reader for the source range rr, taken
+// from the pool of z, and a range that returns the buffer to the pool once
+// closed. The buffers are the bulk of what opening an entry allocates.
+func (z *Reader) bufferedRange(rr io.ReadCloser) (*bufio.Reader, io.ReadCloser) {
+	br, ok := z.buffers.Get().(*bufio.Reader)
+	if ok {
+		br.Reset(rr)
+	} else {
+		size := z.opts.BufferSize
+		if size <= 0 {
+			size = defaultBufferSize
+		}
+		br = bufio.NewReaderSize(rr, size)
+	}
+	return br, &pooledRange{ReadCloser: rr, buf: br, pool: &z.buffers}
+}
+
+// pooledRange is a source range read through buf, which it returns to
+// pool once closed.
+type pooledRange struct {
+	io.ReadCloser
+	buf  *bufio.Reader
+	pool *sync.Pool
+}
+
+func (p *pooledRange) Close() error {
+	if p.buf != nil {
+		p.buf.Reset(nil)
+		p.pool.Put(p.buf)
+		p.buf = nil
+	}
+	return p.ReadCloser.Close()
+}
+
+// openOverlapped requests exactly the local file header and the content
+// body from the source. Since the header length is already known, the body
+// can be handed to the decompressor right away while the header is
+// validated in the background; the result is delivered on header.
+func (f *File) openOverlapped(headerLen int64) (rr io.ReadCloser, data *bufio.Reader, header <-chan error, err error) {
+	length := headerLen + int64(f.CompressedSize64)
+	rr, err = f.zips.Range(context.TODO(), f.headerOffset, length)
 	if err != nil {
-		return nil, errs.Combine(err, rr.Close())
+		return nil, nil, nil, err
+	}
+	if ahead := f.zip.opts.ReadAhead; ahead > 0 {
+		rr = newReadAhead(rr, ahead, length)
+	}
+	data, rr = f.zip.bufferedRange(rr)
+	buf := make([]byte, headerLen)
+	if _, err = io.ReadFull(data, buf); err != nil {
+		return nil, nil, nil, errs.Combine(err, rr.Close())
 	}
+	validated := make(chan error, 1)
+	go func() {
+		hr := bytes.NewReader(buf)
+		err := f.validateFileHeader(hr)
+		if errors.Is(err, io.ErrUnexpectedEOF) || err == nil && hr.Len() != 0 {
+			err = f.localHeaderError("length differs from the central directory")
+		}
+		validated <- err
+	}()
+	return rr, data, validated, nil
+}
 
-	return ioutil.NopCloser(GzipWrapper(io.LimitReader(data, size), f.CRC32, uint32(f.UncompressedSize64))), nil
+// knownHeaderLen returns the length of the local file header, including
+// the name and extra fields, or zero if it has not been resolved yet.
+func (f *File) knownHeaderLen() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dataOffset == 0 {
+		return 0
+	}
+	return f.dataOffset - f.headerOffset
 }
 
-// GzipWrapper wraps a reader with gzip headers and footers.
-func GzipWrapper(r io.Reader, digest, decompressedSize uint32) io.Reader {
-	const (
-		gzipID1     = 0x1f
-		gzipID2     = 0x8b
-		gzipDeflate = 8
-		osUnknown   = 255
-	)
-	header := [10]byte{0: gzipID1, 1: gzipID2, 2: gzipDeflate, 8: 2, 9: osUnknown}
-	footer := [8]byte{}
-	binary.LittleEndian.PutUint32(footer[:4], digest)
-	binary.LittleEndian.PutUint32(footer[4:8], decompressedSize)
+// OpenAsGzip returns a ReadCloser that provides access to the File's compressed contents.
+// Deflate entries are passed through as is; stored entries are framed as
+// non-compressed deflate blocks on the fly. Other methods return an
+// ErrAlgorithm error, and encrypted entries ErrEncrypted. The returned
+// ReadCloser implements io.WriterTo, which copies the compressed body
+// straight from the source to the destination.
+func (f *File) OpenAsGzip() (io.ReadCloser, error) {
+	if f.Method != Deflate && f.Method != Store {
+		return nil, ErrAlgorithm
+	}
+	if f.IsEncrypted() {
+		return nil, ErrEncrypted
+	}
+	rr, data, err := f.openValidated(false)
+	if err != nil {
+		return nil, err
+	}
 
-	return io.MultiReader(bytes.NewReader(header[:]), r, bytes.NewReader(footer[:]))
+	var body io.Reader = io.LimitReader(data, int64(f.CompressedSize64))
+	if f.Method == Store {
+		body = newStoredDeflater(body, int64(f.CompressedSize64))
+	}
+	g := newGzipWrapper(body, f.CRC32, uint32(f.UncompressedSize64))
+	return &gzipReadCloser{g: g, rr: rr}, nil
+}
+
+// GzipSize returns the length of the stream OpenAsGzip produces for f.
+// It is only meaningful for methods OpenAsGzip supports.
+func (f *File) GzipSize() int64 {
+	size := int64(f.CompressedSize64)
+	if f.Method == Store {
+		size = storedDeflateSize(size)
+	}
+	return size + gzipOverhead
 }
 
 type checksumReader struct {
@@ -223,10 +657,21 @@ type checksumReader struct {
 	f     *File
 	desr  io.Reader // if non-nil, where to read the data descriptor
 	err   error     // sticky error
+
+	digest hash.Hash // SHA-256 of the data read, for entries with File.SHA256
+
+	header <-chan error // if non-nil, delivers the local header validation result
+	policy CRCPolicy
+	crcErr error // checksum mismatch held back until Close under CRCReport
+
+	unverified bool // set once a seek broke up contiguous reading
+	closed     bool
+
+	compressed *byteCounter // if non-nil, counts the compressed bytes consumed
 }
 
 func (r *checksumReader) Stat() (fs.FileInfo, error) {
-	return headerFileInfo{&r.f.FileHeader}, nil
+	return r.f.stat(path.Base(r.f.zip.fsName(r.f.Name))), nil
 }
 
 func (r *checksumReader) Read(b []byte) (n int, err error) {
@@ -234,12 +679,35 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 		return 0, r.err
 	}
 	n, err = r.rc.Read(b)
-	r.hash.Write(b[:n])
+	if r.policy != CRCOff {
+		r.hash.Write(b[:n])
+		if r.f.SHA256 != nil {
+			if r.digest == nil {
+				r.digest = sha256.New()
+			}
+			r.digest.Write(b[:n])
+		}
+	}
 	r.nread += uint64(n)
+	if berr := r.checkBomb(); berr != nil {
+		r.err = berr
+		return n, berr
+	}
 	if err == nil {
 		return
 	}
 	if errors.Is(err, io.EOF) {
+		if herr := r.headerResult(); herr != nil {
+			r.err = herr
+			return 0, herr
+		}
+		if r.unverified {
+			if r.nread < r.f.UncompressedSize64 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			r.err = err
+			return
+		}
 		if r.nread != r.f.UncompressedSize64 {
 			return 0, io.ErrUnexpectedEOF
 		}
@@ -247,51 +715,375 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 		// We still compare the CRC32 of what we've read
 		// against the file header or TOC's CRC32, if it seems
 		// like it was set.
-		if r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
-			err = ErrChecksum
+		if r.policy != CRCOff && r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
+			crcErr := &ChecksumError{
+				Name:      r.f.Name,
+				Expected:  r.f.CRC32,
+				Computed:  r.hash.Sum32(),
+				BytesRead: r.nread,
+			}
+			atomic.AddInt64(&r.f.zip.stats.ChecksumErrors, 1)
+			if r.f.zip.opts.RetryChecksum {
+				r.f.recheck(crcErr)
+			}
+			if r.policy == CRCReport {
+				r.crcErr = crcErr
+			} else {
+				err = crcErr
+			}
+		}
+		if r.policy != CRCOff && r.f.SHA256 != nil && errors.Is(err, io.EOF) && r.crcErr == nil &&
+			!bytes.Equal(r.digest.Sum(nil), r.f.SHA256) {
+			digestErr := fmt.Errorf("%w: %q: SHA-256 digest mismatch over %d bytes", ErrChecksum, r.f.Name, r.nread)
+			atomic.AddInt64(&r.f.zip.stats.ChecksumErrors, 1)
+			if r.policy == CRCReport {
+				r.crcErr = digestErr
+			} else {
+				err = digestErr
+			}
 		}
 	}
 	r.err = err
 	return
 }
 
-func (r *checksumReader) Close() error { return r.rc.Close() }
+// checkBomb returns ErrBomb if the output so far exceeds the limits set
+// by Options.MaxCompressionRatio or Options.UncompressedSizeMargin.
+func (r *checksumReader) checkBomb() error {
+	opts := &r.f.zip.opts
+	if margin := opts.UncompressedSizeMargin; margin > 0 && !r.unverified &&
+		r.nread > r.f.UncompressedSize64 && r.nread-r.f.UncompressedSize64 > uint64(margin) {
+		return fmt.Errorf("%w: %q produced more than %d bytes beyond its declared size of %d",
+			ErrBomb, r.f.Name, margin, r.f.UncompressedSize64)
+	}
+	if ratio := opts.MaxCompressionRatio; ratio > 0 && !r.unverified && r.compressed != nil &&
+		float64(r.nread) > ratio*float64(r.compressed.n+1) {
+		return fmt.Errorf("%w: %q produced %d bytes from %d compressed bytes",
+			ErrBomb, r.f.Name, r.nread, r.compressed.n)
+	}
+	return nil
+}
+
+func (r *checksumReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	// The buffers behind rc go back to their pools, so it must not be
+	// read from anymore.
+	r.err = errReadAfterClose
+	return errs.Combine(r.rc.Close(), r.crcErr, r.headerResult())
+}
+
+// headerResult waits for the result of the overlapped validation of the
+// local file header, if it has not been collected yet.
+func (r *checksumReader) headerResult() error {
+	if r.header == nil {
+		return nil
+	}
+	err := <-r.header
+	r.header = nil
+	return err
+}
+
+// A FormatError describes a malformed structure in an archive. It matches
+// ErrFormat with errors.Is.
+type FormatError struct {
+	Structure string // the structure that failed to parse, e.g. "end of central directory"
+	Offset    int64  // its offset in the archive, or -1 if unknown
+	Reason    string
+}
+
+func (e *FormatError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("%v: %s: %s", ErrFormat, e.Structure, e.Reason)
+	}
+	return fmt.Sprintf("%v: %s at offset %d: %s", ErrFormat, e.Structure, e.Offset, e.Reason)
+}
+
+func (e *FormatError) Unwrap() error { return ErrFormat }
+
+func formatError(structure string, offset int64, reason string) error {
+	return &FormatError{Structure: structure, Offset: offset, Reason: reason}
+}
+
+// localHeaderError returns a FormatError for the local file header of f.
+func (f *File) localHeaderError(reason string) error {
+	return formatError(fmt.Sprintf("local file header of %q", f.Name), f.headerOffset, reason)
+}
+
+// A LimitError reports which of the limits set in Options an archive
+// exceeds. It matches ErrLimit with errors.Is.
+type LimitError struct {
+	Limit string // "entries", "directory size", "total uncompressed size", "name length" or "path depth"
+	Max   int64
+	Value int64  // the value found, possibly only counted up to the limit
+	Name  string // the offending entry, for per-entry limits
+}
+
+func (e *LimitError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%v: %s %d of %q exceeds %d", ErrLimit, e.Limit, e.Value, e.Name, e.Max)
+	}
+	return fmt.Sprintf("%v: %s %d exceeds %d", ErrLimit, e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error { return ErrLimit }
+
+// checkNameLimits applies Options.MaxNameLength and Options.MaxPathDepth
+// to f.
+func (z *Reader) checkNameLimits(f *File) error {
+	err := checkLimit("name length", int64(len(f.RawName)), int64(z.opts.MaxNameLength))
+	if err == nil && z.opts.MaxPathDepth > 0 {
+		depth := 0
+		if name := toValidName(f.Name); name != "." {
+			depth = strings.Count(name, "/") + 1
+		}
+		err = checkLimit("path depth", int64(depth), int64(z.opts.MaxPathDepth))
+	}
+	if err != nil {
+		err.(*LimitError).Name = f.Name
+	}
+	return err
+}
+
+// checkLimit returns a LimitError if max is positive and value exceeds it.
+func checkLimit(limit string, value, max int64) error {
+	if max > 0 && value > max {
+		return &LimitError{Limit: limit, Max: max, Value: value}
+	}
+	return nil
+}
+
+// ChecksumError describes a checksum mismatch. It matches ErrChecksum
+// with errors.Is.
+type ChecksumError struct {
+	Name      string
+	Expected  uint32 // CRC-32 recorded in the archive
+	Computed  uint32 // CRC-32 of the data read
+	BytesRead uint64
+
+	// Retried is set if the entry was fetched again to check the
+	// mismatch, see Options.RetryChecksum.
+	Retried bool
+
+	// Transient reports whether a fresh fetch of the entry did match its
+	// checksum, meaning the data delivered was corrupted in transit rather
+	// than in the archive. Reading the entry again should succeed.
+	Transient bool
+
+	// RetryErr holds the error that prevented the fresh fetch from being
+	// checked, if any.
+	RetryErr error
+}
+
+func (e *ChecksumError) Error() string {
+	msg := fmt.Sprintf("%v: %q: expected %08x, computed %08x over %d bytes",
+		ErrChecksum, e.Name, e.Expected, e.Computed, e.BytesRead)
+	switch {
+	case !e.Retried:
+		return msg
+	case e.RetryErr != nil:
+		return fmt.Sprintf("%s (retry failed: %v)", msg, e.RetryErr)
+	case e.Transient:
+		return msg + " (transient, fresh fetch matched)"
+	default:
+		return msg + " (archive is corrupt)"
+	}
+}
+
+func (e *ChecksumError) Unwrap() error { return ErrChecksum }
+
+// recheck fetches and decompresses the entry once more, bypassing any
+// source caching, to find out whether the checksum mismatch cerr is
+// transient.
+func (f *File) recheck(cerr *ChecksumError) {
+	cerr.Retried = true
+	crc, err := f.freshChecksum()
+	if err != nil {
+		cerr.RetryErr = err
+		return
+	}
+	cerr.Transient = crc == f.CRC32
+}
+
+func (f *File) freshChecksum() (_ uint32, err error) {
+	dcomp := f.zip.decompressor(f.Method)
+	if dcomp == nil {
+		return 0, ErrAlgorithm
+	}
+	rr, data, err := f.openValidated(true)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errs.Combine(err, rr.Close()) }()
+
+	plain, err := f.decrypt(context.TODO(), io.LimitReader(data, int64(f.CompressedSize64)))
+	if err != nil {
+		return 0, err
+	}
+	rc := dcomp(plain)
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, rc); err != nil {
+		return 0, err
+	}
+	return hash.Sum32(), nil
+}
 
 // validateFileHeader reads off the header, fast-forwarding data to
 // start at the content body.
 func (f *File) validateFileHeader(data io.Reader) (err error) {
-	buf := make([]byte, fileHeaderLen+len(f.Name))
-	if _, err = io.ReadFull(data, buf[:]); err != nil {
+	extraLen, err := f.readLocalHeader(data)
+	if err != nil {
+		return err
+	}
+	if _, err = io.CopyN(io.Discard, data, int64(extraLen)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return err
 	}
+	return nil
+}
+
+// readLocalHeader reads and checks the fixed part of the local file header
+// and the name, records the resolved data offset, and returns the length
+// of the extra field that follows.
+func (f *File) readLocalHeader(data io.Reader) (extraLen int, err error) {
+	scratch, _ := f.zip.headers.Get().(*[]byte)
+	if scratch == nil || cap(*scratch) < fileHeaderLen+len(f.RawName) {
+		b := make([]byte, fileHeaderLen+len(f.RawName))
+		scratch = &b
+	}
+	defer f.zip.headers.Put(scratch)
+	buf := (*scratch)[:fileHeaderLen+len(f.RawName)]
+	if _, err = io.ReadFull(data, buf); err != nil {
+		return 0, err
+	}
 
-	b := readBuf(buf[:])
+	b := readBuf(buf)
 	if sig := b.uint32(); sig != fileHeaderSignature {
-		return ErrFormat
+		return 0, f.localHeaderError("bad signature")
 	}
-	b = b[22:] // skip over most of the header
+	b = b[2:] // skip over the version needed
+	flags := b.uint16()
+	method := b.uint16()
+	b = b[16:] // skip over the time, date, checksum and sizes
 	filenameLen := int(b.uint16())
-	extraLen := int(b.uint16())
-	if filenameLen != len(f.Name) {
-		return ErrFormat
+	extraLen = int(b.uint16())
+	if filenameLen != len(f.RawName) {
+		return 0, f.localHeaderError("name length differs from the central directory")
 	}
-	if _, err = io.ReadFull(data, make([]byte, extraLen)); err != nil {
-		return err
+	if opts := &f.zip.opts; opts.VerifyLocalName || opts.VerifyLocalMethod {
+		if name := buf[fileHeaderLen:]; string(name) != f.RawName {
+			return 0, f.localHeaderError(fmt.Sprintf("name %q differs from the central directory", name))
+		}
 	}
-	return nil
+	if f.zip.opts.VerifyLocalMethod {
+		if method != f.Method {
+			return 0, f.localHeaderError(fmt.Sprintf("method %d differs from %d in the central directory", method, f.Method))
+		}
+		if flags != f.Flags {
+			return 0, f.localHeaderError(fmt.Sprintf("flags %#x differ from %#x in the central directory", flags, f.Flags))
+		}
+	}
+
+	f.mu.Lock()
+	f.dataOffset = f.headerOffset + fileHeaderLen + int64(filenameLen) + int64(extraLen)
+	f.mu.Unlock()
+	return extraLen, nil
 }
 
 // readDirectoryHeader attempts to read a directory header from r.
 // It returns io.ErrUnexpectedEOF if it cannot read a complete header,
 // and ErrFormat if it doesn't find a valid header signature.
 func readDirectoryHeader(f *File, r io.Reader) error {
-	var buf [directoryHeaderLen]byte
-	if _, err := io.ReadFull(r, buf[:]); err != nil {
+	return new(directoryParser).readDirectoryHeader(f, r)
+}
+
+// A directoryParser reads central directory records. It reuses its scratch
+// space from record to record and hands out Files, names and extra fields
+// from larger blocks, so that most records do not cost an allocation of
+// their own.
+type directoryParser struct {
+	file    File // template for the Files handed out
+	files   []File
+	extras  []byte
+	names   nameArena
+	blocks  int // number of blocks of Files allocated so far
+	scratch []byte
+}
+
+// maxParserBlockShift sets the largest number of Files a directoryParser
+// allocates at once to 256. Blocks start small and double, so that small
+// archives do not pay for it.
+const maxParserBlockShift = 8
+
+// blockSize returns the size of the next block for perFile units per File.
+func (p *directoryParser) blockSize(perFile int) int {
+	if p.blocks < maxParserBlockShift {
+		return perFile << p.blocks
+	}
+	return perFile << maxParserBlockShift
+}
+
+func (p *directoryParser) newFile() *File {
+	if len(p.files) == 0 {
+		p.files = make([]File, p.blockSize(1))
+		p.blocks++
+	}
+	f := &p.files[0]
+	p.files = p.files[1:]
+	f.zip, f.zips, f.zipsize = p.file.zip, p.file.zips, p.file.zipsize
+	return f
+}
+
+// extra returns a copy of b carved from the current block.
+func (p *directoryParser) extra(b []byte) []byte {
+	if len(b) == 0 {
+		return []byte{}
+	}
+	if len(b) > len(p.extras) {
+		n := p.blockSize(64)
+		if len(b) > n/4 {
+			return append([]byte(nil), b...)
+		}
+		p.extras = make([]byte, n)
+	}
+	e := p.extras[:len(b):len(b)]
+	p.extras = p.extras[len(b):]
+	copy(e, b)
+	return e
+}
+
+// string returns b as a string carved from the current block of names,
+// sharing the bytes of the name before when b starts with it.
+func (p *directoryParser) string(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if p.names.free() < p.names.size(b) {
+		n := p.blockSize(64)
+		if len(b) > n/4 {
+			return string(b)
+		}
+		p.names.reset(n)
+	}
+	return p.names.add(b)
+}
+
+func (p *directoryParser) readDirectoryHeader(f *File, r io.Reader) error {
+	if cap(p.scratch) < directoryHeaderLen {
+		p.scratch = make([]byte, 0, 1024)
+	}
+	buf := p.scratch[:directoryHeaderLen]
+	if _, err := io.ReadFull(r, buf); err != nil {
 		return err
 	}
-	b := readBuf(buf[:])
+	b := readBuf(buf)
 	if sig := b.uint32(); sig != directoryHeaderSignature {
-		return ErrFormat
+		return formatError("central directory record", -1, "bad signature")
 	}
 	f.CreatorVersion = b.uint16()
 	f.ReaderVersion = b.uint16()
@@ -307,16 +1099,21 @@ func readDirectoryHeader(f *File, r io.Reader) error {
 	filenameLen := int(b.uint16())
 	extraLen := int(b.uint16())
 	commentLen := int(b.uint16())
-	b = b[4:] // skipped start disk number and internal attributes (2x uint16)
+	f.disk = uint32(b.uint16())
+	b = b[2:] // skipped internal attributes (uint16)
 	f.ExternalAttrs = b.uint32()
 	f.headerOffset = int64(b.uint32())
-	d := make([]byte, filenameLen+extraLen+commentLen)
+	if n := filenameLen + extraLen + commentLen; cap(p.scratch) < n {
+		p.scratch = make([]byte, 0, n)
+	}
+	d := p.scratch[:filenameLen+extraLen+commentLen]
 	if _, err := io.ReadFull(r, d); err != nil {
 		return err
 	}
-	f.Name = string(d[:filenameLen])
-	f.Extra = d[filenameLen : filenameLen+extraLen]
-	f.Comment = string(d[filenameLen+extraLen:])
+	f.Name = p.string(d[:filenameLen])
+	f.Extra = p.extra(d[filenameLen : filenameLen+extraLen])
+	f.Comment = p.string(d[filenameLen+extraLen:])
+	f.RawName, f.RawComment = f.Name, f.Comment
 
 	// Determine the character encoding.
 	utf8Valid1, utf8Require1 := detectUTF8(f.Name)
@@ -339,6 +1136,7 @@ func readDirectoryHeader(f *File, r io.Reader) error {
 	needUSize := f.UncompressedSize == ^uint32(0)
 	needCSize := f.CompressedSize == ^uint32(0)
 	needHeaderOffset := f.headerOffset == int64(^uint32(0))
+	needDisk := f.disk == uint32(^uint16(0))
 
 	// Best effort to find what we need.
 	// Other zip authors might not even follow the basic format,
@@ -362,24 +1160,28 @@ parseExtras:
 			if needUSize {
 				needUSize = false
 				if len(fieldBuf) < 8 {
-					return ErrFormat
+					return formatError("central directory record", -1, "short zip64 extra field")
 				}
 				f.UncompressedSize64 = fieldBuf.uint64()
 			}
 			if needCSize {
 				needCSize = false
 				if len(fieldBuf) < 8 {
-					return ErrFormat
+					return formatError("central directory record", -1, "short zip64 extra field")
 				}
 				f.CompressedSize64 = fieldBuf.uint64()
 			}
 			if needHeaderOffset {
 				needHeaderOffset = false
 				if len(fieldBuf) < 8 {
-					return ErrFormat
+					return formatError("central directory record", -1, "short zip64 extra field")
 				}
 				f.headerOffset = int64(fieldBuf.uint64())
 			}
+			if needDisk && len(fieldBuf) >= 4 {
+				needDisk = false
+				f.disk = fieldBuf.uint32()
+			}
 		case ntfsExtraID:
 			if len(fieldBuf) < 4 {
 				continue parseExtras
@@ -410,12 +1212,30 @@ parseExtras:
 			fieldBuf.uint32()              // AcTime (ignored)
 			ts := int64(fieldBuf.uint32()) // ModTime since Unix epoch
 			modified = time.Unix(ts, 0)
+		case sha256ExtraID:
+			if len(fieldBuf) == sha256.Size {
+				f.SHA256 = append([]byte(nil), fieldBuf...)
+			}
 		case extTimeExtraID:
-			if len(fieldBuf) < 5 || fieldBuf.uint8()&1 == 0 {
+			if len(fieldBuf) < 1 {
 				continue parseExtras
 			}
-			ts := int64(fieldBuf.uint32()) // ModTime since Unix epoch
-			modified = time.Unix(ts, 0)
+			// The flags tell which timestamps the local header carries.
+			// The central directory copy may be truncated to just the
+			// modification time, so only decode what is actually present.
+			flags := fieldBuf.uint8()
+			if flags&1 != 0 && len(fieldBuf) >= 4 {
+				ts := int64(fieldBuf.uint32()) // ModTime since Unix epoch
+				modified = time.Unix(ts, 0)
+			}
+			if flags&2 != 0 && len(fieldBuf) >= 4 {
+				ts := int64(fieldBuf.uint32()) // AcTime since Unix epoch
+				f.Accessed = time.Unix(ts, 0).UTC()
+			}
+			if flags&4 != 0 && len(fieldBuf) >= 4 {
+				ts := int64(fieldBuf.uint32()) // CrTime since Unix epoch
+				f.Created = time.Unix(ts, 0).UTC()
+			}
 		}
 	}
 
@@ -448,13 +1268,17 @@ parseExtras:
 	_ = needUSize
 
 	if needCSize || needHeaderOffset {
-		return ErrFormat
+		return formatError("central directory record", -1, "missing zip64 extra field")
 	}
 
 	return nil
 }
 
-func readDirectoryEnd(source Source) (dir *directoryEnd, size int64, err error) {
+// readDirectoryEnd finds and reads the directory end record. For spanned
+// archives, disks holds the offset of each disk in source and the
+// directory offset is made absolute; otherwise the offset of any data
+// prepended to the archive is returned as baseOffset.
+func readDirectoryEnd(source Source, disks []int64) (dir *directoryEnd, baseOffset, size int64, err error) {
 	// look for directoryEndSignature in the last 1k, then in the last 65k
 	var buf []byte
 	var directoryEndOffset int64
@@ -464,7 +1288,7 @@ func readDirectoryEnd(source Source) (dir *directoryEnd, size int64, err error)
 		var r io.ReadCloser
 		r, size, err = source.RangeFromEnd(context.TODO(), bLen)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, 0, err
 		}
 
 		n, err := io.ReadFull(r, buf)
@@ -472,21 +1296,23 @@ func readDirectoryEnd(source Source) (dir *directoryEnd, size int64, err error)
 			err = nil
 		}
 		if err != nil {
-			return nil, 0, errs.Combine(err, r.Close())
+			return nil, 0, 0, errs.Combine(err, r.Close())
 		}
 		err = r.Close()
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, 0, err
 		}
 		buf = buf[:n]
 
-		if p := findSignatureInBlock(buf); p >= 0 {
+		// A record found in the first block that is inconsistent with the
+		// archive may be part of the comment of one further back.
+		if p, ok := findDirectoryEnd(source, buf, size-int64(n)); p >= 0 && (ok || i == 1 || int64(n) == size) {
 			buf = buf[p:]
 			directoryEndOffset = size - int64(n) + int64(p)
 			break
 		}
 		if i == 1 || int64(n) == size {
-			return nil, 0, ErrFormat
+			return nil, 0, 0, formatError("end of central directory", -1, "signature not found")
 		}
 	}
 
@@ -500,34 +1326,85 @@ func readDirectoryEnd(source Source) (dir *directoryEnd, size int64, err error)
 		directorySize:      uint64(b.uint32()),
 		directoryOffset:    uint64(b.uint32()),
 		commentLen:         b.uint16(),
+		offset:             directoryEndOffset,
 	}
 	l := int(d.commentLen)
 	if l > len(b) {
-		return nil, 0, errors.New("zip: invalid comment length")
+		return nil, 0, 0, errors.New("zip: invalid comment length")
 	}
 	d.comment = string(b[:l])
 
 	// These values mean that the file can be a zip64 file
 	if d.directoryRecords == 0xffff || d.directorySize == 0xffff || d.directoryOffset == 0xffffffff {
-		p, err := findDirectory64End(source, directoryEndOffset)
+		legacy := *d
+		p, err := findDirectory64End(source, directoryEndOffset, disks)
 		if err == nil && p >= 0 {
 			err = readDirectory64End(source, p, d)
+			// With data prepended to the archive the recorded offset is
+			// off by the length of that data. The record normally sits
+			// right before its locator, so look there instead.
+			if actual := directoryEndOffset - directory64LocLen - directory64EndLen; errors.Is(err, ErrFormat) && actual > p {
+				p, err = actual, readDirectory64End(source, actual, d)
+			}
+			directoryEndOffset = p
+			d.zip64Offset, d.legacy = p, &legacy
 		}
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, 0, err
+		}
+	}
+
+	if disks != nil {
+		if int64(d.dirDiskNbr) >= int64(len(disks)) {
+			return nil, 0, 0, formatError("end of central directory", directoryEndOffset,
+				fmt.Sprintf("central directory disk %d out of range", d.dirDiskNbr))
+		}
+		d.directoryOffset += uint64(disks[d.dirDiskNbr])
+		if o := int64(d.directoryOffset); o >= size {
+			return nil, 0, 0, formatError("end of central directory", directoryEndOffset,
+				fmt.Sprintf("central directory offset %d out of range", o))
 		}
+		return d, 0, size, nil
+	}
+
+	// The central directory ends where the directory end starts, so any
+	// difference from the recorded offset is data prepended to the
+	// archive, such as a self-extractor stub.
+	baseOffset = directoryEndOffset - int64(d.directorySize) - int64(d.directoryOffset)
+	if baseOffset < 0 {
+		baseOffset = 0
 	}
 	// Make sure directoryOffset points to somewhere in our file.
-	if o := int64(d.directoryOffset); o < 0 || o >= size {
-		return nil, 0, ErrFormat
+	if o := baseOffset + int64(d.directoryOffset); o < 0 || o >= size {
+		return nil, 0, 0, formatError("end of central directory", directoryEndOffset,
+			fmt.Sprintf("central directory offset %d out of range", o))
+	}
+	// Some writers record a directory size that includes padding or other
+	// data. If a directory header is found at the recorded offset, trust
+	// it rather than the computed base offset.
+	if baseOffset > 0 && hasDirectoryHeaderAt(source, int64(d.directoryOffset)) {
+		baseOffset = 0
 	}
-	return d, size, nil
+	return d, baseOffset, size, nil
+}
+
+// hasDirectoryHeaderAt reports whether a central directory header
+// signature is found at offset.
+func hasDirectoryHeaderAt(source Source, offset int64) bool {
+	r, err := source.Range(context.TODO(), offset, 4)
+	if err != nil {
+		return false
+	}
+	var buf [4]byte
+	_, err = io.ReadFull(r, buf[:])
+	err = errs.Combine(err, r.Close())
+	return err == nil && binary.LittleEndian.Uint32(buf[:]) == directoryHeaderSignature
 }
 
 // findDirectory64End tries to read the zip64 locator just before the
 // directory end and returns the offset of the zip64 directory end if
 // found.
-func findDirectory64End(source Source, directoryEndOffset int64) (int64, error) {
+func findDirectory64End(source Source, directoryEndOffset int64, disks []int64) (int64, error) {
 	locOffset := directoryEndOffset - directory64LocLen
 	if locOffset < 0 {
 		return -1, nil // no need to look for a header outside the file
@@ -549,12 +1426,22 @@ func findDirectory64End(source Source, directoryEndOffset int64) (int64, error)
 	if sig := b.uint32(); sig != directory64LocSignature {
 		return -1, nil
 	}
-	if b.uint32() != 0 { // number of the disk with the start of the zip64 end of central directory
-		return -1, nil // the file is not a valid zip64-file
+	disk := b.uint32()  // number of the disk with the start of the zip64 end of central directory
+	p := b.uint64()     // relative offset of the zip64 end of central directory record
+	total := b.uint32() // total number of disks
+	if disks == nil {
+		if disk != 0 || total != 1 {
+			return -1, nil // the file is not a valid zip64-file
+		}
+	} else {
+		if int64(total) != int64(len(disks)) || disk >= total {
+			return -1, nil // the file is not a valid zip64-file
+		}
+		p += uint64(disks[disk])
 	}
-	p := b.uint64()      // relative offset of the zip64 end of central directory record
-	if b.uint32() != 1 { // total number of disks
-		return -1, nil // the file is not a valid zip64-file
+	if p > uint64(locOffset) {
+		return -1, formatError("zip64 end of central directory locator", locOffset,
+			fmt.Sprintf("record offset %d out of range", p))
 	}
 	return int64(p), nil
 }
@@ -577,7 +1464,7 @@ func readDirectory64End(source Source, offset int64, d *directoryEnd) (err error
 
 	b := readBuf(buf)
 	if sig := b.uint32(); sig != directory64EndSignature {
-		return ErrFormat
+		return formatError("zip64 end of central directory", offset, "bad signature")
 	}
 
 	b = b[12:]                        // skip dir size, version and version needed (uint64 + 2x uint16)
@@ -591,8 +1478,74 @@ func readDirectory64End(source Source, offset int64, d *directoryEnd) (err error
 	return nil
 }
 
+// findDirectoryEnd returns the position in b, which ends at the end of the
+// archive and starts at offset, of the end of central directory record.
+// The archive comment may itself contain the record's signature, so when
+// there are several candidates, each is checked from the last one on: its
+// comment should reach the end of the archive and its central directory
+// should lie before it and start with a directory header. Archives with
+// trailing data are accepted if no candidate passes the comment check.
+// If none passes at all, the last candidate is returned with ok unset.
+func findDirectoryEnd(source Source, b []byte, offset int64) (p int, ok bool) {
+	var candidates []int
+	for p := findSignatureInBlock(b); p >= 0; p = findSignatureBefore(b, p) {
+		candidates = append(candidates, p)
+	}
+	switch len(candidates) {
+	case 0:
+		return -1, false
+	case 1:
+		// Spare the request for the directory header.
+		source = nil
+	}
+	for _, strict := range []bool{true, false} {
+		for _, p := range candidates {
+			if plausibleDirectoryEnd(source, b[p:], offset+int64(p), strict) {
+				return p, true
+			}
+		}
+	}
+	return candidates[0], false
+}
+
+// plausibleDirectoryEnd reports whether the end of central directory
+// record at the start of b, at offset in the archive, is consistent with
+// the rest of it. If strict is set, its comment has to end exactly at the
+// end of b. The directory header is only looked for if source is non-nil.
+func plausibleDirectoryEnd(source Source, b []byte, offset int64, strict bool) bool {
+	r := readBuf(b[8:]) // skip over the signature and disk numbers
+	r.uint16()          // records on this disk
+	records := int64(r.uint16())
+	size := int64(r.uint32())
+	dirOffset := int64(r.uint32())
+	commentLen := int(r.uint16())
+	if strict && directoryEndLen+commentLen != len(b) {
+		return false
+	}
+	if records == 0xffff || size == 0xffffffff || dirOffset == 0xffffffff {
+		return true // zip64, the values are elsewhere
+	}
+	if dirOffset+size > offset || size < records*directoryHeaderLen {
+		return false
+	}
+	if records == 0 {
+		// Without data prepended, an empty directory is where the record is.
+		return size == 0 && dirOffset == offset
+	}
+	return source == nil || hasDirectoryHeaderAt(source, offset-size)
+}
+
 func findSignatureInBlock(b []byte) int {
-	for i := len(b) - directoryEndLen; i >= 0; i-- {
+	return findSignatureBefore(b, len(b)-directoryEndLen+1)
+}
+
+// findSignatureBefore is like findSignatureInBlock, but only considers
+// records starting before end.
+func findSignatureBefore(b []byte, end int) int {
+	if end > len(b)-directoryEndLen+1 {
+		end = len(b) - directoryEndLen + 1
+	}
+	for i := end - 1; i >= 0; i-- {
 		// defined from directoryEndSignature in struct.go
 		if b[i] == 'P' && b[i+1] == 'K' && b[i+2] == 0x05 && b[i+3] == 0x06 {
 			// n is length of comment
@@ -643,6 +1596,10 @@ type fileListEntry struct {
 	name  string
 	file  *File
 	isDir bool
+
+	// For directories.
+	mode  fs.FileMode // permission bits
+	below *dirTime    // if there is no file, see ModTime
 }
 
 type fileInfoDirEntry interface {
@@ -652,7 +1609,7 @@ type fileInfoDirEntry interface {
 
 func (e *fileListEntry) stat() fileInfoDirEntry {
 	if !e.isDir {
-		return headerFileInfo{&e.file.FileHeader}
+		return e.file.stat(path.Base(e.name))
 	}
 	return e
 }
@@ -660,20 +1617,50 @@ func (e *fileListEntry) stat() fileInfoDirEntry {
 // Only used for directories.
 func (f *fileListEntry) Name() string      { _, elem, _ := split(f.name); return elem }
 func (f *fileListEntry) Size() int64       { return 0 }
-func (f *fileListEntry) Mode() fs.FileMode { return fs.ModeDir | 0555 }
+func (f *fileListEntry) Mode() fs.FileMode { return fs.ModeDir | f.mode }
 func (f *fileListEntry) Type() fs.FileMode { return fs.ModeDir }
 func (f *fileListEntry) IsDir() bool       { return true }
 func (f *fileListEntry) Sys() interface{}  { return nil }
 
 func (f *fileListEntry) ModTime() time.Time {
 	if f.file == nil {
-		return time.Time{}
+		if f.below == nil {
+			return time.Time{}
+		}
+		return f.below.modTime()
 	}
 	return f.file.FileHeader.Modified.UTC()
 }
 
 func (f *fileListEntry) Info() (fs.FileInfo, error) { return f, nil }
 
+// InsecurePaths returns the entries whose names are not local, relative,
+// slash-separated paths: absolute names, names with a drive letter, names
+// escaping the root with "..", and names containing backslashes or NUL
+// bytes. Empty names, which the format permits, are not reported.
+func (z *Reader) InsecurePaths() []*File {
+	var files []*File
+	for _, f := range z.File {
+		if isInsecurePath(f.Name) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func isInsecurePath(name string) bool {
+	switch {
+	case name == "":
+		return false
+	case strings.ContainsAny(name, "\\\x00"),
+		strings.HasPrefix(name, "/"),
+		len(name) >= 2 && name[1] == ':':
+		return true
+	}
+	p := path.Clean(name)
+	return p == ".." || strings.HasPrefix(p, "../")
+}
+
 // toValidName coerces name to be a valid name for fs.FS.Open.
 func toValidName(name string) string {
 	name = strings.ReplaceAll(name, `\`, `/`)
@@ -687,53 +1674,271 @@ func toValidName(name string) string {
 	return p
 }
 
+// fsName returns the name under which the fs.FS view lists an entry.
+func (r *Reader) fsName(name string) string {
+	if r.opts.EscapeNames {
+		return EscapeName(name)
+	}
+	return toValidName(name)
+}
+
+// stat returns a FileInfo for f listed under the base name elem.
+func (f *File) stat(elem string) headerFileInfo {
+	return headerFileInfo{fh: &f.FileHeader, mode: f.Mode(), name: elem}
+}
+
+// An indexEntry is an entry of the file list under its name in the fs.FS
+// view, which differs from File.Name for directories and names that are
+// not valid there.
+type indexEntry struct {
+	name string
+	file *File
+}
+
+// initFileList builds the index behind the fs.FS view: the entries grouped
+// by the directory they are in, and the names of those directories sorted
+// element by element, so that the directories below one are a contiguous
+// range. Directories are listed from the index on first use by dirEntries,
+// so touching one directory does not cost listing all of them.
 func (r *Reader) initFileList() {
 	r.fileListOnce.Do(func() {
-		dirs := make(map[string]bool)
-		knownDirs := make(map[string]bool)
-		for _, file := range r.File {
-			isDir := len(file.Name) > 0 && file.Name[len(file.Name)-1] == '/'
-			name := toValidName(file.Name)
-			for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
-				dirs[dir] = true
+		r.dirFiles = make(map[string][]indexEntry)
+		for _, f := range r.File {
+			name := r.fsName(f.Name)
+			dir, _, _ := split(name)
+			r.dirFiles[dir] = append(r.dirFiles[dir], indexEntry{name: name, file: f})
+		}
+		r.dirNames = make([]string, 0, len(r.dirFiles))
+		for dir := range r.dirFiles {
+			if dir != "." {
+				r.dirNames = append(r.dirNames, dir)
 			}
-			entry := fileListEntry{
-				name:  name,
-				file:  file,
-				isDir: isDir,
+		}
+		sort.Slice(r.dirNames, func(i, j int) bool { return lessPathElements(r.dirNames[i], r.dirNames[j]) })
+		r.fileList = make(map[string]*dirShard)
+		r.dot = fileListEntry{name: "./", isDir: true, mode: r.dirMode(), below: &dirTime{zip: r, dir: "."}}
+	})
+}
+
+// dirMode returns the permission bits of directories without an entry.
+func (r *Reader) dirMode() fs.FileMode {
+	if mode := r.opts.DirMode.Perm(); mode != 0 {
+		return mode
+	}
+	return 0555
+}
+
+// subdirs returns the directories below dir holding entries.
+func (r *Reader) subdirs(dir string) []string {
+	if dir == "." {
+		return r.dirNames
+	}
+	lo := sort.Search(len(r.dirNames), func(i int) bool { return lessPathElements(dir, r.dirNames[i]) })
+	return r.dirNames[lo : lo+countPrefixed(r.dirNames[lo:], dir+"/")]
+}
+
+// countPrefixed returns the number of leading names starting with prefix.
+func countPrefixed(names []string, prefix string) int {
+	return sort.Search(len(names), func(i int) bool { return !strings.HasPrefix(names[i], prefix) })
+}
+
+// eachBelow calls fn with the entries below dir until it returns false.
+func (r *Reader) eachBelow(dir string, fn func(e indexEntry) bool) {
+	for _, e := range r.dirFiles[dir] {
+		if !fn(e) {
+			return
+		}
+	}
+	for _, sub := range r.subdirs(dir) {
+		for _, e := range r.dirFiles[sub] {
+			if !fn(e) {
+				return
 			}
-			r.fileList = append(r.fileList, entry)
-			if isDir {
-				knownDirs[name] = true
+		}
+	}
+}
+
+// isJunk reports whether e is hidden by Options.HideJunk.
+func (r *Reader) isJunk(e indexEntry) bool {
+	return r.opts.HideJunk && isJunk(e.name, e.file)
+}
+
+// hasVisible reports whether there is an entry below dir that is not
+// hidden. With HideJunk, directories with nothing else below them are
+// placeholders and hidden as well.
+func (r *Reader) hasVisible(dir string) bool {
+	visible := false
+	r.eachBelow(dir, func(e indexEntry) bool {
+		visible = !r.isJunk(e)
+		return !visible
+	})
+	return visible
+}
+
+// A dirShard holds the entries directly in one directory of the file list.
+type dirShard struct {
+	once    sync.Once
+	entries []fileListEntry
+}
+
+// dirEntries returns the entries directly in dir, sorted by ename. The
+// entries are listed from the index on first use.
+func (r *Reader) dirEntries(dir string) []fileListEntry {
+	if len(r.dirFiles[dir]) == 0 && len(r.subdirs(dir)) == 0 {
+		// Not a directory; do not keep a shard for names that are
+		// looked up in vain.
+		return nil
+	}
+	r.fileListMu.Lock()
+	shard := r.fileList[dir]
+	if shard == nil {
+		shard = &dirShard{}
+		r.fileList[dir] = shard
+	}
+	r.fileListMu.Unlock()
+	shard.once.Do(func() {
+		shard.entries = r.listDir(dir)
+	})
+	return shard.entries
+}
+
+func (r *Reader) listDir(dir string) []fileListEntry {
+	var entries []fileListEntry
+	known := make(map[string]bool) // directories with an entry of their own
+	for _, e := range r.dirFiles[dir] {
+		isDir := strings.HasSuffix(e.file.Name, "/")
+		if r.isJunk(e) || isDir && r.opts.HideJunk && !r.hasVisible(e.name) {
+			continue
+		}
+		entries = append(entries, fileListEntry{name: e.name, file: e.file, isDir: isDir, mode: e.file.Mode().Perm()})
+		if isDir {
+			known[e.name] = true
+		}
+	}
+
+	// Subdirectories without an entry of their own are synthesized from
+	// the directories below dir, skipping over the ones below each.
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	subdirs := r.subdirs(dir)
+	for i := 0; i < len(subdirs); {
+		sub := subdirs[i]
+		if slash := strings.IndexByte(sub[len(prefix):], '/'); slash >= 0 {
+			sub = sub[:len(prefix)+slash]
+		}
+		if subdirs[i] == sub {
+			i++
+		}
+		i += countPrefixed(subdirs[i:], sub+"/")
+		if !known[sub] && r.hasVisible(sub) {
+			entries = append(entries, fileListEntry{name: sub, isDir: true, mode: r.dirMode(), below: &dirTime{zip: r, dir: sub}})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return fileEntryLess(entries[i].name, entries[j].name) })
+	return entries
+}
+
+// A dirTime is the modification time of a directory without an entry:
+// that of the newest entry below it, found on first use.
+type dirTime struct {
+	zip  *Reader
+	dir  string
+	once sync.Once
+	t    time.Time
+}
+
+func (d *dirTime) modTime() time.Time {
+	d.once.Do(func() {
+		d.zip.eachBelow(d.dir, func(e indexEntry) bool {
+			if modified := e.file.Modified.UTC(); !d.zip.isJunk(e) && modified.After(d.t) {
+				d.t = modified
 			}
+			return true
+		})
+	})
+	return d.t
+}
+
+// walkDirs calls fn with the entries of every directory of the file list.
+func (r *Reader) walkDirs(fn func(entries []fileListEntry)) {
+	seen := make(map[string]bool)
+	var walk func(dir string)
+	walk = func(dir string) {
+		if seen[dir] {
+			return
 		}
-		for dir := range dirs {
-			if !knownDirs[dir] {
-				entry := fileListEntry{
-					name:  dir,
-					file:  nil,
-					isDir: true,
-				}
-				r.fileList = append(r.fileList, entry)
+		seen[dir] = true
+		entries := r.dirEntries(dir)
+		fn(entries)
+		for i := range entries {
+			if entries[i].isDir {
+				walk(entries[i].dirName())
 			}
 		}
+	}
+	walk(".")
+}
 
-		sort.Slice(r.fileList, func(i, j int) bool { return fileEntryLess(r.fileList[i].name, r.fileList[j].name) })
+// initKeyed indexes the file list by lookupKey, which means listing every
+// directory, so it is only done once a lookup by exact name fails.
+func (r *Reader) initKeyed() {
+	r.keyedOnce.Do(func() {
+		r.keyed = make(map[string]*fileListEntry)
+		r.walkDirs(func(files []fileListEntry) {
+			for i := range files {
+				key := r.lookupKey(files[i].name)
+				if e, ok := r.keyed[key]; !ok || fileEntryLess(files[i].name, e.name) {
+					r.keyed[key] = &files[i]
+				}
+			}
+		})
 	})
 }
 
+// isJunk reports whether the entry f, listed as name, is metadata left by
+// the archiver's platform rather than content: macOS resource forks in
+// __MACOSX and .DS_Store files, Windows Thumbs.db and desktop.ini files,
+// and empty "_$folder$" directory markers.
+func isJunk(name string, f *File) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "__MACOSX" {
+			return true
+		}
+	}
+	switch path.Base(name) {
+	case ".DS_Store", "Thumbs.db", "desktop.ini":
+		return true
+	}
+	return strings.HasSuffix(name, "_$folder$") && f.UncompressedSize64 == 0
+}
+
 func fileEntryLess(x, y string) bool {
 	xdir, xelem, _ := split(x)
 	ydir, yelem, _ := split(y)
 	return xdir < ydir || xdir == ydir && xelem < yelem
 }
 
-func (r *Reader) OpenLookup(name string) (*File, error) {
-	r.initFileList()
+// Lookup returns the entry named exactly name, as stored in the archive,
+// or nil if there is none. Of several entries with the same name, the
+// first in the central directory is returned. Unlike OpenLookup, it does
+// not build the index behind the fs.FS view, only a list of the entries
+// sorted by name, so callers finding entries by their exact names do not
+// pay for one.
+func (r *Reader) Lookup(name string) *File {
+	sorted := r.sortedFiles()
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Name >= name })
+	if i < len(sorted) && sorted[i].Name == name {
+		return sorted[i]
+	}
+	return nil
+}
 
-	e := r.openLookup(name)
-	if e == nil || !fs.ValidPath(name) {
-		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+func (r *Reader) OpenLookup(name string) (*File, error) {
+	e, err := r.lookup("open", name)
+	if err != nil {
+		return nil, err
 	}
 	if e.isDir || e.file == nil {
 		return nil, errs.Errorf("not a file")
@@ -746,20 +1951,33 @@ func (r *Reader) OpenLookup(name string) (*File, error) {
 // paths are always slash separated, with no
 // leading / or ../ elements.
 func (r *Reader) Open(name string) (fs.File, error) {
-	r.initFileList()
-
-	e := r.openLookup(name)
-	if e == nil || !fs.ValidPath(name) {
-		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	e, err := r.lookup("open", name)
+	if err != nil {
+		return nil, err
 	}
 	if e.isDir {
-		return &openDir{e, r.openReadDir(name), 0}, nil
+		return &openDir{e, r.openReadDir(e.dirName()), 0}, nil
 	}
 	rc, err := e.file.Open()
 	if err != nil {
 		return nil, err
 	}
-	return rc.(fs.File), nil
+	return &openFile{rc: rc, e: e}, nil
+}
+
+// openFile is an entry opened through the fs.FS view. The ReadCloser
+// returned by File.Open is also an io.Seeker.
+type openFile struct {
+	rc io.ReadCloser
+	e  *fileListEntry
+}
+
+func (f *openFile) Read(p []byte) (int, error) { return f.rc.Read(p) }
+func (f *openFile) Close() error               { return f.rc.Close() }
+func (f *openFile) Stat() (fs.FileInfo, error) { return f.e.stat(), nil }
+
+func (f *openFile) Seek(offset int64, whence int) (int64, error) {
+	return f.rc.(io.Seeker).Seek(offset, whence)
 }
 
 func split(name string) (dir, elem string, isDir bool) {
@@ -777,18 +1995,16 @@ func split(name string) (dir, elem string, isDir bool) {
 	return name[:i], name[i+1:], isDir
 }
 
-var dotFile = &fileListEntry{name: "./", isDir: true}
-
 func (r *Reader) openLookup(name string) *fileListEntry {
 	if name == "." {
-		return dotFile
+		return &r.dot
 	}
 
 	dir, elem, _ := split(name)
-	files := r.fileList
+	files := r.dirEntries(dir)
 	i := sort.Search(len(files), func(i int) bool {
-		idir, ielem, _ := split(files[i].name)
-		return idir > dir || idir == dir && ielem >= elem
+		_, ielem, _ := split(files[i].name)
+		return ielem >= elem
 	})
 	if i < len(files) {
 		fname := files[i].name
@@ -800,16 +2016,7 @@ func (r *Reader) openLookup(name string) *fileListEntry {
 }
 
 func (r *Reader) openReadDir(dir string) []fileListEntry {
-	files := r.fileList
-	i := sort.Search(len(files), func(i int) bool {
-		idir, _, _ := split(files[i].name)
-		return idir >= dir
-	})
-	j := sort.Search(len(files), func(j int) bool {
-		jdir, _, _ := split(files[j].name)
-		return jdir > dir
-	})
-	return files[i:j]
+	return r.dirEntries(dir)
 }
 
 type openDir struct {
@@ -6,16 +6,26 @@ package zipread
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"io/fs"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
 	"time"
@@ -444,6 +454,30 @@ var tests = []ZipTest{
 			},
 		},
 	},
+	{
+		Name: "bzip2.zip",
+		File: []ZipTestFile{
+			{
+				Name:    "bzip2.txt",
+				File:    "bzip2.txt",
+				Mode:    0644,
+				ModTime: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+			},
+		},
+	},
+	{
+		// Matches of up to 34345 bytes from up to 34740 bytes back, which
+		// only Deflate64 can encode; Info-ZIP's unzip extracts it.
+		Name: "deflate64.zip",
+		File: []ZipTestFile{
+			{
+				Name:    "deflate64.txt",
+				File:    "deflate64.txt",
+				Mode:    0644,
+				ModTime: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+			},
+		},
+	},
 }
 
 func TestReader(t *testing.T) {
@@ -463,13 +497,13 @@ func readTestZip(t *testing.T, zt ZipTest) {
 		path := filepath.Join("testdata", zt.Name)
 		z, err = Open(SourceFromFile(path))
 	}
-	if err != zt.Error {
+	if !errors.Is(err, zt.Error) {
 		t.Errorf("error=%+v, want %v", err, zt.Error)
 		return
 	}
 
 	// bail if file is not zip
-	if err == ErrFormat {
+	if errors.Is(err, ErrFormat) {
 		return
 	}
 
@@ -557,7 +591,7 @@ func readTestFile(t *testing.T, zt ZipTest, ft ZipTestFile, f *File) {
 
 	var b bytes.Buffer
 	_, err = io.Copy(&b, r)
-	if err != ft.ContentErr {
+	if !errors.Is(err, ft.ContentErr) {
 		t.Errorf("copying contents: %v (want %v)", err, ft.ContentErr)
 	}
 	if err != nil {
@@ -608,11 +642,18 @@ func readTestFileAsGZip(t *testing.T, zt ZipTest, ft ZipTestFile, f *File) {
 		return
 	}
 
+	// The compressed stream is passed through without verification, so
+	// checksum errors only surface when the gzip stream is decoded.
+	copyErr, decodeErr := ft.ContentErr, error(nil)
+	if errors.Is(copyErr, ErrChecksum) {
+		copyErr, decodeErr = nil, gzip.ErrChecksum
+	}
+
 	// check compressed gzip stream size
 	var b bytes.Buffer
 	_, err = io.Copy(&b, r)
-	if err != ft.ContentErr {
-		t.Errorf("copying contents: %v (want %v)", err, ft.ContentErr)
+	if err != copyErr {
+		t.Errorf("copying contents: %v (want %v)", err, copyErr)
 	}
 	if err != nil {
 		t.Error(err)
@@ -620,7 +661,10 @@ func readTestFileAsGZip(t *testing.T, zt ZipTest, ft ZipTestFile, f *File) {
 	}
 	r.Close()
 
-	expectedSize := f.CompressedSize64 + 18
+	expectedSize := uint64(f.GzipSize())
+	if f.Method == Deflate && expectedSize != f.CompressedSize64+18 {
+		t.Errorf("%v: GzipSize() == %v, want %v", f.Name, expectedSize, f.CompressedSize64+18)
+	}
 	if g := uint64(b.Len()); g != expectedSize {
 		t.Errorf("%v: read %v bytes but f.UncompressedSize == %v", f.Name, g, expectedSize)
 	}
@@ -633,8 +677,10 @@ func readTestFileAsGZip(t *testing.T, zt ZipTest, ft ZipTestFile, f *File) {
 		return
 	}
 	_, err = io.Copy(&b2, gz)
+	if err != decodeErr {
+		t.Errorf("decoding gzip stream: %v (want %v)", err, decodeErr)
+	}
 	if err != nil {
-		t.Error(err)
 		return
 	}
 
@@ -674,7 +720,7 @@ func TestInvalidFiles(t *testing.T) {
 
 	// zeroes
 	_, err := Open(SourceFromReaderAt(bytes.NewReader(b), size))
-	if err != ErrFormat {
+	if !errors.Is(err, ErrFormat) {
 		t.Errorf("zeroes: error=%v, want %v", err, ErrFormat)
 	}
 
@@ -685,7 +731,7 @@ func TestInvalidFiles(t *testing.T) {
 		copy(b[i:i+4], sig)
 	}
 	_, err = Open(SourceFromReaderAt(bytes.NewReader(b), size))
-	if err != ErrFormat {
+	if !errors.Is(err, ErrFormat) {
 		t.Errorf("sigs: error=%v, want %v", err, ErrFormat)
 	}
 }
@@ -1070,6 +1116,7 @@ func TestFS(t *testing.T) {
 			[]string{"a/b/c"},
 		},
 	} {
+		test := test
 		t.Run(test.file, func(t *testing.T) {
 			t.Parallel()
 			z, err := Open(SourceFromFile(test.file))
@@ -1148,3 +1195,1131 @@ func TestCVE202127919(t *testing.T) {
 		t.Errorf("Error reading file: %v", err)
 	}
 }
+
+type testZipFile struct {
+	Name   string
+	Method uint16
+	Data   []byte
+}
+
+// buildTestZip writes files into an in-memory archive.
+func buildTestZip(t testing.TB, files ...testZipFile) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, f := range files {
+		fw, err := w.CreateHeader(&FileHeader{Name: f.Name, Method: f.Method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func openTestZip(t testing.TB, data []byte, opts *Options) *Reader {
+	z, err := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+func readAllFile(f *File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	return b, err
+}
+
+func TestOverlapHeaderValidation(t *testing.T) {
+	content := bytes.Repeat([]byte("overlapped "), 1000)
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Deflate, Data: content},
+		testZipFile{Name: "b", Method: Store, Data: content})
+	z := openTestZip(t, data, &Options{OverlapHeaderValidation: true})
+
+	for _, f := range z.File {
+		for i := 0; i < 2; i++ {
+			got, err := readAllFile(f)
+			if err != nil {
+				t.Fatalf("%s: read %d: %v", f.Name, i, err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("%s: read %d: content mismatch", f.Name, i)
+			}
+		}
+		if f.knownHeaderLen() == 0 {
+			t.Errorf("%s: header length was not resolved", f.Name)
+		}
+	}
+
+	// Corrupt the local header signature once the length is known; the
+	// overlapped open succeeds, but reading to the end or closing fails.
+	data[0] = 'X'
+	if _, err := readAllFile(z.File[0]); !errors.Is(err, ErrFormat) {
+		t.Errorf("corrupt header: got %v, want %v", err, ErrFormat)
+	}
+	rc, err := z.File[0].Open()
+	if err != nil {
+		t.Fatalf("corrupt header: Open: %v", err)
+	}
+	if err := rc.Close(); !errors.Is(err, ErrFormat) {
+		t.Errorf("corrupt header: Close: got %v, want %v", err, ErrFormat)
+	}
+}
+
+func BenchmarkOpen(b *testing.B) {
+	data := buildTestZip(b, testZipFile{Name: "a", Method: Deflate, Data: bytes.Repeat([]byte("x"), 4096)})
+	for _, overlap := range []bool{false, true} {
+		b.Run(fmt.Sprintf("overlap=%v", overlap), func(b *testing.B) {
+			z := openTestZip(b, data, &Options{OverlapHeaderValidation: overlap})
+			f := z.File[0]
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := readAllFile(f); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtendedTimestamps(t *testing.T) {
+	var (
+		mtime = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+		atime = time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+		ctime = time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	)
+	extra := make([]byte, 17)
+	copy(extra, []byte{0x55, 0x54, 13, 0, 7})
+	for i, ts := range []time.Time{mtime, atime, ctime} {
+		binary.LittleEndian.PutUint32(extra[5+4*i:], uint32(ts.Unix()))
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.CreateHeader(&FileHeader{Name: "a", Extra: extra}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f := openTestZip(t, buf.Bytes(), nil).File[0]
+	if !f.Modified.Equal(mtime) {
+		t.Errorf("Modified=%v, want %v", f.Modified, mtime)
+	}
+	if !f.Accessed.Equal(atime) {
+		t.Errorf("Accessed=%v, want %v", f.Accessed, atime)
+	}
+	if !f.Created.Equal(ctime) {
+		t.Errorf("Created=%v, want %v", f.Created, ctime)
+	}
+}
+
+func TestOpenProgress(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store},
+		testZipFile{Name: "b/c", Method: Deflate})
+
+	var got []DirectoryProgress
+	z := openTestZip(t, data, &Options{OpenProgress: func(p DirectoryProgress) {
+		got = append(got, p)
+	}})
+	if len(got) != len(z.File) {
+		t.Fatalf("got %d progress calls, want %d", len(got), len(z.File))
+	}
+	for i, p := range got {
+		if p.Entries != i+1 || p.TotalEntries != 2 || p.Name != z.File[i].Name {
+			t.Errorf("call %d: unexpected progress %+v", i, p)
+		}
+	}
+	if last := got[len(got)-1]; last.Bytes != last.TotalBytes {
+		t.Errorf("parsed %d directory bytes, want %d", last.Bytes, last.TotalBytes)
+	}
+}
+
+func TestNameDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	// "Über.txt" in code page 437, without the UTF-8 flag.
+	if _, err := w.CreateHeader(&FileHeader{Name: "\x9aber.txt", NonUTF8: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z := openTestZip(t, buf.Bytes(), &Options{NameDecoder: DecodeCP437})
+	f := z.File[0]
+	if f.Name != "Über.txt" {
+		t.Errorf("Name=%q, want %q", f.Name, "Über.txt")
+	}
+	if f.RawName != "\x9aber.txt" {
+		t.Errorf("RawName=%q, want %q", f.RawName, "\x9aber.txt")
+	}
+	if _, err := z.Open("Über.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCRCPolicy(t *testing.T) {
+	content := []byte("checksummed content")
+	data := buildTestZip(t, testZipFile{Name: "a", Method: Store, Data: content})
+	// Flip a content byte; the stored entry keeps its original CRC.
+	data[bytes.Index(data, content)] ^= 0xff
+
+	for _, test := range []struct {
+		policy            CRCPolicy
+		readErr, closeErr error
+	}{
+		{CRCStrict, ErrChecksum, nil},
+		{CRCOff, nil, nil},
+		{CRCReport, nil, ErrChecksum},
+	} {
+		f := openTestZip(t, data, &Options{CRCPolicy: test.policy}).File[0]
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(rc); !errors.Is(err, test.readErr) {
+			t.Errorf("policy %d: read error=%v, want %v", test.policy, err, test.readErr)
+		}
+		if err := rc.Close(); !errors.Is(err, test.closeErr) {
+			t.Errorf("policy %d: close error=%v, want %v", test.policy, err, test.closeErr)
+		}
+	}
+}
+
+func TestOpenAsGzipWriteTo(t *testing.T) {
+	content := bytes.Repeat([]byte("gzip wrapped "), 1000)
+	f := openTestZip(t, buildTestZip(t, testZipFile{Name: "a", Method: Deflate, Data: content}), nil).File[0]
+
+	readGzip := func(copyFn func(io.Writer, io.Reader) error) []byte {
+		rc, err := f.OpenAsGzip()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		var buf bytes.Buffer
+		if err := copyFn(&buf, rc); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	viaRead := readGzip(func(w io.Writer, r io.Reader) error {
+		_, err := io.Copy(w, struct{ io.Reader }{r})
+		return err
+	})
+	viaWriteTo := readGzip(func(w io.Writer, r io.Reader) error {
+		_, err := r.(io.WriterTo).WriteTo(w)
+		return err
+	})
+	if !bytes.Equal(viaRead, viaWriteTo) {
+		t.Fatal("Read and WriteTo produced different streams")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(viaWriteTo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+}
+
+func TestOpenAsGzipStored(t *testing.T) {
+	for _, size := range []int{0, 1, maxStoredBlock, maxStoredBlock + 1, 3 * maxStoredBlock} {
+		content := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(content)
+		f := openTestZip(t, buildTestZip(t, testZipFile{Name: "a", Method: Store, Data: content}), nil).File[0]
+
+		rc, err := f.OpenAsGzip()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+		if int64(len(stream)) != f.GzipSize() {
+			t.Errorf("size %d: stream is %d bytes, GzipSize()=%d", size, len(stream), f.GzipSize())
+		}
+
+		gz, err := gzip.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("size %d: content mismatch", size)
+		}
+	}
+}
+
+// flakySource corrupts the first byte of the first n ranges it serves.
+type flakySource struct {
+	Source
+	mu sync.Mutex
+	n  int
+}
+
+func (s *flakySource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	rc, err := s.Source.Range(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n == 0 {
+		return rc, nil
+	}
+	s.n--
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	b[len(b)-1] ^= 0xff
+	return io.NopCloser(bytes.NewReader(b)), rc.Close()
+}
+
+func TestRetryChecksum(t *testing.T) {
+	content := []byte("retried content")
+	data := buildTestZip(t, testZipFile{Name: "a", Method: Store, Data: content})
+
+	for _, test := range []struct {
+		name      string
+		flaky     int
+		transient bool
+	}{
+		{"transient", 1, true},
+		{"persistent", 2, false},
+	} {
+		// Only the content body ends up corrupted: the last byte of each
+		// range lies past the local header.
+		src := &flakySource{
+			Source: SourceFromReaderAt(bytes.NewReader(data[:bytes.Index(data, content)+len(content)]), int64(len(data))),
+			n:      test.flaky,
+		}
+		z, err := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &Options{RetryChecksum: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range z.File {
+			f.zips = src
+		}
+
+		_, err = readAllFile(z.File[0])
+		var cerr *ChecksumError
+		if !errors.As(err, &cerr) || !errors.Is(err, ErrChecksum) {
+			t.Fatalf("%s: got %v, want *ChecksumError", test.name, err)
+		}
+		if cerr.Transient != test.transient {
+			t.Errorf("%s: Transient=%v, want %v", test.name, cerr.Transient, test.transient)
+		}
+	}
+}
+
+func TestSupportedMethods(t *testing.T) {
+	z := openTestZip(t, buildTestZip(t, testZipFile{Name: "a", Method: Deflate, Data: []byte("hello")}), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(method uint16) {
+			defer wg.Done()
+			z.RegisterDecompressor(method, io.NopCloser)
+		}(uint16(100 + i))
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := readAllFile(z.File[0]); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := fmt.Sprint(z.SupportedMethods())
+	if want := "[0 8 9 12 100 101 102 103]"; got != want {
+		t.Fatalf("SupportedMethods()=%s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(SupportedMethods()), "[0 8 9 12]"; got != want {
+		t.Fatalf("package SupportedMethods()=%s, want %s", got, want)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store, Data: []byte("first")},
+		testZipFile{Name: "b", Method: Store, Data: []byte("second")})
+
+	// Break the second central directory record.
+	cen := bytes.LastIndex(data, []byte("PK\x01\x02"))
+	corrupt := append([]byte(nil), data...)
+	corrupt[cen] = 'X'
+	_, err := Open(SourceFromReaderAt(bytes.NewReader(corrupt), int64(len(corrupt))))
+	var fe *FormatError
+	if !errors.As(err, &fe) || !errors.Is(err, ErrFormat) {
+		t.Fatalf("central directory: err=%v, want a FormatError", err)
+	}
+	if fe.Structure != "central directory record 1" || fe.Offset != int64(cen) {
+		t.Fatalf("central directory: got %q at %d, want record 1 at %d", fe.Structure, fe.Offset, cen)
+	}
+
+	// Break the second local file header.
+	loc := bytes.LastIndex(data[:cen], []byte("PK\x03\x04"))
+	corrupt = append([]byte(nil), data...)
+	corrupt[loc] = 'X'
+	z := openTestZip(t, corrupt, nil)
+	_, err = readAllFile(z.File[1])
+	if !errors.As(err, &fe) || !errors.Is(err, ErrFormat) {
+		t.Fatalf("local header: err=%v, want a FormatError", err)
+	}
+	if fe.Structure != `local file header of "b"` || fe.Offset != int64(loc) {
+		t.Fatalf("local header: got %q at %d, want %d", fe.Structure, fe.Offset, loc)
+	}
+}
+
+func TestVerifyLocalHeader(t *testing.T) {
+	data := buildTestZip(t, testZipFile{Name: "safe.txt", Method: Store, Data: []byte("content")})
+	spoofed := append([]byte(nil), data...)
+	copy(spoofed[fileHeaderLen:], "evil.sh")
+	remethod := append([]byte(nil), data...)
+	remethod[8] = byte(Deflate)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		opts Options
+		ok   bool
+	}{
+		{"spoofed name unchecked", spoofed, Options{}, true},
+		{"spoofed name", spoofed, Options{VerifyLocalName: true}, false},
+		{"spoofed name with method", spoofed, Options{VerifyLocalMethod: true}, false},
+		{"method unchecked", remethod, Options{VerifyLocalName: true}, true},
+		{"method", remethod, Options{VerifyLocalMethod: true}, false},
+		{"intact", data, Options{VerifyLocalMethod: true}, true},
+	} {
+		opts := tc.opts
+		_, err := readAllFile(openTestZip(t, tc.data, &opts).File[0])
+		var fe *FormatError
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		} else if !tc.ok && !errors.As(err, &fe) {
+			t.Errorf("%s: err=%v, want a FormatError", tc.name, err)
+		}
+	}
+}
+
+func TestChecksumErrorDetails(t *testing.T) {
+	content := []byte("checksummed content")
+	data := buildTestZip(t, testZipFile{Name: "a", Method: Store, Data: content})
+	data[bytes.Index(data, content)] ^= 0xff
+
+	_, err := readAllFile(openTestZip(t, data, nil).File[0])
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("got %v, want *ChecksumError", err)
+	}
+	corrupted := append([]byte(nil), content...)
+	corrupted[0] ^= 0xff
+	want := ChecksumError{
+		Name:      "a",
+		Expected:  crc32.ChecksumIEEE(content),
+		Computed:  crc32.ChecksumIEEE(corrupted),
+		BytesRead: uint64(len(content)),
+	}
+	if *cerr != want {
+		t.Fatalf("got %+v, want %+v", *cerr, want)
+	}
+}
+
+func TestPrependedData(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store, Data: []byte("stored")},
+		testZipFile{Name: "b", Method: Deflate, Data: []byte("deflated")})
+	stub := bytes.Repeat([]byte("MZ stub "), 125)
+	z := openTestZip(t, append(stub, data...), nil)
+
+	if got := z.BaseOffset(); got != int64(len(stub)) {
+		t.Fatalf("BaseOffset()=%d, want %d", got, len(stub))
+	}
+	for i, want := range []string{"stored", "deflated"} {
+		got, err := readAllFile(z.File[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s: got %q, want %q", z.File[i].Name, got, want)
+		}
+	}
+
+	if got := openTestZip(t, data, nil).BaseOffset(); got != 0 {
+		t.Fatalf("BaseOffset()=%d without prepended data, want 0", got)
+	}
+}
+
+func TestSignatureInComment(t *testing.T) {
+	fake := func(records, size, offset uint32, commentLen uint16) string {
+		b := make([]byte, directoryEndLen)
+		copy(b, "PK\x05\x06")
+		binary.LittleEndian.PutUint16(b[8:], uint16(records))
+		binary.LittleEndian.PutUint16(b[10:], uint16(records))
+		binary.LittleEndian.PutUint32(b[12:], size)
+		binary.LittleEndian.PutUint32(b[16:], offset)
+		binary.LittleEndian.PutUint16(b[20:], commentLen)
+		return string(b)
+	}
+	for _, comment := range []string{
+		"empty directory " + fake(0, 0, 0, 0) + " and more",
+		"reaching the end " + fake(3, 200, 1<<20, 0),
+		"pointing at the entry " + fake(1, 46, 0, 0),
+		strings.Repeat("long ", 300) + fake(0, 0, 0, 0),
+	} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if _, err := w.Create("file"); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.SetComment(comment); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		z := openTestZip(t, buf.Bytes(), nil)
+		if len(z.File) != 1 || z.Comment != comment {
+			t.Errorf("%.20q: got %d entries and comment %.20q", comment, len(z.File), z.Comment)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var files []testZipFile
+	for i := 0; i < 10; i++ {
+		files = append(files, testZipFile{Name: fmt.Sprintf("dir/file%d", i), Method: Deflate, Data: make([]byte, 1000)})
+	}
+	data := buildTestZip(t, files...)
+
+	for _, test := range []struct {
+		opts  Options
+		limit string
+	}{
+		{Options{MaxEntries: 10, MaxDirectorySize: 1000, MaxTotalUncompressed: 10000, MaxNameLength: 9, MaxPathDepth: 2}, ""},
+		{Options{MaxEntries: 9}, "entries"},
+		{Options{MaxDirectorySize: 100}, "directory size"},
+		{Options{MaxTotalUncompressed: 9999}, "total uncompressed size"},
+		{Options{MaxNameLength: 8}, "name length"},
+		{Options{MaxPathDepth: 1}, "path depth"},
+	} {
+		_, err := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &test.opts)
+		if test.limit == "" {
+			if err != nil {
+				t.Errorf("%+v: %v", test.opts, err)
+			}
+			continue
+		}
+		var lerr *LimitError
+		if !errors.As(err, &lerr) || !errors.Is(err, ErrLimit) {
+			t.Errorf("%+v: got %v, want *LimitError", test.opts, err)
+			continue
+		}
+		if lerr.Limit != test.limit {
+			t.Errorf("%+v: limit %q, want %q", test.opts, lerr.Limit, test.limit)
+		}
+	}
+}
+
+func TestBombGuard(t *testing.T) {
+	data := buildTestZip(t, testZipFile{Name: "zeros", Method: Deflate, Data: make([]byte, 1<<20)})
+
+	_, err := readAllFile(openTestZip(t, data, &Options{MaxCompressionRatio: 100}).File[0])
+	if !errors.Is(err, ErrBomb) {
+		t.Errorf("ratio: got %v, want %v", err, ErrBomb)
+	}
+	if _, err := readAllFile(openTestZip(t, data, &Options{MaxCompressionRatio: 2000}).File[0]); err != nil {
+		t.Errorf("ratio within limit: %v", err)
+	}
+
+	// Understate the uncompressed size in the central directory.
+	cen := bytes.LastIndex(data, []byte("PK\x01\x02"))
+	binary.LittleEndian.PutUint32(data[cen+24:], 1000)
+	_, err = readAllFile(openTestZip(t, data, &Options{UncompressedSizeMargin: 4096}).File[0])
+	if !errors.Is(err, ErrBomb) {
+		t.Errorf("size margin: got %v, want %v", err, ErrBomb)
+	}
+}
+
+func TestInsecurePaths(t *testing.T) {
+	names := []string{"ok.txt", "dir/ok", "../escape", "/abs", `back\slash`, "C:drive", "a/../../b", "a/../fine"}
+	var files []testZipFile
+	for _, name := range names {
+		files = append(files, testZipFile{Name: name, Method: Store})
+	}
+	data := buildTestZip(t, files...)
+	src := SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))
+
+	z, err := OpenWithOptions(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range z.InsecurePaths() {
+		got = append(got, f.Name)
+	}
+	if want := []string{"../escape", "/abs", `back\slash`, "C:drive", "a/../../b"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("InsecurePaths()=%q, want %q", got, want)
+	}
+
+	if z, err := OpenWithOptions(src, &Options{InsecurePaths: PathsReport}); err != ErrInsecurePath || z == nil {
+		t.Errorf("PathsReport: got %v, %v; want a Reader and %v", z, err, ErrInsecurePath)
+	}
+	if z, err := OpenWithOptions(src, &Options{InsecurePaths: PathsReject}); err != ErrInsecurePath || z != nil {
+		t.Errorf("PathsReject: got %v, %v; want %v", z, err, ErrInsecurePath)
+	}
+}
+
+func TestMSDOSAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, fh := range []*FileHeader{
+		{Name: "ntfs-readonly-hidden", CreatorVersion: creatorWindowsNTFS << 8, ExternalAttrs: 0x03},
+		{Name: "hpfs-dir/", CreatorVersion: creatorOS2HPFS << 8, ExternalAttrs: 0x10},
+		{Name: "unix-without-mode", CreatorVersion: creatorUnix << 8, ExternalAttrs: 0x21},
+		{Name: "unix", CreatorVersion: creatorUnix << 8, ExternalAttrs: 0100750 << 16},
+	} {
+		if _, err := w.CreateHeader(fh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z := openTestZip(t, buf.Bytes(), nil)
+
+	for i, want := range []struct {
+		mode  fs.FileMode
+		attrs uint8
+		ok    bool
+	}{
+		{0444, 0x03, true},
+		{fs.ModeDir | 0777, 0x10, true},
+		{0444, 0, false},
+		{0750, 0, false},
+	} {
+		f := z.File[i]
+		if mode := f.Mode(); mode != want.mode {
+			t.Errorf("%s: Mode()=%v, want %v", f.Name, mode, want.mode)
+		}
+		if attrs, ok := f.MSDOSAttrs(); attrs != want.attrs || ok != want.ok {
+			t.Errorf("%s: MSDOSAttrs()=%#x, %v, want %#x, %v", f.Name, attrs, ok, want.attrs, want.ok)
+		}
+		if want.mode.IsDir() {
+			continue // the fs view reports its own directory modes
+		}
+		if fi, err := z.Stat(f.Name); err != nil || fi.Mode() != want.mode {
+			t.Errorf("%s: Stat: got %v, %v, want mode %v", f.Name, fi, err, want.mode)
+		}
+	}
+}
+
+func TestReadAfterClose(t *testing.T) {
+	z := openTestZip(t, buildTestZip(t,
+		testZipFile{Name: "stored", Method: Store, Data: []byte("stored content")},
+		testZipFile{Name: "deflated", Method: Deflate, Data: bytes.Repeat([]byte("deflated "), 100)}), nil)
+	for _, f := range z.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rc.Read(make([]byte, 4)); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// The entry's buffers are back in their pools, possibly in use
+		// by another entry already.
+		if _, err := readAllFile(z.File[0]); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := rc.Read(make([]byte, 4)); n != 0 || err == nil {
+			t.Errorf("%s: Read after Close: got %d, %v", f.Name, n, err)
+		}
+		if _, err := rc.(io.Seeker).Seek(0, io.SeekStart); err == nil {
+			t.Errorf("%s: Seek after Close succeeded", f.Name)
+		}
+		if err := rc.Close(); err != nil {
+			t.Errorf("%s: second Close: %v", f.Name, err)
+		}
+	}
+}
+
+func TestSetDeflateDecompressor(t *testing.T) {
+	z := openTestZip(t, buildTestZip(t, testZipFile{Name: "a", Method: Deflate, Data: []byte("content")}), nil)
+	var calls int32
+	SetDeflateDecompressor(func(r io.Reader) io.ReadCloser {
+		atomic.AddInt32(&calls, 1)
+		return flate.NewReader(r)
+	})
+	defer SetDeflateDecompressor(nil)
+	if got, err := readAllFile(z.File[0]); err != nil || string(got) != "content" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("replacement called %d times, want 1", calls)
+	}
+}
+
+// strictSource rejects ranges reaching past the end of the archive, like
+// some HTTP servers do.
+type strictSource struct {
+	Source
+	size int64
+}
+
+func (s strictSource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	if offset+length > s.size {
+		return nil, fmt.Errorf("range %d-%d not satisfiable", offset, offset+length)
+	}
+	return s.Source.Range(ctx, offset, length)
+}
+
+func TestRangeWithinArchive(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store, Data: []byte("first")},
+		testZipFile{Name: "b", Method: Deflate, Data: []byte("second")})
+	source := strictSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), size: int64(len(data))}
+	z, err := Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range z.File {
+		if _, err := readAllFile(f); err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+		}
+	}
+}
+
+// requestSource counts the requests made to the wrapped Source.
+type requestSource struct {
+	Source
+	requests int
+}
+
+func (s *requestSource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	s.requests++
+	return s.Source.Range(ctx, offset, length)
+}
+
+func (s *requestSource) RangeFromEnd(ctx context.Context, length int64) (io.ReadCloser, int64, error) {
+	s.requests++
+	return s.Source.RangeFromEnd(ctx, length)
+}
+
+func TestTailWindow(t *testing.T) {
+	zip64, err := os.ReadFile(filepath.Join("testdata", "zip64.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := buildTestZip(t,
+		testZipFile{Name: "big", Method: Store, Data: bytes.Repeat([]byte("x"), 200<<10)},
+		testZipFile{Name: "small", Method: Deflate, Data: []byte("small")})
+
+	for _, test := range []struct {
+		name     string
+		data     []byte
+		window   int64
+		requests int
+	}{
+		{"zip64", zip64, 128 << 10, 1},
+		{"large", large, 128 << 10, 1},
+		{"straddling", large, 64, 0},
+	} {
+		source := &requestSource{Source: SourceFromReaderAt(bytes.NewReader(test.data), int64(len(test.data)))}
+		z, err := OpenWithOptions(source, &Options{TailWindow: test.window})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.requests > 0 && source.requests != test.requests {
+			t.Errorf("%s: %d requests to open, want %d", test.name, source.requests, test.requests)
+		}
+		for _, f := range z.File {
+			if _, err := readAllFile(f); err != nil {
+				t.Errorf("%s: %s: %v", test.name, f.Name, err)
+			}
+		}
+	}
+}
+
+// readSizeSource records the largest read made from its ranges.
+type readSizeSource struct {
+	Source
+	largest int
+}
+
+func (s *readSizeSource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	rc, err := s.Source.Range(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: readerFunc(func(p []byte) (int, error) {
+			if len(p) > s.largest {
+				s.largest = len(p)
+			}
+			return rc.Read(p)
+		}),
+		Closer: rc,
+	}, nil
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestBufferSize(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 20<<10)
+	data := buildTestZip(t, testZipFile{Name: "a", Method: Deflate, Data: content})
+	for _, size := range []int{0, 64 << 10} {
+		source := &readSizeSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}
+		z, err := OpenWithOptions(source, &Options{BufferSize: size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := size
+		if want == 0 {
+			want = defaultBufferSize
+		}
+		for i := 0; i < 2; i++ {
+			source.largest = 0
+			got, err := readAllFile(z.File[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("size %d: content mismatch", size)
+			}
+			if source.largest != want {
+				t.Errorf("size %d, open %d: largest read %d, want %d", size, i, source.largest, want)
+			}
+		}
+	}
+}
+
+func TestOpenDirect(t *testing.T) {
+	content := bytes.Repeat([]byte("stored asset "), 1000)
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store, Data: content},
+		testZipFile{Name: "b", Method: Store, Data: []byte("next")})
+	z := openTestZip(t, data, &Options{CRCPolicy: CRCOff})
+	f := z.File[0]
+
+	// The first open validates the local header, later ones start at the
+	// known content offset.
+	for i := 0; i < 2; i++ {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := rc.(*directReader); !ok {
+			t.Fatalf("open %d: got %T, want a direct reader", i, rc)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("open %d: content mismatch", i)
+		}
+
+		rs := rc.(io.ReadSeeker)
+		if pos, err := rs.Seek(-100, io.SeekEnd); err != nil || pos != int64(len(content))-100 {
+			t.Fatalf("seek: %d, %v", pos, err)
+		}
+		got, err = io.ReadAll(rs)
+		if err != nil || !bytes.Equal(got, content[len(content)-100:]) {
+			t.Fatalf("read after seek: %q, %v", got, err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rc.Read(make([]byte, 1)); err == nil {
+			t.Error("read after close succeeded")
+		}
+	}
+
+	fi, err := fs.Stat(z, "a")
+	if err != nil || fi.Size() != int64(len(content)) {
+		t.Errorf("stat: %v, %v", fi, err)
+	}
+
+	// The local header is still validated.
+	data[0] = 'X'
+	f = openTestZip(t, data, &Options{CRCPolicy: CRCOff}).File[0]
+	if _, err := f.Open(); !errors.Is(err, ErrFormat) {
+		t.Errorf("corrupt header: got %v, want %v", err, ErrFormat)
+	}
+}
+
+func TestKnownHeaderOpen(t *testing.T) {
+	content := bytes.Repeat([]byte("hot entry "), 1000)
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Deflate, Data: content},
+		testZipFile{Name: "b", Method: Store, Data: []byte("next")})
+	source := &countingSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}
+	z, err := Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := z.File[0]
+	if _, err := readAllFile(f); err != nil {
+		t.Fatal(err)
+	}
+
+	source.calls, source.requested = 0, 0
+	got, err := readAllFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("content mismatch")
+	}
+	if source.calls != 1 || source.requested != int64(f.CompressedSize64) {
+		t.Errorf("reopen requested %d bytes in %d calls, want %d in 1", source.requested, source.calls, f.CompressedSize64)
+	}
+}
+
+func TestParseWorkers(t *testing.T) {
+	var files []testZipFile
+	for i := 0; i < 1000; i++ {
+		files = append(files, testZipFile{Name: fmt.Sprintf("dir%d/file%d", i%7, i), Method: Store, Data: []byte(strconv.Itoa(i))})
+	}
+	archives := map[string][]byte{"many": buildTestZip(t, files...)}
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives[path] = data
+	}
+
+	for name, data := range archives {
+		want, wantErr := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), nil)
+		got, err := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &Options{ParseWorkers: 4})
+		if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+			t.Errorf("%s: error %v, want %v", name, err, wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(got.File) != len(want.File) {
+			t.Errorf("%s: %d entries, want %d", name, len(got.File), len(want.File))
+			continue
+		}
+		for i := range want.File {
+			if !reflect.DeepEqual(got.File[i].FileHeader, want.File[i].FileHeader) || got.File[i].headerOffset != want.File[i].headerOffset {
+				t.Errorf("%s: entry %d differs: %+v, want %+v", name, i, got.File[i].FileHeader, want.File[i].FileHeader)
+			}
+		}
+	}
+}
+
+func TestParseWorkersLimits(t *testing.T) {
+	var files []testZipFile
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("dir%d/file%d", i%7, i)
+		switch i {
+		case 600:
+			name = "a/b/c/d/e"
+		case 700:
+			name = strings.Repeat("n", 100)
+		}
+		files = append(files, testZipFile{Name: name, Method: Store, Data: []byte(strconv.Itoa(i))})
+	}
+	data := buildTestZip(t, files...)
+
+	for _, opts := range []Options{
+		{MaxEntries: 500},
+		{MaxPathDepth: 3},
+		{MaxNameLength: 50},
+		{MaxTotalUncompressed: 1000},
+	} {
+		_, want := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &opts)
+		opts.ParseWorkers = 4
+		_, err := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &opts)
+		if !errors.Is(err, ErrLimit) || err.Error() != want.Error() {
+			t.Errorf("%+v: got %v, want %v", opts, err, want)
+		}
+	}
+}
+
+func TestCompactDirectory(t *testing.T) {
+	for _, name := range []string{"test.zip", "utf8-winrar.zip", "crypto.zip", "time-infozip.zip"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := &Options{NameDecoder: DecodeCP437, Password: "secret"}
+		want := openTestZip(t, data, opts)
+		opts.CompactDirectory = true
+		got := openTestZip(t, data, opts)
+
+		for i, f := range got.File {
+			w := want.File[i]
+			if f.Name != w.Name || f.Comment != w.Comment || f.RawName != w.RawName || f.RawComment != w.RawComment {
+				t.Errorf("%s: entry %d: got %q %q %q %q, want %q %q %q %q", name, i,
+					f.Name, f.Comment, f.RawName, f.RawComment, w.Name, w.Comment, w.RawName, w.RawComment)
+			}
+			if !f.Modified.Equal(w.Modified) {
+				t.Errorf("%s: %s: modified %v, want %v", name, f.Name, f.Modified, w.Modified)
+			}
+			if f.IsEncrypted() && !bytes.Equal(f.Extra, w.Extra) || !f.IsEncrypted() && f.Extra != nil {
+				t.Errorf("%s: %s: extra %x, want %x if encrypted", name, f.Name, f.Extra, w.Extra)
+			}
+			gotData, err := readAllFile(f)
+			if err != nil {
+				t.Fatalf("%s: %s: %v", name, f.Name, err)
+			}
+			wantData, _ := readAllFile(w)
+			if !bytes.Equal(gotData, wantData) {
+				t.Errorf("%s: %s: content mismatch", name, f.Name)
+			}
+		}
+	}
+}
+
+func BenchmarkOpenDirectory(b *testing.B) {
+	var files []testZipFile
+	for i := 0; i < 10000; i++ {
+		files = append(files, testZipFile{Name: fmt.Sprintf("dir%d/file%d", i%100, i), Method: Store})
+	}
+	data := buildTestZip(b, files...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		openTestZip(b, data, nil)
+	}
+}
+
+func TestOpenDirectoryAllocs(t *testing.T) {
+	const n = 10000
+	var files []testZipFile
+	for i := 0; i < n; i++ {
+		files = append(files, testZipFile{Name: fmt.Sprintf("dir%d/file%d", i%100, i), Method: Store})
+	}
+	data := buildTestZip(t, files...)
+	allocs := testing.AllocsPerRun(5, func() {
+		openTestZip(t, data, nil)
+	})
+	// Records are handed out in blocks, so the count grows with the number
+	// of blocks rather than with the number of entries.
+	if allocs > n/100 {
+		t.Fatalf("got %v allocations opening %d entries, want at most %d", allocs, n, n/100)
+	}
+}
+
+func TestCompactFilesAllocs(t *testing.T) {
+	var files []testZipFile
+	for i := 0; i < 1000; i++ {
+		files = append(files, testZipFile{Name: fmt.Sprintf("dir%d/file%d", i%10, i), Method: Store})
+	}
+	z := openTestZip(t, buildTestZip(t, files...), nil)
+	// Only the arena is allocated, not anything per entry.
+	if allocs := testing.AllocsPerRun(5, z.compactFiles); allocs > 1 {
+		t.Fatalf("got %v allocations compacting %d entries, want 1", allocs, len(z.File))
+	}
+}
+
+func TestNameArena(t *testing.T) {
+	names := []string{"a/", "a/b/", "a/b/c", "a/d", "a/d", "e"}
+	var want int
+	for i, name := range names {
+		if i == len(names)-1 || !strings.HasPrefix(names[i+1], name) {
+			want += len(name)
+		}
+	}
+
+	var a nameArena
+	n := 0
+	for _, name := range names {
+		n += a.sizeString(name)
+	}
+	if n != want {
+		t.Errorf("sizeString: got %d bytes, want %d", n, want)
+	}
+	for _, add := range []func(name string) string{
+		a.addString,
+		func(name string) string { return a.add([]byte(name)) },
+	} {
+		a.reset(n)
+		for _, name := range names {
+			if got := add(name); got != name {
+				t.Errorf("got %q, want %q", got, name)
+			}
+		}
+		if a.b.Len() != want {
+			t.Errorf("got %d bytes, want %d", a.b.Len(), want)
+		}
+	}
+}
+
+// BenchmarkCompactDirectoryTree reports the heap held by an open archive
+// whose directories have entries of their own, listed before the entries
+// in them as zip -r does.
+func BenchmarkCompactDirectoryTree(b *testing.B) {
+	var files []testZipFile
+	for i := 0; i < 1000; i++ {
+		dir := fmt.Sprintf("project/src/module%d/", i)
+		files = append(files, testZipFile{Name: dir, Method: Store},
+			testZipFile{Name: dir + "internal/", Method: Store},
+			testZipFile{Name: dir + "internal/impl.go", Method: Store},
+			testZipFile{Name: dir + "module.go", Method: Store})
+	}
+	data := buildTestZip(b, files...)
+	for _, compact := range []bool{false, true} {
+		b.Run(fmt.Sprintf("compact=%v", compact), func(b *testing.B) {
+			var held uint64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				z := openTestZip(b, data, &Options{CompactDirectory: compact})
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				held += after.HeapAlloc - before.HeapAlloc
+				runtime.KeepAlive(z)
+			}
+			b.ReportMetric(float64(held)/float64(b.N*len(files)), "B/entry")
+		})
+	}
+}
@@ -5,9 +5,11 @@
 package zipread
 
 import (
+	"compress/bzip2"
 	"compress/flate"
 	"errors"
 	"io"
+	"sort"
 	"sync"
 )
 
@@ -63,16 +65,51 @@ var (
 func init() {
 	decompressors.Store(Store, Decompressor(io.NopCloser))
 	decompressors.Store(Deflate, Decompressor(newFlateReader))
+	decompressors.Store(Deflate64, Decompressor(newDeflate64Reader))
+	decompressors.Store(Bzip2, Decompressor(newBzip2Reader))
+}
+
+func newBzip2Reader(r io.Reader) io.ReadCloser {
+	return io.NopCloser(bzip2.NewReader(r))
 }
 
 // RegisterDecompressor allows custom decompressors for a specified method ID.
-// The common methods Store and Deflate are built in.
+// The common methods Store and Deflate, and Deflate64 and Bzip2, are built
+// in. It is safe to call concurrently with opening files.
 func RegisterDecompressor(method uint16, dcomp Decompressor) {
 	if _, dup := decompressors.LoadOrStore(method, dcomp); dup {
 		panic("decompressor already registered")
 	}
 }
 
+// SetDeflateDecompressor replaces the built-in decompressor for Deflate,
+// which uses compress/flate, with a faster implementation, such as the one
+// installed by importing zipread/fastflate. A nil dcomp restores the
+// built-in one. Decompressors registered with a Reader still take
+// precedence. It is safe to call concurrently with opening files.
+func SetDeflateDecompressor(dcomp Decompressor) {
+	if dcomp == nil {
+		dcomp = newFlateReader
+	}
+	decompressors.Store(Deflate, dcomp)
+}
+
+// SupportedMethods returns the sorted compression methods that have a
+// decompressor registered at the package level.
+func SupportedMethods() []uint16 {
+	var methods []uint16
+	decompressors.Range(func(key, _ interface{}) bool {
+		methods = append(methods, key.(uint16))
+		return true
+	})
+	sortMethods(methods)
+	return methods
+}
+
+func sortMethods(methods []uint16) {
+	sort.Slice(methods, func(i, j int) bool { return methods[i] < methods[j] })
+}
+
 func decompressor(method uint16) Decompressor {
 	di, ok := decompressors.Load(method)
 	if !ok {
@@ -0,0 +1,82 @@
+package zipread
+
+import (
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// Scoped is a view of the entries of a Reader under a directory, with
+// names relative to it. It shares the entries of the Reader, so creating
+// one costs a binary search over the names, and any number of them can be
+// used concurrently. See Reader.Scope.
+type Scoped struct {
+	r      *Reader
+	prefix string  // directory name followed by a slash
+	files  []*File // run of r.sortedFiles() under prefix
+}
+
+// Scope returns a view of the entries under the directory dir, a valid
+// path as defined by fs.ValidPath, with names rebased to it. The entry of
+// dir itself, if any, is not part of the view. Unlike Sub, which only
+// serves the fs.FS interfaces, the view also exposes the entries as Files.
+// A directory without entries gives an empty view.
+func (z *Reader) Scope(dir string) (*Scoped, error) {
+	if !fs.ValidPath(dir) || dir == "." {
+		return nil, errs.Errorf("invalid scope %q", dir)
+	}
+	return newScoped(z, z.sortedFiles(), dir+"/"), nil
+}
+
+func newScoped(z *Reader, sorted []*File, prefix string) *Scoped {
+	lo := sort.Search(len(sorted), func(i int) bool { return sorted[i].Name > prefix })
+	hi := lo + sort.Search(len(sorted)-lo, func(i int) bool {
+		return !strings.HasPrefix(sorted[lo+i].Name, prefix)
+	})
+	return &Scoped{r: z, prefix: prefix, files: sorted[lo:hi:hi]}
+}
+
+// Dir returns the name of the directory the view is scoped to.
+func (s *Scoped) Dir() string { return strings.TrimSuffix(s.prefix, "/") }
+
+// Files returns the entries of the view sorted by name. The slice is
+// shared and must not be modified. The Name of each File is the full name
+// in the archive, use Name to get it relative to the view.
+func (s *Scoped) Files() []*File { return s.files }
+
+// Name returns the name of f relative to the directory of the view, and
+// whether f is part of the view.
+func (s *Scoped) Name(f *File) (string, bool) {
+	if !strings.HasPrefix(f.Name, s.prefix) || f.Name == s.prefix {
+		return "", false
+	}
+	return f.Name[len(s.prefix):], true
+}
+
+// Lookup returns the entry named name relative to the directory of the
+// view, or nil if there is none. Names are matched exactly, as they are
+// recorded in the archive.
+func (s *Scoped) Lookup(name string) *File {
+	full := s.prefix + name
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].Name >= full })
+	if i < len(s.files) && s.files[i].Name == full {
+		return s.files[i]
+	}
+	return nil
+}
+
+// Scope returns a view of the entries under dir, relative to the
+// directory of s.
+func (s *Scoped) Scope(dir string) (*Scoped, error) {
+	if !fs.ValidPath(dir) || dir == "." {
+		return nil, errs.Errorf("invalid scope %q", dir)
+	}
+	return newScoped(s.r, s.files, s.prefix+dir+"/"), nil
+}
+
+// FS returns the file system view of the directory, as Sub does.
+func (s *Scoped) FS() fs.FS {
+	return &subFS{r: s.r, dir: s.Dir()}
+}
@@ -0,0 +1,65 @@
+package zipread
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	var files []testZipFile
+	for _, name := range []string{"tenants/b/x", "tenants/a/", "tenants/a/1", "tenants/a/sub/2", "tenants/ab", "other"} {
+		f := testZipFile{Name: name, Method: Store}
+		if !strings.HasSuffix(name, "/") {
+			f.Data = []byte(name)
+		}
+		files = append(files, f)
+	}
+	z := openTestZip(t, buildTestZip(t, files...), nil)
+
+	s, err := z.Scope("tenants/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range s.Files() {
+		name, ok := s.Name(f)
+		if !ok {
+			t.Fatalf("Name(%q) reported outside of the view", f.Name)
+		}
+		got = append(got, name)
+	}
+	if want := []string{"1", "sub/2"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if f := s.Lookup("sub/2"); f == nil || f.Name != "tenants/a/sub/2" {
+		t.Errorf("Lookup: got %v", f)
+	}
+	if f := s.Lookup("x"); f != nil {
+		t.Errorf("Lookup outside of the view: got %q", f.Name)
+	}
+	if _, ok := s.Name(z.File[0]); ok {
+		t.Errorf("Name(%q) reported inside of the view", z.File[0].Name)
+	}
+
+	sub, err := s.Scope("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sub.Files()) != 1 || sub.Lookup("2") == nil || sub.Dir() != "tenants/a/sub" {
+		t.Errorf("nested scope: got %d files in %q", len(sub.Files()), sub.Dir())
+	}
+	if data, err := fs.ReadFile(s.FS(), "sub/2"); err != nil || string(data) != "tenants/a/sub/2" {
+		t.Errorf("FS: got %q, %v", data, err)
+	}
+
+	empty, err := z.Scope("missing")
+	if err != nil || len(empty.Files()) != 0 {
+		t.Errorf("empty scope: got %v, %v", empty, err)
+	}
+	for _, dir := range []string{".", "/a", "a/", "../a"} {
+		if _, err := z.Scope(dir); err == nil {
+			t.Errorf("Scope(%q): expected error", dir)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// Seek implements io.Seeker for the ReadCloser returned by File.Open.
+// Stored entries seek natively by requesting the new position from the
+// source. Other methods skip forward by decompressing and discarding, and
+// seek backward by restarting decompression from the beginning, or, with
+// Options.SOZip, from the nearest indexed chunk.
+// Checksums are only verified if the content was read contiguously from
+// the start; seeking past the end makes Read return io.EOF.
+func (r *checksumReader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, errReadAfterClose
+	}
+	size := int64(r.f.UncompressedSize64)
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = int64(r.nread) + offset
+	case io.SeekEnd:
+		abs = size + offset
+	default:
+		return 0, errs.Errorf("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errs.Errorf("negative position")
+	}
+	if abs == int64(r.nread) {
+		return abs, nil
+	}
+
+	var err error
+	switch {
+	case abs >= size:
+		err = r.reset(io.NopCloser(bytes.NewReader(nil)), abs, true)
+	case r.f.Method == Store && !r.f.IsEncrypted():
+		err = r.seekStored(abs)
+	default:
+		err = r.seekDecompressed(abs)
+	}
+	if err != nil {
+		r.err = err
+		return 0, err
+	}
+	return abs, nil
+}
+
+// reset replaces the underlying reader with rc, positioned at pos.
+func (r *checksumReader) reset(rc io.ReadCloser, pos int64, unverified bool) error {
+	err := r.rc.Close()
+	r.rc = rc
+	r.nread = uint64(pos)
+	r.hash.Reset()
+	r.digest = nil
+	r.unverified = unverified
+	r.err = nil
+	return err
+}
+
+func (r *checksumReader) seekStored(abs int64) error {
+	rc, err := r.f.OpenRange(context.TODO(), abs, int64(r.f.UncompressedSize64)-abs)
+	if err != nil {
+		return err
+	}
+	return r.reset(rc, abs, true)
+}
+
+func (r *checksumReader) seekDecompressed(abs int64) error {
+	idx, err := r.f.seekIndex(context.TODO())
+	if err != nil {
+		return err
+	}
+	if idx != nil {
+		// Restart at the nearest chunk unless that means going back or
+		// skipping less than reading on would.
+		rp := idx.restartPoint(abs)
+		if rp.uncompressed > int64(r.nread) || abs < int64(r.nread) || r.err != nil {
+			rc, pos, err := r.f.openAt(context.TODO(), rp)
+			if err != nil {
+				return err
+			}
+			if err := r.reset(rc, pos, true); err != nil {
+				return err
+			}
+		}
+	} else if abs < int64(r.nread) || r.err != nil {
+		fresh, err := r.f.Open()
+		if err != nil {
+			return err
+		}
+		// Take over the fresh reader's decompressor; the checksum stays
+		// verifiable since we start over from the beginning.
+		cr := fresh.(*checksumReader)
+		if err := r.reset(cr.rc, 0, false); err != nil {
+			return err
+		}
+		r.header = cr.header
+	}
+	_, err = io.CopyN(io.Discard, struct{ io.Reader }{r}, abs-int64(r.nread))
+	return err
+}
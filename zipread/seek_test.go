@@ -0,0 +1,67 @@
+package zipread
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSeek(t *testing.T) {
+	content := make([]byte, 50000)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	z := openTestZip(t, buildTestZip(t,
+		testZipFile{Name: "stored", Method: Store, Data: content},
+		testZipFile{Name: "deflated", Method: Deflate, Data: content}), nil)
+
+	for _, f := range z.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := rc.(io.ReadSeeker)
+		buf := make([]byte, 100)
+		for _, seek := range []struct {
+			offset int64
+			whence int
+			want   int64
+		}{
+			{1000, io.SeekStart, 1000},
+			{500, io.SeekCurrent, 1600},
+			{-200, io.SeekEnd, 49800},
+			{10, io.SeekStart, 10},
+		} {
+			pos, err := s.Seek(seek.offset, seek.whence)
+			if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+			if pos != seek.want {
+				t.Fatalf("%s: Seek=%d, want %d", f.Name, pos, seek.want)
+			}
+			if _, err := io.ReadFull(s, buf); err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+			if !bytes.Equal(buf, content[pos:pos+100]) {
+				t.Fatalf("%s: wrong content at %d", f.Name, pos)
+			}
+		}
+
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(s); err != nil || !bytes.Equal(got, content) {
+			t.Errorf("%s: reread after seek: %v", f.Name, err)
+		}
+
+		if _, err := s.Seek(10, io.SeekEnd); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := s.Read(buf); n != 0 || err != io.EOF {
+			t.Errorf("%s: read past end: %d, %v", f.Name, n, err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package zipread
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// sequentialChunk is the size of the chunks a SequentialReader fetches
+// ahead of decompression unless Options.ReadAhead says otherwise.
+const sequentialChunk = 1 << 20
+
+// A SequentialReader reads entries in the order they are stored in the
+// archive, like extracting a whole archive does. Rather than a request per
+// entry, it streams the entries from a single range of the source per run
+// of neighbouring entries, fetching ahead of decompression so that the
+// payload of the next entry arrives while the current one is decompressed.
+// Entry contents are verified like with File.Open.
+//
+//	sr := z.NewSequentialReader(ctx, nil)
+//	defer sr.Close()
+//	for {
+//		f, err := sr.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//		_, err = io.Copy(dst, sr)
+//	}
+type SequentialReader struct {
+	ctx    context.Context
+	z      *Reader
+	files  []*File // sorted by offset
+	bounds []int64
+	next   int
+
+	stream io.ReadCloser // the current run, if any
+	src    *byteCounter  // reads from stream, counting from start
+	start  int64         // offset stream starts at
+	end    int64         // offset stream ends at
+
+	cur    *checksumReader // the current entry, if it could be opened
+	err    error           // sticky error of the current entry
+	broken bool            // set if an error left stream at an unknown position
+}
+
+// NewSequentialReader returns a SequentialReader of files, all entries if
+// nil, in the order they are stored in the archive.
+func (z *Reader) NewSequentialReader(ctx context.Context, files []*File) *SequentialReader {
+	if files == nil {
+		files = z.File
+	}
+	sorted := make([]*File, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].headerOffset < sorted[j].headerOffset })
+	return &SequentialReader{ctx: ctx, z: z, files: sorted, bounds: z.entryBounds()}
+}
+
+// Next advances to the next entry and returns it, or io.EOF once all have
+// been read. Whatever is left of the previous entry is skipped. An entry
+// that cannot be read makes Read fail, but not Next.
+func (s *SequentialReader) Next() (*File, error) {
+	if s.cur != nil {
+		_ = s.cur.Close()
+		s.cur = nil
+	}
+	if s.broken {
+		// Start over with the next entry.
+		s.closeStream()
+		s.broken = false
+	}
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.next == len(s.files) {
+		s.closeStream()
+		return nil, io.EOF
+	}
+	f := s.files[s.next]
+	s.next++
+	s.err = s.open(f)
+	return f, nil
+}
+
+// open positions the stream at f and opens it.
+func (s *SequentialReader) open(f *File) error {
+	if err := f.checkFeatures(); err != nil {
+		return err
+	}
+	dcomp := s.z.decompressor(f.Method)
+	if dcomp == nil {
+		return ErrAlgorithm
+	}
+
+	var pos int64
+	if s.stream != nil {
+		pos = s.start + s.src.n
+	}
+	if s.stream == nil || f.headerOffset < pos || f.headerOffset-pos > coalesceGap || s.extent(f) > s.end {
+		s.closeStream()
+		if err := s.openStream(); err != nil {
+			return err
+		}
+		pos = s.start
+	}
+	if _, err := io.CopyN(io.Discard, s.src, f.headerOffset-pos); err != nil {
+		s.broken = true
+		return err
+	}
+	if err := f.validateFileHeader(s.src); err != nil {
+		s.broken = true
+		return err
+	}
+
+	body := &byteCounter{r: io.LimitReader(s.src, int64(f.CompressedSize64))}
+	plain, err := f.decrypt(s.ctx, body)
+	if err != nil {
+		s.broken = true
+		return err
+	}
+	rc := dcomp(plain)
+	s.cur = &checksumReader{
+		rc:         rc,
+		hash:       crc32.NewIEEE(),
+		f:          f,
+		policy:     s.z.opts.CRCPolicy,
+		compressed: body,
+	}
+	return nil
+}
+
+// extent returns the offset at which the data of f ends at the latest: the
+// start of the next entry or the central directory.
+func (s *SequentialReader) extent(f *File) int64 {
+	end := s.z.size
+	if j := sort.Search(len(s.bounds), func(j int) bool { return s.bounds[j] > f.headerOffset }); j < len(s.bounds) {
+		end = s.bounds[j]
+	}
+	if f.headerOffset < s.z.dirOffset && s.z.dirOffset < end {
+		end = s.z.dirOffset
+	}
+	return end
+}
+
+// openStream requests a range of the source from the next entry on,
+// covering every following entry up to the first one too far from the
+// previous.
+func (s *SequentialReader) openStream() error {
+	first := s.files[s.next-1]
+	s.start, s.end = first.headerOffset, s.extent(first)
+	for _, f := range s.files[s.next:] {
+		if f.headerOffset < s.end || f.headerOffset-s.end > coalesceGap {
+			break
+		}
+		s.end = s.extent(f)
+	}
+
+	rr, err := s.z.source.Range(s.ctx, s.start, s.end-s.start)
+	if err != nil {
+		return err
+	}
+	chunk := s.z.opts.ReadAhead
+	if chunk <= 0 {
+		chunk = sequentialChunk
+	}
+	s.stream = newReadAhead(rr, chunk, s.end-s.start)
+	s.src = &byteCounter{r: s.stream}
+	return nil
+}
+
+func (s *SequentialReader) closeStream() {
+	if s.stream != nil {
+		_ = s.stream.Close()
+		s.stream, s.src = nil, nil
+	}
+}
+
+// Read reads from the current entry.
+func (s *SequentialReader) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.cur == nil {
+		return 0, errs.Errorf("no current entry, call Next")
+	}
+	n, err := s.cur.Read(p)
+	if err != nil && err != io.EOF {
+		s.err, s.broken = err, true
+	}
+	if err == io.EOF && s.cur.crcErr != nil {
+		// CRCReport holds back the mismatch until Close, which Next
+		// does not report; report it at the end of the entry instead.
+		err, s.err = s.cur.crcErr, s.cur.crcErr
+	}
+	return n, err
+}
+
+// Close releases the stream. It does not close the Reader.
+func (s *SequentialReader) Close() error {
+	if s.cur != nil {
+		_ = s.cur.Close()
+		s.cur = nil
+	}
+	var err error
+	if s.stream != nil {
+		err = s.stream.Close()
+		s.stream, s.src = nil, nil
+	}
+	s.next, s.err = len(s.files), errReadAfterClose
+	return err
+}
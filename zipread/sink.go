@@ -0,0 +1,95 @@
+package zipread
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// A Sink is the write-side counterpart of Source. It receives an object as
+// a sequence of numbered parts, which maps directly onto multipart uploads
+// and lets an interrupted write resume at a part boundary.
+type Sink interface {
+	// WritePart stores data, which must be read until EOF, as part number
+	// (starting at 1) beginning at offset within the final object. Writing
+	// a part number again replaces its previous contents.
+	WritePart(ctx context.Context, number int, offset int64, data io.Reader) error
+	// Commit assembles the written parts, in order, into the final object.
+	Commit(ctx context.Context) error
+	// Abort discards everything written so far.
+	Abort(ctx context.Context) error
+}
+
+// A FileSink is a Sink writing to a local file, which lays out the parts
+// at their offsets as they arrive. See SinkToFile.
+type FileSink struct {
+	name string
+	fh   *os.File
+	end  int64 // end of the furthest part written
+}
+
+// SinkToFile returns a Sink that writes to the named file, creating it if
+// needed. Parts already present in an existing file are kept, so a write
+// can be resumed by another FileSink for the same name. The file is
+// truncated to the end of the last part written on Commit. A FileSink must
+// not be used concurrently.
+func SinkToFile(name string) *FileSink {
+	return &FileSink{name: name}
+}
+
+func (fs *FileSink) open() error {
+	if fs.fh != nil {
+		return nil
+	}
+	fh, err := os.OpenFile(fs.name, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fs.fh = fh
+	return nil
+}
+
+func (fs *FileSink) WritePart(ctx context.Context, number int, offset int64, data io.Reader) error {
+	if offset < 0 {
+		return fmt.Errorf("negative offset")
+	}
+	if err := fs.open(); err != nil {
+		return err
+	}
+	if _, err := fs.fh.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.Copy(fs.fh, data)
+	if end := offset + n; end > fs.end {
+		fs.end = end
+	}
+	return err
+}
+
+func (fs *FileSink) Commit(ctx context.Context) error {
+	if err := fs.open(); err != nil {
+		return err
+	}
+	var err error
+	if fs.end > 0 {
+		err = fs.fh.Truncate(fs.end)
+	}
+	if err == nil {
+		err = fs.fh.Sync()
+	}
+	err = errs.Combine(err, fs.fh.Close())
+	fs.fh = nil
+	return err
+}
+
+func (fs *FileSink) Abort(ctx context.Context) error {
+	var err error
+	if fs.fh != nil {
+		err = fs.fh.Close()
+		fs.fh = nil
+	}
+	return errs.Combine(err, os.Remove(fs.name))
+}
@@ -15,6 +15,22 @@ type Source interface {
 	RangeFromEnd(ctx context.Context, length int64) (data io.ReadCloser, sourceLength int64, err error)
 }
 
+// An UncachedSource is a Source with a way to serve a range bypassing any
+// caching, used when data has to be fetched again after a checksum
+// mismatch.
+type UncachedSource interface {
+	Source
+	RangeUncached(ctx context.Context, offset, length int64) (data io.ReadCloser, err error)
+}
+
+// rangeUncached requests a range from s, bypassing caches if s supports it.
+func rangeUncached(ctx context.Context, s Source, offset, length int64) (io.ReadCloser, error) {
+	if us, ok := s.(UncachedSource); ok {
+		return us.RangeUncached(ctx, offset, length)
+	}
+	return s.Range(ctx, offset, length)
+}
+
 type FileSource struct {
 	name string
 }
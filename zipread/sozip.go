@@ -0,0 +1,191 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// SOZip (seek-optimized ZIP) archives accompany large deflate entries with
+// a hidden index entry that records where independently decodable chunks
+// start in the compressed stream.
+//
+// See: https://github.com/sozip/sozip-spec
+const (
+	sozipIndexVersion   = 1
+	sozipIndexHeaderLen = 32
+	sozipOffsetSize     = 8
+)
+
+// A SOZipIndex locates the independently decodable chunks of a deflate
+// entry in a SOZip archive.
+type SOZipIndex struct {
+	ChunkSize        uint32
+	UncompressedSize uint64
+	CompressedSize   uint64
+
+	// Offsets holds the offset of every chunk relative to the start of
+	// the compressed body. Offsets[0] is always zero.
+	Offsets []uint64
+}
+
+func (idx *SOZipIndex) restartPoint(off int64) restartPoint {
+	chunk := off / int64(idx.ChunkSize)
+	if chunk >= int64(len(idx.Offsets)) {
+		chunk = int64(len(idx.Offsets)) - 1
+	}
+	return restartPoint{
+		uncompressed: chunk * int64(idx.ChunkSize),
+		compressed:   int64(idx.Offsets[chunk]),
+	}
+}
+
+// sozipIndexName returns the name of the index entry for name.
+func sozipIndexName(name string) string {
+	dir, elem := path.Split(name)
+	return dir + "." + elem + ".sozip.idx"
+}
+
+// SOZipIndex returns the SOZip index of a deflate entry, or nil if it has
+// none. The index entry is looked up in the central directory first and
+// otherwise expected as a hidden entry right after the entry's data. The
+// result is cached.
+func (f *File) SOZipIndex(ctx context.Context) (*SOZipIndex, error) {
+	f.mu.Lock()
+	idx, loaded := f.sozip, f.sozipLoaded
+	f.mu.Unlock()
+	if loaded {
+		return idx, nil
+	}
+	if f.Method != Deflate {
+		return nil, nil
+	}
+
+	raw, err := f.loadSOZipIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		if idx, err = parseSOZipIndex(raw, f); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	f.sozip, f.sozipLoaded = idx, true
+	f.mu.Unlock()
+	return idx, nil
+}
+
+// loadSOZipIndex returns the raw contents of the index entry, if any.
+func (f *File) loadSOZipIndex(ctx context.Context) ([]byte, error) {
+	name := sozipIndexName(f.RawName)
+	for _, g := range f.zip.File {
+		if g.RawName == name && g.Method == Store {
+			return readStored(ctx, g)
+		}
+	}
+
+	// Look for a hidden index: a local file header right after the data,
+	// possibly preceded by a data descriptor.
+	dataOffset, err := f.resolveDataOffset(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dataEnd := dataOffset + int64(f.CompressedSize64)
+	probe, err := readRange(ctx, f.zips, dataEnd, dataDescriptor64Len+fileHeaderLen+int64(len(name)))
+	if err != nil {
+		return nil, err
+	}
+	for _, skip := range []int{0, dataDescriptorLen - 4, dataDescriptorLen, dataDescriptor64Len - 4, dataDescriptor64Len} {
+		if len(probe) < skip+fileHeaderLen+len(name) {
+			break
+		}
+		b := readBuf(probe[skip:])
+		if b.uint32() != fileHeaderSignature {
+			continue
+		}
+		b = b[4:] // version needed, flags
+		method := b.uint16()
+		b = b[8:] // time, date, crc32
+		csize := int64(b.uint32())
+		b = b[4:] // uncompressed size
+		nameLen := int(b.uint16())
+		extraLen := int64(b.uint16())
+		if method != Store || nameLen != len(name) || string(b[:nameLen]) != name {
+			continue
+		}
+		start := dataEnd + int64(skip) + fileHeaderLen + int64(nameLen) + extraLen
+		return readRange(ctx, f.zips, start, csize)
+	}
+	return nil, nil
+}
+
+func parseSOZipIndex(raw []byte, f *File) (*SOZipIndex, error) {
+	invalid := errs.Errorf("zip: invalid SOZip index for %q", f.Name)
+	if len(raw) < sozipIndexHeaderLen {
+		return nil, invalid
+	}
+	b := readBuf(raw)
+	if b.uint32() != sozipIndexVersion {
+		return nil, invalid
+	}
+	skip := b.uint32()
+	idx := &SOZipIndex{ChunkSize: b.uint32()}
+	if b.uint32() != sozipOffsetSize || idx.ChunkSize == 0 {
+		return nil, invalid
+	}
+	idx.UncompressedSize = b.uint64()
+	idx.CompressedSize = b.uint64()
+	if idx.UncompressedSize != f.UncompressedSize64 || idx.CompressedSize != f.CompressedSize64 {
+		// The index is stale or belongs to another entry.
+		return nil, invalid
+	}
+	if uint64(len(b)) < uint64(skip) {
+		return nil, invalid
+	}
+	b = b[skip:]
+
+	chunks := (idx.UncompressedSize + uint64(idx.ChunkSize) - 1) / uint64(idx.ChunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+	if uint64(len(b)) != (chunks-1)*sozipOffsetSize {
+		return nil, invalid
+	}
+	idx.Offsets = make([]uint64, 1, chunks)
+	for len(b) > 0 {
+		off := b.uint64()
+		if off < idx.Offsets[len(idx.Offsets)-1] || off >= idx.CompressedSize {
+			return nil, invalid
+		}
+		idx.Offsets = append(idx.Offsets, off)
+	}
+	return idx, nil
+}
+
+// readStored returns the contents of a small stored entry.
+func readStored(ctx context.Context, f *File) ([]byte, error) {
+	dataOffset, err := f.resolveDataOffset(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return readRange(ctx, f.zips, dataOffset, int64(f.CompressedSize64))
+}
+
+// readRange reads up to length bytes at offset from s.
+func readRange(ctx context.Context, s Source, offset, length int64) (_ []byte, err error) {
+	rr, err := s.Range(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errs.Combine(err, rr.Close()) }()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(rr, length)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,186 @@
+package zipread
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// rawZipEntry is an entry written verbatim by buildRawZip.
+type rawZipEntry struct {
+	Name   string
+	Method uint16
+	Body   []byte // compressed body
+	CRC32  uint32
+	USize  uint64
+	Hidden bool // omit from the central directory
+}
+
+// buildRawZip lays out entries with precomputed bodies, which lets tests
+// produce archives archive/zip cannot write.
+func buildRawZip(entries ...rawZipEntry) []byte {
+	var buf, cen bytes.Buffer
+	le := binary.LittleEndian
+	count := 0
+	for _, e := range entries {
+		offset := buf.Len()
+		var loc [fileHeaderLen]byte
+		le.PutUint32(loc[0:], fileHeaderSignature)
+		le.PutUint16(loc[4:], zipVersion20)
+		le.PutUint16(loc[8:], e.Method)
+		le.PutUint32(loc[14:], e.CRC32)
+		le.PutUint32(loc[18:], uint32(len(e.Body)))
+		le.PutUint32(loc[22:], uint32(e.USize))
+		le.PutUint16(loc[26:], uint16(len(e.Name)))
+		buf.Write(loc[:])
+		buf.WriteString(e.Name)
+		buf.Write(e.Body)
+		if e.Hidden {
+			continue
+		}
+		count++
+		var hdr [directoryHeaderLen]byte
+		le.PutUint32(hdr[0:], directoryHeaderSignature)
+		le.PutUint16(hdr[4:], zipVersion20)
+		le.PutUint16(hdr[6:], zipVersion20)
+		le.PutUint16(hdr[10:], e.Method)
+		le.PutUint32(hdr[16:], e.CRC32)
+		le.PutUint32(hdr[20:], uint32(len(e.Body)))
+		le.PutUint32(hdr[24:], uint32(e.USize))
+		le.PutUint16(hdr[28:], uint16(len(e.Name)))
+		le.PutUint32(hdr[42:], uint32(offset))
+		cen.Write(hdr[:])
+		cen.WriteString(e.Name)
+	}
+	var end [directoryEndLen]byte
+	le.PutUint32(end[0:], directoryEndSignature)
+	le.PutUint16(end[8:], uint16(count))
+	le.PutUint16(end[10:], uint16(count))
+	le.PutUint32(end[12:], uint32(cen.Len()))
+	le.PutUint32(end[16:], uint32(buf.Len()))
+	buf.Write(cen.Bytes())
+	buf.Write(end[:])
+	return buf.Bytes()
+}
+
+// sozipCompress deflates data in independently decodable chunks, as SOZip
+// writers do, and returns the body along with its index.
+func sozipCompress(t testing.TB, data []byte, chunkSize int) ([]byte, *SOZipIndex) {
+	var body bytes.Buffer
+	idx := &SOZipIndex{ChunkSize: uint32(chunkSize), UncompressedSize: uint64(len(data))}
+	for start := 0; start == 0 || start < len(data); start += chunkSize {
+		if start > 0 {
+			idx.Offsets = append(idx.Offsets, uint64(body.Len()))
+		} else {
+			idx.Offsets = []uint64{0}
+		}
+		end := start + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		// A fresh compressor per chunk means no back-references across
+		// chunk boundaries; Flush byte-aligns the stream.
+		fw, err := flate.NewWriter(&body, flate.BestSpeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data[start:end])
+		if end == len(data) {
+			err = fw.Close()
+		} else {
+			err = fw.Flush()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.CompressedSize = uint64(body.Len())
+	return body.Bytes(), idx
+}
+
+func appendSOZipIndex(dst []byte, idx *SOZipIndex) []byte {
+	var hdr [sozipIndexHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:], sozipIndexVersion)
+	binary.LittleEndian.PutUint32(hdr[8:], idx.ChunkSize)
+	binary.LittleEndian.PutUint32(hdr[12:], sozipOffsetSize)
+	binary.LittleEndian.PutUint64(hdr[16:], idx.UncompressedSize)
+	binary.LittleEndian.PutUint64(hdr[24:], idx.CompressedSize)
+	dst = append(dst, hdr[:]...)
+	for _, off := range idx.Offsets[1:] {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], off)
+		dst = append(dst, b[:]...)
+	}
+	return dst
+}
+
+// countingSource counts the requests and bytes requested from the
+// wrapped Source.
+type countingSource struct {
+	Source
+	calls     int
+	requested int64
+}
+
+func (s *countingSource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	s.calls++
+	s.requested += length
+	return s.Source.Range(ctx, offset, length)
+}
+
+func TestSOZip(t *testing.T) {
+	content := make([]byte, 1<<20)
+	for i := range content {
+		content[i] = byte(i*i>>7) ^ byte(i>>11)
+	}
+	body, idx := sozipCompress(t, content, 32<<10)
+	rawIdx := appendSOZipIndex(nil, idx)
+
+	for _, hidden := range []bool{true, false} {
+		data := buildRawZip(
+			rawZipEntry{Name: "dir/big", Method: Deflate, Body: body, CRC32: crc32.ChecksumIEEE(content), USize: uint64(len(content))},
+			rawZipEntry{Name: "dir/.big.sozip.idx", Method: Store, Body: rawIdx, CRC32: crc32.ChecksumIEEE(rawIdx), USize: uint64(len(rawIdx)), Hidden: hidden},
+		)
+		src := &countingSource{Source: SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))}
+		z, err := OpenWithOptions(src, &Options{SOZip: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := z.File[0]
+
+		got, err := f.SOZipIndex(context.Background())
+		if err != nil || got == nil {
+			t.Fatalf("hidden=%v: index %v, %v", hidden, got, err)
+		}
+		if len(got.Offsets) != len(idx.Offsets) {
+			t.Fatalf("hidden=%v: %d offsets, want %d", hidden, len(got.Offsets), len(idx.Offsets))
+		}
+
+		src.requested = 0
+		const off = 900000
+		rc, err := f.OpenRange(context.Background(), off, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(part, content[off:off+1000]) {
+			t.Errorf("hidden=%v: OpenRange content mismatch", hidden)
+		}
+		if src.requested >= int64(len(body)) {
+			t.Errorf("hidden=%v: requested %d bytes, index not used", hidden, src.requested)
+		}
+
+		full, err := readAllFile(f)
+		if err != nil || !bytes.Equal(full, content) {
+			t.Errorf("hidden=%v: full read: %v", hidden, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package zipread
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// OpenSpanned reads the central directory of a split archive, whose
+// segments (.z01, .z02, ..., .zip) are served by parts in disk order.
+// Entry offsets are resolved using the disk numbers recorded in the
+// central directory. Entries may cross segment boundaries.
+func OpenSpanned(parts []Source, opts *Options) (*Reader, error) {
+	if len(parts) == 0 {
+		return nil, errs.Errorf("no parts")
+	}
+	source, err := newSpannedSource(context.TODO(), parts)
+	if err != nil {
+		return nil, err
+	}
+	zr := &Reader{disks: source.starts[:len(parts)]}
+	if opts != nil {
+		zr.opts = *opts
+	}
+	if err := zr.init(source, nil); err != nil {
+		return nil, err
+	}
+	return zr.checkPaths()
+}
+
+// spannedSource serves the concatenation of several Sources.
+type spannedSource struct {
+	parts []Source
+
+	// starts holds the offset of each part, followed by the total size.
+	starts []int64
+}
+
+func newSpannedSource(ctx context.Context, parts []Source) (*spannedSource, error) {
+	s := &spannedSource{parts: parts, starts: make([]int64, len(parts)+1)}
+	for i, part := range parts {
+		rc, size, err := part.RangeFromEnd(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := rc.Close(); err != nil {
+			return nil, err
+		}
+		s.starts[i+1] = s.starts[i] + size
+	}
+	return s, nil
+}
+
+func (s *spannedSource) size() int64 { return s.starts[len(s.parts)] }
+
+func (s *spannedSource) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	return s.rangeParts(ctx, offset, length, func(ctx context.Context, part Source, offset, length int64) (io.ReadCloser, error) {
+		return part.Range(ctx, offset, length)
+	})
+}
+
+func (s *spannedSource) RangeUncached(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	return s.rangeParts(ctx, offset, length, rangeUncached)
+}
+
+func (s *spannedSource) RangeFromEnd(ctx context.Context, length int64) (io.ReadCloser, int64, error) {
+	if length < 0 {
+		return nil, 0, errs.Errorf("negative length")
+	}
+	if length > s.size() {
+		length = s.size()
+	}
+	rc, err := s.Range(ctx, s.size()-length, length)
+	return rc, s.size(), err
+}
+
+func (s *spannedSource) rangeParts(ctx context.Context, offset, length int64,
+	get func(context.Context, Source, int64, int64) (io.ReadCloser, error)) (_ io.ReadCloser, err error) {
+	if offset < 0 {
+		return nil, errs.Errorf("negative offset")
+	}
+	end := offset + length
+	if end > s.size() {
+		end = s.size()
+	}
+
+	var rcs []io.ReadCloser
+	closeAll := func() (err error) {
+		for _, rc := range rcs {
+			err = errs.Combine(err, rc.Close())
+		}
+		return err
+	}
+	first := sort.Search(len(s.parts), func(i int) bool { return s.starts[i+1] > offset })
+	for i := first; i < len(s.parts) && s.starts[i] < end; i++ {
+		lo, hi := offset-s.starts[i], end-s.starts[i]
+		if lo < 0 {
+			lo = 0
+		}
+		if size := s.starts[i+1] - s.starts[i]; hi > size {
+			hi = size
+		}
+		rc, err := get(ctx, s.parts[i], lo, hi-lo)
+		if err != nil {
+			return nil, errs.Combine(err, closeAll())
+		}
+		rcs = append(rcs, rc)
+	}
+
+	readers := make([]io.Reader, len(rcs))
+	for i, rc := range rcs {
+		readers[i] = rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.MultiReader(readers...),
+		Closer: closerFunc(closeAll),
+	}, nil
+}
@@ -0,0 +1,68 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestOpenSpanned(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store, Data: []byte("on the first disk")},
+		testZipFile{Name: "b", Method: Deflate, Data: bytes.Repeat([]byte("on the second disk "), 50)})
+
+	// Split the archive before the second local header, starting the first
+	// segment with the spanning signature, and rewrite the offsets to be
+	// relative to the disk they are on.
+	eocd := bytes.LastIndex(data, []byte("PK\x05\x06"))
+	cen := int(binary.LittleEndian.Uint32(data[eocd+16:]))
+	split := bytes.LastIndex(data[:cen], []byte("PK\x03\x04"))
+
+	disk0 := append([]byte("PK\x07\x08"), data[:split]...)
+	disk1 := append([]byte(nil), data[split:]...)
+	cen -= split
+	cen2 := bytes.LastIndex(disk1, []byte("PK\x01\x02"))
+	binary.LittleEndian.PutUint32(disk1[cen+42:], 4)  // a: offset on disk 0
+	binary.LittleEndian.PutUint16(disk1[cen2+34:], 1) // b: disk 1
+	binary.LittleEndian.PutUint32(disk1[cen2+42:], 0) // b: offset on disk 1
+	eocd -= split
+	binary.LittleEndian.PutUint16(disk1[eocd+4:], 1) // this disk
+	binary.LittleEndian.PutUint16(disk1[eocd+6:], 1) // disk with the directory
+	binary.LittleEndian.PutUint16(disk1[eocd+8:], 2) // records on this disk
+	binary.LittleEndian.PutUint32(disk1[eocd+16:], uint32(cen))
+
+	z, err := OpenSpanned([]Source{
+		SourceFromReaderAt(bytes.NewReader(disk0), int64(len(disk0))),
+		SourceFromReaderAt(bytes.NewReader(disk1), int64(len(disk1))),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"on the first disk", string(bytes.Repeat([]byte("on the second disk "), 50))} {
+		got, err := readAllFile(z.File[i])
+		if err != nil {
+			t.Fatalf("%s: %v", z.File[i].Name, err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s: content mismatch", z.File[i].Name)
+		}
+	}
+
+	// A range across the segment boundary is served from both parts.
+	rc, err := z.source.Range(context.Background(), int64(len(disk0))-2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(got), string(disk0[len(disk0)-2:])+string(disk1[:2]); got != want {
+		t.Fatalf("boundary range: got %q, want %q", got, want)
+	}
+}
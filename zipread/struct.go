@@ -22,13 +22,16 @@ package zipread
 import (
 	"archive/zip"
 	"io/fs"
-	"path"
+	"sync"
 	"time"
 )
 
 const (
 	Store   = zip.Store
 	Deflate = zip.Deflate
+
+	Deflate64 uint16 = 9  // decompression only
+	Bzip2     uint16 = 12 // decompression only
 )
 
 const (
@@ -38,6 +41,7 @@ const (
 	directory64LocSignature  = 0x07064b50
 	directory64EndSignature  = 0x06064b50
 	dataDescriptorSignature  = 0x08074b50 // de-facto standard; required by OS X Finder
+	archiveExtraSignature    = 0x08064b50 // precedes an encrypted central directory
 	fileHeaderLen            = 30         // + filename + extra
 	directoryHeaderLen       = 46         // + filename + extra + comment
 	directoryEndLen          = 22         // + comment
@@ -47,11 +51,13 @@ const (
 	directory64EndLen        = 56         // + extra
 
 	// Constants for the first byte in CreatorVersion.
-	creatorFAT    = 0
-	creatorUnix   = 3
-	creatorNTFS   = 11
-	creatorVFAT   = 14
-	creatorMacOSX = 19
+	creatorFAT         = 0
+	creatorUnix        = 3
+	creatorOS2HPFS     = 6
+	creatorWindowsNTFS = 10 // as numbered by the specification
+	creatorNTFS        = 11 // as numbered by archive/zip
+	creatorVFAT        = 14
+	creatorMacOSX      = 19
 
 	// Version numbers.
 	zipVersion20 = 20 // 2.0
@@ -72,19 +78,24 @@ const (
 	// See http://mdfs.net/Docs/Comp/Archiving/Zip/ExtraField
 	zip64ExtraID       = 0x0001 // Zip64 extended information
 	ntfsExtraID        = 0x000a // NTFS
+	strongEncExtraID   = 0x0017 // Strong encryption header
 	unixExtraID        = 0x000d // UNIX
 	extTimeExtraID     = 0x5455 // Extended timestamp
 	infoZipUnixExtraID = 0x5855 // Info-ZIP Unix extension
+	sha256ExtraID      = 0x6873 // SHA-256 digest, see File.SHA256
+	winZipAESExtraID   = 0x9901 // WinZip AES encryption
 )
 
 type FileHeader = zip.FileHeader
 
 // headerFileInfo implements fs.FileInfo.
 type headerFileInfo struct {
-	fh *FileHeader
+	fh   *FileHeader
+	mode fs.FileMode
+	name string
 }
 
-func (fi headerFileInfo) Name() string { return path.Base(fi.fh.Name) }
+func (fi headerFileInfo) Name() string { return fi.name }
 func (fi headerFileInfo) Size() int64 {
 	if fi.fh.UncompressedSize64 > 0 {
 		return int64(fi.fh.UncompressedSize64)
@@ -98,8 +109,8 @@ func (fi headerFileInfo) ModTime() time.Time {
 	}
 	return fi.fh.Modified.UTC()
 }
-func (fi headerFileInfo) Mode() fs.FileMode { return fi.fh.Mode() }
-func (fi headerFileInfo) Type() fs.FileMode { return fi.fh.Mode().Type() }
+func (fi headerFileInfo) Mode() fs.FileMode { return fi.mode }
+func (fi headerFileInfo) Type() fs.FileMode { return fi.mode.Type() }
 func (fi headerFileInfo) Sys() interface{}  { return fi.fh }
 
 func (fi headerFileInfo) Info() (fs.FileInfo, error) { return fi, nil }
@@ -136,8 +147,16 @@ type directoryEnd struct {
 	directoryOffset    uint64 // relative to file
 	commentLen         uint16
 	comment            string
+
+	offset      int64         // of the end of central directory record
+	zip64Offset int64         // of the zip64 record, if legacy is set
+	legacy      *directoryEnd // the values zip64 overrode, if any
 }
 
+// timeZones holds the locations returned by timeZone, so that entries
+// share them instead of each allocating its own.
+var timeZones sync.Map // time.Duration -> *time.Location
+
 // timeZone returns a *time.Location based on the provided offset.
 // If the offset is non-sensible, then this uses an offset of zero.
 func timeZone(offset time.Duration) *time.Location {
@@ -150,7 +169,11 @@ func timeZone(offset time.Duration) *time.Location {
 	if offset < minOffset || maxOffset < offset {
 		offset = 0
 	}
-	return time.FixedZone("", int(offset/time.Second))
+	if loc, ok := timeZones.Load(offset); ok {
+		return loc.(*time.Location)
+	}
+	loc, _ := timeZones.LoadOrStore(offset, time.FixedZone("", int(offset/time.Second)))
+	return loc.(*time.Location)
 }
 
 // msDosTimeToTime converts an MS-DOS date and time into a time.Time.
@@ -201,6 +224,42 @@ const (
 	msdosReadOnly = 0x01
 )
 
+// Mode returns the permission and mode bits for the entry. Unlike
+// FileHeader.Mode, it derives them from the MS-DOS attributes for every
+// host system using them, including OS/2 HPFS and Windows NTFS as numbered
+// by the ZIP specification, and for Unix hosts that recorded no Unix
+// mode. See MSDOSAttrs for the attributes that have no fs.FileMode bit.
+func (f *File) Mode() fs.FileMode {
+	var mode fs.FileMode
+	switch f.CreatorVersion >> 8 {
+	case creatorUnix, creatorMacOSX:
+		if f.ExternalAttrs>>16 == 0 {
+			mode = msdosModeToFileMode(f.ExternalAttrs)
+		} else {
+			mode = unixModeToFileMode(f.ExternalAttrs >> 16)
+		}
+	case creatorFAT, creatorOS2HPFS, creatorWindowsNTFS, creatorNTFS, creatorVFAT:
+		mode = msdosModeToFileMode(f.ExternalAttrs)
+	default:
+		return f.FileHeader.Mode()
+	}
+	if len(f.Name) > 0 && f.Name[len(f.Name)-1] == '/' {
+		mode |= fs.ModeDir
+	}
+	return mode
+}
+
+// MSDOSAttrs returns the MS-DOS attributes of an entry created on a host
+// system using them: read-only (0x01), hidden (0x02), system (0x04),
+// directory (0x10) and archive (0x20). It returns false for other hosts.
+func (f *File) MSDOSAttrs() (attrs uint8, ok bool) {
+	switch f.CreatorVersion >> 8 {
+	case creatorFAT, creatorOS2HPFS, creatorWindowsNTFS, creatorNTFS, creatorVFAT:
+		return uint8(f.ExternalAttrs), true
+	}
+	return 0, false
+}
+
 func msdosModeToFileMode(m uint32) (mode fs.FileMode) {
 	if m&msdosDir != 0 {
 		mode = fs.ModeDir | 0777
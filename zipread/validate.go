@@ -0,0 +1,299 @@
+package zipread
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// An OverlapError lists entries whose data regions overlap. Overlapping
+// entries are how some zip bombs reach extreme ratios, and are used to
+// hide content from tools that walk local headers. It matches ErrFormat
+// with errors.Is.
+type OverlapError struct {
+	Overlaps []Overlap
+}
+
+// An Overlap is a pair of overlapping regions. Other is nil if File
+// overlaps the central directory.
+type Overlap struct {
+	File  *File
+	Other *File
+}
+
+func (e *OverlapError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v: overlapping entries:", ErrFormat)
+	for i, o := range e.Overlaps {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		if o.Other == nil {
+			fmt.Fprintf(&b, " %q and the central directory", o.File.Name)
+		} else {
+			fmt.Fprintf(&b, " %q and %q", o.File.Name, o.Other.Name)
+		}
+	}
+	return b.String()
+}
+
+func (e *OverlapError) Unwrap() error { return ErrFormat }
+
+// CheckOverlaps returns an *OverlapError if the regions of any entries,
+// each made up of its local file header and data, overlap each other or
+// the central directory. Only the sizes known from the central directory
+// are used, so no requests are made; the local extra field is not
+// accounted for.
+func (z *Reader) CheckOverlaps() error {
+	files := make([]*File, len(z.File))
+	copy(files, z.File)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].headerOffset < files[j].headerOffset })
+
+	var (
+		overlaps []Overlap
+		last     *File // the entry extending furthest so far
+		lastEnd  int64
+	)
+	for _, f := range files {
+		if last != nil && f.headerOffset < lastEnd {
+			overlaps = append(overlaps, Overlap{File: last, Other: f})
+		}
+		end := f.minEnd()
+		if last == nil || end > lastEnd {
+			last, lastEnd = f, end
+		}
+		if end > z.dirOffset {
+			overlaps = append(overlaps, Overlap{File: f})
+		}
+	}
+	if len(overlaps) > 0 {
+		return &OverlapError{Overlaps: overlaps}
+	}
+	return nil
+}
+
+// minEnd returns the smallest offset the entry's region can end at.
+func (f *File) minEnd() int64 {
+	return f.headerOffset + fileHeaderLen + int64(len(f.RawName)) + int64(f.CompressedSize64)
+}
+
+// A ValidationReport lists the structural problems found by Validate.
+type ValidationReport struct {
+	Violations []*FormatError
+}
+
+// Valid reports whether no violations were found.
+func (r *ValidationReport) Valid() bool { return len(r.Violations) == 0 }
+
+func (r *ValidationReport) add(structure string, offset int64, format string, args ...interface{}) {
+	r.Violations = append(r.Violations, &FormatError{
+		Structure: structure,
+		Offset:    offset,
+		Reason:    fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate opens the archive in source and checks its structure strictly,
+// see Reader.Validate. An archive that cannot be opened is reported with
+// a single violation.
+func Validate(ctx context.Context, source Source) (*ValidationReport, error) {
+	z, err := Open(source)
+	if err != nil {
+		var fe *FormatError
+		switch {
+		case errors.As(err, &fe):
+		case errors.Is(err, ErrFormat) || errors.Is(err, io.ErrUnexpectedEOF):
+			fe = &FormatError{Structure: "central directory", Offset: -1, Reason: err.Error()}
+		default:
+			return nil, err
+		}
+		return &ValidationReport{Violations: []*FormatError{fe}}, nil
+	}
+	return z.Validate(ctx)
+}
+
+// Validate checks the structure of the archive more strictly than Open,
+// which tolerates the many ways writers deviate from the specification.
+// It reports central directories whose size or record count differs from
+// the end record, zip64 end records disagreeing with the legacy one, end
+// records not directly following the central directory, data before or
+// after the archive, local file headers disagreeing with the central
+// directory, and entries overlapping each other or the central directory.
+//
+// Every local file header is fetched, costing a request per entry. The
+// returned error only reports failures to read the archive.
+func (z *Reader) Validate(ctx context.Context) (*ValidationReport, error) {
+	r := &ValidationReport{}
+	end := z.end
+
+	const structure = "end of central directory"
+	if z.baseOffset > 0 {
+		r.add("archive", 0, "%d bytes of data before the first entry", z.baseOffset)
+	}
+	if trailing := z.size - (end.offset + directoryEndLen + int64(end.commentLen)); trailing > 0 {
+		r.add(structure, end.offset, "followed by %d bytes of data", trailing)
+	}
+	next := end.offset
+	if end.legacy != nil {
+		next = end.zip64Offset
+	}
+	if dirEnd := z.dirOffset + int64(end.directorySize); dirEnd != next {
+		r.add(structure, end.offset, "central directory ends at %d, not at the end record at %d", dirEnd, next)
+	}
+	if z.dirLen != int64(end.directorySize) {
+		r.add(structure, end.offset, "central directory size %d, but its records span %d bytes", end.directorySize, z.dirLen)
+	}
+	if uint64(len(z.File)) != end.directoryRecords {
+		r.add(structure, end.offset, "%d records declared, but %d found", end.directoryRecords, len(z.File))
+	}
+	if z.disks == nil {
+		if end.diskNbr != 0 || end.dirDiskNbr != 0 {
+			r.add(structure, end.offset, "disk number %d, directory disk number %d in a single disk archive", end.diskNbr, end.dirDiskNbr)
+		}
+		if end.dirRecordsThisDisk != end.directoryRecords {
+			r.add(structure, end.offset, "%d records on this disk, but %d in total", end.dirRecordsThisDisk, end.directoryRecords)
+		}
+	}
+	if legacy := end.legacy; legacy != nil {
+		recorded := end.directoryOffset
+		if z.disks != nil {
+			recorded -= uint64(z.disks[end.dirDiskNbr])
+		}
+		for _, field := range []struct {
+			name          string
+			legacy, zip64 uint64
+			max           uint64
+		}{
+			{"record count", legacy.directoryRecords, end.directoryRecords, uint16max},
+			{"records on this disk", legacy.dirRecordsThisDisk, end.dirRecordsThisDisk, uint16max},
+			{"directory size", legacy.directorySize, end.directorySize, uint32max},
+			{"directory offset", legacy.directoryOffset, recorded, uint32max},
+		} {
+			if field.legacy != field.max && field.legacy != field.zip64 {
+				r.add(structure, end.offset, "%s %d disagrees with %d in the zip64 record", field.name, field.legacy, field.zip64)
+			}
+		}
+	}
+
+	files := make([]*File, len(z.File))
+	copy(files, z.File)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].headerOffset < files[j].headerOffset })
+	var (
+		last    *File // the entry extending furthest so far
+		lastEnd int64
+	)
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entryEnd, err := f.validateLocalHeader(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		structure := fmt.Sprintf("local file header of %q", f.Name)
+		if last != nil && f.headerOffset < lastEnd {
+			r.add(structure, f.headerOffset, "overlaps the entry %q", last.Name)
+		}
+		if last == nil || entryEnd > lastEnd {
+			last, lastEnd = f, entryEnd
+		}
+		if entryEnd > z.dirOffset {
+			r.add(structure, f.headerOffset, "entry extends into the central directory")
+		}
+	}
+	return r, nil
+}
+
+// validateLocalHeader compares the local file header of f with its
+// central directory record, adding any differences to r, and returns the
+// offset at which the entry's data ends.
+func (f *File) validateLocalHeader(ctx context.Context, r *ValidationReport) (end int64, err error) {
+	structure := fmt.Sprintf("local file header of %q", f.Name)
+	end = f.minEnd()
+	if f.headerOffset+fileHeaderLen > f.zipsize {
+		r.add(structure, f.headerOffset, "beyond the end of the archive")
+		return end, nil
+	}
+
+	length := int64(fileHeaderLen + len(f.RawName) + uint16max)
+	if rest := f.zipsize - f.headerOffset; length > rest {
+		length = rest
+	}
+	rr, err := f.zips.Range(ctx, f.headerOffset, length)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errs.Combine(err, rr.Close()) }()
+
+	var buf [fileHeaderLen]byte
+	if _, err := io.ReadFull(rr, buf[:]); err != nil {
+		return 0, err
+	}
+	b := readBuf(buf[:])
+	if sig := b.uint32(); sig != fileHeaderSignature {
+		r.add(structure, f.headerOffset, "bad signature")
+		return end, nil
+	}
+	b = b[2:] // skip over the version needed
+	flags := b.uint16()
+	method := b.uint16()
+	b = b[4:] // skip over the time and date
+	crc := b.uint32()
+	compressed := uint64(b.uint32())
+	uncompressed := uint64(b.uint32())
+	name := make([]byte, b.uint16())
+	extra := make([]byte, b.uint16())
+	if _, err := io.ReadFull(rr, name); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(rr, extra); err != nil {
+		return 0, err
+	}
+	end += int64(len(extra))
+
+	if string(name) != f.RawName {
+		r.add(structure, f.headerOffset, "name %q differs from the central directory", name)
+	}
+	if method != f.Method {
+		r.add(structure, f.headerOffset, "method %d differs from %d in the central directory", method, f.Method)
+	}
+	if flags != f.Flags {
+		r.add(structure, f.headerOffset, "flags %#x differ from %#x in the central directory", flags, f.Flags)
+	}
+
+	if compressed == uint32max || uncompressed == uint32max {
+		found := false
+		for extra := readBuf(extra); len(extra) >= 4; {
+			tag, size := extra.uint16(), int(extra.uint16())
+			if size > len(extra) {
+				break
+			}
+			field := extra.sub(size)
+			if tag != zip64ExtraID {
+				continue
+			}
+			found = true
+			if uncompressed == uint32max && len(field) >= 8 {
+				uncompressed = field.uint64()
+			}
+			if compressed == uint32max && len(field) >= 8 {
+				compressed = field.uint64()
+			}
+		}
+		if !found {
+			r.add(structure, f.headerOffset, "missing zip64 extra field")
+		}
+	}
+	// With a data descriptor the header may leave the fields zero.
+	if !(f.Flags&0x8 != 0 && crc == 0 && compressed == 0 && uncompressed == 0) &&
+		(crc != f.CRC32 || compressed != f.CompressedSize64 || uncompressed != f.UncompressedSize64) {
+		r.add(structure, f.headerOffset, "checksum %#08x and sizes %d/%d differ from %#08x and %d/%d in the central directory",
+			crc, compressed, uncompressed, f.CRC32, f.CompressedSize64, f.UncompressedSize64)
+	}
+	return end, nil
+}
@@ -0,0 +1,102 @@
+package zipread
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckOverlaps(t *testing.T) {
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store, Data: []byte("first")},
+		testZipFile{Name: "b", Method: Store, Data: []byte("second")})
+	if err := openTestZip(t, data, nil).CheckOverlaps(); err != nil {
+		t.Fatal(err)
+	}
+
+	cen := bytes.LastIndex(data, []byte("PK\x01\x02"))
+	for _, test := range []struct {
+		name   string
+		patch  func(b []byte)
+		first  string
+		second string
+	}{
+		{"shared header", func(b []byte) { binary.LittleEndian.PutUint32(b[cen+42:], 0) }, "a", "b"},
+		{"into directory", func(b []byte) { binary.LittleEndian.PutUint32(b[cen+20:], 1<<16) }, "b", ""},
+	} {
+		corrupt := append([]byte(nil), data...)
+		test.patch(corrupt)
+		z := openTestZip(t, corrupt, nil)
+
+		var oerr *OverlapError
+		if err := z.CheckOverlaps(); !errors.As(err, &oerr) || !errors.Is(err, ErrFormat) {
+			t.Fatalf("%s: got %v, want *OverlapError", test.name, err)
+		}
+		o := oerr.Overlaps[0]
+		other := ""
+		if o.Other != nil {
+			other = o.Other.Name
+		}
+		if o.File.Name != test.first || other != test.second {
+			t.Errorf("%s: got %q/%q, want %q/%q", test.name, o.File.Name, other, test.first, test.second)
+		}
+
+		if _, err := OpenWithOptions(SourceFromReaderAt(bytes.NewReader(corrupt), int64(len(corrupt))), &Options{RejectOverlaps: true}); !errors.As(err, &oerr) {
+			t.Errorf("%s: Open with RejectOverlaps: got %v, want *OverlapError", test.name, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	data := buildTestZip(t,
+		testZipFile{Name: "a", Method: Store, Data: []byte("first")},
+		testZipFile{Name: "b", Method: Deflate, Data: []byte("second")})
+	cen := bytes.Index(data, []byte("PK\x01\x02"))
+	eocd := bytes.LastIndex(data, []byte("PK\x05\x06"))
+
+	for _, test := range []struct {
+		name   string
+		data   func() []byte
+		reason string // expected in the first violation, empty if valid
+	}{
+		{"valid", func() []byte { return data }, ""},
+		{"prepended", func() []byte { return append([]byte("stub"), data...) }, "before the first entry"},
+		{"trailing", func() []byte { return append(append([]byte(nil), data...), "junk"...) }, "followed by 4 bytes"},
+		{"local name", func() []byte {
+			b := append([]byte(nil), data...)
+			b[fileHeaderLen] = 'x'
+			return b
+		}, `name "x" differs`},
+		{"directory size", func() []byte {
+			b := append([]byte(nil), data...)
+			binary.LittleEndian.PutUint32(b[eocd+12:], uint32(eocd-cen-1))
+			return b
+		}, "central directory ends at"},
+		{"record count", func() []byte {
+			b := append([]byte(nil), data...)
+			binary.LittleEndian.PutUint16(b[eocd+8:], 3)
+			binary.LittleEndian.PutUint16(b[eocd+10:], 3)
+			return b
+		}, "central directory: unexpected EOF"},
+		{"not an archive", func() []byte { return []byte("not an archive") }, "signature not found"},
+	} {
+		b := test.data()
+		report, err := Validate(ctx, SourceFromReaderAt(bytes.NewReader(b), int64(len(b))))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.reason == "" {
+			if !report.Valid() {
+				t.Errorf("%s: unexpected violations %v", test.name, report.Violations)
+			}
+			continue
+		}
+		if report.Valid() || !strings.Contains(report.Violations[0].Error(), test.reason) {
+			t.Errorf("%s: got %v, want a violation containing %q", test.name, report.Violations, test.reason)
+		}
+	}
+}
@@ -10,8 +10,15 @@ import (
 	"unicode/utf8"
 )
 
+// Writer is archive/zip's Writer.
+//
+// Deprecated: Use zipwrite.Writer, which reads back with this package and
+// supports Zip64 layouts, encryption and more compression methods.
 type Writer = zip.Writer
 
+// NewWriter returns an archive/zip Writer writing to w.
+//
+// Deprecated: Use zipwrite.NewWriter.
 var NewWriter = zip.NewWriter
 
 // detectUTF8 reports whether s is a valid UTF-8 string, and whether the string
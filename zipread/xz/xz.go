@@ -0,0 +1,39 @@
+// Package xz registers a decompressor for XZ compressed entries, method 95,
+// with zipread. It is a separate package to keep its dependency out of
+// programs that do not need it; import it for its side effect:
+//
+//	import _ "zipper/zipread/xz"
+package xz
+
+import (
+	"io"
+
+	"github.com/ulikunitz/xz"
+
+	"zipper/zipread"
+)
+
+// Method is the compression method of XZ compressed entries.
+const Method uint16 = 95
+
+func init() {
+	zipread.RegisterDecompressor(Method, NewReader)
+}
+
+// NewReader returns a reader decompressing the XZ stream r. It is the
+// zipread.Decompressor registered for Method, and can be registered with a
+// single Reader instead where the package level registration is not
+// wanted.
+func NewReader(r io.Reader) io.ReadCloser {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return errReader{err}
+	}
+	return io.NopCloser(xr)
+}
+
+// errReader fails every Read with err.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+func (r errReader) Close() error             { return nil }
@@ -0,0 +1,28 @@
+package xz
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestDecompress(t *testing.T) {
+	var want bytes.Buffer
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&want, "line %d of an XZ compressed entry\n", i)
+	}
+
+	z, err := zipread.Open(zipread.SourceFromFile("testdata/xz.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := z.ReadFile("xz.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("got %q, want %q", got, want.Bytes())
+	}
+}
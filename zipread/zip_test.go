@@ -418,7 +418,7 @@ func suffixIsZip64(t *testing.T, zip sizedReaderAt) bool {
 	}
 
 	dirOff, err := findDirectory64End(SourceFromReaderAt(zip, zip.Size()),
-		zip.Size()-int64(len(d))+int64(sigOff))
+		zip.Size()-int64(len(d))+int64(sigOff), nil)
 	if err != nil {
 		t.Fatalf("findDirectory64End: %v", err)
 	}
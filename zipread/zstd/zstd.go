@@ -0,0 +1,47 @@
+// Package zstd registers a decompressor for Zstandard compressed entries,
+// method 93, with zipread. It is a separate package to keep its dependency
+// out of programs that do not need it; import it for its side effect:
+//
+//	import _ "zipper/zipread/zstd"
+package zstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	"zipper/zipread"
+)
+
+// Method is the compression method of Zstandard compressed entries.
+const Method uint16 = 93
+
+// maxWindow bounds the memory the decompressor allocates for the window,
+// which is chosen by the archive. 128 MiB is the limit of the zstd command
+// line tool without --long.
+const maxWindow = 128 << 20
+
+func init() {
+	zipread.RegisterDecompressor(Method, NewReader)
+}
+
+// NewReader returns a reader decompressing the Zstandard stream r. It is
+// the zipread.Decompressor registered for Method, and can be registered
+// with a single Reader instead where the package level registration is
+// not wanted.
+func NewReader(r io.Reader) io.ReadCloser {
+	d, err := zstd.NewReader(r,
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderLowmem(true),
+		zstd.WithDecoderMaxMemory(maxWindow))
+	if err != nil {
+		return errReader{err}
+	}
+	return d.IOReadCloser()
+}
+
+// errReader fails every Read with err.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+func (r errReader) Close() error             { return nil }
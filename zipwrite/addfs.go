@@ -0,0 +1,256 @@
+package zipwrite
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// A ReadLinkFS is a file system that can read symbolic links, as
+// fs.ReadLinkFS in later versions of Go. zipread.Reader, DirFS, and
+// os.DirFS in those versions implement it.
+type ReadLinkFS interface {
+	fs.FS
+
+	// ReadLink returns the destination of the named symbolic link.
+	ReadLink(name string) (string, error)
+
+	// Lstat returns a FileInfo describing the named file without
+	// following a final symbolic link.
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// DirFS returns a file system for the tree of files rooted at dir, like
+// os.DirFS, that also implements ReadLinkFS, so that AddFS records the
+// symbolic links in the tree as links.
+func DirFS(dir string) fs.FS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d dirFS) ReadLink(name string) (string, error) {
+	full, err := d.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := os.Readlink(full)
+	return filepath.ToSlash(target), err
+}
+
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) {
+	full, err := d.join("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(full)
+}
+
+func (d dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+// HardLinkPolicy selects how AddFS records files that are linked into the
+// tree under more than one name.
+type HardLinkPolicy int
+
+const (
+	// HardLinksCopy records the contents of every name, as if they were
+	// different files.
+	HardLinksCopy HardLinkPolicy = iota
+
+	// HardLinksSymlink records the contents of the first name only, and
+	// the other names as relative symbolic links to it, which extract
+	// to the same tree on systems with links.
+	HardLinksSymlink
+)
+
+// AddFSOptions configures Writer.AddFSWithOptions.
+// The zero value selects the default behavior.
+type AddFSOptions struct {
+	// HardLinks selects how files linked under more than one name are
+	// recorded. Only files stat'ed on Unix systems report their links.
+	HardLinks HardLinkPolicy
+
+	// Include, if not empty, restricts the files added to those matching
+	// one of its patterns, and the directories to those matching one or
+	// holding files that are added. Patterns have the syntax of
+	// path.Match, and are matched against the whole path of a file in
+	// fsys, or if they contain no slash, against its last element, so
+	// that "*.go" selects Go files in any directory.
+	Include []string
+
+	// Exclude leaves out files and directories matching one of its
+	// patterns, including everything in excluded directories, even if
+	// they match Include.
+	Exclude []string
+}
+
+// matchAny reports whether any of patterns matches name, see
+// AddFSOptions.Include.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		elem := name
+		if !strings.Contains(pattern, "/") {
+			elem = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, elem); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var errNoReadLink = errors.New("zipwrite: file system cannot read symbolic links")
+
+// AddFS adds the files from fsys to the archive, like AddFSWithOptions with
+// nil options.
+func (w *Writer) AddFS(fsys fs.FS) error {
+	return w.AddFSWithOptions(fsys, nil)
+}
+
+// AddFSWithOptions adds the files from fsys to the archive, walking it in
+// lexical order, under their paths in fsys. Every directory gets an entry
+// of its own, so that empty ones are kept too. Regular files are
+// compressed with Deflate, and symbolic links are added with
+// CreateSymlink, for which fsys must implement ReadLinkFS. Other kinds of
+// files are an error. Entries keep the mode, modification time and, with
+// FileInfoHeader, owner of their file. Since zipread.Reader implements
+// fs.FS and ReadLinkFS, this copies the files of one archive to another,
+// recompressing them; see CopyRaw for copying entries as they are. A nil
+// opts selects the defaults. With Options.Concurrency, files are opened
+// and compressed concurrently, so fsys must support that.
+func (w *Writer) AddFSWithOptions(fsys fs.FS, opts *AddFSOptions) error {
+	if opts == nil {
+		opts = &AddFSOptions{}
+	}
+	for _, patterns := range [][]string{opts.Include, opts.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errs.Errorf("zipwrite: invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	p := w.newPipeline()
+	links := make(map[fileID]string)
+	// dirs holds the entries of the directories leading to the current
+	// file that are only added with the first file added below them.
+	var dirs []*Header
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		if matchAny(opts.Exclude, name) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		for len(dirs) > 0 && !strings.HasPrefix(name, dirs[len(dirs)-1].Name) {
+			dirs = dirs[:len(dirs)-1]
+		}
+		if !d.IsDir() && len(opts.Include) > 0 && !matchAny(opts.Include, name) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		h, err := FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		h.Name = name
+		if d.IsDir() {
+			h.Name += "/"
+			dirs = append(dirs, h)
+			if len(opts.Include) > 0 && !matchAny(opts.Include, name) {
+				return nil
+			}
+		}
+		for _, dir := range dirs {
+			if err := p.add(dir, nil); err != nil {
+				return err
+			}
+		}
+		dirs = dirs[:0]
+		if d.IsDir() {
+			return nil
+		}
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			lfs, ok := fsys.(ReadLinkFS)
+			if !ok {
+				return &fs.PathError{Op: "readlink", Path: name, Err: errNoReadLink}
+			}
+			target, err := lfs.ReadLink(name)
+			if err != nil {
+				return err
+			}
+			return p.addSymlink(h, target)
+		case !info.Mode().IsRegular():
+			return errs.Errorf("zipwrite: cannot add non-regular file %q", name)
+		}
+
+		if id, ok := hardLink(info); ok && opts.HardLinks == HardLinksSymlink {
+			if first, ok := links[id]; ok {
+				return p.addSymlink(h, relativeLink(name, first))
+			}
+			links[id] = name
+		}
+		h.Method = Deflate
+		return p.add(h, func() (io.ReadCloser, error) { return fsys.Open(name) })
+	})
+	if perr := p.close(); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// addSymlink adds the entry h as a symbolic link to target, see
+// CreateSymlink.
+func (p *pipeline) addSymlink(h *Header, target string) error {
+	if err := setSymlink(h, target); err != nil {
+		return err
+	}
+	return p.add(h, func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(target)), nil
+	})
+}
+
+// A fileID identifies a file with several links on its device.
+type fileID struct {
+	dev, ino uint64
+}
+
+// relativeLink returns the destination of a symbolic link named name that
+// leads to target, both slash-separated paths within the same tree.
+func relativeLink(name, target string) string {
+	from := strings.Split(path.Dir(name), "/")
+	to := strings.Split(target, "/")
+	if from[0] == "." {
+		from = nil
+	}
+	for len(from) > 0 && len(to) > 1 && from[0] == to[0] {
+		from, to = from[1:], to[1:]
+	}
+	return strings.Repeat("../", len(from)) + strings.Join(to, "/")
+}
@@ -0,0 +1,173 @@
+package zipwrite
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"zipper/zipread"
+)
+
+func TestAddFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "file"), []byte("contents"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "a", "link")); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Link(filepath.Join(dir, "a", "file"), filepath.Join(dir, "b", "hard")); err != nil {
+		t.Skip(err)
+	}
+
+	add := func(fsys fs.FS, opts *AddFSOptions) *zipread.Reader {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if err := w.AddFSWithOptions(fsys, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return z
+	}
+	check := func(z *zipread.Reader, hard string) {
+		t.Helper()
+		if got, err := z.ReadFile("a/file"); err != nil || string(got) != "contents" {
+			t.Errorf("a/file: got %q, %v", got, err)
+		}
+		if fi, err := z.Stat("a/file"); err != nil || fi.Mode() != 0640 {
+			t.Errorf("a/file: got %v, %v", fi, err)
+		}
+		if got, err := z.ReadLink("a/link"); err != nil || got != "file" {
+			t.Errorf("a/link: got %q, %v", got, err)
+		}
+		if hard == "" {
+			if got, err := z.ReadFile("b/hard"); err != nil || string(got) != "contents" {
+				t.Errorf("b/hard: got %q, %v", got, err)
+			}
+		} else if got, err := z.ReadLink("b/hard"); err != nil || got != hard {
+			t.Errorf("b/hard: got %q, %v, want link to %q", got, err, hard)
+		}
+	}
+
+	check(add(DirFS(dir), nil), "")
+	z := add(DirFS(dir), &AddFSOptions{HardLinks: HardLinksSymlink})
+	check(z, "../a/file")
+
+	// Archives round-trip through their file system view.
+	check(add(z, nil), "../a/file")
+}
+
+func TestAddFSDirectories(t *testing.T) {
+	modified := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a/x.go":     {Data: []byte("package x"), Mode: 0600, ModTime: modified},
+		"a/x.txt":    {Data: []byte("x")},
+		"a/b/y.go":   {Data: []byte("package y")},
+		"empty":      {Mode: fs.ModeDir | 0750, ModTime: modified},
+		"skip/z.go":  {Data: []byte("package z")},
+		"skip/z.txt": {Data: []byte("z")},
+	}
+	add := func(opts *AddFSOptions) *zipread.Reader {
+		t.Helper()
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if err := w.AddFSWithOptions(fsys, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return z
+	}
+	names := func(z *zipread.Reader) []string {
+		var names []string
+		for _, f := range z.File {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	z := add(nil)
+	want := []string{"a/", "a/b/", "a/b/y.go", "a/x.go", "a/x.txt", "empty/", "skip/", "skip/z.go", "skip/z.txt"}
+	if got := names(z); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, f := range z.File {
+		if f.Name != "a/x.go" && f.Name != "empty/" {
+			continue
+		}
+		if want := fsys[strings.TrimSuffix(f.Name, "/")].Mode; f.Mode() != want || !f.Modified.Equal(modified) {
+			t.Errorf("%s: mode %v, modified %v, want %v, %v", f.Name, f.Mode(), f.Modified, want, modified)
+		}
+	}
+
+	// Directories are only kept for the files they hold.
+	want = []string{"a/", "a/b/", "a/b/y.go", "a/x.go"}
+	if got := names(add(&AddFSOptions{Include: []string{"*.go"}, Exclude: []string{"skip"}})); !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered: got %q, want %q", got, want)
+	}
+	want = []string{"a/", "a/x.go", "a/x.txt"}
+	if got := names(add(&AddFSOptions{Include: []string{"a/x.*"}})); !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered by path: got %q, want %q", got, want)
+	}
+	want = []string{"empty/"}
+	if got := names(add(&AddFSOptions{Include: []string{"empty"}})); !reflect.DeepEqual(got, want) {
+		t.Errorf("directory: got %q, want %q", got, want)
+	}
+
+	w := NewWriter(new(bytes.Buffer))
+	if err := w.AddFSWithOptions(fsys, &AddFSOptions{Exclude: []string{"["}}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestAddFSNoReadLink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Symlink("target", filepath.Join(dir, "link")); err != nil {
+		t.Skip(err)
+	}
+	// Hide the ReadLink method of DirFS.
+	fsys := struct{ fs.FS }{DirFS(dir)}
+	w := NewWriter(new(bytes.Buffer))
+	if err := w.AddFS(fsys); !errors.Is(err, errNoReadLink) {
+		t.Errorf("got %v, want %v", err, errNoReadLink)
+	}
+}
+
+func TestRelativeLink(t *testing.T) {
+	for _, test := range []struct{ name, target, want string }{
+		{"a", "b", "b"},
+		{"a/b", "a/c", "c"},
+		{"a/b", "c", "../c"},
+		{"a/b/c", "a/d/e", "../d/e"},
+		{"a", "b/c", "b/c"},
+		{"a/a", "a", "../a"},
+	} {
+		if got := relativeLink(test.name, test.target); got != test.want {
+			t.Errorf("relativeLink(%q, %q) = %q, want %q", test.name, test.target, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package zipwrite
+
+import (
+	"errors"
+	"strings"
+)
+
+// alignExtraID is the ID of the extra field Android's zipalign pads local
+// file headers with. Its data is the alignment as a uint16, followed by
+// the padding, in zeros.
+const alignExtraID = 0xd935
+
+// maxAlign is the largest Options.Align, for which the padding still fits
+// an extra field.
+const maxAlign = 1 << 15
+
+var errAlign = errors.New("zip: Options.Align too large")
+
+// alignment returns the extra field that pads the local file header of h,
+// in which extraLen bytes of other extra fields precede it, so that the
+// contents of h start at a multiple of h.align, or nil for entries that are
+// not aligned.
+func (h *header) alignment(extraLen int) []byte {
+	if h.align <= 1 || h.Method != Store || strings.HasSuffix(h.Name, "/") {
+		return nil
+	}
+	align := int64(h.align)
+	start := int64(h.offset) + fileHeaderLen + int64(len(h.Name)) + int64(extraLen) + 6
+	pad := (align - start%align) % align
+	field := make([]byte, 6+pad)
+	b := writeBuf(field)
+	b.uint16(alignExtraID)
+	b.uint16(uint16(2 + pad))
+	b.uint16(uint16(align))
+	return field
+}
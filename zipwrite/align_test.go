@@ -0,0 +1,43 @@
+package zipwrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestAlign(t *testing.T) {
+	entries := []testEntry{
+		{Name: "a", Method: Store, Data: []byte("first")},
+		{Name: "bb/", Method: Store},
+		{Name: "ccc", Method: Deflate, Data: bytes.Repeat([]byte("c"), 100)},
+		{Name: "dddd", Method: Store, Data: []byte("fourth")},
+	}
+	for _, opts := range []*Options{
+		{Align: 4},
+		{Align: 4096},
+		{Align: 4096, Deterministic: true},
+		{Align: 8, ForceZip64: true},
+	} {
+		t.Run(fmt.Sprintf("%+v", *opts), func(t *testing.T) {
+			data := writeTestZip(t, opts, entries...)
+			z := checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), entries...)
+			for _, f := range z.File {
+				h := data[f.HeaderOffset():]
+				start := f.HeaderOffset() + fileHeaderLen + int64(binary.LittleEndian.Uint16(h[26:])) + int64(binary.LittleEndian.Uint16(h[28:]))
+				padded := hasExtra(h[fileHeaderLen+len(f.Name):start-f.HeaderOffset()], alignExtraID)
+				if aligned := f.Method == Store && f.UncompressedSize64 > 0; padded != aligned || aligned && start%int64(opts.Align) != 0 {
+					t.Errorf("%s: contents at %d, padded: %v", f.Name, start, padded)
+				}
+			}
+		})
+	}
+
+	w := NewWriterWithOptions(new(bytes.Buffer), &Options{Align: maxAlign * 2})
+	if _, err := w.CreateHeader(&FileHeader{Name: "a"}); err != errAlign {
+		t.Errorf("got %v, want %v", err, errAlign)
+	}
+}
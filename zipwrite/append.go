@@ -0,0 +1,81 @@
+package zipwrite
+
+import (
+	"io"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// A Target is an archive file that entries are appended to, such as an
+// *os.File opened for reading and writing.
+type Target interface {
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// Append opens the archive served by source, which target writes, for
+// adding entries to it the way "zip -u" does: the central directory and
+// everything after it are cut off, and the Writer returned appends the
+// new entries in their place, and a central directory listing both the
+// old and the new ones on Close. The contents of the existing entries are
+// neither read nor written again. The archive comment is kept unless
+// SetComment replaces it.
+//
+// The archive is invalid from the moment Append returns until Close does.
+// The existing entries are described in the new central directory as they
+// were in the old one, with their offsets and sizes in the Zip64 format
+// where needed. Data prepended to the archive, such as a self-extractor
+// stub, is kept and offsets stay relative to it.
+func Append(source zipread.Source, target Target) (*Writer, error) {
+	return AppendWithOptions(source, target, nil)
+}
+
+// AppendWithOptions is like Append but configures the Writer with opts,
+// which may be nil.
+func AppendWithOptions(source zipread.Source, target Target, opts *Options) (*Writer, error) {
+	if opts != nil && opts.Deterministic {
+		return nil, errs.Errorf("zipwrite: cannot append deterministically")
+	}
+	z, err := zipread.Open(source)
+	if err != nil {
+		return nil, err
+	}
+
+	w := NewWriterWithOptions(target, opts)
+	for _, f := range z.File {
+		w.keep(z, f, rawHeader(f))
+	}
+	w.comment = z.Comment
+	if err := w.truncate(z, target, z.DirectoryOffset()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// keep lists the entry f of z, which stays where it is, in the central
+// directory with fh.
+func (w *Writer) keep(z *zipread.Reader, f *zipread.File, fh *FileHeader) {
+	w.dir = append(w.dir, &header{
+		FileHeader: fh,
+		offset:     uint64(f.HeaderOffset() - z.BaseOffset()),
+		raw:        true,
+		zip64:      w.opts.ForceZip64,
+	})
+}
+
+// truncate cuts the archive z, which target writes, off at the offset end,
+// where w goes on writing.
+func (w *Writer) truncate(z *zipread.Reader, target Target, end int64) error {
+	if err := target.Truncate(end); err != nil {
+		return err
+	}
+	if _, err := target.Seek(end, io.SeekStart); err != nil {
+		return err
+	}
+	// Offsets in the archive are relative to its base.
+	w.cw.count = end - z.BaseOffset()
+	return nil
+}
@@ -0,0 +1,75 @@
+package zipwrite
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestAppend(t *testing.T) {
+	for _, stub := range []string{"", "#!/bin/sh\nexit 1\n"} {
+		name := filepath.Join(t.TempDir(), "a.zip")
+		var buf bytes.Buffer
+		buf.WriteString(stub)
+		w := NewWriter(&buf)
+		for _, e := range testEntries[:2] {
+			fw, err := w.CreateHeader(&FileHeader{Name: e.Name, Method: e.Method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(e.Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.SetComment("kept"); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		original := buf.Bytes()
+		if err := os.WriteFile(name, original, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fh, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err = Append(zipread.SourceFromFile(name), fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range testEntries[2:] {
+			fw, err := w.CreateHeader(&FileHeader{Name: e.Name, Method: e.Method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(e.Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := fh.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		appended, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirOffset := bytes.Index(original, []byte("PK\x01\x02"))
+		if !bytes.Equal(appended[:dirOffset], original[:dirOffset]) {
+			t.Errorf("stub %q: existing entries were rewritten", stub)
+		}
+		z := checkTestZip(t, zipread.SourceFromFile(name), testEntries...)
+		if z.BaseOffset() != int64(len(stub)) || z.Comment != "kept" {
+			t.Errorf("stub %q: base offset %d, comment %q", stub, z.BaseOffset(), z.Comment)
+		}
+	}
+}
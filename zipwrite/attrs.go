@@ -0,0 +1,51 @@
+package zipwrite
+
+import (
+	"io/fs"
+)
+
+// Host systems, recorded in the high byte of FileHeader.CreatorVersion,
+// which select how readers interpret FileHeader.ExternalAttrs. The low
+// byte is the version of the specification the archiver implements, in
+// tenths, like 20 for 2.0.
+const (
+	HostMSDOS  = creatorFAT
+	HostUnix   = creatorUnix
+	HostNTFS   = 10 // as numbered by the specification
+	HostMacOSX = 19
+)
+
+// MS-DOS attributes, recorded in the low byte of FileHeader.ExternalAttrs
+// by every host.
+const (
+	MSDOSReadOnly  = 0x01
+	MSDOSHidden    = 0x02
+	MSDOSSystem    = 0x04
+	MSDOSDirectory = 0x10
+	MSDOSArchive   = 0x20
+)
+
+// UnixAttrs returns the external attributes recording mode for HostUnix,
+// as Info-ZIP and FileHeader.SetMode do: the Unix type and permission bits
+// in the high 16 bits, and the MS-DOS attributes of MSDOSAttrs in the low
+// ones.
+func UnixAttrs(mode fs.FileMode) uint32 {
+	var fh FileHeader
+	fh.SetMode(mode)
+	return fh.ExternalAttrs
+}
+
+// MSDOSAttrs returns the external attributes recording mode for
+// HostMSDOS or HostNTFS, which only tell directories, and read-only files
+// without any write permission. MSDOSHidden, MSDOSSystem and MSDOSArchive
+// may be added to them.
+func MSDOSAttrs(mode fs.FileMode) uint32 {
+	var attrs uint32
+	if mode.IsDir() {
+		attrs |= MSDOSDirectory
+	}
+	if mode&0222 == 0 {
+		attrs |= MSDOSReadOnly
+	}
+	return attrs
+}
@@ -0,0 +1,101 @@
+package zipwrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/fs"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestAttrs(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		host  uint16
+		attrs uint32
+		mode  fs.FileMode
+		msdos uint8 // MS-DOS attributes zipread reports, if any
+	}{
+		{"unix file", HostUnix, UnixAttrs(0640), 0640, 0},
+		{"unix read-only", HostUnix, UnixAttrs(0444), 0444, 0},
+		{"unix dir/", HostUnix, UnixAttrs(fs.ModeDir | 0750), fs.ModeDir | 0750, 0},
+		{"unix link", HostUnix, UnixAttrs(fs.ModeSymlink | 0777), fs.ModeSymlink | 0777, 0},
+		{"msdos file", HostMSDOS, MSDOSAttrs(0644) | MSDOSArchive, 0666, MSDOSArchive},
+		{"msdos hidden", HostMSDOS, MSDOSAttrs(0444) | MSDOSHidden | MSDOSSystem, 0444, MSDOSReadOnly | MSDOSHidden | MSDOSSystem},
+		{"ntfs dir/", HostNTFS, MSDOSAttrs(fs.ModeDir | 0755), fs.ModeDir | 0777, MSDOSDirectory},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			fh := &FileHeader{Name: test.name, CreatorVersion: test.host << 8, ExternalAttrs: test.attrs}
+			if _, err := w.CreateHeader(fh); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := z.File[0]
+			if f.ExternalAttrs != test.attrs {
+				t.Errorf("external attributes %#x, want %#x", f.ExternalAttrs, test.attrs)
+			}
+			if mode := f.Mode(); mode != test.mode {
+				t.Errorf("mode %v, want %v", mode, test.mode)
+			}
+			if attrs, ok := f.MSDOSAttrs(); ok != (test.host != HostUnix) || attrs != test.msdos {
+				t.Errorf("MS-DOS attributes %#x, %v, want %#x", attrs, ok, test.msdos)
+			}
+		})
+	}
+}
+
+func TestKeepVersions(t *testing.T) {
+	for _, test := range []struct {
+		name                  string
+		opts                  Options
+		keep                  bool
+		creator, reader       uint16
+		wantCreator, wantRead uint16
+	}{
+		{"derived", Options{}, false, HostNTFS<<8 | 63, 10, HostNTFS<<8 | 20, 20},
+		{"kept", Options{}, true, HostNTFS<<8 | 63, 10, HostNTFS<<8 | 63, 10},
+		{"kept zip64", Options{ForceZip64: true}, true, HostUnix<<8 | 30, 10, HostUnix<<8 | 30, 45},
+		{"kept higher", Options{ForceZip64: true}, true, HostUnix<<8 | 63, 63, HostUnix<<8 | 63, 63},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriterWithOptions(&buf, &test.opts)
+			fw, err := w.CreateEntry(&Header{
+				FileHeader:   FileHeader{Name: "a", Method: Store, CreatorVersion: test.creator, ReaderVersion: test.reader},
+				KeepVersions: test.keep,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write([]byte("a")); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := z.File[0]
+			local := binary.LittleEndian.Uint16(data[f.HeaderOffset()+4:])
+			if f.CreatorVersion != test.wantCreator || f.ReaderVersion != test.wantRead || local != test.wantRead {
+				t.Errorf("creator version %#x, reader version %d, %d in the local file header, want %#x, %d",
+					f.CreatorVersion, f.ReaderVersion, local, test.wantCreator, test.wantRead)
+			}
+			if got, err := z.ReadFile("a"); err != nil || string(got) != "a" {
+				t.Errorf("got %q, %v", got, err)
+			}
+		})
+	}
+}
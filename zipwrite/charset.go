@@ -0,0 +1,73 @@
+package zipwrite
+
+import (
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// A UTF8Policy selects which entries the Writer flags as having UTF-8
+// names and comments.
+type UTF8Policy int
+
+const (
+	// UTF8Auto flags the entries whose name or comment is valid UTF-8 but
+	// not plain ASCII, leaving entries that any reader decodes the same
+	// way unflagged.
+	UTF8Auto UTF8Policy = iota
+
+	// UTF8Always flags every entry, failing for names or comments that are
+	// not valid UTF-8.
+	UTF8Always
+
+	// UTF8Never flags no entry, for consumers that take flagged names for
+	// corrupt, encoding names and comments with Options.NameEncoder if set,
+	// and writing them as they are otherwise.
+	UTF8Never
+)
+
+// encodeNames encodes the name and comment of fh with
+// Options.NameEncoder.
+func (w *Writer) encodeNames(fh *FileHeader) error {
+	name, err := w.opts.NameEncoder(fh.Name)
+	if err != nil {
+		return errs.Errorf("zipwrite: encoding name %q: %w", fh.Name, err)
+	}
+	comment, err := w.opts.NameEncoder(fh.Comment)
+	if err != nil {
+		return errs.Errorf("zipwrite: encoding comment of %q: %w", fh.Name, err)
+	}
+	fh.Name, fh.Comment = string(name), string(comment)
+	fh.NonUTF8 = true
+	return nil
+}
+
+// cp437 maps the characters of the upper half of code page 437 to their
+// bytes, as zipread.DecodeCP437 decodes them.
+var cp437 = func() map[rune]byte {
+	m := make(map[rune]byte, 0x80)
+	for c := 0x80; c <= 0xff; c++ {
+		s, _ := zipread.DecodeCP437([]byte{byte(c)})
+		m[[]rune(s)[0]] = byte(c)
+	}
+	return m
+}()
+
+// EncodeCP437 encodes s as IBM code page 437, the character set the ZIP
+// specification prescribes for names without the UTF-8 flag, failing for
+// characters it lacks. It is suitable for Options.NameEncoder, and
+// zipread.DecodeCP437 reverses it.
+func EncodeCP437(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch c, ok := cp437[r]; {
+		case r < 0x80:
+			out = append(out, byte(r))
+		case ok:
+			out = append(out, c)
+		default:
+			return nil, errs.Errorf("zipwrite: %q not in code page 437", r)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package zipwrite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestUTF8(t *testing.T) {
+	write := func(opts *Options, fhs ...*FileHeader) (*zipread.Reader, error) {
+		var buf bytes.Buffer
+		w := NewWriterWithOptions(&buf, opts)
+		if err := w.SetComment("archive comment"); err != nil {
+			t.Fatal(err)
+		}
+		for _, fh := range fhs {
+			if _, err := w.CreateHeader(fh); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return zipread.OpenWithOptions(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())),
+			&zipread.Options{NameDecoder: zipread.DecodeCP437})
+	}
+	flagged := func(z *zipread.Reader) string {
+		var names []string
+		for _, f := range z.File {
+			if f.Flags&0x800 != 0 {
+				names = append(names, f.Name)
+			}
+		}
+		return strings.Join(names, ",")
+	}
+	headers := func() []*FileHeader {
+		return []*FileHeader{
+			{Name: "plain", Comment: "plain comment"},
+			{Name: "naïve", Comment: "½"},
+			{Name: "raw\x82", NonUTF8: true},
+		}
+	}
+
+	for _, test := range []struct {
+		policy  UTF8Policy
+		flagged string
+	}{
+		{UTF8Auto, "naïve"},
+		{UTF8Always, "plain,naïve"},
+		{UTF8Never, ""},
+	} {
+		z, err := write(&Options{UTF8: test.policy}, headers()...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := flagged(z); got != test.flagged {
+			t.Errorf("policy %d: flagged %q, want %q", test.policy, got, test.flagged)
+		}
+		if z.Comment != "archive comment" || z.File[0].Comment != "plain comment" {
+			t.Errorf("policy %d: comments %q, %q", test.policy, z.Comment, z.File[0].Comment)
+		}
+		if f := z.File[2]; f.RawName != "raw\x82" || f.Name != "rawé" {
+			t.Errorf("policy %d: NonUTF8 name %q, raw %q", test.policy, f.Name, f.RawName)
+		}
+	}
+
+	z, err := write(&Options{UTF8: UTF8Never, NameEncoder: EncodeCP437}, headers()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := z.File[1]; f.RawName != "na\x8bve" || f.Name != "naïve" || f.RawComment != "\xab" || f.Comment != "½" {
+		t.Errorf("encoded name %q (raw %q), comment %q (raw %q)", f.Name, f.RawName, f.Comment, f.RawComment)
+	}
+
+	if _, err := write(&Options{UTF8: UTF8Never, NameEncoder: EncodeCP437}, &FileHeader{Name: "日本"}); err == nil {
+		t.Error("name not in code page 437 encoded")
+	}
+	if _, err := write(&Options{UTF8: UTF8Always}, &FileHeader{Name: "\xff"}); err == nil {
+		t.Error("invalid UTF-8 name flagged")
+	}
+	if _, err := write(nil, &FileHeader{Name: "a", Comment: strings.Repeat("c", uint16max+1)}); err != errLongComment {
+		t.Errorf("long comment: got %v, want %v", err, errLongComment)
+	}
+}
+
+func TestEncodeCP437(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	s, _ := zipread.DecodeCP437(all)
+	if got, err := EncodeCP437(s); err != nil || !bytes.Equal(got, all) {
+		t.Errorf("got %x, %v, want %x", got, err, all)
+	}
+}
@@ -0,0 +1,43 @@
+package zipwrite
+
+import (
+	"errors"
+	"io"
+
+	"zipper/zipread"
+)
+
+var errLongArchiveComment = errors.New("zipwrite: archive comment too long")
+
+// RewriteComment replaces the comment of the archive served by source,
+// which target writes, by rewriting the end of its end of central
+// directory record in place: the entries, the central directory and any
+// Zip64 records, which do not depend on the comment, are neither read nor
+// written. This is how build metadata is stamped into large archives.
+// Anything following the comment is cut off.
+func RewriteComment(source zipread.Source, target Target, comment string) error {
+	if len(comment) > uint16max {
+		return errLongArchiveComment
+	}
+	z, err := zipread.Open(source)
+	if err != nil {
+		return err
+	}
+	return writeComment(z, target, comment)
+}
+
+// writeComment rewrites the comment length and the comment closing the
+// archive z, which target writes.
+func writeComment(z *zipread.Reader, target Target, comment string) error {
+	off := z.EndOffset() + directoryEndLen - 2 // the comment length
+	if _, err := target.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, 2, 2+len(comment))
+	b := writeBuf(buf)
+	b.uint16(uint16(len(comment)))
+	if _, err := target.Write(append(buf, comment...)); err != nil {
+		return err
+	}
+	return target.Truncate(off + int64(len(buf)+len(comment)))
+}
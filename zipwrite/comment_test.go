@@ -0,0 +1,94 @@
+package zipwrite
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestRewriteComment(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		opts    Options
+		stub    string
+		comment string
+	}{
+		{"shorter", Options{}, "", "new"},
+		{"longer", Options{}, "", strings.Repeat("longer ", 100)},
+		{"empty", Options{}, "", ""},
+		{"zip64", Options{ForceZip64: true}, "", "new"},
+		{"prefixed", Options{}, "#!/bin/sh\nexit 1\n", "new"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			buf.WriteString(test.stub)
+			w := NewWriterWithOptions(&buf, &test.opts)
+			for _, e := range testEntries {
+				fw, err := w.CreateHeader(&FileHeader{Name: e.Name, Method: e.Method})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := fw.Write(e.Data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.SetComment("the comment the archive was written with"); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			original := buf.Bytes()
+			name := filepath.Join(t.TempDir(), "a.zip")
+			if err := os.WriteFile(name, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.OpenFile(name, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := RewriteComment(zipread.SourceFromFile(name), f, test.comment); err != nil {
+				t.Fatal(err)
+			}
+			updated, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept := len(original) - len("the comment the archive was written with") - 2
+			if !bytes.Equal(updated[:kept], original[:kept]) {
+				t.Error("rewrote more than the comment")
+			}
+			source := zipread.SourceFromReaderAt(bytes.NewReader(updated), int64(len(updated)))
+			z := checkTestZip(t, source, testEntries...)
+			if z.Comment != test.comment {
+				t.Errorf("comment %q, want %q", z.Comment, test.comment)
+			}
+			if test.stub != "" || test.opts.ForceZip64 {
+				// Validate reports the prefix, and Zip64 records in an
+				// archive small enough not to need them, as it does before
+				// the rewrite.
+				return
+			}
+			report, err := z.Validate(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range report.Violations {
+				t.Error(v)
+			}
+		})
+	}
+}
+
+func TestRewriteCommentTooLong(t *testing.T) {
+	if err := RewriteComment(nil, nil, strings.Repeat("x", uint16max+1)); err != errLongArchiveComment {
+		t.Errorf("got %v, want %v", err, errLongArchiveComment)
+	}
+}
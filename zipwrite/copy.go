@@ -0,0 +1,72 @@
+package zipwrite
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// CopyRaw adds the entry f, read from another archive, to the archive
+// being written without decompressing and compressing it again: its
+// contents are copied as stored, with a single ranged request on the
+// source of f, and so is its metadata, including the name and comment as
+// stored before any zipread.Options.NameDecoder, the flags, apart from
+// the data descriptor flag with Options.DataDescriptors, and the extra
+// fields. Only the Zip64 extra field is dropped, to be written again if
+// the new offsets and sizes need it. The contents are not verified; read
+// f to check them. Copying from a Reader opened with
+// zipread.Options.CompactDirectory loses the extra fields that were not
+// needed to read the entry.
+func (w *Writer) CopyRaw(f *zipread.File) error {
+	rc, err := f.OpenRaw(context.TODO())
+	if err != nil {
+		return err
+	}
+	fh := rawHeader(f)
+	w.setRawDescriptor(fh)
+	fw, err := w.createRaw(fh)
+	if err == nil {
+		_, err = io.Copy(fw, rc)
+	}
+	return errs.Combine(err, rc.Close())
+}
+
+// rawHeader returns a copy of the FileHeader of f, so that the Writer
+// doesn't share its fields, with the name and comment as stored and
+// without the Zip64 extra field.
+func rawHeader(f *zipread.File) *FileHeader {
+	fh := f.FileHeader
+	fh.Name, fh.Comment = f.RawName, f.RawComment
+	fh.Extra = withoutExtra(f.Extra, zip64ExtraID)
+	return &fh
+}
+
+// withoutExtra returns a copy of the extra fields in extra, leaving out
+// those with the given IDs. Malformed trailing bytes are kept as they are.
+func withoutExtra(extra []byte, ids ...uint16) []byte {
+	out := make([]byte, 0, len(extra))
+	for len(extra) >= 4 {
+		size := 4 + int(binary.LittleEndian.Uint16(extra[2:]))
+		if size > len(extra) {
+			break
+		}
+		if !hasID(ids, binary.LittleEndian.Uint16(extra)) {
+			out = append(out, extra[:size]...)
+		}
+		extra = extra[size:]
+	}
+	return append(out, extra...)
+}
+
+func hasID(ids []uint16, id uint16) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
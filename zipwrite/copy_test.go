@@ -0,0 +1,91 @@
+package zipwrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestCopyRaw(t *testing.T) {
+	// The source uses Zip64 extra fields, which must not be duplicated.
+	data := writeTestZip(t, &Options{ForceZip64: true}, testEntries...)
+	src, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasExtra(src.File[0].Extra, zip64ExtraID) {
+		t.Fatal("source entries without zip64 extra fields")
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, f := range src.File {
+		if err := w.CopyRaw(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())), testEntries...)
+	for i, f := range dst.File {
+		g := src.File[i]
+		if f.CRC32 != g.CRC32 || f.CompressedSize64 != g.CompressedSize64 || f.Flags != g.Flags || !f.Modified.Equal(g.Modified) {
+			t.Errorf("%s: header differs from the source", f.Name)
+		}
+		if hasExtra(f.Extra, zip64ExtraID) {
+			t.Errorf("%s: zip64 extra field in a small archive", f.Name)
+		}
+		if got, want := readRaw(t, f), readRaw(t, g); !bytes.Equal(got, want) {
+			t.Errorf("%s: stored contents differ from the source", f.Name)
+		}
+	}
+
+	// Copying into a Zip64 archive writes a single Zip64 extra field.
+	buf.Reset()
+	w = NewWriterWithOptions(&buf, &Options{ForceZip64: true})
+	if err := w.CopyRaw(src.File[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dst = checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())), testEntries[2])
+	if got := countExtra(dst.File[0].Extra, zip64ExtraID); got != 1 {
+		t.Errorf("%d zip64 extra fields", got)
+	}
+}
+
+func readRaw(t *testing.T, f *zipread.File) []byte {
+	t.Helper()
+	rc, err := f.OpenRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func countExtra(extra []byte, id uint16) int {
+	n := 0
+	for len(extra) >= 4 {
+		if binary.LittleEndian.Uint16(extra) == id {
+			n++
+		}
+		extra = extra[4+int(binary.LittleEndian.Uint16(extra[2:])):]
+	}
+	return n
+}
+
+func hasExtra(extra []byte, id uint16) bool {
+	return countExtra(extra, id) > 0
+}
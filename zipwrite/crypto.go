@@ -0,0 +1,279 @@
+package zipwrite
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// An Encryption selects how the contents of entries are encrypted.
+type Encryption int
+
+const (
+	// AES256 encrypts with AES in the WinZip AE-2 format, which records
+	// the method 99 along with an extra field with ID 0x9901, and no
+	// CRC-32, so as not to reveal anything about the contents. Archivers
+	// from 7-Zip to WinZip read it, but zipread does not.
+	AES256 Encryption = iota
+	AES192
+	AES128
+
+	// ZipCrypto encrypts with the traditional PKWARE stream cipher, which
+	// every archiver and zipread read, but which is broken: it only keeps
+	// out the casual reader.
+	ZipCrypto
+)
+
+const (
+	methodWinZipAES = 99
+
+	zipCryptoHeaderLen = 12
+	aesVerifierLen     = 2  // password verification value
+	aesAuthLen         = 10 // truncated HMAC-SHA1 of the encrypted contents
+	aesIterations      = 1000
+)
+
+var errZipCryptoDescriptor = errors.New("zipwrite: ZipCrypto entries written with CreateHeader need a data descriptor")
+
+// keyLen returns the length of the AES key of e.
+func (e Encryption) keyLen() int {
+	switch e {
+	case AES128:
+		return 16
+	case AES192:
+		return 24
+	}
+	return 32
+}
+
+// overhead returns how many bytes encrypting with e adds to the
+// compressed contents.
+func (e Encryption) overhead() uint64 {
+	if e == ZipCrypto {
+		return zipCryptoHeaderLen
+	}
+	return uint64(e.keyLen()/2 + aesVerifierLen + aesAuthLen) // the salt is half as long as the key
+}
+
+// encryption returns how to encrypt the entry h, which may be nil, and
+// with which password, which is empty if it is not to be encrypted.
+func (w *Writer) encryption(h *Header) (Encryption, string) {
+	if h != nil && h.Password != "" {
+		return h.Encryption, h.Password
+	}
+	return w.opts.Encryption, w.opts.Password
+}
+
+// setEncryption marks fh as encrypted with e. For AES, that replaces the
+// compression method with 99, recording it in the WinZip AES extra field.
+func setEncryption(fh *FileHeader, e Encryption) {
+	fh.Flags |= 0x1
+	if e == ZipCrypto {
+		return
+	}
+	var buf [11]byte // 2x uint16 + uint16 + 2x uint8 + uint8 + uint16
+	b := writeBuf(buf[:])
+	b.uint16(winZipAESExtraID)
+	b.uint16(7) // size of the extra field data
+	b.uint16(2) // AE-2
+	b.uint8('A')
+	b.uint8('E')
+	b.uint8(uint8(e.keyLen()/8 - 1)) // strength: 1, 2 or 3 for 128, 192 or 256 bits
+	b.uint16(fh.Method)
+	fh.Extra = append(fh.Extra, buf[:]...)
+	fh.Method = methodWinZipAES
+}
+
+// zipCryptoCheck returns the value of the last byte of the ZipCrypto
+// encryption header of fh, which readers check the password against: the
+// high byte of the checksum, or of the modification time for entries
+// whose checksum follows the data.
+func zipCryptoCheck(fh *FileHeader) byte {
+	if fh.Flags&0x8 != 0 {
+		return byte(fh.ModifiedTime >> 8)
+	}
+	return byte(fh.CRC32 >> 24)
+}
+
+// newEncrypter returns a WriteCloser encrypting what is written to it with
+// e and password to w. The encryption header, which for ZipCrypto ends with
+// check, is written along with the first contents, or by Close, so that
+// creating it writes nothing. Closing it writes what follows the encrypted
+// contents, if anything, but does not close w.
+func newEncrypter(w io.Writer, e Encryption, password string, check byte) (io.WriteCloser, error) {
+	if e == ZipCrypto {
+		return newZipCryptoWriter(w, []byte(password), check)
+	}
+	return newAESWriter(w, []byte(password), e.keyLen())
+}
+
+// zipCryptoKeys is the state of the traditional PKWARE stream cipher.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, c := range password {
+		k.update(c)
+	}
+	return k
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+func (k *zipCryptoKeys) update(c byte) {
+	k[0] = crc32Update(k[0], c)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) encryptByte(c byte) byte {
+	t := k[2] | 2
+	k.update(c)
+	return c ^ byte(t*(t^1)>>8)
+}
+
+// prefixWriter writes prefix to w before anything else.
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte // nil once written
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	if p.prefix != nil {
+		if _, err := p.w.Write(p.prefix); err != nil {
+			return 0, err
+		}
+		p.prefix = nil
+	}
+	return p.w.Write(b)
+}
+
+// zipCryptoWriter encrypts a ZipCrypto stream.
+type zipCryptoWriter struct {
+	w    prefixWriter
+	keys *zipCryptoKeys
+	buf  []byte
+}
+
+func newZipCryptoWriter(w io.Writer, password []byte, check byte) (*zipCryptoWriter, error) {
+	keys := newZipCryptoKeys(password)
+	header := make([]byte, zipCryptoHeaderLen)
+	if _, err := rand.Read(header[:zipCryptoHeaderLen-1]); err != nil {
+		return nil, err
+	}
+	header[zipCryptoHeaderLen-1] = check
+	for i, c := range header {
+		header[i] = keys.encryptByte(c)
+	}
+	return &zipCryptoWriter{w: prefixWriter{w: w, prefix: header}, keys: keys}, nil
+}
+
+func (z *zipCryptoWriter) Write(p []byte) (int, error) {
+	if cap(z.buf) < len(p) {
+		z.buf = make([]byte, len(p))
+	}
+	buf := z.buf[:len(p)]
+	for i, c := range p {
+		buf[i] = z.keys.encryptByte(c)
+	}
+	return z.w.Write(buf)
+}
+
+// Close writes the encryption header if nothing was written.
+func (z *zipCryptoWriter) Close() error {
+	_, err := z.w.Write(nil)
+	return err
+}
+
+// aesWriter encrypts a WinZip AES stream: AES in counter mode, with a
+// little-endian counter starting at 1, authenticated by HMAC-SHA1.
+type aesWriter struct {
+	w       prefixWriter
+	block   cipher.Block
+	mac     hash.Hash
+	counter uint64
+	stream  [aes.BlockSize]byte
+	used    int // bytes of stream used
+	buf     []byte
+}
+
+func newAESWriter(w io.Writer, password []byte, keyLen int) (*aesWriter, error) {
+	salt := make([]byte, keyLen/2)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := pbkdf2SHA1(password, salt, aesIterations, 2*keyLen+aesVerifierLen)
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	return &aesWriter{
+		w:     prefixWriter{w: w, prefix: append(salt, key[2*keyLen:]...)},
+		block: block,
+		mac:   hmac.New(sha1.New, key[keyLen:2*keyLen]),
+		used:  aes.BlockSize,
+	}, nil
+}
+
+func (a *aesWriter) Write(p []byte) (int, error) {
+	if cap(a.buf) < len(p) {
+		a.buf = make([]byte, len(p))
+	}
+	buf := a.buf[:len(p)]
+	for i, c := range p {
+		if a.used == aes.BlockSize {
+			a.counter++
+			var ctr [aes.BlockSize]byte
+			binary.LittleEndian.PutUint64(ctr[:], a.counter)
+			a.block.Encrypt(a.stream[:], ctr[:])
+			a.used = 0
+		}
+		buf[i] = c ^ a.stream[a.used]
+		a.used++
+	}
+	a.mac.Write(buf)
+	return a.w.Write(buf)
+}
+
+// Close writes the authentication code, after the salt and password
+// verification value if nothing was written.
+func (a *aesWriter) Close() error {
+	_, err := a.w.Write(a.mac.Sum(nil)[:aesAuthLen])
+	return err
+}
+
+// pbkdf2SHA1 derives a key of keyLen bytes from password and salt with
+// PBKDF2, as specified by RFC 8018, using HMAC-SHA1.
+func pbkdf2SHA1(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var key []byte
+	u := make([]byte, 0, sha1.Size)
+	for block := uint32(1); len(key) < keyLen; block++ {
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], block)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(index[:])
+		key = prf.Sum(key)
+		t := key[len(key)-sha1.Size:]
+		u = append(u[:0], t...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+	}
+	return key[:keyLen]
+}
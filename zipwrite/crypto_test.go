@@ -0,0 +1,268 @@
+package zipwrite
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zipper/zipread"
+)
+
+// writeEncrypted writes testEntries with opts, to a file if seekable is
+// set, and returns the archive.
+func writeEncrypted(t *testing.T, opts *Options, seekable bool, headers ...*Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var dst io.Writer = &buf
+	var file *os.File
+	if seekable {
+		f, err := os.Create(filepath.Join(t.TempDir(), "a.zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		dst, file = f, f
+	}
+	w := NewWriterWithOptions(dst, opts)
+	if headers == nil {
+		for _, e := range testEntries {
+			headers = append(headers, &Header{FileHeader: FileHeader{Name: e.Name, Method: e.Method}})
+		}
+	}
+	if opts.Concurrency > 1 {
+		var entries []Entry
+		for i, h := range headers {
+			data := testEntries[i%len(testEntries)].Data
+			fh := h.FileHeader
+			entries = append(entries, Entry{Header: &fh, Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}})
+		}
+		if err := w.AddEntries(entries); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		for i, h := range headers {
+			fw, err := w.CreateEntry(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(testEntries[i%len(testEntries)].Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if file != nil {
+		data, err := os.ReadFile(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	return buf.Bytes()
+}
+
+func TestZipCrypto(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		opts     Options
+		seekable bool
+	}{
+		{"stream", Options{}, false},
+		{"seekable", Options{}, true},
+		{"concurrent", Options{Concurrency: 2}, false},
+		{"concurrent descriptors", Options{Concurrency: 2, DataDescriptors: DescriptorsAlways}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.Password, opts.Encryption = "secret", ZipCrypto
+			data := writeEncrypted(t, &opts, test.seekable)
+			source := zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))
+			z, err := zipread.OpenWithOptions(source, &zipread.Options{Password: "secret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, f := range z.File {
+				e := testEntries[i]
+				if got := f.IsEncrypted(); got != (e.Name != "dir/") {
+					t.Errorf("%s: encrypted %v", f.Name, got)
+				}
+				if e.Name == "dir/" {
+					continue
+				}
+				if got, err := z.ReadFile(f.Name); err != nil || !bytes.Equal(got, e.Data) {
+					t.Errorf("%s: got %d bytes, %v, want %d bytes", f.Name, len(got), err, len(e.Data))
+				}
+			}
+
+			z, err = zipread.OpenWithOptions(source, &zipread.Options{Password: "wrong"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := z.ReadFile("stored"); err == nil {
+				t.Error("read with the wrong password")
+			}
+		})
+	}
+}
+
+func TestPasswordPerEntry(t *testing.T) {
+	data := writeEncrypted(t, &Options{Password: "archive", Encryption: ZipCrypto}, false,
+		&Header{FileHeader: FileHeader{Name: "stored", Method: Store}},
+		&Header{FileHeader: FileHeader{Name: "own", Method: Store}, Password: "entry", Encryption: ZipCrypto},
+	)
+	z, err := zipread.OpenWithOptions(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &zipread.Options{
+		PasswordProvider: func(ctx context.Context, fh *zipread.FileHeader) (string, error) {
+			if fh.Name == "own" {
+				return "entry", nil
+			}
+			return "archive", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range z.File {
+		if _, err := z.ReadFile(f.Name); err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+		}
+	}
+}
+
+func TestAES(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		opts     Options
+		seekable bool
+	}{
+		{"AES-256 stream", Options{Encryption: AES256}, false},
+		{"AES-192 seekable", Options{Encryption: AES192}, true},
+		{"AES-128 concurrent", Options{Encryption: AES128, Concurrency: 2}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.Password = "secret"
+			data := writeEncrypted(t, &opts, test.seekable)
+			z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, f := range z.File {
+				e := testEntries[i]
+				if e.Name == "dir/" {
+					if f.IsEncrypted() {
+						t.Errorf("%s: encrypted", f.Name)
+					}
+					continue
+				}
+				if want := fmt.Sprint("WinZip AES-", 8*test.opts.Encryption.keyLen()); f.EncryptionScheme() != want || f.CRC32 != 0 {
+					t.Errorf("%s: scheme %q, CRC-32 %#x, want %q, 0", f.Name, f.EncryptionScheme(), f.CRC32, want)
+				}
+				if got := decryptAES(t, f, readRaw(t, f), "secret"); !bytes.Equal(got, e.Data) {
+					t.Errorf("%s: got %d bytes, want %d", f.Name, len(got), len(e.Data))
+				}
+			}
+		})
+	}
+}
+
+// decryptAES decrypts and decompresses the contents of the WinZip AES
+// entry f, as stored in raw, following the specification rather than the
+// code writing them.
+func decryptAES(t *testing.T, f *zipread.File, raw []byte, password string) []byte {
+	t.Helper()
+	var field []byte
+	for extra := f.Extra; len(extra) >= 4; {
+		size := 4 + int(binary.LittleEndian.Uint16(extra[2:]))
+		if binary.LittleEndian.Uint16(extra) == winZipAESExtraID {
+			field = extra[4:size]
+		}
+		extra = extra[size:]
+	}
+	if len(field) != 7 || binary.LittleEndian.Uint16(field) != 2 || string(field[2:4]) != "AE" {
+		t.Fatalf("%s: AES extra field %x", f.Name, field)
+	}
+	keyLen := 8 + 8*int(field[4])
+	method := binary.LittleEndian.Uint16(field[5:])
+
+	salt, raw := raw[:keyLen/2], raw[keyLen/2:]
+	verifier, raw := raw[:2], raw[2:]
+	body, auth := raw[:len(raw)-10], raw[len(raw)-10:]
+	key := pbkdf2SHA1([]byte(password), salt, 1000, 2*keyLen+2)
+	if !bytes.Equal(verifier, key[2*keyLen:]) {
+		t.Fatalf("%s: password verification value %x, want %x", f.Name, verifier, key[2*keyLen:])
+	}
+	mac := hmac.New(sha1.New, key[keyLen:2*keyLen])
+	mac.Write(body)
+	if !bytes.Equal(auth, mac.Sum(nil)[:10]) {
+		t.Fatalf("%s: authentication code mismatch", f.Name)
+	}
+
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := make([]byte, len(body))
+	var ctr, stream [aes.BlockSize]byte
+	for i := range body {
+		if i%aes.BlockSize == 0 {
+			binary.LittleEndian.PutUint64(ctr[:], uint64(i/aes.BlockSize+1))
+			block.Encrypt(stream[:], ctr[:])
+		}
+		plain[i] = body[i] ^ stream[i%aes.BlockSize]
+	}
+	if method == Store {
+		return plain
+	}
+	out, err := io.ReadAll(flate.NewReader(bytes.NewReader(plain)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Test vectors from RFC 6070.
+	for _, test := range []struct {
+		iterations, keyLen int
+		want               string
+	}{
+		{1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{2, 20, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{4096, 25, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+	} {
+		password, salt := "password", "salt"
+		if test.keyLen == 25 {
+			password, salt = "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt"
+		}
+		if got := hex.EncodeToString(pbkdf2SHA1([]byte(password), []byte(salt), test.iterations, test.keyLen)); got != test.want {
+			t.Errorf("%d iterations: got %s, want %s", test.iterations, got, test.want)
+		}
+	}
+}
+
+func TestZipCryptoNeverDescriptors(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "a.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := NewWriterWithOptions(f, &Options{Password: "secret", Encryption: ZipCrypto, DataDescriptors: DescriptorsNever})
+	if _, err := w.Create("a"); err != errZipCryptoDescriptor {
+		t.Errorf("got %v, want %v", err, errZipCryptoDescriptor)
+	}
+}
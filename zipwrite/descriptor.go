@@ -0,0 +1,135 @@
+package zipwrite
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// A DescriptorPolicy selects where the Writer records the CRC-32 and sizes
+// of the entries it compresses: in their local file headers, before their
+// contents, or in data descriptors following them, as a Writer must when
+// it cannot go back to the header once the contents are written.
+type DescriptorPolicy int
+
+const (
+	// DescriptorsAuto records the CRC-32 and sizes in the local file
+	// headers when the destination implements io.Seeker, by going back
+	// to them after the contents, and with Options.Deterministic, and in
+	// data descriptors otherwise. The headers of entries that turn out too
+	// large for them, without Options.ForceZip64, get a descriptor as
+	// well. Entries copied with CopyRaw keep their descriptor flag.
+	DescriptorsAuto DescriptorPolicy = iota
+
+	// DescriptorsAlways writes a data descriptor after every file, as
+	// streaming writers do, even where the sizes are known.
+	DescriptorsAlways
+
+	// DescriptorsNever records the CRC-32 and sizes in the local file
+	// headers of every file, for readers that ignore the central
+	// directory. The destination must implement io.Seeker, unless
+	// Options.Deterministic is set.
+	DescriptorsNever
+)
+
+var errNotSeekable = errors.New("zipwrite: DescriptorsNever needs a destination implementing io.Seeker")
+
+// seekable returns w as an io.Seeker if it is one that works, which an
+// *os.File for a pipe is not.
+func seekable(w io.Writer) io.Seeker {
+	s, ok := w.(io.Seeker)
+	if !ok {
+		return nil
+	}
+	if _, err := s.Seek(0, io.SeekCurrent); err != nil {
+		return nil
+	}
+	return s
+}
+
+// useDescriptor reports whether the contents of an entry the Writer
+// compresses are followed by a data descriptor, rather than going back to
+// the local file header for its sizes, see patchHeader.
+func (w *Writer) useDescriptor() (bool, error) {
+	switch w.opts.DataDescriptors {
+	case DescriptorsAlways:
+		return true, nil
+	case DescriptorsNever:
+		if w.seeker == nil && !w.opts.Deterministic {
+			return false, errNotSeekable
+		}
+		return false, nil
+	}
+	return w.seeker == nil && !w.opts.Deterministic, nil
+}
+
+// setRawDescriptor sets the data descriptor flag of fh, an entry whose
+// sizes are known before its contents are written, as the DescriptorsAlways
+// and DescriptorsNever policies require. Encrypted entries keep theirs,
+// since ZipCrypto checks passwords against the CRC-32 or the modification
+// time depending on it.
+func (w *Writer) setRawDescriptor(fh *FileHeader) {
+	switch {
+	case fh.Flags&0x1 != 0:
+	case w.opts.DataDescriptors == DescriptorsAlways:
+		fh.Flags |= 0x8
+	case w.opts.DataDescriptors == DescriptorsNever:
+		fh.Flags &^= 0x8
+	}
+}
+
+// patchHeader fills in the CRC-32 and sizes of h, written without a data
+// descriptor, in its local file header, by seeking back to it in the
+// destination. If the header has no room for sizes that need the Zip64
+// format, it sets the data descriptor flag in the header instead.
+func (w *Writer) patchHeader(h *header) error {
+	if err := w.cw.w.(*bufio.Writer).Flush(); err != nil {
+		return err
+	}
+	end, err := w.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	start := end - (w.cw.count - int64(h.offset))
+
+	if !h.zip64 && (h.CompressedSize64 > uint32max || h.UncompressedSize64 > uint32max) {
+		h.Flags |= 0x8
+		var buf [2]byte
+		b := writeBuf(buf[:])
+		b.uint16(h.Flags)
+		if err := writeAt(w.seeker, buf[:], start+6); err != nil {
+			return err
+		}
+	} else {
+		var buf [12]byte
+		b := writeBuf(buf[:])
+		b.uint32(h.CRC32)
+		b.uint32(h.CompressedSize)
+		b.uint32(h.UncompressedSize)
+		if err := writeAt(w.seeker, buf[:], start+14); err != nil {
+			return err
+		}
+		if h.zip64 {
+			// The sizes in the Zip64 extra field follow its ID and size.
+			var buf [16]byte
+			b := writeBuf(buf[:])
+			b.uint64(h.UncompressedSize64)
+			b.uint64(h.CompressedSize64)
+			off := start + fileHeaderLen + int64(len(h.Name)+len(h.Extra)+len(h.localExtra)) + 4
+			if err := writeAt(w.seeker, buf[:], off); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = w.seeker.Seek(end, io.SeekStart)
+	return err
+}
+
+// writeAt writes p at the offset off of s, which must be an io.Writer.
+func writeAt(s io.Seeker, p []byte, off int64) error {
+	if _, err := s.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := s.(io.Writer).Write(p)
+	return err
+}
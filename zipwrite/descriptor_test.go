@@ -0,0 +1,125 @@
+package zipwrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestDataDescriptors(t *testing.T) {
+	// write writes testEntries to a file if seekable is set, or else a
+	// buffer, and returns the archive.
+	write := func(opts *Options, seekable bool) []byte {
+		var buf bytes.Buffer
+		var dst io.Writer = &buf
+		if seekable {
+			f, err := os.Create(filepath.Join(t.TempDir(), "a.zip"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			dst = f
+		}
+		w := NewWriterWithOptions(dst, opts)
+		for _, e := range testEntries {
+			fw, err := w.CreateHeader(&FileHeader{Name: e.Name, Method: e.Method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(e.Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if f, ok := dst.(*os.File); ok {
+			data, err := os.ReadFile(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return data
+		}
+		return buf.Bytes()
+	}
+	sortedTestEntries := []testEntry{testEntries[1], testEntries[2], testEntries[3], testEntries[0]}
+	// check checks that the files in data have data descriptors if
+	// descriptors is set, and otherwise the right sizes in their local
+	// file headers.
+	check := func(data []byte, descriptors bool, entries []testEntry) {
+		t.Helper()
+		z := checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), entries...)
+		for _, f := range z.File {
+			if f.Name == "dir/" {
+				continue
+			}
+			loc := data[f.HeaderOffset():]
+			flags := binary.LittleEndian.Uint16(loc[6:])
+			if got := flags&0x8 != 0; got != descriptors {
+				t.Errorf("%s: data descriptor %v, want %v", f.Name, got, descriptors)
+				continue
+			}
+			if descriptors {
+				continue
+			}
+			crc := binary.LittleEndian.Uint32(loc[14:])
+			compressedSize := uint64(binary.LittleEndian.Uint32(loc[18:]))
+			uncompressedSize := uint64(binary.LittleEndian.Uint32(loc[22:]))
+			if compressedSize == uint32max {
+				extra := loc[fileHeaderLen+len(f.Name):]
+				if binary.LittleEndian.Uint16(extra) != zip64ExtraID {
+					t.Fatalf("%s: no Zip64 extra field first in %x", f.Name, extra[:4])
+				}
+				uncompressedSize = binary.LittleEndian.Uint64(extra[4:])
+				compressedSize = binary.LittleEndian.Uint64(extra[12:])
+			}
+			if crc != f.CRC32 || compressedSize != f.CompressedSize64 || uncompressedSize != f.UncompressedSize64 {
+				t.Errorf("%s: local header has CRC-32 %#x and sizes %d, %d, want %#x, %d, %d",
+					f.Name, crc, compressedSize, uncompressedSize, f.CRC32, f.CompressedSize64, f.UncompressedSize64)
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		opts        Options
+		seekable    bool
+		descriptors bool
+	}{
+		{Options{}, false, true},
+		{Options{}, true, false},
+		{Options{ForceZip64: true}, true, false},
+		{Options{DataDescriptors: DescriptorsAlways}, true, true},
+		{Options{Deterministic: true}, false, false},
+		{Options{DataDescriptors: DescriptorsNever, Deterministic: true}, false, false},
+		{Options{DataDescriptors: DescriptorsAlways, Deterministic: true}, false, true},
+	} {
+		t.Run(fmt.Sprintf("%+v,seekable=%v", test.opts, test.seekable), func(t *testing.T) {
+			entries := testEntries
+			if test.opts.Deterministic {
+				entries = sortedTestEntries
+			}
+			check(write(&test.opts, test.seekable), test.descriptors, entries)
+		})
+	}
+
+	w := NewWriterWithOptions(new(bytes.Buffer), &Options{DataDescriptors: DescriptorsNever})
+	if _, err := w.Create("a"); err != errNotSeekable {
+		t.Errorf("got %v, want %v", err, errNotSeekable)
+	}
+
+	r, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer pw.Close()
+	if seekable(pw) != nil {
+		t.Error("pipe reported seekable")
+	}
+}
@@ -0,0 +1,109 @@
+package zipwrite
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// A spool holds the contents of the entries of a Writer with
+// Options.Deterministic until Close writes them out sorted by name. The
+// contents do not depend on where they end up in the archive, but their
+// local file headers do, so those are only written then.
+type spool struct {
+	file *os.File
+	buf  *bufio.Writer
+	cw   *countWriter
+}
+
+func newSpool() (*spool, error) {
+	file, err := os.CreateTemp("", "zipwrite-*")
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	return &spool{file: file, buf: buf, cw: &countWriter{w: buf}}, nil
+}
+
+// remove closes and removes the file of the spool.
+func (s *spool) remove() error {
+	return errs.Combine(s.file.Close(), os.Remove(s.file.Name()))
+}
+
+// bodyWriter returns the writer for the contents of the next entry: the
+// archive, or with Options.Deterministic, the spool.
+func (w *Writer) bodyWriter() (*countWriter, error) {
+	if !w.opts.Deterministic {
+		return w.cw, nil
+	}
+	if w.spool == nil {
+		s, err := newSpool()
+		if err != nil {
+			return nil, err
+		}
+		w.spool = s
+	}
+	return w.spool.cw, nil
+}
+
+// begin adds the entry h to the archive, writing its local file header,
+// or with Options.Deterministic, recording where its contents start in
+// the spool.
+func (w *Writer) begin(h *header) error {
+	w.dir = append(w.dir, h)
+	if w.spool == nil {
+		return w.writeLocalHeader(h)
+	}
+	h.body = w.spool.cw.count
+	return checkHeader(h)
+}
+
+// unspool writes the spooled entries to the archive sorted by name.
+func (w *Writer) unspool() error {
+	if err := w.spool.buf.Flush(); err != nil {
+		return err
+	}
+	// Entries are spooled one after the other, in the order of w.dir.
+	end := w.spool.cw.count
+	for i := len(w.dir) - 1; i >= 0; i-- {
+		h := w.dir[i]
+		h.bodyLen = end - h.body
+		end = h.body
+	}
+
+	sort.SliceStable(w.dir, func(i, j int) bool { return w.dir[i].Name < w.dir[j].Name })
+	for _, h := range w.dir {
+		h.offset = uint64(w.cw.count)
+		if err := w.writeLocalHeader(h); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w.cw, io.NewSectionReader(w.spool.file, h.body, h.bodyLen)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalize replaces the fields of fh that vary between otherwise
+// identical entries, see Options.Deterministic. The times of encrypted
+// entries are kept, since ZipCrypto may check them against the password.
+func (w *Writer) normalize(fh *FileHeader) {
+	fh.Extra = withoutExtra(fh.Extra, extTimeExtraID, ntfsExtraID, unixExtraID, infoZipUnixExtraID, unixOwnerExtraID)
+	if fh.Flags&0x1 == 0 {
+		fh.Modified = time.Time{}
+		if t := w.opts.DeterministicTime; !t.IsZero() {
+			fh.Modified = t.UTC()
+		} else {
+			fh.ModifiedDate, fh.ModifiedTime = timeToMsDosTime(time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC))
+		}
+	}
+	if fh.ExternalAttrs>>16 != 0 {
+		fh.CreatorVersion = creatorUnix<<8 | zipVersion20
+	} else {
+		fh.CreatorVersion = creatorFAT<<8 | zipVersion20
+	}
+}
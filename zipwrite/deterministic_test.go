@@ -0,0 +1,71 @@
+package zipwrite
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"zipper/zipread"
+)
+
+func TestDeterministic(t *testing.T) {
+	tmp := t.TempDir()
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmp)
+
+	write := func(opts *Options, order []int, modified time.Time, creator uint16) []byte {
+		var buf bytes.Buffer
+		w := NewWriterWithOptions(&buf, opts)
+		for _, i := range order {
+			e := testEntries[i]
+			fh := &FileHeader{
+				Name:           e.Name,
+				Method:         e.Method,
+				Modified:       modified,
+				CreatorVersion: creator << 8,
+				// An NTFS extra field with a single, empty attribute.
+				Extra: []byte{0x0a, 0x00, 0x08, 0x00, 0, 0, 0, 0, 0x01, 0x00, 0x00, 0x00},
+			}
+			fw, err := w.CreateHeader(fh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(e.Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	opts := &Options{Deterministic: true}
+	a := write(opts, []int{0, 1, 2, 3}, time.Now(), creatorUnix)
+	b := write(opts, []int{3, 2, 0, 1}, time.Now().Add(-time.Hour), creatorFAT)
+	if !bytes.Equal(a, b) {
+		t.Fatal("archives of the same entries differ")
+	}
+	if c := write(nil, []int{0, 1, 2, 3}, time.Now(), creatorUnix); bytes.Equal(a, c) {
+		t.Fatal("archive written without Deterministic is the same")
+	}
+	z := checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(a), int64(len(a))),
+		testEntries[1], testEntries[2], testEntries[3], testEntries[0])
+	for _, f := range z.File {
+		if want := time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC); !f.Modified.Equal(want) || len(f.Extra) != 0 {
+			t.Errorf("%s: modified %v, extra %x", f.Name, f.Modified, f.Extra)
+		}
+	}
+
+	fixed := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+	a = write(&Options{Deterministic: true, DeterministicTime: fixed}, []int{0, 1}, time.Now(), creatorUnix)
+	z = checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(a), int64(len(a))), testEntries[1], testEntries[0])
+	if got := z.File[0].Modified; !got.Equal(fixed) {
+		t.Errorf("modified %v, want %v", got, fixed)
+	}
+
+	if left, err := os.ReadDir(tmp); err != nil || len(left) != 0 {
+		t.Errorf("%d temporary files left, %v", len(left), err)
+	}
+}
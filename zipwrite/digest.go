@@ -0,0 +1,31 @@
+package zipwrite
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// newDigest returns the hash computing the digest of the contents of an
+// entry to be encrypted with password, if not empty, see Options.SHA256,
+// or nil if it gets none.
+func (w *Writer) newDigest(password string) hash.Hash {
+	if !w.opts.SHA256 || password != "" {
+		return nil
+	}
+	return sha256.New()
+}
+
+// appendDigest records the SHA-256 digest of the contents of fh in its
+// extra fields, replacing any digest it had.
+func appendDigest(fh *FileHeader, digest hash.Hash) error {
+	extra := withoutExtra(fh.Extra, sha256ExtraID)
+	if len(extra)+4+sha256.Size > uint16max {
+		return errLongExtra
+	}
+	var buf [4]byte
+	b := writeBuf(buf[:])
+	b.uint16(sha256ExtraID)
+	b.uint16(sha256.Size)
+	fh.Extra = digest.Sum(append(extra, buf[:]...))
+	return nil
+}
@@ -0,0 +1,79 @@
+package zipwrite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestSHA256(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		opts     Options
+		seekable bool
+	}{
+		{"stream", Options{}, false},
+		{"seekable", Options{}, true},
+		{"deterministic", Options{Deterministic: true}, false},
+		{"concurrent", Options{Concurrency: 2}, false},
+		{"sozip", Options{SOZip: true, SOZipMinSize: 1}, false},
+		{"sampled", Options{StoreIncompressible: 100}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.SHA256 = true
+			data := writeEncrypted(t, &opts, test.seekable)
+			z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range testEntries {
+				f := findFile(t, z, e.Name)
+				if e.Name == "dir/" {
+					if f.SHA256 != nil {
+						t.Errorf("%s: digest on a directory", f.Name)
+					}
+					continue
+				}
+				if sum := sha256.Sum256(e.Data); !bytes.Equal(f.SHA256, sum[:]) {
+					t.Errorf("%s: digest %x, want %x", f.Name, f.SHA256, sum)
+				}
+				if got, err := z.ReadFile(f.Name); err != nil || !bytes.Equal(got, e.Data) {
+					t.Errorf("%s: got %d bytes, %v, want %d bytes", f.Name, len(got), err, len(e.Data))
+				}
+			}
+
+			// Corrupting the digest, wherever it is recorded, fails the
+			// read that the CRC-32 alone lets through.
+			sum := sha256.Sum256(testEntries[0].Data)
+			corrupt := bytes.ReplaceAll(data, sum[:], make([]byte, sha256.Size))
+			z, err = zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(corrupt), int64(len(corrupt))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := z.ReadFile(testEntries[0].Name); !errors.Is(err, zipread.ErrChecksum) {
+				t.Errorf("read with a corrupt digest: %v", err)
+			}
+			if _, err := z.ReadFile(testEntries[2].Name); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSHA256Encrypted(t *testing.T) {
+	opts := &Options{SHA256: true, Password: "secret", Encryption: ZipCrypto}
+	data := writeEncrypted(t, opts, false)
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range z.File {
+		if f.SHA256 != nil {
+			t.Errorf("%s: digest on an encrypted entry", f.Name)
+		}
+	}
+}
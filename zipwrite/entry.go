@@ -0,0 +1,64 @@
+package zipwrite
+
+import (
+	"io"
+	"time"
+)
+
+// A Header describes an entry to write with CreateEntry, including what
+// zipread reports about entries beyond their FileHeader.
+type Header struct {
+	FileHeader
+
+	// Accessed and Created are the last access and creation times,
+	// recorded along with FileHeader.Modified, see TimePolicy. They are
+	// left out when zero.
+	Accessed time.Time
+	Created  time.Time
+
+	// Owner, if not nil, is the user and group owning the entry, recorded
+	// in the Info-ZIP Unix extra field 0x7875 for extractors restoring
+	// ownership, see also Options.LegacyUnixOwner. It is left out with
+	// Options.Deterministic.
+	Owner *Owner
+
+	// Password, if not empty, encrypts the contents of the entry with
+	// Encryption, instead of Options.Password and Options.Encryption.
+	Password   string
+	Encryption Encryption
+
+	// Extras are extra fields to record for the entry, after those of the
+	// FileHeader, for other tools to find their own metadata in. Their IDs
+	// should be registered, or chosen not to clash with those of the ZIP
+	// specification, see ExtraField.
+	Extras []ExtraField
+
+	// KeepVersions records FileHeader.CreatorVersion and ReaderVersion as
+	// they are, for consumers that check them. Otherwise, the Writer
+	// records version 2.0 in the low byte of the CreatorVersion, keeping
+	// the host in the high one, and a ReaderVersion of 2.0, or 4.5 with
+	// Options.ForceZip64. The ReaderVersion is raised to 4.5 wherever the
+	// Zip64 format is used. FileHeader.ExternalAttrs are always recorded
+	// as they are, see UnixAttrs and MSDOSAttrs.
+	KeepVersions bool
+
+	// Raw writes the contents as they are stored: compressed with the
+	// FileHeader.Method already, or not at all with Store. The CRC32,
+	// CompressedSize64 and UncompressedSize64 of the FileHeader must
+	// describe them, so that the local file header is written complete,
+	// without a data descriptor or going back to it, and the contents go
+	// straight to the archive, as fast as they are copied, for entries
+	// whose checksum and sizes are known from a manifest or another
+	// archive. Writing other than CompressedSize64 bytes fails, but the
+	// contents are not checked against the CRC32. Raw entries are not
+	// encrypted, and get no SOZip index or SHA-256 digest.
+	Raw bool
+}
+
+// CreateEntry is like CreateHeader, but also records the metadata of h
+// that a FileHeader cannot hold. Writer takes ownership of h and may
+// mutate its fields. The caller must not modify h after calling
+// CreateEntry.
+func (w *Writer) CreateEntry(h *Header) (io.Writer, error) {
+	return w.createHeader(&h.FileHeader, h)
+}
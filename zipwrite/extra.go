@@ -0,0 +1,64 @@
+package zipwrite
+
+import (
+	"github.com/zeebo/errs/v2"
+)
+
+// An ExtraLocation selects the headers of an entry that record an extra
+// field.
+type ExtraLocation int
+
+const (
+	// ExtraBoth records the field in the local file header and in the
+	// central directory, as most extra fields are.
+	ExtraBoth ExtraLocation = iota
+
+	// ExtraLocal records the field in the local file header only, for
+	// readers going through the archive from its start.
+	ExtraLocal
+
+	// ExtraCentral records the field in the central directory only, for
+	// readers listing the archive, and to keep the local file headers
+	// short.
+	ExtraCentral
+)
+
+// An ExtraField is an extra field of an entry, see Header.Extras: the
+// data, of up to 65531 bytes, follows the ID and the size of the data,
+// each 2 bytes long. The ID 0x0001 is reserved for the Zip64 extra field
+// the Writer writes itself.
+type ExtraField struct {
+	ID    uint16
+	Data  []byte
+	Where ExtraLocation
+}
+
+// encodeExtras returns the extra fields for the local file header and the
+// central directory, for the local file header only, and for the central
+// directory only, encoding fields.
+func encodeExtras(fields []ExtraField) (both, local, central []byte, err error) {
+	for _, f := range fields {
+		if f.ID == zip64ExtraID {
+			return nil, nil, nil, errs.Errorf("extra field %#04x is written by the Writer", f.ID)
+		}
+		if len(f.Data) > uint16max-4 {
+			return nil, nil, nil, errs.Errorf("extra field %#04x: %d bytes of data, more than %d", f.ID, len(f.Data), uint16max-4)
+		}
+		buf := make([]byte, 4, 4+len(f.Data))
+		b := writeBuf(buf)
+		b.uint16(f.ID)
+		b.uint16(uint16(len(f.Data)))
+		buf = append(buf, f.Data...)
+		switch f.Where {
+		case ExtraBoth:
+			both = append(both, buf...)
+		case ExtraLocal:
+			local = append(local, buf...)
+		case ExtraCentral:
+			central = append(central, buf...)
+		default:
+			return nil, nil, nil, errs.Errorf("extra field %#04x: unknown location %d", f.ID, f.Where)
+		}
+	}
+	return both, local, central, nil
+}
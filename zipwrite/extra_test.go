@@ -0,0 +1,122 @@
+package zipwrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zipper/zipread"
+)
+
+// localExtraOf returns the extra fields of the local file header of f in
+// the archive data.
+func localExtraOf(data []byte, f *zipread.File) []byte {
+	h := data[f.HeaderOffset():]
+	nameLen := int(binary.LittleEndian.Uint16(h[26:]))
+	extraLen := int(binary.LittleEndian.Uint16(h[28:]))
+	return h[fileHeaderLen+nameLen:][:extraLen]
+}
+
+func TestExtras(t *testing.T) {
+	const bothID, localID, centralID = 0xfb01, 0xfb02, 0xfb03
+	for _, test := range []struct {
+		name     string
+		opts     Options
+		seekable bool
+	}{
+		{"stream", Options{}, false},
+		{"seekable", Options{}, true},
+		{"seekable zip64", Options{ForceZip64: true}, true},
+		{"deterministic", Options{Deterministic: true}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var dst io.Writer = &buf
+			if test.seekable {
+				f, err := os.Create(filepath.Join(t.TempDir(), "a.zip"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				dst = f
+			}
+			w := NewWriterWithOptions(dst, &test.opts)
+			for _, e := range testEntries {
+				fw, err := w.CreateEntry(&Header{
+					FileHeader: FileHeader{Name: e.Name, Method: e.Method},
+					Extras: []ExtraField{
+						{ID: bothID, Data: []byte("both")},
+						{ID: localID, Data: []byte("local"), Where: ExtraLocal},
+						{ID: centralID, Data: []byte("central"), Where: ExtraCentral},
+					},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := fw.Write(e.Data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			if f, ok := dst.(*os.File); ok {
+				var err error
+				if data, err = os.ReadFile(f.Name()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range testEntries {
+				f := findFile(t, z, e.Name)
+				if got, err := z.ReadFile(e.Name); e.Name != "dir/" && (err != nil || !bytes.Equal(got, e.Data)) {
+					t.Errorf("%s: got %d bytes, %v, want %d bytes", e.Name, len(got), err, len(e.Data))
+				}
+				local := localExtraOf(data, f)
+				for _, c := range []struct {
+					id             uint16
+					local, central bool
+				}{
+					{bothID, true, true},
+					{localID, true, false},
+					{centralID, false, true},
+				} {
+					if hasExtra(local, c.id) != c.local || hasExtra(f.Extra, c.id) != c.central {
+						t.Errorf("%s: extra field %#04x in the local file header %v, in the central directory %v",
+							f.Name, c.id, hasExtra(local, c.id), hasExtra(f.Extra, c.id))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExtrasInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		extras []ExtraField
+	}{
+		{"zip64", []ExtraField{{ID: zip64ExtraID, Data: make([]byte, 16)}}},
+		{"too long", []ExtraField{{ID: 0xfb01, Data: make([]byte, uint16max-3)}}},
+		{"location", []ExtraField{{ID: 0xfb01, Where: ExtraCentral + 1}}},
+		{"central too long", []ExtraField{
+			{ID: 0xfb01, Data: make([]byte, 40000), Where: ExtraCentral},
+			{ID: 0xfb02, Data: make([]byte, 40000), Where: ExtraCentral},
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := NewWriter(io.Discard)
+			if _, err := w.CreateEntry(&Header{FileHeader: FileHeader{Name: "a"}, Extras: test.extras}); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
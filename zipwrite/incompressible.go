@@ -0,0 +1,40 @@
+package zipwrite
+
+import (
+	"compress/flate"
+	"io"
+	"sync"
+)
+
+// sampleWriterPool holds the compressors chooseMethod tries samples with.
+var sampleWriterPool sync.Pool
+
+// sampleSize returns how many bytes of the contents of fh to sample before
+// choosing its compression method, see Options.StoreIncompressible, or 0
+// if the method is not to be chosen.
+func (w *Writer) sampleSize(fh *FileHeader) int {
+	if fh.Method != Deflate {
+		return 0
+	}
+	return w.opts.StoreIncompressible
+}
+
+// chooseMethod stores fh rather than deflating it if sample, the start of
+// its contents, deflates by less than a sixteenth. Empty entries are
+// stored too, since deflating them only adds an empty block.
+func chooseMethod(fh *FileHeader, sample []byte) {
+	fw, ok := sampleWriterPool.Get().(*flate.Writer)
+	cw := &countWriter{w: io.Discard}
+	if ok {
+		fw.Reset(cw)
+	} else {
+		fw, _ = flate.NewWriter(cw, flate.BestSpeed)
+	}
+	defer sampleWriterPool.Put(fw)
+	if _, err := fw.Write(sample); err != nil || fw.Close() != nil {
+		return // writing to io.Discard does not fail
+	}
+	if cw.count >= int64(len(sample)-len(sample)/16) {
+		fh.Method = Store
+	}
+}
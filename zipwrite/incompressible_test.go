@@ -0,0 +1,150 @@
+package zipwrite
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestStoreIncompressible(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 2000)
+	contents := []struct {
+		name   string
+		data   []byte
+		method uint16
+	}{
+		{"random", random, Store},
+		{"text", text, Deflate},
+		{"short", []byte("abc"), Store},
+		{"empty", nil, Store},
+		{"compressible after the sample", append(append([]byte(nil), random[:4096]...), text...), Store},
+	}
+
+	for _, test := range []struct {
+		name     string
+		opts     Options
+		seekable bool
+	}{
+		{"stream", Options{}, false},
+		{"seekable", Options{}, true},
+		{"deterministic", Options{Deterministic: true}, false},
+		{"concurrent", Options{Concurrency: 2}, false},
+		{"sozip", Options{SOZip: true, SOZipMinSize: 1}, false},
+		{"encrypted", Options{Password: "secret", Encryption: ZipCrypto}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var dst io.Writer = &buf
+			if test.seekable {
+				f, err := os.Create(filepath.Join(t.TempDir(), "a.zip"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				dst = f
+			}
+			opts := test.opts
+			opts.StoreIncompressible = 4096
+			w := NewWriterWithOptions(dst, &opts)
+			if opts.Concurrency > 1 {
+				var entries []Entry
+				for _, c := range contents {
+					data := c.data
+					entries = append(entries, Entry{Header: &FileHeader{Name: c.name, Method: Deflate}, Open: func() (io.ReadCloser, error) {
+						return io.NopCloser(bytes.NewReader(data)), nil
+					}})
+				}
+				if err := w.AddEntries(entries); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				for _, c := range contents {
+					fh := &FileHeader{Name: c.name, Method: Deflate}
+					fw, err := w.CreateHeader(fh)
+					if err != nil {
+						t.Fatal(err)
+					}
+					// Write in pieces that do not line up with the sample.
+					for p := c.data; len(p) > 0; {
+						n := 1000
+						if n > len(p) {
+							n = len(p)
+						}
+						if _, err := fw.Write(p[:n]); err != nil {
+							t.Fatal(err)
+						}
+						p = p[n:]
+					}
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			if f, ok := dst.(*os.File); ok {
+				var err error
+				if data, err = os.ReadFile(f.Name()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			z, err := zipread.OpenWithOptions(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &zipread.Options{Password: "secret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range contents {
+				f := findFile(t, z, c.name)
+				if f.Method != c.method {
+					t.Errorf("%s: method %d, want %d", c.name, f.Method, c.method)
+				}
+				if got, err := z.ReadFile(c.name); err != nil || !bytes.Equal(got, c.data) {
+					t.Errorf("%s: read %d bytes, %v, want %d", c.name, len(got), err, len(c.data))
+				}
+			}
+		})
+	}
+}
+
+func findFile(t *testing.T, z *zipread.Reader, name string) *zipread.File {
+	t.Helper()
+	for _, f := range z.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	t.Fatalf("no entry %q", name)
+	return nil
+}
+
+func TestStoreIncompressibleHeader(t *testing.T) {
+	w := NewWriterWithOptions(io.Discard, &Options{StoreIncompressible: 4096})
+	fh := &FileHeader{Name: "random", Method: Deflate}
+	fw, err := w.CreateHeader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(random)
+	if _, err := fw.Write(random); err != nil {
+		t.Fatal(err)
+	}
+	if fh.Method != Store {
+		t.Errorf("method %d once the sample is written, want %d", fh.Method, Store)
+	}
+
+	// The header is checked before the entry begins.
+	if _, err := w.CreateHeader(&FileHeader{Name: "a", Method: Deflate, Comment: strings.Repeat("a", 1<<16)}); err != errLongComment {
+		t.Errorf("got %v, want %v", err, errLongComment)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
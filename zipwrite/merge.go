@@ -0,0 +1,151 @@
+package zipwrite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// A ConflictPolicy selects what Merge does with entries whose name another
+// entry already has.
+type ConflictPolicy int
+
+const (
+	// ConflictsKeepFirst keeps the first entry with a name, leaving out
+	// the later ones.
+	ConflictsKeepFirst ConflictPolicy = iota
+
+	// ConflictsKeepLast keeps the last entry with a name, in the place of
+	// the first, so that later archives update earlier ones.
+	ConflictsKeepLast
+
+	// ConflictsKeepAll keeps every entry, duplicates included, which
+	// readers resolve as they see fit.
+	ConflictsKeepAll
+
+	// ConflictsFail makes Merge fail before writing anything.
+	ConflictsFail
+)
+
+// mergePartSize is the size of the parts Merge writes, as zipcopy writes
+// them, well above the smallest part object stores accept.
+const mergePartSize = 64 << 20
+
+var errDuplicate = errors.New("zipwrite: duplicate entry")
+
+// Merge writes the entries of srcs, in order, to an archive it commits to
+// dst, copying them with CopyRaw, which neither decompresses nor
+// compresses them. This is how many small archives are consolidated into
+// one. Names are matched as decoded by the Readers, and policy selects
+// which entries with the same name are kept, apart from directories, of
+// which only the first is. After a failure, Merge aborts dst.
+func Merge(dst zipread.Sink, srcs []*zipread.Reader, policy ConflictPolicy) error {
+	parts := &sinkParts{ctx: context.TODO(), sink: dst}
+	files, err := mergeFiles(srcs, policy)
+	if err == nil {
+		err = writeMerged(NewSplitWriter(parts, mergePartSize), files)
+	}
+	if err != nil {
+		return errs.Combine(err, parts.abort())
+	}
+	return nil
+}
+
+// mergeFiles returns the entries of srcs Merge writes with policy.
+func mergeFiles(srcs []*zipread.Reader, policy ConflictPolicy) ([]*zipread.File, error) {
+	var files []*zipread.File
+	kept := make(map[string]int) // index in files of the entry with a name
+	for _, z := range srcs {
+		for _, f := range z.File {
+			i, dup := kept[f.Name]
+			switch {
+			case !dup || policy == ConflictsKeepAll:
+			case strings.HasSuffix(f.Name, "/") || policy == ConflictsKeepFirst:
+				continue
+			case policy == ConflictsKeepLast:
+				files[i] = f
+				continue
+			default:
+				return nil, errs.Errorf("%w: %q", errDuplicate, f.Name)
+			}
+			kept[f.Name] = len(files)
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// writeMerged copies files to w, and closes it.
+func writeMerged(w *Writer, files []*zipread.File) error {
+	for _, f := range files {
+		if err := w.CopyRaw(f); err != nil {
+			return errs.Errorf("zipwrite: copying %q: %w", f.Name, err)
+		}
+	}
+	return w.Close()
+}
+
+// sinkParts writes the parts of an archive to a zipread.Sink, each as
+// it is written, and commits it once the last is.
+type sinkParts struct {
+	ctx    context.Context
+	sink   zipread.Sink
+	offset int64     // of the next part in the archive
+	part   *sinkPart // being written, if any
+}
+
+// A sinkPart feeds a part to Sink.WritePart, which runs until Close.
+type sinkPart struct {
+	parts *sinkParts
+	pw    *io.PipeWriter
+	n     int64
+	done  chan error // receives the result of WritePart
+}
+
+func (p *sinkParts) Create(n int) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	part := &sinkPart{parts: p, pw: pw, done: make(chan error, 1)}
+	offset := p.offset
+	go func() {
+		err := p.sink.WritePart(p.ctx, n, offset, pr)
+		// Writes fail from now on, rather than block, should WritePart
+		// have returned without reading the whole part.
+		pr.CloseWithError(err)
+		part.done <- err
+	}()
+	p.part = part
+	return part, nil
+}
+
+func (p *sinkParts) Finish(n int) error {
+	return p.sink.Commit(p.ctx)
+}
+
+// abort stops writing the current part, if any, and aborts the sink.
+func (p *sinkParts) abort() error {
+	if p.part != nil {
+		p.part.pw.CloseWithError(errors.New("zipwrite: aborted"))
+		<-p.part.done
+		p.part = nil
+	}
+	return p.sink.Abort(p.ctx)
+}
+
+func (s *sinkPart) Write(b []byte) (int, error) {
+	n, err := s.pw.Write(b)
+	s.n += int64(n)
+	return n, err
+}
+
+func (s *sinkPart) Close() error {
+	s.pw.Close()
+	err := <-s.done
+	s.parts.offset += s.n
+	s.parts.part = nil
+	return err
+}
@@ -0,0 +1,145 @@
+package zipwrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"zipper/zipread"
+)
+
+// memSink is a zipread.Sink keeping the parts in memory.
+type memSink struct {
+	parts     [][]byte
+	offsets   []int64
+	committed bool
+	aborted   bool
+}
+
+func (s *memSink) WritePart(ctx context.Context, number int, offset int64, data io.Reader) error {
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	if number != len(s.parts)+1 {
+		return errors.New("parts out of order")
+	}
+	s.parts = append(s.parts, b)
+	s.offsets = append(s.offsets, offset)
+	return nil
+}
+
+func (s *memSink) Commit(ctx context.Context) error {
+	s.committed = true
+	return nil
+}
+
+func (s *memSink) Abort(ctx context.Context) error {
+	s.aborted = true
+	return nil
+}
+
+// source checks that the parts were committed, laid out one after the
+// other, and returns the archive they make up.
+func (s *memSink) source(t *testing.T) zipread.Source {
+	t.Helper()
+	if !s.committed || s.aborted {
+		t.Fatalf("committed %v, aborted %v", s.committed, s.aborted)
+	}
+	var data []byte
+	for i, p := range s.parts {
+		if s.offsets[i] != int64(len(data)) {
+			t.Fatalf("part %d at %d, want %d", i+1, s.offsets[i], len(data))
+		}
+		data = append(data, p...)
+	}
+	return zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestMerge(t *testing.T) {
+	var srcs []*zipread.Reader
+	for _, entries := range [][]testEntry{
+		{{Name: "a", Method: Deflate, Data: []byte("first a")}, {Name: "dir/"}, {Name: "dir/b", Data: []byte("b")}},
+		{{Name: "dir/", Method: Store}, {Name: "a", Method: Store, Data: []byte("second a")}, {Name: "c", Data: []byte("c")}},
+	} {
+		data := writeTestZip(t, nil, entries...)
+		z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcs = append(srcs, z)
+	}
+
+	for _, test := range []struct {
+		name   string
+		policy ConflictPolicy
+		want   []testEntry
+	}{
+		{"keep first", ConflictsKeepFirst, []testEntry{{Name: "a", Data: []byte("first a")}, {Name: "dir/"}, {Name: "dir/b", Data: []byte("b")}, {Name: "c", Data: []byte("c")}}},
+		{"keep last", ConflictsKeepLast, []testEntry{{Name: "a", Data: []byte("second a")}, {Name: "dir/"}, {Name: "dir/b", Data: []byte("b")}, {Name: "c", Data: []byte("c")}}},
+		{"keep all", ConflictsKeepAll, []testEntry{{Name: "a", Data: []byte("first a")}, {Name: "dir/"}, {Name: "dir/b", Data: []byte("b")}, {Name: "dir/"}, {Name: "a", Data: []byte("second a")}, {Name: "c", Data: []byte("c")}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sink := new(memSink)
+			if err := Merge(sink, srcs, test.policy); err != nil {
+				t.Fatal(err)
+			}
+			z, err := zipread.Open(sink.source(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(z.File) != len(test.want) {
+				t.Fatalf("%d entries, want %d", len(z.File), len(test.want))
+			}
+			for i, f := range z.File {
+				want := test.want[i]
+				if f.Name != want.Name {
+					t.Errorf("entry %d: %q, want %q", i, f.Name, want.Name)
+					continue
+				}
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil || !bytes.Equal(got, want.Data) {
+					t.Errorf("%s: got %q, %v, want %q", f.Name, got, err, want.Data)
+				}
+			}
+		})
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		sink := new(memSink)
+		if err := Merge(sink, srcs, ConflictsFail); !errors.Is(err, errDuplicate) {
+			t.Fatalf("got %v, want a duplicate entry error", err)
+		}
+		if sink.parts != nil || sink.committed || !sink.aborted {
+			t.Errorf("%d parts written, committed %v, aborted %v", len(sink.parts), sink.committed, sink.aborted)
+		}
+	})
+}
+
+func TestSinkParts(t *testing.T) {
+	sink := new(memSink)
+	w := NewSplitWriter(&sinkParts{ctx: context.Background(), sink: sink}, 100)
+	for _, e := range testEntries {
+		fw, err := w.CreateHeader(&FileHeader{Name: e.Name, Method: e.Method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(e.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.parts) < 2 {
+		t.Fatalf("%d parts, want several", len(sink.parts))
+	}
+	checkTestZip(t, sink.source(t), testEntries...)
+}
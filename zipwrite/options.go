@@ -0,0 +1,140 @@
+package zipwrite
+
+import "time"
+
+// Options configures how a Writer lays out an archive.
+// The zero value selects the default behavior.
+type Options struct {
+	// ForceZip64 makes the Writer use the Zip64 format for every entry and
+	// for the end of the archive, whether or not their sizes, offsets and
+	// count need it. Every header then has the same length whatever the
+	// sizes turn out to be, so the layout of an archive can be computed
+	// before its contents are known. By default, the Zip64 format is only
+	// used where a value does not fit its 16 or 32-bit field.
+	ForceZip64 bool
+
+	// Deterministic makes the archive depend only on the entries written
+	// to it, not on when or in which order, so that build systems can
+	// cache and sign archives: entries are written sorted by name, all
+	// with the modification time DeterministicTime, without the extra
+	// fields recording other times or ownership, and with the version
+	// made by derived from their attributes. The contents are kept in a
+	// temporary file until Close writes them out in order. It cannot be
+	// used with Append.
+	Deterministic bool
+
+	// DeterministicTime is the modification time of every entry with
+	// Deterministic. The zero value selects 1980-01-01 00:00:00, the
+	// earliest time the format can represent, without an extended
+	// timestamp extra field.
+	DeterministicTime time.Time
+
+	// Align, when greater than 1, pads the local file headers of stored
+	// entries so that their contents start at a multiple of Align bytes
+	// into the archive, like Android's zipalign does, for consumers that
+	// map them into memory: 4 for aligned reads, or the page size. The
+	// padding is in an extra field with ID 0xd935, as zipalign writes it.
+	// Align can be at most 32768.
+	Align int
+
+	// Times selects which timestamps are recorded for entries, and so how
+	// precisely.
+	Times TimePolicy
+
+	// TimesUTC records the MS-DOS date and time of entries in UTC. By
+	// default, they are recorded in the location of FileHeader.Modified,
+	// which readers without support for the extended timestamp take to be
+	// their local time zone.
+	TimesUTC bool
+
+	// LegacyUnixOwner records the Owner of a Header in the local file
+	// header with the older Info-ZIP Unix extra field 0x5855 as well, for
+	// extractors predating the 0x7875 field. The older field only holds
+	// 16-bit IDs next to 32-bit modification and access times, and is left
+	// out where those do not fit.
+	LegacyUnixOwner bool
+
+	// Concurrency, when greater than 1, is the number of entries that
+	// AddEntries and AddFS compress at a time, each on its own goroutine,
+	// while the archive is written in order. Each entry compressed ahead
+	// of the one being written holds up to 1 MiB of compressed contents
+	// in memory, and the rest in a temporary file. Those entries get
+	// their sizes in the local file header rather than in a data
+	// descriptor.
+	Concurrency int
+
+	// DataDescriptors selects where the CRC-32 and sizes of entries are
+	// recorded: in data descriptors, or in their local file headers.
+	DataDescriptors DescriptorPolicy
+
+	// UTF8 selects which entries get the UTF-8 flag, declaring their
+	// names and comments to be UTF-8 rather than the legacy encoding of
+	// the ZIP specification, IBM code page 437. Entries with
+	// FileHeader.NonUTF8 set never do.
+	UTF8 UTF8Policy
+
+	// NameEncoder, if not nil, encodes the names and comments that are
+	// not plain ASCII of entries written without the UTF-8 flag with
+	// UTF8Never, from UTF-8 into a legacy encoding, as
+	// zipread.Options.NameDecoder decodes them. EncodeCP437 encodes the
+	// one mandated by the ZIP specification.
+	NameEncoder func(s string) ([]byte, error)
+
+	// Password, if not empty, encrypts the contents of every entry with
+	// Encryption, except for directories, entries copied with CopyRaw and
+	// those with a Header.Password of their own. Since the salt and the ZipCrypto
+	// encryption header are random, encrypted entries are never written
+	// the same twice, even with Deterministic.
+	Password   string
+	Encryption Encryption
+
+	// SOZip makes deflated entries seek-optimized, as GDAL writes them:
+	// their contents are compressed in chunks of SOZipChunkSize bytes, 32
+	// KiB by default, that decompress independently, and those of at least
+	// SOZipMinSize bytes, 1 MiB by default, are followed by a hidden entry
+	// indexing the chunks. zipread with zipread.Options.SOZip then reads
+	// any part of them without decompressing what precedes it. Encrypted
+	// entries are not seek-optimized, and the contents are compressed
+	// with compress/flate whatever compressor is registered for Deflate.
+	SOZip          bool
+	SOZipChunkSize int
+	SOZipMinSize   int64
+
+	// StoreIncompressible, when greater than zero, is how many bytes at
+	// the start of entries to be deflated are compressed as a sample first,
+	// to store the entries whose sample does not compress instead, like
+	// media files and compressed archives, saving the time deflating them
+	// would take for nothing. The FileHeader of those entries is changed
+	// to Store. Entries written with CreateHeader only begin once that
+	// many bytes of their contents, or all of them, are written.
+	StoreIncompressible int
+
+	// Spanned makes a Writer from NewSplitWriter write a spanned archive,
+	// as "zip -s" does, rather than slices of a plain one: the first part
+	// starts with a marker, and the local file headers, central directory
+	// headers and end records are each kept whole on one part, whose
+	// number locates them next to their offset on it. zipread.OpenSpanned
+	// reads it, as do Info-ZIP and 7-Zip given the parts named as
+	// SpannedFiles names them. No header may then be larger than a part.
+	Spanned bool
+
+	// SHA256 records the SHA-256 digest of the contents of entries in an
+	// extra field with ID 0x6873, whose data is the 32 bytes of the
+	// digest, for pipelines that need more than the CRC-32 to trust them:
+	// zipread verifies it as it does the CRC-32, see zipread.File.SHA256.
+	// Entries written with CreateHeader only have it in the central
+	// directory, since their local file header is written first.
+	// Encrypted entries, whose digest would reveal what they hold, and
+	// entries copied as stored get none.
+	SHA256 bool
+
+	// Progress, if not nil, is called as entries are written, so that
+	// long jobs can drive progress bars and metrics: when an entry begins,
+	// after every write of its contents, and once it is complete, and by
+	// Close once the archive is. Calls never overlap, not even with
+	// Concurrency. The contents of entries copied as stored,
+	// including those compressed ahead with Concurrency, only count once
+	// they are complete, and those of entries written with Deterministic
+	// only reach the archive on Close.
+	Progress func(Progress)
+}
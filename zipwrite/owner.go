@@ -0,0 +1,68 @@
+package zipwrite
+
+import (
+	"io/fs"
+	"math"
+	"time"
+
+	"zipper/zipread"
+)
+
+// An Owner is the user and group owning an entry, by their numeric IDs.
+type Owner struct {
+	UID, GID int
+}
+
+// FileInfoHeader creates a partially-populated Header from an
+// fs.FileInfo, like zipread.FileInfoHeader, adding the Owner of the file
+// where fi.Sys reports it: for files stat'ed on Unix systems, and for
+// archive/tar headers.
+func FileInfoHeader(fi fs.FileInfo) (*Header, error) {
+	fh, err := zipread.FileInfoHeader(fi)
+	if err != nil {
+		return nil, err
+	}
+	return &Header{FileHeader: *fh, Owner: fileOwner(fi)}, nil
+}
+
+// unixOwner returns the Info-ZIP Unix extra field 0x7875 recording o, with
+// 32-bit IDs. The same field goes in the local file header and in the
+// central directory.
+func unixOwner(o *Owner) []byte {
+	var buf [15]byte // 2x uint16 + 3x uint8 + 2x uint32
+	b := writeBuf(buf[:])
+	b.uint16(unixOwnerExtraID)
+	b.uint16(11) // size of the extra field data
+	b.uint8(1)   // version
+	b.uint8(4)   // size of the UID
+	b.uint32(uint32(o.UID))
+	b.uint8(4) // size of the GID
+	b.uint32(uint32(o.GID))
+	return buf[:]
+}
+
+// legacyUnixOwner returns the local file header version of the Info-ZIP
+// Unix extra field 0x5855 recording o along with the modification and
+// access times, or nil if they do not fit it. A zero access time is
+// recorded as the modification time.
+func legacyUnixOwner(o *Owner, modified, accessed time.Time) []byte {
+	if accessed.IsZero() {
+		accessed = modified
+	}
+	fits := func(v int64) bool { return v >= 0 && v <= math.MaxUint16 }
+	if modified.IsZero() || modified.Unix() < 0 || modified.Unix() > math.MaxUint32 ||
+		accessed.Unix() < 0 || accessed.Unix() > math.MaxUint32 ||
+		!fits(int64(o.UID)) || !fits(int64(o.GID)) {
+		return nil
+	}
+
+	var buf [16]byte // 2x uint16 + 2x uint32 + 2x uint16
+	b := writeBuf(buf[:])
+	b.uint16(infoZipUnixExtraID)
+	b.uint16(12) // size of the extra field data
+	b.uint32(uint32(accessed.Unix()))
+	b.uint32(uint32(modified.Unix()))
+	b.uint16(uint16(o.UID))
+	b.uint16(uint16(o.GID))
+	return buf[:]
+}
@@ -0,0 +1,78 @@
+package zipwrite
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"zipper/zipread"
+)
+
+func TestOwner(t *testing.T) {
+	write := func(opts *Options, h *Header) (local, central []byte) {
+		var buf bytes.Buffer
+		w := NewWriterWithOptions(&buf, opts)
+		if _, err := w.CreateEntry(h); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		nameLen := int(binary.LittleEndian.Uint16(data[26:]))
+		extraLen := int(binary.LittleEndian.Uint16(data[28:]))
+		z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data[30+nameLen : 30+nameLen+extraLen], z.File[0].Extra
+	}
+	modified := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	owner := &Owner{UID: 1000, GID: 70000}
+
+	local, central := write(nil, &Header{FileHeader: FileHeader{Name: "a", Modified: modified}, Owner: owner})
+	want := []byte("ux\x0b\x00\x01\x04\xe8\x03\x00\x00\x04\x70\x11\x01\x00")
+	if !bytes.Contains(local, want) || !bytes.Contains(central, want) {
+		t.Errorf("local extra %x, central extra %x, want %x in both", local, central, want)
+	}
+	if hasExtra(local, infoZipUnixExtraID) {
+		t.Error("legacy field written by default")
+	}
+
+	// The legacy field cannot hold the GID, so it is left out.
+	local, _ = write(&Options{LegacyUnixOwner: true}, &Header{FileHeader: FileHeader{Name: "a", Modified: modified}, Owner: owner})
+	if hasExtra(local, infoZipUnixExtraID) {
+		t.Error("legacy field written with a 32-bit GID")
+	}
+
+	local, central = write(&Options{LegacyUnixOwner: true}, &Header{FileHeader: FileHeader{Name: "a", Modified: modified}, Owner: &Owner{UID: 1000, GID: 100}})
+	var legacy [16]byte
+	b := writeBuf(legacy[:])
+	b.uint16(infoZipUnixExtraID)
+	b.uint16(12)
+	b.uint32(uint32(modified.Unix()))
+	b.uint32(uint32(modified.Unix()))
+	b.uint16(1000)
+	b.uint16(100)
+	if !bytes.Contains(local, legacy[:]) || hasExtra(central, infoZipUnixExtraID) {
+		t.Errorf("local extra %x, central extra %x, want %x in the local one only", local, central, legacy)
+	}
+
+	local, central = write(&Options{Deterministic: true}, &Header{FileHeader: FileHeader{Name: "a"}, Owner: owner})
+	if hasExtra(local, unixOwnerExtraID) || hasExtra(central, unixOwnerExtraID) {
+		t.Error("owner written with Deterministic")
+	}
+}
+
+func TestFileInfoHeader(t *testing.T) {
+	th := &tar.Header{Name: "a", Mode: 0640, Uid: 1000, Gid: 100, ModTime: time.Now()}
+	h, err := FileInfoHeader(th.FileInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "a" || h.Mode() != 0640 || h.Owner == nil || *h.Owner != (Owner{UID: 1000, GID: 100}) {
+		t.Errorf("got %q mode %v owner %v", h.Name, h.Mode(), h.Owner)
+	}
+}
@@ -0,0 +1,319 @@
+package zipwrite
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// AddEntries adds entries to the archive in order. With
+// Options.Concurrency, they are compressed that many at a time, which is
+// how archives of many large entries are written faster than a single core
+// compresses. Otherwise, it is the same as writing each entry with
+// CreateHeader. The Open functions of entries may be called concurrently,
+// and their headers are left unchanged.
+func (w *Writer) AddEntries(entries []Entry) error {
+	p := w.newPipeline()
+	for _, e := range entries {
+		if err := p.add(&Header{FileHeader: *e.Header}, e.Open); err != nil {
+			break
+		}
+	}
+	return p.close()
+}
+
+// writeEntry writes the entry h with the contents returned by open, which
+// may be nil for an empty entry.
+func (w *Writer) writeEntry(h *Header, open func() (io.ReadCloser, error)) (err error) {
+	fw, err := w.CreateEntry(h)
+	if err != nil {
+		return err
+	}
+	if _, ok := fw.(dirWriter); ok || open == nil {
+		return nil
+	}
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, rc.Close()) }()
+	if _, err := io.Copy(fw, rc); err != nil {
+		return errs.Errorf("zipwrite: writing %q: %w", h.Name, err)
+	}
+	return nil
+}
+
+// errStopped is returned by pipeline.add after writing an entry failed,
+// with the error close returns.
+var errStopped = errors.New("zipwrite: stopped")
+
+// A pipeline adds entries to a Writer, compressing them on up to
+// Options.Concurrency goroutines while a single one writes them out in
+// the order they were added.
+type pipeline struct {
+	w *Writer
+
+	// queue holds the results of the entries being compressed, in order.
+	// It is nil when entries are written as they are added.
+	queue chan chan compressed
+	stop  chan struct{} // closed when writing fails
+	done  chan struct{} // closed when run returns
+	err   error
+}
+
+// A compressed is an entry compressed ahead of being written.
+type compressed struct {
+	h     *Header
+	body  *spillBuffer // nil for directories
+	sozip *sozipWriter // see Options.SOZip
+	err   error
+}
+
+func (w *Writer) newPipeline() *pipeline {
+	p := &pipeline{w: w}
+	if w.opts.Concurrency <= 1 {
+		return p
+	}
+	// One entry is compressed for run to write, the others wait in line.
+	p.queue = make(chan chan compressed, w.opts.Concurrency-1)
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.run()
+	return p
+}
+
+// add adds the entry h with the contents returned by open, which may be
+// nil for an empty entry. It takes ownership of h. After an error, no
+// more entries are added, and close returns the cause.
+func (p *pipeline) add(h *Header, open func() (io.ReadCloser, error)) error {
+	if p.queue == nil {
+		if p.err == nil {
+			p.err = p.w.writeEntry(h, open)
+		}
+		return p.err
+	}
+	res := make(chan compressed, 1)
+	select {
+	case p.queue <- res:
+	case <-p.stop:
+		return errStopped
+	}
+	go func() { res <- p.w.compress(h, open) }()
+	return nil
+}
+
+// close waits for the entries added to be written, and returns the first
+// error in doing so.
+func (p *pipeline) close() error {
+	if p.queue != nil {
+		close(p.queue)
+		<-p.done
+	}
+	return p.err
+}
+
+// run writes the compressed entries in the order they were added. After
+// an error, the remaining ones are discarded.
+func (p *pipeline) run() {
+	defer close(p.done)
+	for res := range p.queue {
+		c := <-res
+		err := c.err
+		if err == nil && p.err == nil {
+			err = p.w.writeCompressed(c)
+		}
+		err = errs.Combine(err, c.body.remove())
+		if err != nil && p.err == nil {
+			p.err = err
+			close(p.stop)
+		}
+	}
+}
+
+// compress compresses the entry h with the contents returned by open, and
+// sets the sizes and CRC32 of h to match. It is safe to call concurrently.
+func (w *Writer) compress(h *Header, open func() (io.ReadCloser, error)) compressed {
+	fh := &h.FileHeader
+	c := compressed{h: h}
+	if strings.HasSuffix(fh.Name, "/") {
+		// As for createHeader, directories hold nothing.
+		fh.Method = Store
+		fh.CRC32, fh.CompressedSize64, fh.UncompressedSize64 = 0, 0, 0
+		return c
+	}
+	comp := w.compressor(fh.Method)
+	if comp == nil {
+		c.err = zipread.ErrAlgorithm
+		return c
+	}
+	var rc io.ReadCloser = io.NopCloser(strings.NewReader(""))
+	if open != nil {
+		var err error
+		if rc, err = open(); err != nil {
+			c.err = errs.Errorf("zipwrite: writing %q: %w", fh.Name, err)
+			return c
+		}
+	}
+	n, err := w.compressBody(&c, comp, rc)
+	if err := errs.Combine(err, rc.Close()); err != nil {
+		c.err = errs.Errorf("zipwrite: writing %q: %w", fh.Name, err)
+		return c
+	}
+	fh.UncompressedSize64 = uint64(n)
+	return c
+}
+
+// compressBody compresses what r reads with comp into c.body, unless
+// Options.StoreIncompressible stores it instead, and sets the CRC32 and
+// compressed size of c.h. It returns how many bytes it read.
+func (w *Writer) compressBody(c *compressed, comp Compressor, r io.Reader) (int64, error) {
+	fh := &c.h.FileHeader
+	if size := w.sampleSize(fh); size > 0 {
+		sample := make([]byte, size)
+		n, err := io.ReadFull(r, sample)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		sample = sample[:n]
+		chooseMethod(fh, sample)
+		comp = w.compressor(fh.Method)
+		r = io.MultiReader(bytes.NewReader(sample), r)
+	}
+
+	c.body = new(spillBuffer)
+	compCount := &countWriter{w: c.body}
+	var zw io.WriteCloser
+	_, password := w.encryption(c.h)
+	if c.sozip = w.newSOZipWriter(fh, compCount, password != ""); c.sozip != nil {
+		zw = c.sozip
+	} else {
+		var err error
+		if zw, err = comp(compCount); err != nil {
+			return 0, err
+		}
+	}
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(zw, crc)
+	digest := w.newDigest(password)
+	if digest != nil {
+		out = io.MultiWriter(out, digest)
+	}
+	n, err := io.Copy(out, r)
+	if err := errs.Combine(err, zw.Close()); err != nil {
+		return n, err
+	}
+	fh.CRC32 = crc.Sum32()
+	fh.CompressedSize64 = uint64(compCount.count)
+	if digest != nil {
+		return n, appendDigest(fh, digest)
+	}
+	return n, nil
+}
+
+// writeCompressed writes the entry c compressed.
+func (w *Writer) writeCompressed(c compressed) error {
+	fh := &c.h.FileHeader
+	if err := w.prepare(fh); err != nil {
+		return err
+	}
+	localExtra, centralExtra, err := w.describe(fh, c.h)
+	if err != nil {
+		return err
+	}
+	fh.Flags &^= 0x8 // the sizes are known, we need no data descriptor
+	w.setRawDescriptor(fh)
+	enc, password := w.encryption(c.h)
+	if c.body == nil {
+		password = "" // directories are not encrypted
+	}
+	var check byte
+	if password != "" {
+		setEncryption(fh, enc)
+		check = zipCryptoCheck(fh)
+		fh.CompressedSize64 += enc.overhead()
+		if fh.Method == methodWinZipAES {
+			fh.CRC32 = 0 // AE-2 leaves it out
+		}
+	}
+	fw, err := w.beginRaw(fh, localExtra, centralExtra)
+	if err != nil || c.body == nil {
+		return err
+	}
+	fw.(*fileWriter).sozip = c.sozip
+	if password == "" {
+		_, err = c.body.WriteTo(fw)
+		return err
+	}
+	ew, err := newEncrypter(fw, enc, password, check)
+	if err != nil {
+		return err
+	}
+	if _, err := c.body.WriteTo(ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// spillSize is how much of the contents of an entry a spillBuffer keeps
+// in memory.
+const spillSize = 1 << 20
+
+// A spillBuffer holds the contents of an entry compressed ahead of being
+// written, in memory up to spillSize bytes, and in a temporary file
+// beyond.
+type spillBuffer struct {
+	mem  bytes.Buffer
+	file *os.File
+}
+
+func (b *spillBuffer) Write(p []byte) (int, error) {
+	if b.file == nil && b.mem.Len()+len(p) > spillSize {
+		file, err := os.CreateTemp("", "zipwrite-*")
+		if err != nil {
+			return 0, err
+		}
+		b.file = file
+	}
+	if b.file != nil {
+		return b.file.Write(p)
+	}
+	return b.mem.Write(p)
+}
+
+// reader returns a Reader for what was written to b.
+func (b *spillBuffer) reader() (io.Reader, error) {
+	if b.file == nil {
+		return &b.mem, nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.MultiReader(&b.mem, b.file), nil
+}
+
+func (b *spillBuffer) WriteTo(w io.Writer) (int64, error) {
+	n, err := b.mem.WriteTo(w)
+	if err != nil || b.file == nil {
+		return n, err
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return n, err
+	}
+	m, err := io.Copy(w, b.file)
+	return n + m, err
+}
+
+// remove closes and removes the temporary file of b, if any.
+func (b *spillBuffer) remove() error {
+	if b == nil || b.file == nil {
+		return nil
+	}
+	return errs.Combine(b.file.Close(), os.Remove(b.file.Name()))
+}
@@ -0,0 +1,93 @@
+package zipwrite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"zipper/zipread"
+)
+
+func TestAddEntries(t *testing.T) {
+	tmp := t.TempDir()
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmp)
+
+	// Random contents do not compress, so that the large ones spill over
+	// to temporary files.
+	rng := rand.New(rand.NewSource(1))
+	entries := append([]testEntry(nil), testEntries...)
+	for i := 0; i < 20; i++ {
+		data := make([]byte, rng.Intn(3*spillSize))
+		rng.Read(data)
+		entries = append(entries, testEntry{Name: fmt.Sprint("random", i), Method: Deflate, Data: data})
+	}
+	toEntries := func(entries []testEntry) []Entry {
+		var out []Entry
+		for _, e := range entries {
+			data := e.Data
+			out = append(out, Entry{
+				Header: &FileHeader{Name: e.Name, Method: e.Method},
+				Open:   func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil },
+			})
+		}
+		return out
+	}
+
+	for _, concurrency := range []int{0, 4} {
+		t.Run(fmt.Sprintf("Concurrency=%d", concurrency), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriterWithOptions(&buf, &Options{Concurrency: concurrency})
+			if err := w.AddEntries(toEntries(entries)); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())), entries...)
+		})
+	}
+
+	// A failing entry stops the rest.
+	failing := toEntries(entries)
+	errOpen := errors.New("open failed")
+	failing[5].Open = func() (io.ReadCloser, error) { return nil, errOpen }
+	w := NewWriterWithOptions(io.Discard, &Options{Concurrency: 4})
+	if err := w.AddEntries(failing); !errors.Is(err, errOpen) {
+		t.Errorf("got %v, want %v", err, errOpen)
+	}
+	if len(w.dir) != 5 {
+		t.Errorf("%d entries written before the failing one, want 5", len(w.dir))
+	}
+
+	if files, err := os.ReadDir(tmp); err != nil || len(files) != 0 {
+		t.Errorf("temporary files left: %v, %v", files, err)
+	}
+}
+
+func TestAddFSConcurrency(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x": {Data: []byte("x")},
+		"b/y": {Data: bytes.Repeat([]byte("y"), 10000)},
+		"c":   {Data: []byte("c")},
+	}
+	var buf bytes.Buffer
+	w := NewWriterWithOptions(&buf, &Options{Concurrency: 2})
+	if err := w.AddFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())),
+		testEntry{Name: "a/", Method: Store},
+		testEntry{Name: "a/x", Method: Deflate, Data: fsys["a/x"].Data},
+		testEntry{Name: "b/", Method: Store},
+		testEntry{Name: "b/y", Method: Deflate, Data: fsys["b/y"].Data},
+		testEntry{Name: "c", Method: Deflate, Data: fsys["c"].Data})
+}
@@ -0,0 +1,57 @@
+package zipwrite
+
+// Progress reports how far writing an archive has got, see
+// Options.Progress.
+type Progress struct {
+	Entries    int    // entries written completely so far
+	Name       string // name of the entry being written, or last written
+	EntryBytes int64  // contents of the entry Name written so far
+	BytesIn    int64  // contents of all entries written so far
+	BytesOut   int64  // size of the archive written so far
+}
+
+// reporter returns the function reporting the progress of the entry
+// name, after in more bytes of its contents are written, or once it is
+// done, or nil without Options.Progress.
+func (w *Writer) reporter(name string) func(in int64, done bool) {
+	if w.opts.Progress == nil {
+		return nil
+	}
+	var entry int64
+	return func(in int64, done bool) {
+		entry += in
+		w.progress.Name = name
+		w.progress.EntryBytes = entry
+		w.progress.BytesIn += in
+		if done {
+			w.progress.Entries++
+		}
+		w.progress.BytesOut = w.cw.count
+		w.opts.Progress(w.progress)
+	}
+}
+
+// reportBegin reports the progress of the entry fw, which just began.
+func (fw *fileWriter) reportBegin() {
+	if fw.report != nil {
+		fw.report(0, false)
+	}
+}
+
+// reportDir reports the progress of the directory name, written
+// completely once it begins.
+func (w *Writer) reportDir(name string) {
+	if report := w.reporter(name); report != nil {
+		report(0, true)
+	}
+}
+
+// reportClose reports the progress of the complete archive.
+func (w *Writer) reportClose() {
+	if w.opts.Progress == nil {
+		return
+	}
+	w.progress.Name, w.progress.EntryBytes = "", 0
+	w.progress.BytesOut = w.cw.count
+	w.opts.Progress(w.progress)
+}
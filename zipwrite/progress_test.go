@@ -0,0 +1,64 @@
+package zipwrite
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var total int64
+	for _, e := range testEntries {
+		total += int64(len(e.Data))
+	}
+	for _, test := range []struct {
+		name string
+		opts Options
+	}{
+		{"stream", Options{}},
+		{"concurrent", Options{Concurrency: 2}},
+		{"sampled", Options{StoreIncompressible: 100}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var events []Progress
+			opts := test.opts
+			opts.Progress = func(p Progress) { events = append(events, p) }
+			var buf bytes.Buffer
+			w := NewWriterWithOptions(&buf, &opts)
+			var entries []Entry
+			for _, e := range testEntries {
+				data := e.Data
+				entries = append(entries, Entry{Header: &FileHeader{Name: e.Name, Method: e.Method}, Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(data)), nil
+				}})
+			}
+			if err := w.AddEntries(entries); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			last := events[len(events)-1]
+			if last != (Progress{Entries: len(testEntries), BytesIn: total, BytesOut: int64(buf.Len())}) {
+				t.Errorf("last report %+v, want %d entries, %d bytes in, %d out", last, len(testEntries), total, buf.Len())
+			}
+			var prev Progress
+			done := make(map[string]int64)
+			for _, p := range events[:len(events)-1] {
+				if p.BytesIn < prev.BytesIn || p.BytesOut < prev.BytesOut || p.Entries < prev.Entries || p.Entries > prev.Entries+1 {
+					t.Errorf("report %+v after %+v", p, prev)
+				}
+				if p.Entries > prev.Entries {
+					done[p.Name] = p.EntryBytes
+				}
+				prev = p
+			}
+			for _, e := range testEntries {
+				if got, ok := done[e.Name]; !ok || got != int64(len(e.Data)) {
+					t.Errorf("%s: reported done with %d bytes, %v, want %d", e.Name, got, ok, len(e.Data))
+				}
+			}
+		})
+	}
+}
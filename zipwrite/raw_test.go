@@ -0,0 +1,109 @@
+package zipwrite
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestRaw(t *testing.T) {
+	data := bytes.Repeat([]byte("raw contents "), 1000)
+	var deflated bytes.Buffer
+	fw, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries := []struct {
+		name   string
+		method uint16
+		stored []byte
+	}{
+		{"stored", Store, data},
+		{"deflated", Deflate, deflated.Bytes()},
+	}
+
+	for _, test := range []struct {
+		name       string
+		opts       Options
+		descriptor bool
+	}{
+		{"stream", Options{}, false},
+		{"deterministic", Options{Deterministic: true}, false},
+		{"descriptors", Options{DataDescriptors: DescriptorsAlways}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriterWithOptions(&buf, &test.opts)
+			for _, e := range entries {
+				fw, err := w.CreateEntry(&Header{
+					FileHeader: FileHeader{
+						Name:               e.name,
+						Method:             e.method,
+						CRC32:              crc32.ChecksumIEEE(data),
+						CompressedSize64:   uint64(len(e.stored)),
+						UncompressedSize64: uint64(len(data)),
+					},
+					Raw: true,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := fw.Write(e.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			archive := buf.Bytes()
+			z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(archive), int64(len(archive))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				f := findFile(t, z, e.name)
+				if got, err := z.ReadFile(e.name); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("%s: got %d bytes, %v, want %d", e.name, len(got), err, len(data))
+				}
+				if got := f.Flags&0x8 != 0; got != test.descriptor {
+					t.Errorf("%s: data descriptor %v", e.name, got)
+				}
+				// The local file header is complete, although the
+				// archive was not written to a Seeker.
+				local := archive[f.HeaderOffset():]
+				if !test.descriptor && (binary.LittleEndian.Uint32(local[14:]) != f.CRC32 ||
+					binary.LittleEndian.Uint32(local[18:]) != uint32(len(e.stored))) {
+					t.Errorf("%s: local file header without the CRC-32 and sizes", e.name)
+				}
+			}
+		})
+	}
+}
+
+func TestRawSize(t *testing.T) {
+	for _, n := range []int{2, 4} {
+		w := NewWriter(new(bytes.Buffer))
+		fw, err := w.CreateEntry(&Header{
+			FileHeader: FileHeader{Name: "a", CRC32: crc32.ChecksumIEEE([]byte("abc")), CompressedSize64: 3, UncompressedSize64: 3},
+			Raw:        true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte("abcd")[:n]); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err == nil {
+			t.Errorf("%d bytes written for 3: no error", n)
+		}
+	}
+}
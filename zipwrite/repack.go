@@ -0,0 +1,79 @@
+package zipwrite
+
+import (
+	"io"
+	"sort"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// An Entry is an entry to write from its header and its uncompressed
+// contents, for Repack and the operations built on it.
+type Entry struct {
+	Header *FileHeader
+
+	// Open returns the contents of the entry. It is not called for
+	// directories, and may be nil for them.
+	Open func() (io.ReadCloser, error)
+}
+
+// RepackOptions selects the entries Repack leaves out or replaces.
+type RepackOptions struct {
+	// Delete holds the names of the entries to leave out.
+	Delete map[string]bool
+
+	// Replace maps the names of entries to the entries written in their
+	// place, instead of copying them. Entries for names that are not in
+	// the archive are added after all others, sorted by name.
+	Replace map[string]Entry
+}
+
+// Repack writes the entries of z to w with CopyRaw, which updates their
+// offsets without decompressing or compressing them, except for those
+// opts deletes or replaces. This is how an entry is removed from an
+// archive: by writing a new one without it. Names are matched as decoded
+// by z; all entries with a deleted name are left out, and a replacement
+// is written in place of the first entry with its name only. Repack does
+// not close w, so that more entries can be written and the comment set.
+func Repack(w *Writer, z *zipread.Reader, opts *RepackOptions) error {
+	if opts == nil {
+		opts = &RepackOptions{}
+	}
+	replaced := make(map[string]bool, len(opts.Replace))
+	for _, f := range z.File {
+		if opts.Delete[f.Name] || replaced[f.Name] {
+			continue
+		}
+		if e, ok := opts.Replace[f.Name]; ok {
+			replaced[f.Name] = true
+			if err := w.add(e); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.CopyRaw(f); err != nil {
+			return errs.Errorf("zipwrite: copying %q: %w", f.Name, err)
+		}
+	}
+
+	var added []string
+	for name := range opts.Replace {
+		if !replaced[name] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		if err := w.add(opts.Replace[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add writes the entry e, leaving e.Header unchanged.
+func (w *Writer) add(e Entry) error {
+	return w.writeEntry(&Header{FileHeader: *e.Header}, e.Open)
+}
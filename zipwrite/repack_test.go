@@ -0,0 +1,50 @@
+package zipwrite
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestRepack(t *testing.T) {
+	data := writeTestZip(t, nil, testEntries...)
+	src, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := func(name string, content string) Entry {
+		return Entry{
+			Header: &FileHeader{Name: name, Method: Deflate},
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader([]byte(content))), nil
+			},
+		}
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err = Repack(w, src, &RepackOptions{
+		Delete: map[string]bool{"stored": true},
+		Replace: map[string]Entry{
+			"dir/deflated": entry("dir/deflated", "replaced"),
+			"new":          entry("new", "added"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())),
+		testEntries[1],
+		testEntry{Name: "dir/deflated", Method: Deflate, Data: []byte("replaced")},
+		testEntries[3],
+		testEntry{Name: "new", Method: Deflate, Data: []byte("added")})
+	if got, want := readRaw(t, dst.File[2]), readRaw(t, src.File[3]); !bytes.Equal(got, want) {
+		t.Error("kept entry was not copied as stored")
+	}
+}
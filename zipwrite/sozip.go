@@ -0,0 +1,137 @@
+package zipwrite
+
+import (
+	"compress/flate"
+	"hash/crc32"
+	"io"
+	"path"
+)
+
+// SOZip (seek-optimized ZIP) archives accompany large deflate entries with
+// a hidden index entry that records where independently decodable chunks
+// start in the compressed stream, see zipread.SOZipIndex.
+//
+// See: https://github.com/sozip/sozip-spec
+const (
+	sozipIndexVersion   = 1
+	sozipIndexHeaderLen = 32
+	sozipOffsetSize     = 8
+
+	// The defaults of GDAL, which wrote the first SOZip archives.
+	defaultSOZipChunkSize = 32 << 10
+	defaultSOZipMinSize   = 1 << 20
+)
+
+// sozipIndexName returns the name of the index entry for name.
+func sozipIndexName(name string) string {
+	dir, elem := path.Split(name)
+	return dir + "." + elem + ".sozip.idx"
+}
+
+// A sozipWriter deflates the contents of an entry in chunks of chunkSize
+// bytes that decompress independently of each other: each ends with a
+// sync flush, which byte-aligns the stream, and the next one starts with
+// an empty dictionary.
+type sozipWriter struct {
+	out       *countWriter
+	fw        *flate.Writer
+	chunkSize int
+	minSize   int64
+	n         int      // bytes written to the current chunk
+	offsets   []uint64 // of the chunks after the first
+}
+
+// newSOZipWriter returns a sozipWriter writing to out if the contents of
+// fh are to be seek-optimized, see Options.SOZip, and nil otherwise.
+func (w *Writer) newSOZipWriter(fh *FileHeader, out io.Writer, encrypted bool) *sozipWriter {
+	if !w.opts.SOZip || fh.Method != Deflate || encrypted {
+		return nil
+	}
+	s := &sozipWriter{
+		out:       &countWriter{w: out},
+		chunkSize: w.opts.SOZipChunkSize,
+		minSize:   w.opts.SOZipMinSize,
+	}
+	if s.chunkSize <= 0 || s.chunkSize > uint32max {
+		s.chunkSize = defaultSOZipChunkSize
+	}
+	if s.minSize <= 0 {
+		s.minSize = defaultSOZipMinSize
+	}
+	s.fw, _ = flate.NewWriter(s.out, 5) // the level of the built-in compressor
+	return s
+}
+
+func (s *sozipWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		if s.n == s.chunkSize {
+			// The previous chunk was flushed when it filled up.
+			s.fw.Reset(s.out)
+			s.offsets = append(s.offsets, uint64(s.out.count))
+			s.n = 0
+		}
+		chunk := p
+		if len(chunk) > s.chunkSize-s.n {
+			chunk = chunk[:s.chunkSize-s.n]
+		}
+		n, err := s.fw.Write(chunk)
+		written += n
+		s.n += n
+		if err != nil {
+			return written, err
+		}
+		if s.n == s.chunkSize {
+			if err := s.fw.Flush(); err != nil {
+				return written, err
+			}
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (s *sozipWriter) Close() error {
+	return s.fw.Close()
+}
+
+// writeIndex writes the hidden index entry for fh, whose contents s
+// compressed and whose sizes are set, to w, unless fh is smaller than
+// Options.SOZipMinSize. The entry has a local file header but, unlike the
+// one it indexes, no central directory header.
+func (s *sozipWriter) writeIndex(w io.Writer, fh *FileHeader) error {
+	if fh.UncompressedSize64 < uint64(s.minSize) {
+		return nil
+	}
+	index := make([]byte, sozipIndexHeaderLen+sozipOffsetSize*len(s.offsets))
+	b := writeBuf(index)
+	b.uint32(sozipIndexVersion)
+	b.uint32(0) // no bytes to skip before the offsets
+	b.uint32(uint32(s.chunkSize))
+	b.uint32(sozipOffsetSize)
+	b.uint64(fh.UncompressedSize64)
+	b.uint64(fh.CompressedSize64)
+	for _, off := range s.offsets {
+		b.uint64(off)
+	}
+
+	h := &header{
+		FileHeader: &FileHeader{
+			Name:               sozipIndexName(fh.Name),
+			ReaderVersion:      zipVersion20,
+			Flags:              fh.Flags & 0x800, // the name is encoded as that of fh
+			Method:             Store,
+			ModifiedTime:       fh.ModifiedTime,
+			ModifiedDate:       fh.ModifiedDate,
+			CRC32:              crc32.ChecksumIEEE(index),
+			CompressedSize64:   uint64(len(index)),
+			UncompressedSize64: uint64(len(index)),
+		},
+		sized: true,
+	}
+	if err := writeHeader(w, h); err != nil {
+		return err
+	}
+	_, err := w.Write(index)
+	return err
+}
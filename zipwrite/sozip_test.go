@@ -0,0 +1,152 @@
+package zipwrite
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zipper/zipread"
+)
+
+func TestSOZip(t *testing.T) {
+	content := make([]byte, 300000)
+	for i := range content {
+		content[i] = byte(i*i>>7) ^ byte(i>>11)
+	}
+	const chunkSize = 64 << 10
+	for _, test := range []struct {
+		name     string
+		opts     Options
+		seekable bool
+	}{
+		{"stream", Options{}, false},
+		{"seekable", Options{}, true},
+		{"deterministic", Options{Deterministic: true}, false},
+		{"concurrent", Options{Concurrency: 2}, false},
+		{"concurrent descriptors", Options{Concurrency: 2, DataDescriptors: DescriptorsAlways}, false},
+		{"zip64", Options{ForceZip64: true}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var dst io.Writer = &buf
+			if test.seekable {
+				f, err := os.Create(filepath.Join(t.TempDir(), "a.zip"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				dst = f
+			}
+			opts := test.opts
+			opts.SOZip, opts.SOZipChunkSize, opts.SOZipMinSize = true, chunkSize, 100000
+			w := NewWriterWithOptions(dst, &opts)
+			entries := []Entry{
+				{Header: &FileHeader{Name: "dir/big", Method: Deflate}, Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(content)), nil
+				}},
+				{Header: &FileHeader{Name: "small", Method: Deflate}, Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(content[:1000])), nil
+				}},
+				{Header: &FileHeader{Name: "stored", Method: Store}, Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(content)), nil
+				}},
+			}
+			if err := w.AddEntries(entries); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			if f, ok := dst.(*os.File); ok {
+				var err error
+				if data, err = os.ReadFile(f.Name()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			z, err := zipread.OpenWithOptions(zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), &zipread.Options{SOZip: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(z.File) != 3 {
+				t.Fatalf("%d entries, want the index entries hidden", len(z.File))
+			}
+			for _, f := range z.File {
+				idx, err := f.SOZipIndex(context.Background())
+				if err != nil {
+					t.Fatalf("%s: %v", f.Name, err)
+				}
+				if (idx != nil) != (f.Name == "dir/big") {
+					t.Errorf("%s: index %v", f.Name, idx)
+				}
+				if _, err := z.ReadFile(f.Name); err != nil {
+					t.Errorf("%s: %v", f.Name, err)
+				}
+			}
+
+			f := z.File[0]
+			idx, _ := f.SOZipIndex(context.Background())
+			if idx == nil {
+				t.FailNow()
+			}
+			if want := (len(content) + chunkSize - 1) / chunkSize; idx.ChunkSize != chunkSize || len(idx.Offsets) != want {
+				t.Fatalf("chunk size %d, %d chunks, want %d, %d", idx.ChunkSize, len(idx.Offsets), chunkSize, want)
+			}
+			body := readRaw(t, f)
+			for i, off := range idx.Offsets {
+				want := content[i*chunkSize:]
+				if len(want) > chunkSize {
+					want = want[:chunkSize]
+				}
+				got := make([]byte, len(want))
+				if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(body[off:])), got); err != nil || !bytes.Equal(got, want) {
+					t.Errorf("chunk %d does not decompress on its own: %v", i, err)
+				}
+			}
+
+			rc, err := f.OpenRange(context.Background(), 200000, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			if got, err := io.ReadAll(rc); err != nil || !bytes.Equal(got, content[200000:201000]) {
+				t.Errorf("OpenRange: %v", err)
+			}
+		})
+	}
+}
+
+func TestSOZipCreate(t *testing.T) {
+	// Chunks end where they fill up, whatever the writes.
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	var buf bytes.Buffer
+	w := NewWriterWithOptions(&buf, &Options{SOZip: true, SOZipChunkSize: 4096, SOZipMinSize: 1})
+	fw, err := w.CreateHeader(&FileHeader{Name: "a", Method: Deflate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := content; len(p) > 0; p = p[len(p)/3+1:] {
+		if _, err := fw.Write(p[:len(p)/3+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zipread.OpenWithOptions(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())), &zipread.Options{SOZip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := z.File[0].SOZipIndex(context.Background())
+	if err != nil || idx == nil || len(idx.Offsets) != len(content)/4096 {
+		t.Fatalf("index %+v, %v", idx, err)
+	}
+	if got, err := z.ReadFile("a"); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("read %d bytes, %v", len(got), err)
+	}
+}
@@ -0,0 +1,238 @@
+package zipwrite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Parts receives the parts of an archive written by a Writer from
+// NewSplitWriter, for destinations that limit the size of what they store,
+// like object stores and removable media.
+type Parts interface {
+	// Create returns where to write part number n, counting from 1. The
+	// Writer closes it once the part is complete, before creating the
+	// next one.
+	Create(n int) (io.WriteCloser, error)
+
+	// Finish is called by Writer.Close once the last part, number n, is
+	// closed.
+	Finish(n int) error
+}
+
+// spanSignature starts the first part of a spanned archive.
+const spanSignature = 0x08074b50
+
+var (
+	errLongRecord = errors.New("zipwrite: header larger than the part size")
+	errManyParts  = errors.New("zipwrite: too many parts")
+)
+
+// NewSplitWriter returns a Writer writing an archive in parts of partSize
+// bytes, but for the last one, to parts. The parts are slices of the
+// archive, which read back concatenated, unless Options.Spanned is set.
+// NewSplitWriter panics if partSize is not positive.
+func NewSplitWriter(parts Parts, partSize int64) *Writer {
+	return NewSplitWriterWithOptions(parts, partSize, nil)
+}
+
+// NewSplitWriterWithOptions is like NewSplitWriter but configures the
+// Writer with opts, which may be nil.
+func NewSplitWriterWithOptions(parts Parts, partSize int64, opts *Options) *Writer {
+	if partSize <= 0 {
+		panic("zipwrite: part size must be positive")
+	}
+	s := &splitWriter{parts: parts, size: partSize}
+	w := NewWriterWithOptions(s, opts)
+	w.split = s
+	if w.opts.Spanned {
+		var buf [4]byte
+		b := writeBuf(buf[:])
+		b.uint32(spanSignature)
+		w.cw.Write(buf[:]) // buffered, and so cannot fail yet
+		s.plan = true
+	}
+	return w
+}
+
+// A splitWriter writes to a new part every size bytes, or where reserve
+// planned to cut the archive, see Options.Spanned.
+type splitWriter struct {
+	parts Parts
+	size  int64
+
+	part  io.WriteCloser // nil before the first part
+	n     int            // number of the current part
+	start int64          // offset of the current part in the archive
+	count int64          // bytes written
+
+	// With plan set, reserve plans the parts ahead of the writes, which
+	// the Writer buffers: cuts holds the offsets at which parts start
+	// before size bytes of the previous one are written, and planStart
+	// and planDisk locate the part the next record goes to.
+	plan      bool
+	cuts      []int64
+	planStart int64
+	planDisk  int
+}
+
+// end returns the offset in the archive at which the current part ends.
+func (s *splitWriter) end() int64 {
+	end := s.start + s.size
+	if len(s.cuts) > 0 && s.cuts[0] < end {
+		end = s.cuts[0]
+	}
+	return end
+}
+
+func (s *splitWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		if s.part == nil || s.count == s.end() {
+			if err := s.next(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p
+		if room := s.end() - s.count; int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		n, err := s.part.Write(chunk)
+		written += n
+		s.count += int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// next closes the current part, if any, and creates the next one.
+func (s *splitWriter) next() error {
+	if s.part != nil {
+		if err := s.part.Close(); err != nil {
+			return err
+		}
+		s.part = nil
+	}
+	for len(s.cuts) > 0 && s.cuts[0] <= s.count {
+		s.cuts = s.cuts[1:]
+	}
+	part, err := s.parts.Create(s.n + 1)
+	if err != nil {
+		return err
+	}
+	s.part, s.start = part, s.count
+	s.n++
+	return nil
+}
+
+// reserve keeps the n bytes to be written at the offset pos of the
+// archive, a record readers of spanned archives expect whole, on a single
+// part, and returns its disk number, counting from 0, and the offset of
+// the record on it.
+func (s *splitWriter) reserve(pos, n int64) (disk uint32, offset uint64, err error) {
+	if n > s.size {
+		return 0, 0, errLongRecord
+	}
+	for pos >= s.planStart+s.size {
+		s.planStart += s.size
+		s.planDisk++
+	}
+	if pos+n > s.planStart+s.size {
+		s.cuts = append(s.cuts, pos)
+		s.planStart = pos
+		s.planDisk++
+	}
+	if s.planDisk >= uint16max {
+		// Larger disk numbers take Zip64 extra fields, which readers
+		// of spanned archives hardly expect.
+		return 0, 0, errManyParts
+	}
+	return uint32(s.planDisk), uint64(pos - s.planStart), nil
+}
+
+// close closes the last part and finishes the parts.
+func (s *splitWriter) close() error {
+	if s.part == nil {
+		// Only an empty plain archive has nothing to write.
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+	if err := s.part.Close(); err != nil {
+		return err
+	}
+	s.part = nil
+	return s.parts.Finish(s.n)
+}
+
+// reserve returns the disk number and the offset on it of the n bytes
+// about to be written, which for spanned archives are kept together on one
+// disk. Otherwise, there is only disk 0.
+func (w *Writer) reserve(n int) (disk uint32, offset uint64, err error) {
+	if w.split == nil || !w.split.plan {
+		return 0, uint64(w.cw.count), nil
+	}
+	return w.split.reserve(w.cw.count, int64(n))
+}
+
+// writeLocalHeader writes the local file header of h, and records the
+// disk it is on and its offset there.
+func (w *Writer) writeLocalHeader(h *header) error {
+	if w.split == nil || !w.split.plan {
+		return writeHeader(w.cw, h)
+	}
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, h); err != nil {
+		return err
+	}
+	disk, offset, err := w.reserve(buf.Len())
+	if err != nil {
+		return err
+	}
+	h.disk, h.offset = disk, offset
+	_, err = w.cw.Write(buf.Bytes())
+	return err
+}
+
+// SplitFiles returns Parts writing the parts of an archive to the files
+// name.001, name.002, and so on, as 7-Zip names the slices of an archive.
+func SplitFiles(name string) Parts {
+	return &fileParts{name: func(n int) string { return fmt.Sprintf("%s.%03d", name, n) }}
+}
+
+// SpannedFiles returns Parts writing the parts of a spanned archive to
+// files named as archivers expect them: the last one is name, and the
+// ones before it have the extension of name replaced with .z01, .z02, and
+// so on.
+func SpannedFiles(name string) Parts {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	return &fileParts{
+		name: func(n int) string { return fmt.Sprintf("%s.z%02d", base, n) },
+		last: name,
+	}
+}
+
+// fileParts writes parts to the files named by name, renaming the last
+// one to last, if set.
+type fileParts struct {
+	name func(n int) string
+	last string
+}
+
+func (p *fileParts) Create(n int) (io.WriteCloser, error) {
+	return os.Create(p.name(n))
+}
+
+func (p *fileParts) Finish(n int) error {
+	if p.last == "" {
+		return nil
+	}
+	return os.Rename(p.name(n), p.last)
+}
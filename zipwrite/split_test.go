@@ -0,0 +1,206 @@
+package zipwrite
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zipper/zipread"
+)
+
+// memParts holds the parts of a split archive in memory.
+type memParts struct {
+	parts    []*bytes.Buffer
+	finished int
+}
+
+func (p *memParts) Create(n int) (io.WriteCloser, error) {
+	if n != len(p.parts)+1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf := new(bytes.Buffer)
+	p.parts = append(p.parts, buf)
+	return nopCloser{buf}, nil
+}
+
+func (p *memParts) Finish(n int) error {
+	p.finished = n
+	return nil
+}
+
+func (p *memParts) sources() []zipread.Source {
+	var sources []zipread.Source
+	for _, part := range p.parts {
+		sources = append(sources, zipread.SourceFromReaderAt(bytes.NewReader(part.Bytes()), int64(part.Len())))
+	}
+	return sources
+}
+
+var splitTestEntries = append(testEntries,
+	testEntry{Name: "big", Method: Store, Data: bytes.Repeat([]byte("0123456789"), 500)},
+	testEntry{Name: strings.Repeat("long/", 40), Method: Store},
+	testEntry{Name: "last", Method: Deflate, Data: []byte("last entry")},
+)
+
+func writeSplit(t *testing.T, partSize int64, opts *Options) *memParts {
+	t.Helper()
+	parts := new(memParts)
+	w := NewSplitWriterWithOptions(parts, partSize, opts)
+	for _, e := range splitTestEntries {
+		fw, err := w.CreateHeader(&FileHeader{Name: e.Name, Method: e.Method, Modified: time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(e.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.SetComment("comment")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if parts.finished != len(parts.parts) {
+		t.Fatalf("finished %d parts, want %d", parts.finished, len(parts.parts))
+	}
+	return parts
+}
+
+func TestSplit(t *testing.T) {
+	const partSize = 1000
+	parts := writeSplit(t, partSize, nil)
+	if len(parts.parts) < 5 {
+		t.Fatalf("%d parts", len(parts.parts))
+	}
+	var data []byte
+	for i, part := range parts.parts {
+		if i < len(parts.parts)-1 && part.Len() != partSize || part.Len() > partSize {
+			t.Errorf("part %d: %d bytes", i+1, part.Len())
+		}
+		data = append(data, part.Bytes()...)
+	}
+	checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), splitTestEntries...)
+}
+
+func TestSpanned(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts Options
+	}{
+		{"default", Options{}},
+		{"zip64", Options{ForceZip64: true}},
+		{"deterministic", Options{Deterministic: true}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			const partSize = 700
+			opts := test.opts
+			opts.Spanned = true
+			parts := writeSplit(t, partSize, &opts)
+			if len(parts.parts) < 5 {
+				t.Fatalf("%d parts", len(parts.parts))
+			}
+			if !bytes.HasPrefix(parts.parts[0].Bytes(), []byte("PK\x07\x08")) {
+				t.Error("no spanning signature")
+			}
+			var starts []int64
+			var size int64
+			for i, part := range parts.parts {
+				if part.Len() > partSize {
+					t.Errorf("part %d: %d bytes", i+1, part.Len())
+				}
+				starts = append(starts, size)
+				size += int64(part.Len())
+			}
+			disk := func(off int64) int {
+				i := 0
+				for i+1 < len(starts) && starts[i+1] <= off {
+					i++
+				}
+				return i
+			}
+
+			z, err := zipread.OpenSpanned(parts.sources(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if z.Comment != "comment" || len(z.File) != len(splitTestEntries) {
+				t.Fatalf("comment %q, %d entries", z.Comment, len(z.File))
+			}
+			entries := splitTestEntries
+			if opts.Deterministic {
+				entries = nil
+				for _, f := range z.File {
+					for _, e := range splitTestEntries {
+						if e.Name == f.Name {
+							entries = append(entries, e)
+						}
+					}
+				}
+			}
+			for i, f := range z.File {
+				e := entries[i]
+				if start, end := f.HeaderOffset(), f.HeaderOffset()+fileHeaderLen+int64(len(f.Name)); disk(start) != disk(end-1) {
+					t.Errorf("%s: local file header split across parts %d and %d", f.Name, disk(start)+1, disk(end-1)+1)
+				}
+				if got, err := z.ReadFile(e.Name); e.Name[len(e.Name)-1] != '/' && (err != nil || !bytes.Equal(got, e.Data)) {
+					t.Errorf("%s: read %d bytes, %v, want %d", e.Name, len(got), err, len(e.Data))
+				}
+			}
+		})
+	}
+}
+
+func TestSpannedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		parts Parts
+		opts  Options
+		want  []string
+	}{
+		{SpannedFiles(filepath.Join(dir, "a.zip")), Options{Spanned: true}, []string{"a.z01", "a.z02", "a.zip"}},
+		{SplitFiles(filepath.Join(dir, "b.zip")), Options{}, []string{"b.zip.001", "b.zip.002", "b.zip.003"}},
+	} {
+		w := NewSplitWriterWithOptions(test.parts, 4096, &test.opts)
+		fw, err := w.CreateHeader(&FileHeader{Name: "a", Method: Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(make([]byte, 9000)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range test.want {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(names) != 6 {
+		t.Errorf("files %q, %v", names, err)
+	}
+
+	var sources []zipread.Source
+	for _, name := range []string{"a.z01", "a.z02", "a.zip"} {
+		sources = append(sources, zipread.SourceFromFile(filepath.Join(dir, name)))
+	}
+	z, err := zipread.OpenSpanned(sources, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := z.ReadFile("a"); err != nil || len(got) != 9000 {
+		t.Errorf("read %d bytes, %v", len(got), err)
+	}
+}
+
+func TestSpannedLongHeader(t *testing.T) {
+	w := NewSplitWriterWithOptions(new(memParts), 100, &Options{Spanned: true})
+	if _, err := w.Create(strings.Repeat("a", 100)); err != errLongRecord {
+		t.Errorf("got %v, want %v", err, errLongRecord)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package zipwrite
+
+import (
+	"archive/tar"
+	"io/fs"
+)
+
+// fileOwner returns the owner of the file fi describes, if fi.Sys
+// reports it. Files stat'ed on this system report none.
+func fileOwner(fi fs.FileInfo) *Owner {
+	if sys, ok := fi.Sys().(*tar.Header); ok {
+		return &Owner{UID: sys.Uid, GID: sys.Gid}
+	}
+	return nil
+}
+
+// hardLink returns the identity of the file fi describes, if fi.Sys
+// reports that it has more than one link. Files stat'ed on this system
+// report none.
+func hardLink(fi fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,32 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package zipwrite
+
+import (
+	"archive/tar"
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the owner of the file fi describes, if fi.Sys
+// reports it.
+func fileOwner(fi fs.FileInfo) *Owner {
+	switch sys := fi.Sys().(type) {
+	case *syscall.Stat_t:
+		return &Owner{UID: int(sys.Uid), GID: int(sys.Gid)}
+	case *tar.Header:
+		return &Owner{UID: sys.Uid, GID: sys.Gid}
+	}
+	return nil
+}
+
+// hardLink returns the identity of the file fi describes, if fi.Sys
+// reports that it has more than one link.
+func hardLink(fi fs.FileInfo) (fileID, bool) {
+	sys, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || sys.Nlink < 2 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(sys.Dev), ino: uint64(sys.Ino)}, true
+}
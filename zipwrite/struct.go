@@ -0,0 +1,84 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zipwrite writes ZIP archives, including Zip64 archives of any
+// size, for reading back with zipread.
+package zipwrite
+
+import (
+	"time"
+
+	"zipper/zipread"
+)
+
+// Compression methods.
+const (
+	Store   = zipread.Store
+	Deflate = zipread.Deflate
+)
+
+const (
+	fileHeaderSignature      = 0x04034b50
+	directoryHeaderSignature = 0x02014b50
+	directoryEndSignature    = 0x06054b50
+	directory64LocSignature  = 0x07064b50
+	directory64EndSignature  = 0x06064b50
+	dataDescriptorSignature  = 0x08074b50 // de-facto standard; required by OS X Finder
+	fileHeaderLen            = 30         // + filename + extra
+	directoryHeaderLen       = 46         // + filename + extra + comment
+	directoryEndLen          = 22         // + comment
+	dataDescriptorLen        = 16         // four uint32: descriptor signature, crc32, compressed size, size
+	dataDescriptor64Len      = 24         // descriptor with 8 byte sizes
+	directory64LocLen        = 20         //
+	directory64EndLen        = 56         // + extra
+
+	// Constants for the first byte in CreatorVersion.
+	creatorFAT  = 0
+	creatorUnix = 3
+
+	// Version numbers.
+	zipVersion20 = 20 // 2.0
+	zipVersion45 = 45 // 4.5 (reads and writes zip64 archives)
+
+	// Limits for non zip64 files.
+	uint16max = (1 << 16) - 1
+	uint32max = (1 << 32) - 1
+
+	// Extra header IDs, see zipread.
+	zip64ExtraID       = 0x0001 // Zip64 extended information
+	ntfsExtraID        = 0x000a // NTFS
+	unixExtraID        = 0x000d // UNIX
+	extTimeExtraID     = 0x5455 // Extended timestamp
+	infoZipUnixExtraID = 0x5855 // Info-ZIP Unix extension
+	sha256ExtraID      = 0x6873 // SHA-256 digest, see Options.SHA256
+	unixOwnerExtraID   = 0x7875 // Info-ZIP Unix UID/GID
+	winZipAESExtraID   = 0x9901 // WinZip AES encryption
+)
+
+// FileHeader describes an entry to write, as zipread describes the
+// entries it reads.
+type FileHeader = zipread.FileHeader
+
+// timeToMsDosTime converts a time.Time to an MS-DOS date and time.
+// The resolution is 2s. Times outside of the years 1980 to 2107 the format
+// can represent are clamped to them.
+// See: https://msdn.microsoft.com/en-us/library/ms724274(v=VS.85).aspx
+func timeToMsDosTime(t time.Time) (fDate uint16, fTime uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else if t.Year() > 2107 {
+		t = time.Date(2107, 12, 31, 23, 59, 58, 0, time.UTC)
+	}
+	fDate = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	fTime = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return
+}
+
+// min64 returns the smaller of a and b.
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
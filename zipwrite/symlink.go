@@ -0,0 +1,39 @@
+package zipwrite
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/zeebo/errs/v2"
+)
+
+// CreateSymlink adds a symbolic link to target, named and otherwise
+// described by h, the way Info-ZIP records links: the target is the
+// contents of the entry, and its external attributes hold the Unix mode
+// fs.ModeSymlink|0777. zipread reports such entries as links, and
+// Reader.Extract can create them. Writer takes ownership of h and may
+// mutate its fields.
+func (w *Writer) CreateSymlink(h *Header, target string) error {
+	if err := setSymlink(h, target); err != nil {
+		return err
+	}
+	fw, err := w.CreateEntry(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(fw, target)
+	return err
+}
+
+// setSymlink makes h describe a symbolic link to target.
+func setSymlink(h *Header, target string) error {
+	if target == "" {
+		return errs.Errorf("zipwrite: empty symbolic link target for %q", h.Name)
+	}
+	if strings.HasSuffix(h.Name, "/") {
+		return errs.Errorf("zipwrite: symbolic link %q named like a directory", h.Name)
+	}
+	h.SetMode(fs.ModeSymlink | 0777)
+	return nil
+}
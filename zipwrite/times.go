@@ -0,0 +1,120 @@
+package zipwrite
+
+import (
+	"math"
+	"time"
+)
+
+// A TimePolicy selects which timestamps the Writer records for entries,
+// and so how precisely.
+type TimePolicy int
+
+const (
+	// TimesExtended records the times of entries to the second in the
+	// extended timestamp extra field, as Info-ZIP does, next to the MS-DOS
+	// date and time: the modification time, and the access and creation
+	// times of a Header. Times before 1970 or after 2106, which the field
+	// cannot hold, are left out of it.
+	TimesExtended TimePolicy = iota
+
+	// TimesDOS records the MS-DOS date and time only, to two seconds, for
+	// consumers that reject extra fields.
+	TimesDOS
+
+	// TimesNTFS records the times of entries to 100 nanoseconds in the NTFS
+	// extra field as well, as Windows archivers do. It follows the extended
+	// timestamp, so that zipread takes the modification time from it.
+	TimesNTFS
+)
+
+// setTimes sets the MS-DOS date and time of fh from fh.Modified, and adds
+// the extra fields Options.Times selects for the times of fh.
+func (w *Writer) setTimes(fh *FileHeader, accessed, created time.Time) {
+	// Contrary to the FileHeader.SetModTime method, we intentionally
+	// do not convert to UTC by default, because we assume the user intends
+	// to encode the date using the specified timezone. A user may want this
+	// control because many legacy ZIP readers interpret the timestamp
+	// according to the local timezone. zipread recovers the timezone from
+	// the difference to the extended timestamp.
+	modified := fh.Modified
+	if w.opts.TimesUTC {
+		modified = modified.UTC()
+	}
+	fh.ModifiedDate, fh.ModifiedTime = timeToMsDosTime(modified)
+
+	if w.opts.Times == TimesExtended || w.opts.Times == TimesNTFS {
+		fh.Extra = append(fh.Extra, extendedTimestamp(fh.Modified, accessed, created)...)
+	}
+	if w.opts.Times == TimesNTFS {
+		fh.Extra = append(fh.Extra, ntfsTimestamp(fh.Modified, accessed, created)...)
+	}
+}
+
+// extendedTimestamp returns the extended timestamp extra field recording
+// the given times, leaving out those that are zero or out of its range, or
+// nil if that leaves none. The same field goes in the local file header and
+// in the central directory, where zipread reads all of its times.
+func extendedTimestamp(times ...time.Time) []byte {
+	var flags uint8
+	var recorded []uint32
+	for i, t := range times {
+		if t.IsZero() || t.Unix() < 0 || t.Unix() > math.MaxUint32 {
+			continue
+		}
+		flags |= 1 << i // ModTime, AcTime, CrTime
+		recorded = append(recorded, uint32(t.Unix()))
+	}
+	if flags == 0 {
+		return nil
+	}
+	buf := make([]byte, 5+4*len(recorded))
+	b := writeBuf(buf)
+	b.uint16(extTimeExtraID)
+	b.uint16(uint16(1 + 4*len(recorded)))
+	b.uint8(flags)
+	for _, t := range recorded {
+		b.uint32(t)
+	}
+	return buf
+}
+
+// ntfsEpoch is the start of the Windows file times the NTFS extra field
+// holds, in 100 nanosecond ticks.
+var ntfsEpoch = time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ntfsTimestamp returns the NTFS extra field recording the given times, or
+// nil if the modification time is out of its range. Access and creation
+// times that are zero or out of range are recorded as zero.
+func ntfsTimestamp(modified, accessed, created time.Time) []byte {
+	mtime, ok := ntfsTime(modified)
+	if !ok {
+		return nil
+	}
+	atime, _ := ntfsTime(accessed)
+	ctime, _ := ntfsTime(created)
+
+	var buf [36]byte // 2x uint16 + uint32 + 2x uint16 + 3x uint64
+	b := writeBuf(buf[:])
+	b.uint16(ntfsExtraID)
+	b.uint16(32) // size of the NTFS extra field data
+	b.uint32(0)  // reserved
+	b.uint16(1)  // attribute tag: times
+	b.uint16(24) // size of the attribute
+	b.uint64(mtime)
+	b.uint64(atime)
+	b.uint64(ctime)
+	return buf[:]
+}
+
+// ntfsTime returns t as a Windows file time, and whether it can be one.
+func ntfsTime(t time.Time) (uint64, bool) {
+	if t.IsZero() || t.Before(ntfsEpoch) {
+		return 0, false
+	}
+	const ticksPerSecond = 10000000 // Windows timestamp resolution
+	secs := t.Unix() - ntfsEpoch.Unix()
+	if secs >= math.MaxInt64/ticksPerSecond {
+		return 0, false
+	}
+	return uint64(secs*ticksPerSecond + int64(t.Nanosecond()/100)), true
+}
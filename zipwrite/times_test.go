@@ -0,0 +1,92 @@
+package zipwrite
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"zipper/zipread"
+)
+
+func TestTimes(t *testing.T) {
+	zone := time.FixedZone("", 2*60*60)
+	modified := time.Date(2021, 5, 6, 7, 8, 9, 0, zone)
+	accessed := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	open := func(opts *Options, h *Header) *zipread.File {
+		var buf bytes.Buffer
+		w := NewWriterWithOptions(&buf, opts)
+		if _, err := w.CreateEntry(h); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return z.File[0]
+	}
+
+	f := open(nil, &Header{FileHeader: FileHeader{Name: "a", Modified: modified}, Accessed: accessed, Created: created})
+	if _, offset := f.Modified.Zone(); !f.Modified.Equal(modified) || offset != 2*60*60 {
+		t.Errorf("modified %v, want %v", f.Modified, modified)
+	}
+	if !f.Accessed.Equal(accessed) || !f.Created.Equal(created) {
+		t.Errorf("accessed %v, created %v", f.Accessed, f.Created)
+	}
+
+	f = open(&Options{TimesUTC: true}, &Header{FileHeader: FileHeader{Name: "a", Modified: modified}})
+	if _, offset := f.Modified.Zone(); !f.Modified.Equal(modified) || offset != 0 {
+		t.Errorf("TimesUTC: modified %v", f.Modified)
+	}
+
+	f = open(&Options{Times: TimesDOS}, &Header{FileHeader: FileHeader{Name: "a", Modified: modified.Add(time.Second)}, Accessed: accessed})
+	if len(f.Extra) != 0 || !f.Accessed.IsZero() || f.Modified.Format("2006-01-02 15:04:05") != "2021-05-06 07:08:10" {
+		t.Errorf("TimesDOS: modified %v, extra %x", f.Modified, f.Extra)
+	}
+
+	// The extended timestamp cannot hold times before 1970, and the MS-DOS
+	// date and time none before 1980.
+	old := time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)
+	f = open(nil, &Header{FileHeader: FileHeader{Name: "a", Modified: old}, Created: created})
+	if !f.Modified.Equal(time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)) || !f.Created.Equal(created) {
+		t.Errorf("out of range: modified %v, created %v", f.Modified, f.Created)
+	}
+}
+
+func TestTimesNTFS(t *testing.T) {
+	modified := time.Date(2021, 5, 6, 7, 8, 9, 123456700, time.UTC)
+	accessed := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w := NewWriterWithOptions(&buf, &Options{Times: TimesNTFS})
+	for _, h := range []*Header{
+		{FileHeader: FileHeader{Name: "a", Modified: modified}, Accessed: accessed},
+		{FileHeader: FileHeader{Name: "b", Modified: time.Date(1500, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	} {
+		if _, err := w.CreateEntry(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := z.File[0]
+	if !a.Modified.Equal(modified) || !a.Accessed.Equal(accessed) {
+		t.Errorf("modified %v, accessed %v", a.Modified, a.Accessed)
+	}
+	if !hasExtra(a.Extra, ntfsExtraID) || !hasExtra(a.Extra, extTimeExtraID) {
+		t.Errorf("extra fields %x", a.Extra)
+	}
+	if b := z.File[1]; hasExtra(b.Extra, ntfsExtraID) {
+		t.Errorf("NTFS extra field for a time before 1601: %x", b.Extra)
+	}
+}
@@ -0,0 +1,267 @@
+package zipwrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+// An Update stages changes to an existing archive, to apply them together
+// with Commit, which rewrites as little of it as it can: the entries up to
+// the first one deleted, replaced or moved stay where they are, the ones
+// after it are copied as stored, and only the entries added or replaced
+// are compressed. Names are matched as decoded by zipread. An Update must
+// not be used concurrently.
+type Update struct {
+	z      *zipread.Reader
+	target Target
+	opts   *Options
+
+	deleted map[string]bool
+	added   map[string]Entry
+	edits   map[string][]func(fh *FileHeader)
+	comment *string
+	done    bool
+}
+
+var errUpdateDone = errors.New("zipwrite: update already committed")
+
+// NewUpdate opens the archive served by source, which target writes, for
+// updating it. Nothing is written before Commit.
+func NewUpdate(source zipread.Source, target Target) (*Update, error) {
+	return NewUpdateWithOptions(source, target, nil)
+}
+
+// NewUpdateWithOptions is like NewUpdate but writes with opts, which may
+// be nil.
+func NewUpdateWithOptions(source zipread.Source, target Target, opts *Options) (*Update, error) {
+	if opts != nil && opts.Deterministic {
+		return nil, errs.Errorf("zipwrite: cannot update deterministically")
+	}
+	z, err := zipread.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Update{
+		z:       z,
+		target:  target,
+		opts:    opts,
+		deleted: make(map[string]bool),
+		added:   make(map[string]Entry),
+		edits:   make(map[string][]func(fh *FileHeader)),
+	}, nil
+}
+
+// Add stages the entry e, which replaces the entries with its name, if
+// any, in the place of the first one, and is otherwise added after all
+// others, sorted by name. The Open function of e is called by Commit, and
+// must not read the archive being updated, which Commit overwrites.
+func (u *Update) Add(e Entry) {
+	delete(u.deleted, e.Header.Name)
+	u.added[e.Header.Name] = e
+}
+
+// Delete stages leaving out the entries with the given name, or the one
+// staged with Add.
+func (u *Update) Delete(name string) error {
+	if _, ok := u.added[name]; !ok && !u.exists(name) {
+		return errs.Errorf("zipwrite: deleting %q: %w", name, fs.ErrNotExist)
+	}
+	delete(u.added, name)
+	u.deleted[name] = true
+	return nil
+}
+
+// Edit stages changing the metadata of the entries with the given name:
+// edit is called by Commit with their FileHeader, whose name and comment
+// are decoded. It may rename them, or change their comment, modification
+// time, external attributes and extra fields, but must leave the method,
+// the encryption and data descriptor flags, the CRC-32 and the sizes,
+// which describe the contents, as they are. Entries whose local file
+// header is left unchanged, by changing the comment or external
+// attributes only, stay where they are.
+func (u *Update) Edit(name string, edit func(fh *FileHeader)) error {
+	if !u.exists(name) {
+		return errs.Errorf("zipwrite: editing %q: %w", name, fs.ErrNotExist)
+	}
+	u.edits[name] = append(u.edits[name], edit)
+	return nil
+}
+
+// SetComment stages replacing the archive comment.
+func (u *Update) SetComment(comment string) {
+	u.comment = &comment
+}
+
+func (u *Update) exists(name string) bool {
+	for _, f := range u.z.File {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Commit applies the changes staged. The archive is invalid from the
+// moment Commit starts writing until it returns without an error, unless
+// only the archive comment changes, which it rewrites as RewriteComment
+// does. The contents of the entries it moves are held in a temporary file,
+// beyond what fits in memory, before they are overwritten.
+func (u *Update) Commit() (err error) {
+	if u.done {
+		return errUpdateDone
+	}
+	u.done = true
+	z := u.z
+	if u.comment != nil && len(u.added) == 0 && len(u.deleted) == 0 && len(u.edits) == 0 {
+		// Only the end record changes, see RewriteComment.
+		if len(*u.comment) > uint16max {
+			return errLongArchiveComment
+		}
+		return writeComment(z, u.target, *u.comment)
+	}
+	w := NewWriterWithOptions(u.target, u.opts)
+
+	// Entries are cut off from the first one whose local file header or
+	// contents change on.
+	headers := make([]*FileHeader, len(z.File)) // nil for those left out
+	cut := z.DirectoryOffset()
+	for i, f := range z.File {
+		if _, ok := u.added[f.Name]; !ok && !u.deleted[f.Name] {
+			headers[i] = u.header(w, f)
+			if !localChanged(f, headers[i]) {
+				continue
+			}
+		}
+		if off := f.HeaderOffset(); off < cut {
+			cut = off
+		}
+	}
+
+	// The contents of the entries after the cut are saved before they are
+	// overwritten.
+	var moved spillBuffer
+	defer func() { err = errs.Combine(err, moved.remove()) }()
+	for i, f := range z.File {
+		if headers[i] == nil || f.HeaderOffset() < cut {
+			continue
+		}
+		if err := spillRaw(&moved, f); err != nil {
+			return errs.Errorf("zipwrite: copying %q: %w", f.Name, err)
+		}
+	}
+
+	for i, f := range z.File {
+		if headers[i] != nil && f.HeaderOffset() < cut {
+			w.keep(z, f, headers[i])
+		}
+	}
+	w.comment = z.Comment
+	if u.comment != nil {
+		if err := w.SetComment(*u.comment); err != nil {
+			return err
+		}
+	}
+	if err := w.truncate(z, u.target, cut); err != nil {
+		return err
+	}
+	r, err := moved.reader()
+	if err != nil {
+		return err
+	}
+	replaced := make(map[string]bool)
+	for i, f := range z.File {
+		if e, ok := u.added[f.Name]; ok {
+			if !replaced[f.Name] {
+				replaced[f.Name] = true
+				if err := w.add(e); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if headers[i] == nil || f.HeaderOffset() < cut {
+			continue
+		}
+		fh := headers[i]
+		w.setRawDescriptor(fh)
+		fw, err := w.createRaw(fh)
+		if err == nil {
+			_, err = io.CopyN(fw, r, int64(f.CompressedSize64))
+		}
+		if err != nil {
+			return errs.Errorf("zipwrite: copying %q: %w", f.Name, err)
+		}
+	}
+
+	var added []string
+	for name := range u.added {
+		if !replaced[name] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		if err := w.add(u.added[name]); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// header returns the header to write the entry f with, as CopyRaw copies
+// it, with the edits staged for it applied.
+func (u *Update) header(w *Writer, f *zipread.File) *FileHeader {
+	edits := u.edits[f.Name]
+	if len(edits) == 0 {
+		return rawHeader(f)
+	}
+	fh := f.FileHeader
+	fh.Extra = append([]byte(nil), f.Extra...)
+	for _, edit := range edits {
+		edit(&fh)
+	}
+	if fh.Name == f.Name && fh.Comment == f.Comment {
+		fh.Name, fh.Comment = f.RawName, f.RawComment
+	} else {
+		// As for describe, by default.
+		utf8Valid1, utf8Require1 := detectUTF8(fh.Name)
+		utf8Valid2, utf8Require2 := detectUTF8(fh.Comment)
+		fh.Flags &^= 0x800
+		if (utf8Require1 || utf8Require2) && (utf8Valid1 && utf8Valid2) {
+			fh.Flags |= 0x800
+		}
+	}
+	if !fh.Modified.Equal(f.Modified) {
+		fh.Extra = withoutExtra(fh.Extra, extTimeExtraID, ntfsExtraID)
+		w.setTimes(&fh, time.Time{}, time.Time{})
+	}
+	fh.Extra = withoutExtra(fh.Extra, zip64ExtraID)
+	return &fh
+}
+
+// localChanged reports whether the local file header of f, written with
+// fh, would change.
+func localChanged(f *zipread.File, fh *FileHeader) bool {
+	return fh.Name != f.RawName || fh.Flags != f.Flags || fh.Method != f.Method ||
+		fh.ModifiedTime != f.ModifiedTime || fh.ModifiedDate != f.ModifiedDate ||
+		!bytes.Equal(fh.Extra, withoutExtra(f.Extra, zip64ExtraID))
+}
+
+// spillRaw appends the contents of f, as stored, to b.
+func spillRaw(b *spillBuffer, f *zipread.File) error {
+	rc, err := f.OpenRaw(context.TODO())
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(b, rc, int64(f.CompressedSize64))
+	return errs.Combine(err, rc.Close())
+}
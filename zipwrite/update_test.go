@@ -0,0 +1,199 @@
+package zipwrite
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zipper/zipread"
+)
+
+func TestUpdate(t *testing.T) {
+	entries := []testEntry{
+		{Name: "a", Method: Deflate, Data: bytes.Repeat([]byte("a"), 1000)},
+		{Name: "b", Method: Store, Data: []byte("b")},
+		{Name: "dir/", Method: Store},
+		{Name: "dir/c", Method: Deflate, Data: bytes.Repeat([]byte("c"), 2000)},
+		{Name: "d", Method: Store, Data: []byte("d")},
+	}
+	original := writeTestZip(t, nil, entries...)
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(original), int64(len(original))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := func(name string) int64 { return findFile(t, z, name).HeaderOffset() }
+	modified := time.Date(2021, 5, 6, 7, 8, 10, 0, time.UTC)
+	open := func(data string) func() (io.ReadCloser, error) {
+		return func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(data)), nil }
+	}
+
+	for _, test := range []struct {
+		name    string
+		stage   func(u *Update) error
+		kept    int64 // bytes of the original left as they were
+		want    []string
+		data    map[string]string
+		comment string
+		check   func(t *testing.T, z *zipread.Reader)
+	}{
+		{
+			name: "add",
+			stage: func(u *Update) error {
+				u.Add(Entry{Header: &FileHeader{Name: "0", Method: Deflate}, Open: open("new")})
+				return nil
+			},
+			kept: z.DirectoryOffset(),
+			want: []string{"a", "b", "dir/", "dir/c", "d", "0"},
+			data: map[string]string{"0": "new", "d": "d"},
+		},
+		{
+			name:  "delete",
+			stage: func(u *Update) error { return u.Delete("b") },
+			kept:  offset("b"),
+			want:  []string{"a", "dir/", "dir/c", "d"},
+			data:  map[string]string{"dir/c": strings.Repeat("c", 2000), "d": "d"},
+		},
+		{
+			name: "replace",
+			stage: func(u *Update) error {
+				u.Add(Entry{Header: &FileHeader{Name: "dir/c", Method: Store}, Open: open("replaced")})
+				return nil
+			},
+			kept: offset("dir/c"),
+			want: []string{"a", "b", "dir/", "dir/c", "d"},
+			data: map[string]string{"dir/c": "replaced", "d": "d"},
+		},
+		{
+			name: "delete added",
+			stage: func(u *Update) error {
+				u.Add(Entry{Header: &FileHeader{Name: "e"}, Open: open("e")})
+				return u.Delete("e")
+			},
+			kept: z.DirectoryOffset(),
+			want: []string{"a", "b", "dir/", "dir/c", "d"},
+		},
+		{
+			name: "comments",
+			stage: func(u *Update) error {
+				u.SetComment("updated")
+				return u.Edit("a", func(fh *FileHeader) { fh.Comment = "entry comment" })
+			},
+			kept:    z.DirectoryOffset(),
+			want:    []string{"a", "b", "dir/", "dir/c", "d"},
+			data:    map[string]string{"a": strings.Repeat("a", 1000)},
+			comment: "updated",
+			check: func(t *testing.T, z *zipread.Reader) {
+				if c := findFile(t, z, "a").Comment; c != "entry comment" {
+					t.Errorf("entry comment %q", c)
+				}
+			},
+		},
+		{
+			name:    "comment",
+			stage:   func(u *Update) error { u.SetComment("only"); return nil },
+			kept:    z.EndOffset() + directoryEndLen - 2,
+			want:    []string{"a", "b", "dir/", "dir/c", "d"},
+			data:    map[string]string{"d": "d"},
+			comment: "only",
+		},
+		{
+			name: "rename",
+			stage: func(u *Update) error {
+				return u.Edit("b", func(fh *FileHeader) { fh.Name = "bé" })
+			},
+			kept: offset("b"),
+			want: []string{"a", "bé", "dir/", "dir/c", "d"},
+			data: map[string]string{"bé": "b", "dir/c": strings.Repeat("c", 2000)},
+		},
+		{
+			name: "modified",
+			stage: func(u *Update) error {
+				return u.Edit("d", func(fh *FileHeader) { fh.Modified = modified })
+			},
+			kept: offset("d"),
+			want: []string{"a", "b", "dir/", "dir/c", "d"},
+			data: map[string]string{"d": "d"},
+			check: func(t *testing.T, z *zipread.Reader) {
+				if m := findFile(t, z, "d").Modified; !m.Equal(modified) {
+					t.Errorf("modified %v, want %v", m, modified)
+				}
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "a.zip")
+			if err := os.WriteFile(name, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.OpenFile(name, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			u, err := NewUpdate(zipread.SourceFromFile(name), f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.stage(u); err != nil {
+				t.Fatal(err)
+			}
+			if err := u.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			if err := u.Commit(); !errors.Is(err, errUpdateDone) {
+				t.Errorf("committed twice: %v", err)
+			}
+
+			updated, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(updated[:test.kept], original[:test.kept]) {
+				t.Errorf("the first %d bytes changed", test.kept)
+			}
+			z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(updated), int64(len(updated))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range z.File {
+				names = append(names, f.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(test.want, ",") {
+				t.Errorf("entries %q, want %q", names, test.want)
+			}
+			for name, data := range test.data {
+				if got, err := z.ReadFile(name); err != nil || string(got) != data {
+					t.Errorf("%s: got %q, %v, want %q", name, got, err, data)
+				}
+			}
+			if z.Comment != test.comment {
+				t.Errorf("comment %q, want %q", z.Comment, test.comment)
+			}
+			if test.check != nil {
+				test.check(t, z)
+			}
+		})
+	}
+}
+
+func TestUpdateMissing(t *testing.T) {
+	original := writeTestZip(t, nil, testEntries...)
+	source := zipread.SourceFromReaderAt(bytes.NewReader(original), int64(len(original)))
+	u, err := NewUpdate(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Delete("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("deleting: %v", err)
+	}
+	if err := u.Edit("missing", func(*FileHeader) {}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("editing: %v", err)
+	}
+}
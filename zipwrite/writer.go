@@ -0,0 +1,1044 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zipwrite
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zeebo/errs/v2"
+
+	"zipper/zipread"
+)
+
+var (
+	errLongName  = errors.New("zip: FileHeader.Name too long")
+	errLongExtra = errors.New("zip: FileHeader.Extra too long")
+
+	errLongComment = errors.New("zip: FileHeader.Comment too long")
+)
+
+// Writer implements a zip file writer.
+type Writer struct {
+	cw          *countWriter
+	dir         []*header
+	last        *fileWriter
+	closed      bool
+	compressors map[uint16]Compressor
+	comment     string
+	opts        Options
+	spool       *spool       // with Options.Deterministic
+	seeker      io.Seeker    // the destination, if it is seekable
+	split       *splitWriter // the destination, with NewSplitWriter
+	progress    Progress     // see Options.Progress
+
+	// testHookCloseSizeOffset if non-nil is called with the size
+	// of offset of the central directory at Close.
+	testHookCloseSizeOffset func(size, offset uint64)
+}
+
+type header struct {
+	*FileHeader
+	offset uint64 // on the disk, with Options.Spanned
+	disk   uint32 // with Options.Spanned
+	raw    bool
+	zip64  bool // forced, see Options.ForceZip64
+	align  int  // see Options.Align
+	sized  bool // the CRC-32 and sizes are set when the header is written
+
+	// localExtra and centralExtra hold extra fields for the local file
+	// header only and for the central directory only, written after those
+	// of the FileHeader.
+	localExtra   []byte
+	centralExtra []byte
+
+	// body and bodyLen locate the contents in the spool, with
+	// Options.Deterministic.
+	body, bodyLen int64
+}
+
+func (h *header) hasDataDescriptor() bool {
+	return h.Flags&0x8 != 0
+}
+
+// NewWriter returns a new Writer writing a zip file to w.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterWithOptions(w, nil)
+}
+
+// NewWriterWithOptions is like NewWriter but configures the Writer with
+// opts, which may be nil.
+func NewWriterWithOptions(w io.Writer, opts *Options) *Writer {
+	zw := &Writer{cw: &countWriter{w: bufio.NewWriter(w)}, seeker: seekable(w)}
+	if opts != nil {
+		zw.opts = *opts
+	}
+	return zw
+}
+
+// SetOffset sets the offset of the beginning of the zip data within the
+// underlying writer. It should be used when the zip data is appended to an
+// existing file, such as a binary executable.
+// It must be called before any data is written.
+func (w *Writer) SetOffset(n int64) {
+	if w.cw.count != 0 {
+		panic("zip: SetOffset called after data was written")
+	}
+	w.cw.count = n
+}
+
+// Flush flushes any buffered data to the underlying writer.
+// Calling Flush is not normally necessary; calling Close is sufficient.
+func (w *Writer) Flush() error {
+	return w.cw.w.(*bufio.Writer).Flush()
+}
+
+// SetComment sets the end-of-central-directory comment field.
+// It can only be called before Close.
+func (w *Writer) SetComment(comment string) error {
+	if len(comment) > uint16max {
+		return errors.New("zip: Writer.Comment too long")
+	}
+	w.comment = comment
+	return nil
+}
+
+// Close finishes writing the zip file by writing the central directory.
+// It does not close the underlying writer.
+func (w *Writer) Close() (err error) {
+	if w.last != nil && !w.last.closed {
+		if err := w.last.close(); err != nil {
+			return err
+		}
+		w.last = nil
+	}
+	if w.closed {
+		return errors.New("zip: writer closed twice")
+	}
+	w.closed = true
+
+	if w.spool != nil {
+		defer func() { err = errs.Combine(err, w.spool.remove()) }()
+		if err := w.unspool(); err != nil {
+			return err
+		}
+	}
+
+	// write central directory
+	start := w.cw.count
+	dirDisk, dirOffset, err := w.reserve(0)
+	if err != nil {
+		return err
+	}
+	// The disk the central directory ends on, and how many of its
+	// records are there.
+	lastDisk, diskRecords := dirDisk, uint64(0)
+	usedZip64 := false
+	for i, h := range w.dir {
+		// For the Central Directory, we always have the correct sizes.
+		//
+		// We conservatively write Zip64 extra fields if any size or offset
+		// REACHES OR EXCEEDS 4GiB - 1, to maximize compatibility with readers,
+		// and then include all and only the fields that do, as the spec
+		// requires. A forced Zip64 extra field includes all three fields.
+		readerVersion := h.ReaderVersion
+		compressedSize := min64(h.CompressedSize64, uint32max)
+		uncompressedSize := min64(h.UncompressedSize64, uint32max)
+		offset := min64(h.offset, uint32max)
+		if h.zip64 {
+			compressedSize, uncompressedSize, offset = uint32max, uint32max, uint32max
+		}
+		if compressedSize == uint32max || uncompressedSize == uint32max || offset == uint32max {
+			usedZip64 = true
+			if readerVersion < zipVersion45 {
+				readerVersion = zipVersion45
+			}
+			var size uint16
+			var buf [28]byte // 2x uint16 + up to 3x uint64
+			eb := writeBuf(buf[:])
+			eb.uint16(zip64ExtraID)
+			eb.uint16(0) // size to be filled out later
+			if uncompressedSize == uint32max {
+				eb.uint64(h.UncompressedSize64)
+				size += 8
+			}
+			if compressedSize == uint32max {
+				eb.uint64(h.CompressedSize64)
+				size += 8
+			}
+			if offset == uint32max {
+				eb.uint64(h.offset)
+				size += 8
+			}
+			sb := writeBuf(buf[2:])
+			sb.uint16(size)
+			h.Extra = append(h.Extra, buf[:4+size]...)
+		}
+		if h.centralExtra != nil {
+			h.Extra = append(h.Extra, h.centralExtra...)
+		}
+
+		disk, off, err := w.reserve(directoryHeaderLen + len(h.Name) + len(h.Extra) + len(h.Comment))
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			dirDisk, dirOffset = disk, off
+		}
+		if disk != lastDisk {
+			lastDisk, diskRecords = disk, 0
+		}
+		diskRecords++
+
+		var buf [directoryHeaderLen]byte
+		b := writeBuf(buf[:])
+		b.uint32(uint32(directoryHeaderSignature))
+		b.uint16(h.CreatorVersion)
+		b.uint16(readerVersion)
+		b.uint16(h.Flags)
+		b.uint16(h.Method)
+		b.uint16(h.ModifiedTime)
+		b.uint16(h.ModifiedDate)
+		b.uint32(h.CRC32)
+		b.uint32(uint32(compressedSize))
+		b.uint32(uint32(uncompressedSize))
+		b.uint16(uint16(len(h.Name)))
+		b.uint16(uint16(len(h.Extra)))
+		b.uint16(uint16(len(h.Comment)))
+		b.uint16(uint16(h.disk)) // disk number start
+		b = b[2:]                // skip internal file attr (uint16)
+		b.uint32(h.ExternalAttrs)
+		b.uint32(uint32(offset))
+		if _, err := w.cw.Write(buf[:]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w.cw, h.Name); err != nil {
+			return err
+		}
+		if _, err := w.cw.Write(h.Extra); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w.cw, h.Comment); err != nil {
+			return err
+		}
+	}
+	end := w.cw.count
+
+	records := uint64(len(w.dir))
+	size := uint64(end - start)
+
+	if f := w.testHookCloseSizeOffset; f != nil {
+		f(size, dirOffset)
+	}
+
+	// Emit the Zip64 EOCD records whenever any individual entry needed a Zip64
+	// extra field, even if the EOCD's own fields fit in 32 bits, matching
+	// Info-ZIP. See APPNOTE 4.3.9.2: "when Zip64 extensions are in use, the
+	// EOCD64 record must be present."
+	zip64 := usedZip64 || w.opts.ForceZip64 || records >= uint16max || size >= uint32max || dirOffset >= uint32max
+	endLen := directoryEndLen + len(w.comment)
+	if zip64 {
+		endLen += directory64EndLen + directory64LocLen
+	}
+	endDisk, endOffset, err := w.reserve(endLen)
+	if err != nil {
+		return err
+	}
+	if endDisk != lastDisk {
+		diskRecords = 0
+	}
+	if zip64 {
+		var buf [directory64EndLen + directory64LocLen]byte
+		b := writeBuf(buf[:])
+
+		// zip64 end of central directory record
+		b.uint32(directory64EndSignature)
+		b.uint64(directory64EndLen - 12) // length minus signature (uint32) and length fields (uint64)
+		b.uint16(zipVersion45)           // version made by
+		b.uint16(zipVersion45)           // version needed to extract
+		b.uint32(endDisk)                // number of this disk
+		b.uint32(dirDisk)                // number of the disk with the start of the central directory
+		b.uint64(diskRecords)            // total number of entries in the central directory on this disk
+		b.uint64(records)                // total number of entries in the central directory
+		b.uint64(size)                   // size of the central directory
+		b.uint64(dirOffset)              // offset of start of central directory with respect to the starting disk number
+
+		// zip64 end of central directory locator
+		b.uint32(directory64LocSignature)
+		b.uint32(endDisk)     // number of the disk with the start of the zip64 end of central directory
+		b.uint64(endOffset)   // relative offset of the zip64 end of central directory record
+		b.uint32(endDisk + 1) // total number of disks
+
+		if _, err := w.cw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+
+	// write end record
+	var buf [directoryEndLen]byte
+	b := writeBuf(buf[:])
+	b.uint32(uint32(directoryEndSignature))
+	b.uint16(uint16(endDisk))                       // number of this disk
+	b.uint16(uint16(dirDisk))                       // number of the disk with the start of the central directory
+	b.uint16(uint16(min64(uint16max, diskRecords))) // number of entries this disk
+	b.uint16(uint16(min64(uint16max, records)))     // number of entries total
+	b.uint32(uint32(min64(uint32max, size)))        // size of directory
+	b.uint32(uint32(min64(uint32max, dirOffset)))   // start of directory
+	b.uint16(uint16(len(w.comment)))                // byte size of EOCD comment
+	if _, err := w.cw.Write(buf[:]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w.cw, w.comment); err != nil {
+		return err
+	}
+
+	if err := w.cw.w.(*bufio.Writer).Flush(); err != nil {
+		return err
+	}
+	if w.split != nil {
+		if err := w.split.close(); err != nil {
+			return err
+		}
+	}
+	w.reportClose()
+	return nil
+}
+
+// Create adds a file to the zip file using the provided name.
+// It returns a Writer to which the file contents should be written.
+// The file contents will be compressed using the Deflate method.
+// The name must be a relative path: it must not start with a drive
+// letter (e.g. C:) or leading slash, and only forward slashes are
+// allowed. To create a directory instead of a file, add a trailing
+// slash to the name. Duplicate names will not overwrite previous entries
+// and are appended to the zip file.
+// The file's contents must be written to the io.Writer before the next
+// call to Create, CreateHeader, CreateEntry, CopyRaw, or Close.
+func (w *Writer) Create(name string) (io.Writer, error) {
+	header := &FileHeader{
+		Name:   name,
+		Method: Deflate,
+	}
+	return w.CreateHeader(header)
+}
+
+// detectUTF8 reports whether s is a valid UTF-8 string, and whether the string
+// must be considered UTF-8 encoding (i.e., not compatible with CP-437, ASCII,
+// or any other common encoding).
+func detectUTF8(s string) (valid, require bool) {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		// Officially, ZIP uses CP-437, but many readers use the system's
+		// local character encoding. Most encoding are compatible with a large
+		// subset of CP-437, which itself is ASCII-like.
+		//
+		// Forbid 0x7e and 0x5c since EUC-KR and Shift-JIS replace those
+		// characters with localized currency and overline characters.
+		if r < 0x20 || r > 0x7d || r == 0x5c {
+			if !utf8.ValidRune(r) || (r == utf8.RuneError && size == 1) {
+				return false, false
+			}
+			require = true
+		}
+	}
+	return true, require
+}
+
+// prepare performs the bookkeeping operations required at the start of
+// CreateHeader and createRaw.
+func (w *Writer) prepare(fh *FileHeader) error {
+	if w.last != nil && !w.last.closed {
+		if err := w.last.close(); err != nil {
+			return err
+		}
+	}
+	if len(w.dir) > 0 && w.dir[len(w.dir)-1].FileHeader == fh {
+		// See https://golang.org/issue/11144 confusion.
+		return errors.New("zip: invalid duplicate FileHeader")
+	}
+	return nil
+}
+
+// CreateHeader adds a file to the zip archive using the provided FileHeader
+// for the file metadata. Writer takes ownership of fh and may mutate
+// its fields. The caller must not modify fh after calling CreateHeader.
+//
+// This returns a Writer to which the file contents should be written.
+// The file's contents must be written to the io.Writer before the next
+// call to Create, CreateHeader, CreateEntry, CopyRaw, or Close.
+func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
+	return w.createHeader(fh, nil)
+}
+
+// createHeader implements CreateHeader and CreateEntry, which passes the
+// Header holding fh as e.
+func (w *Writer) createHeader(fh *FileHeader, e *Header) (io.Writer, error) {
+	if err := w.prepare(fh); err != nil {
+		return nil, err
+	}
+	localExtra, centralExtra, err := w.describe(fh, e)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &header{
+		FileHeader:   fh,
+		offset:       uint64(w.cw.count),
+		zip64:        w.opts.ForceZip64,
+		align:        w.opts.Align,
+		localExtra:   localExtra,
+		centralExtra: centralExtra,
+	}
+	out, err := w.bodyWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(fh.Name, "/") {
+		// Set the compression method to Store to ensure data length is truly zero,
+		// which the writeHeader method always encodes for the size fields.
+		// This is necessary as most compression formats have non-zero lengths
+		// even when compressing an empty string.
+		fh.Method = Store
+		fh.Flags &^= 0x8 // we will not write a data descriptor
+
+		// Explicitly clear sizes as they have no meaning for directories.
+		fh.CompressedSize = 0
+		fh.CompressedSize64 = 0
+		fh.UncompressedSize = 0
+		fh.UncompressedSize64 = 0
+
+		if err := w.begin(h); err != nil {
+			return nil, err
+		}
+		w.last = nil
+		w.reportDir(fh.Name)
+		return dirWriter{}, nil
+	}
+
+	if e != nil && e.Raw {
+		// The local file header is complete, and the contents go
+		// straight after it.
+		w.setRawDescriptor(fh)
+		return w.beginRaw(fh, localExtra, centralExtra)
+	}
+
+	descriptor, err := w.useDescriptor()
+	if err != nil {
+		return nil, err
+	}
+	enc, password := w.encryption(e)
+	if password != "" && enc == ZipCrypto {
+		// The encryption header depends on the checksum, unless it
+		// follows the data.
+		if w.opts.DataDescriptors == DescriptorsNever {
+			return nil, errZipCryptoDescriptor
+		}
+		descriptor = true
+	}
+	if descriptor {
+		fh.Flags |= 0x8 // we will write a data descriptor
+	} else {
+		fh.Flags &^= 0x8 // we will fill in the local file header
+	}
+
+	fw := &fileWriter{
+		header:    h,
+		zipw:      out,
+		compCount: &countWriter{w: out},
+		crc32:     crc32.NewIEEE(),
+		report:    w.reporter(fh.Name),
+	}
+	if !descriptor && !w.opts.Deterministic {
+		fw.patch = func() error { return w.patchHeader(h) }
+	}
+	if n := w.sampleSize(fh); n > 0 {
+		// The entry begins once the sample is written, see
+		// Options.StoreIncompressible.
+		if err := checkHeader(h); err != nil {
+			return nil, err
+		}
+		fw.sample = make([]byte, 0, n)
+		fw.start = func() error { return w.start(fw, enc, password) }
+	} else if err := w.start(fw, enc, password); err != nil {
+		return nil, err
+	}
+	w.last = fw
+	fw.reportBegin()
+	return fw, nil
+}
+
+// start begins the entry fw, whose header is complete but for how its
+// contents are compressed and encrypted, with password if not empty:
+// it sets up the writers for the contents and writes the local file
+// header. With Options.StoreIncompressible, it chooses the compression
+// method first, from the sample of fw.
+func (w *Writer) start(fw *fileWriter, enc Encryption, password string) error {
+	fh := fw.FileHeader
+	if fw.sample != nil {
+		chooseMethod(fh, fw.sample)
+	}
+	comp := w.compressor(fh.Method)
+	if comp == nil {
+		return zipread.ErrAlgorithm
+	}
+	var compressed io.Writer = fw.compCount
+	if fw.sozip = w.newSOZipWriter(fh, fw.compCount, password != ""); fw.sozip != nil {
+		fw.comp = fw.sozip
+	}
+	if password != "" {
+		setEncryption(fh, enc)
+		var err error
+		fw.enc, err = newEncrypter(fw.compCount, enc, password, zipCryptoCheck(fh))
+		if err != nil {
+			return err
+		}
+		compressed = fw.enc
+	}
+	if fw.comp == nil {
+		var err error
+		fw.comp, err = comp(compressed)
+		if err != nil {
+			return err
+		}
+	}
+	fw.rawCount = &countWriter{w: fw.comp}
+	if fw.digest = w.newDigest(password); fw.digest != nil {
+		fw.rawCount.w = io.MultiWriter(fw.comp, fw.digest)
+	}
+	return w.begin(fw.header)
+}
+
+// describe sets the metadata of fh that the Writer derives or normalizes,
+// see createHeader, and returns the extra fields for the local file
+// header only and for the central directory only.
+func (w *Writer) describe(fh *FileHeader, e *Header) (localExtra, centralExtra []byte, err error) {
+	// The ZIP format has a sad state of affairs regarding character encoding.
+	// Officially, the name and comment fields are supposed to be encoded
+	// in CP-437 (which is mostly compatible with ASCII), unless the UTF-8
+	// flag bit is set. However, there are several problems:
+	//
+	//	* Many ZIP readers still do not support UTF-8.
+	//	* If the UTF-8 flag is cleared, several readers simply interpret the
+	//	name and comment fields as whatever the local system encoding is.
+	//
+	// In order to avoid breaking readers without UTF-8 support,
+	// we avoid setting the UTF-8 flag if the strings are CP-437 compatible.
+	// However, if the strings require multibyte UTF-8 encoding and is a
+	// valid UTF-8 string, then we set the UTF-8 bit.
+	//
+	// For the case, where the user explicitly wants to specify the encoding
+	// as UTF-8, they will need to set the flag bit themselves, or select
+	// UTF8Always. UTF8Never leaves the flag unset, encoding the strings
+	// with Options.NameEncoder if any.
+	utf8Valid1, utf8Require1 := detectUTF8(fh.Name)
+	utf8Valid2, utf8Require2 := detectUTF8(fh.Comment)
+	switch {
+	case fh.NonUTF8:
+		fh.Flags &^= 0x800
+	case w.opts.UTF8 == UTF8Always:
+		if !utf8Valid1 || !utf8Valid2 {
+			return nil, nil, errs.Errorf("zipwrite: name or comment of %q not valid UTF-8", fh.Name)
+		}
+		fh.Flags |= 0x800
+	case w.opts.UTF8 == UTF8Never:
+		fh.Flags &^= 0x800
+		if (utf8Require1 || utf8Require2) && w.opts.NameEncoder != nil {
+			if err := w.encodeNames(fh); err != nil {
+				return nil, nil, err
+			}
+		}
+	case (utf8Require1 || utf8Require2) && (utf8Valid1 && utf8Valid2):
+		fh.Flags |= 0x800
+	}
+
+	var accessed, created time.Time
+	var owner *Owner
+	if e != nil {
+		accessed, created, owner = e.Accessed, e.Created, e.Owner
+	}
+	creatorVersion, readerVersion := fh.CreatorVersion, fh.ReaderVersion
+	if w.opts.Deterministic {
+		w.normalize(fh)
+		accessed, created, owner = time.Time{}, time.Time{}, nil
+	}
+	if owner != nil {
+		fh.Extra = append(fh.Extra, unixOwner(owner)...)
+	}
+
+	fh.CreatorVersion = fh.CreatorVersion&0xff00 | zipVersion20 // preserve compatibility byte
+	fh.ReaderVersion = zipVersion20
+	if e != nil && e.KeepVersions {
+		fh.CreatorVersion, fh.ReaderVersion = creatorVersion, readerVersion
+	}
+	if w.opts.ForceZip64 && fh.ReaderVersion < zipVersion45 {
+		fh.ReaderVersion = zipVersion45
+	}
+
+	// If Modified is set, this takes precedence over MS-DOS timestamp fields.
+	if !fh.Modified.IsZero() {
+		w.setTimes(fh, accessed, created)
+	}
+	if owner != nil && w.opts.LegacyUnixOwner {
+		localExtra = legacyUnixOwner(owner, fh.Modified, accessed)
+	}
+	if e != nil && len(e.Extras) > 0 {
+		both, local, central, err := encodeExtras(e.Extras)
+		if err != nil {
+			return nil, nil, errs.Errorf("zipwrite: %q: %w", fh.Name, err)
+		}
+		fh.Extra = append(fh.Extra, both...)
+		localExtra = append(localExtra, local...)
+		centralExtra = central
+	}
+	return localExtra, centralExtra, nil
+}
+
+// checkHeader checks that the name and extra fields of h fit the local
+// file header, and the central directory.
+func checkHeader(h *header) error {
+	const maxUint16 = 1<<16 - 1
+	if len(h.Name) > maxUint16 {
+		return errLongName
+	}
+	if len(h.Extra)+len(h.localExtra) > maxUint16 || len(h.Extra)+len(h.centralExtra) > maxUint16 {
+		return errLongExtra
+	}
+	if len(h.Comment) > maxUint16 {
+		return errLongComment
+	}
+	if h.align > maxAlign {
+		return errAlign
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, h *header) error {
+	if err := checkHeader(h); err != nil {
+		return err
+	}
+
+	// The correct behavior of a streaming writer, implemented by Info-ZIP 3.0,
+	// would be to write 0xFFFFFFFF in the size fields and then write a Zip64
+	// extra field with the sizes at zero (to signal they are stored in a ZIP64
+	// data descriptor, in case the file is > 4GiB).
+	//
+	// By default we don't do that, and instead write zeroes directly in the
+	// size fields, because that wastes 28 bytes for every file smaller than
+	// 4GiB. The Local File Header is not that important, as the Central
+	// Directory is authoritative, and there we always write the correct sizes.
+	//
+	// If we do know the sizes, because createRaw is used or the contents were
+	// spooled, and the data descriptor flag is not set, then we write them to
+	// the header. If either size exceeds 4GiB, we write 0xFFFFFFFF
+	// placeholders and a Zip64 extra field with BOTH sizes, per the spec and
+	// matching Info-ZIP. Without the flag, the sizes are otherwise filled in
+	// once they are known, see Writer.patchHeader.
+	//
+	// A forced Zip64 header follows Info-ZIP too, with the sizes in the extra
+	// field if they are known, and zero otherwise.
+	var zip64ExtraInfo []byte
+	readerVersion := h.ReaderVersion
+	noDataDescriptor := h.sized && !h.hasDataDescriptor()
+	if h.zip64 || noDataDescriptor && (h.CompressedSize64 > uint32max || h.UncompressedSize64 > uint32max) {
+		if readerVersion < zipVersion45 {
+			readerVersion = zipVersion45
+		}
+		var uncompressedSize, compressedSize uint64
+		if noDataDescriptor {
+			uncompressedSize, compressedSize = h.UncompressedSize64, h.CompressedSize64
+		}
+		zip64ExtraInfo = make([]byte, 20) // 2x uint16 + 2x uint64
+		b := writeBuf(zip64ExtraInfo)
+		b.uint16(zip64ExtraID)
+		b.uint16(16) // size of Zip64 extra field data
+		b.uint64(uncompressedSize)
+		b.uint64(compressedSize)
+	}
+
+	var buf [fileHeaderLen]byte
+	b := writeBuf(buf[:])
+	b.uint32(uint32(fileHeaderSignature))
+	b.uint16(readerVersion)
+	b.uint16(h.Flags)
+	b.uint16(h.Method)
+	b.uint16(h.ModifiedTime)
+	b.uint16(h.ModifiedDate)
+	switch {
+	case zip64ExtraInfo != nil:
+		if noDataDescriptor {
+			b.uint32(h.CRC32)
+		} else {
+			b.uint32(0)
+		}
+		b.uint32(uint32max)
+		b.uint32(uint32max)
+	case noDataDescriptor:
+		b.uint32(h.CRC32)
+		b.uint32(uint32(h.CompressedSize64))
+		b.uint32(uint32(h.UncompressedSize64))
+	default:
+		b.uint32(0) // crc32
+		b.uint32(0) // compressed size
+		b.uint32(0) // uncompressed size
+	}
+	extraLen := len(h.Extra) + len(h.localExtra) + len(zip64ExtraInfo)
+	padding := h.alignment(extraLen)
+	if extraLen+len(padding) > uint16max {
+		return errLongExtra
+	}
+	b.uint16(uint16(len(h.Name)))
+	b.uint16(uint16(extraLen + len(padding)))
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, h.Name); err != nil {
+		return err
+	}
+	if _, err := w.Write(h.Extra); err != nil {
+		return err
+	}
+	if _, err := w.Write(h.localExtra); err != nil {
+		return err
+	}
+	if _, err := w.Write(zip64ExtraInfo); err != nil {
+		return err
+	}
+	if _, err := w.Write(padding); err != nil {
+		return err
+	}
+	return nil
+}
+
+// createRaw adds a file to the zip archive using the provided FileHeader
+// and returns a Writer to which the file contents should be written, as
+// they are to be stored: they are not compressed, and the sizes and CRC32
+// of fh must describe them already. The file's contents must be written to
+// the io.Writer before the next call to Create, CreateHeader, CreateEntry,
+// CopyRaw, or Close.
+func (w *Writer) createRaw(fh *FileHeader) (io.Writer, error) {
+	if err := w.prepare(fh); err != nil {
+		return nil, err
+	}
+
+	if w.opts.Deterministic {
+		w.normalize(fh)
+		if !fh.Modified.IsZero() {
+			w.setTimes(fh, time.Time{}, time.Time{})
+		}
+	}
+	return w.beginRaw(fh, nil, nil)
+}
+
+// beginRaw adds fh, whose sizes and CRC32 describe the contents as they
+// are to be stored, with the extra fields localExtra in the local file
+// header only and centralExtra in the central directory only, and returns
+// a Writer for the contents.
+func (w *Writer) beginRaw(fh *FileHeader, localExtra, centralExtra []byte) (io.Writer, error) {
+	fh.CompressedSize = uint32(min64(fh.CompressedSize64, uint32max))
+	fh.UncompressedSize = uint32(min64(fh.UncompressedSize64, uint32max))
+
+	h := &header{
+		FileHeader:   fh,
+		offset:       uint64(w.cw.count),
+		raw:          true,
+		zip64:        w.opts.ForceZip64,
+		align:        w.opts.Align,
+		sized:        true,
+		localExtra:   localExtra,
+		centralExtra: centralExtra,
+	}
+	out, err := w.bodyWriter()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.begin(h); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(fh.Name, "/") {
+		w.last = nil
+		w.reportDir(fh.Name)
+		return dirWriter{}, nil
+	}
+
+	fw := &fileWriter{
+		header:    h,
+		zipw:      out,
+		compCount: &countWriter{w: out},
+		report:    w.reporter(fh.Name),
+	}
+	w.last = fw
+	fw.reportBegin()
+	return fw, nil
+}
+
+// RegisterCompressor registers or overrides a custom compressor for a
+// specific method ID. If a compressor for a given method is not found,
+// Writer will default to looking up the compressor at the package level.
+func (w *Writer) RegisterCompressor(method uint16, comp Compressor) {
+	if w.compressors == nil {
+		w.compressors = make(map[uint16]Compressor)
+	}
+	w.compressors[method] = comp
+}
+
+func (w *Writer) compressor(method uint16) Compressor {
+	comp := w.compressors[method]
+	if comp == nil {
+		comp = compressor(method)
+	}
+	return comp
+}
+
+type dirWriter struct{}
+
+func (dirWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return 0, errors.New("zip: write to directory")
+}
+
+type fileWriter struct {
+	*header
+	zipw      io.Writer
+	rawCount  *countWriter
+	comp      io.WriteCloser
+	compCount *countWriter
+	crc32     hash.Hash32
+	closed    bool
+
+	// enc, if not nil, encrypts the compressed contents, see Encryption.
+	enc io.WriteCloser
+
+	// patch, if not nil, fills in the local file header once the contents
+	// are written, see Writer.patchHeader.
+	patch func() error
+
+	// sozip, if not nil, compressed the contents, and writes their index
+	// after them, see Options.SOZip.
+	sozip *sozipWriter
+
+	// start, if not nil, begins the entry once sample, which holds the
+	// start of the contents, is full, see Options.StoreIncompressible.
+	start  func() error
+	sample []byte
+
+	// report, if not nil, reports the progress of the entry, see
+	// Options.Progress.
+	report func(in int64, done bool)
+
+	// digest, if not nil, hashes the contents, see Options.SHA256.
+	digest hash.Hash
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	n, err := w.write(p)
+	if w.report != nil && n > 0 {
+		in := int64(n)
+		if w.raw {
+			in = 0 // the contents as stored, reported once complete
+		}
+		w.report(in, false)
+	}
+	return n, err
+}
+
+func (w *fileWriter) write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("zip: write to closed file")
+	}
+	if w.start != nil {
+		n := cap(w.sample) - len(w.sample)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.sample = append(w.sample, p[:n]...)
+		if len(w.sample) < cap(w.sample) {
+			return n, nil
+		}
+		if err := w.writeSample(); err != nil {
+			return 0, err
+		}
+		m, err := w.write(p[n:])
+		return n + m, err
+	}
+	if w.raw {
+		return w.compCount.Write(p)
+	}
+	w.crc32.Write(p)
+	return w.rawCount.Write(p)
+}
+
+// writeSample begins the entry, and writes the sample of its contents.
+// If the entry cannot begin, it is left out of the archive.
+func (w *fileWriter) writeSample() error {
+	start := w.start
+	w.start = nil
+	if err := start(); err != nil {
+		w.closed = true
+		return err
+	}
+	sample := w.sample
+	w.sample = nil
+	w.crc32.Write(sample)
+	_, err := w.rawCount.Write(sample)
+	return err
+}
+
+func (w *fileWriter) close() error {
+	if w.closed {
+		return errors.New("zip: file closed twice")
+	}
+	if w.start != nil {
+		if err := w.writeSample(); err != nil {
+			return err
+		}
+	}
+	w.closed = true
+	if w.raw {
+		if n := w.compCount.count; n != int64(w.CompressedSize64) {
+			return errs.Errorf("zipwrite: %q: %d bytes written, CompressedSize64 is %d", w.Name, n, w.CompressedSize64)
+		}
+		return w.finish()
+	}
+	if err := w.comp.Close(); err != nil {
+		return err
+	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err != nil {
+			return err
+		}
+	}
+
+	// update FileHeader
+	fh := w.header.FileHeader
+	fh.CRC32 = w.crc32.Sum32()
+	if fh.Method == methodWinZipAES {
+		fh.CRC32 = 0 // AE-2 leaves it out
+	}
+	fh.CompressedSize64 = uint64(w.compCount.count)
+	fh.UncompressedSize64 = uint64(w.rawCount.count)
+
+	if w.zip64 || fh.CompressedSize64 > uint32max || fh.UncompressedSize64 > uint32max {
+		fh.CompressedSize = uint32max
+		fh.UncompressedSize = uint32max
+		if fh.ReaderVersion < zipVersion45 {
+			fh.ReaderVersion = zipVersion45 // requires 4.5 - File uses ZIP64 format extensions
+		}
+	} else {
+		fh.CompressedSize = uint32(fh.CompressedSize64)
+		fh.UncompressedSize = uint32(fh.UncompressedSize64)
+	}
+
+	if w.patch != nil {
+		if err := w.patch(); err != nil {
+			return err
+		}
+	} else if !w.hasDataDescriptor() {
+		// The header is spooled, and written with the sizes.
+		w.sized = true
+	}
+	if w.digest != nil {
+		if err := appendDigest(fh, w.digest); err != nil {
+			return err
+		}
+	}
+	return w.finish()
+}
+
+// finish writes what follows the contents: the data descriptor, and the
+// SOZip index entry.
+func (w *fileWriter) finish() error {
+	if err := w.writeDataDescriptor(); err != nil {
+		return err
+	}
+	if w.sozip != nil {
+		if err := w.sozip.writeIndex(w.zipw, w.FileHeader); err != nil {
+			return err
+		}
+	}
+	if w.report != nil {
+		var in int64
+		if w.raw {
+			in = int64(w.UncompressedSize64)
+		}
+		w.report(in, true)
+	}
+	return nil
+}
+
+func (w *fileWriter) writeDataDescriptor() error {
+	if !w.hasDataDescriptor() {
+		return nil
+	}
+	// See the comment in writeHeader about how and why we don't signal ZIP64
+	// mode in the local file header by default. If one of the sizes turns
+	// out to exceed 4GiB, we use the 64-bit sizes anyway, for lack of
+	// alternatives.
+	//
+	// See also https://bugs.openjdk.org/browse/JDK-7073588.
+	zip64 := w.zip64 || w.CompressedSize64 > uint32max || w.UncompressedSize64 > uint32max
+	var buf []byte
+	if zip64 {
+		buf = make([]byte, dataDescriptor64Len)
+	} else {
+		buf = make([]byte, dataDescriptorLen)
+	}
+	b := writeBuf(buf)
+	b.uint32(dataDescriptorSignature) // de-facto standard, required by OS X
+	b.uint32(w.CRC32)
+	if zip64 {
+		b.uint64(w.CompressedSize64)
+		b.uint64(w.UncompressedSize64)
+	} else {
+		b.uint32(w.CompressedSize)
+		b.uint32(w.UncompressedSize)
+	}
+	_, err := w.zipw.Write(buf)
+	return err
+}
+
+type countWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.count += int64(n)
+	return n, err
+}
+
+type writeBuf []byte
+
+func (b *writeBuf) uint8(v uint8) {
+	(*b)[0] = v
+	*b = (*b)[1:]
+}
+
+func (b *writeBuf) uint16(v uint16) {
+	binary.LittleEndian.PutUint16(*b, v)
+	*b = (*b)[2:]
+}
+
+func (b *writeBuf) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(*b, v)
+	*b = (*b)[4:]
+}
+
+func (b *writeBuf) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(*b, v)
+	*b = (*b)[8:]
+}
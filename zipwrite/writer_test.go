@@ -0,0 +1,259 @@
+package zipwrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"zipper/zipread"
+)
+
+type testEntry struct {
+	Name   string
+	Method uint16
+	Data   []byte
+}
+
+var testEntries = []testEntry{
+	{Name: "stored", Method: Store, Data: []byte("stored content")},
+	{Name: "dir/", Method: Store},
+	{Name: "dir/deflated", Method: Deflate, Data: bytes.Repeat([]byte("deflated "), 1000)},
+	{Name: "empty", Method: Deflate},
+}
+
+func writeTestZip(t *testing.T, opts *Options, entries ...testEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriterWithOptions(&buf, opts)
+	for _, e := range entries {
+		fw, err := w.CreateHeader(&FileHeader{
+			Name:     e.Name,
+			Method:   e.Method,
+			Modified: time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(e.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func checkTestZip(t *testing.T, source zipread.Source, entries ...testEntry) *zipread.Reader {
+	t.Helper()
+	z, err := zipread.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.File) != len(entries) {
+		t.Fatalf("got %d entries, want %d", len(z.File), len(entries))
+	}
+	for i, e := range entries {
+		f := z.File[i]
+		if f.Name != e.Name || f.Method != e.Method {
+			t.Errorf("entry %d: got %q method %d, want %q method %d", i, f.Name, f.Method, e.Name, e.Method)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		if err := rc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err != nil || !bytes.Equal(data, e.Data) {
+			t.Errorf("%s: got %d bytes, %v, want %d bytes", e.Name, len(data), err, len(e.Data))
+		}
+	}
+	return z
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ForceZip64=%v", force), func(t *testing.T) {
+			data := writeTestZip(t, &Options{ForceZip64: force}, testEntries...)
+			z := checkTestZip(t, zipread.SourceFromReaderAt(bytes.NewReader(data), int64(len(data))), testEntries...)
+			for _, f := range z.File {
+				if got := f.ReaderVersion == zipVersion45; got != force {
+					t.Errorf("%s: reader version %d", f.Name, f.ReaderVersion)
+				}
+			}
+			hasEnd64 := bytes.Contains(data, []byte("PK\x06\x06"))
+			if hasEnd64 != force {
+				t.Errorf("zip64 end of central directory present: %v", hasEnd64)
+			}
+		})
+	}
+}
+
+func TestWriterForceZip64Layout(t *testing.T) {
+	// With every header in the Zip64 format, archives differ in length by
+	// the length of their contents only.
+	overhead := func(size int) int {
+		data := writeTestZip(t, &Options{ForceZip64: true},
+			testEntry{Name: "a", Method: Store, Data: make([]byte, size)},
+			testEntry{Name: "b/", Method: Store})
+		return len(data) - size
+	}
+	if small, large := overhead(10), overhead(1<<20); small != large {
+		t.Errorf("overhead of %d bytes for a small entry, %d for a large one", small, large)
+	}
+}
+
+func TestWriterZip64Entries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes 65536 entries")
+	}
+	entries := make([]testEntry, uint16max+1)
+	for i := range entries {
+		entries[i] = testEntry{Name: fmt.Sprint(i), Method: Store}
+	}
+	data := writeTestZip(t, nil, entries[:10]...)
+	if bytes.Contains(data, []byte("PK\x06\x06")) {
+		t.Error("zip64 end of central directory in a small archive")
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range entries {
+		if _, err := w.CreateHeader(&FileHeader{Name: e.Name, Method: e.Method}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.File) != len(entries) {
+		t.Errorf("got %d entries, want %d", len(z.File), len(entries))
+	}
+}
+
+// prefixedReaderAt serves data after prefix zero bytes.
+type prefixedReaderAt struct {
+	prefix int64
+	data   []byte
+}
+
+func (p prefixedReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n := 0
+	for ; off < p.prefix && n < len(b); off++ {
+		b[n] = 0
+		n++
+	}
+	if off-p.prefix >= int64(len(p.data)) {
+		if n == len(b) {
+			return n, nil
+		}
+		return n, io.EOF
+	}
+	m := copy(b[n:], p.data[off-p.prefix:])
+	if n+m < len(b) {
+		return n + m, io.EOF
+	}
+	return n + m, nil
+}
+
+func TestWriterZip64Offsets(t *testing.T) {
+	const prefix = 5 << 30
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetOffset(prefix)
+	entries := []testEntry{{Name: "far", Method: Deflate, Data: []byte(strings.Repeat("far away ", 100))}}
+	fw, err := w.Create(entries[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(entries[0].Data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The 32-bit offsets in the directory record and the end record are
+	// saturated, the real ones are in the Zip64 records.
+	data := buf.Bytes()
+	dir := bytes.Index(data, []byte("PK\x01\x02"))
+	if got := binary.LittleEndian.Uint32(data[dir+42:]); got != uint32max {
+		t.Errorf("directory record offset %#x", got)
+	}
+	end := bytes.LastIndex(data, []byte("PK\x05\x06"))
+	if got := binary.LittleEndian.Uint32(data[end+16:]); got != uint32max {
+		t.Errorf("end record directory offset %#x", got)
+	}
+
+	source := zipread.SourceFromReaderAt(prefixedReaderAt{prefix: prefix, data: data}, prefix+int64(len(data)))
+	checkTestZip(t, source, entries...)
+}
+
+func TestWriterRegisterCompressor(t *testing.T) {
+	const method = 0x7fff
+	content := []byte("compressed by a registered compressor")
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.CreateHeader(&FileHeader{Name: "a", Method: method}); err != zipread.ErrAlgorithm {
+		t.Fatalf("unregistered method: got %v, want %v", err, zipread.ErrAlgorithm)
+	}
+	w.RegisterCompressor(method, func(w io.Writer) (io.WriteCloser, error) {
+		return nopCloser{xorWriter{w}}, nil
+	})
+	fw, err := w.CreateHeader(&FileHeader{Name: "b", Method: method})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), content) {
+		t.Error("content written uncompressed")
+	}
+
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.RegisterDecompressor(method, func(r io.Reader) io.ReadCloser {
+		return io.NopCloser(xorReader{r})
+	})
+	if got, err := z.ReadFile("b"); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+// xorWriter and xorReader flip every bit of the data passing through.
+type xorWriter struct{ w io.Writer }
+
+func (x xorWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	for i := range p {
+		b[i] = ^p[i]
+	}
+	return x.w.Write(b)
+}
+
+type xorReader struct{ r io.Reader }
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] = ^p[i]
+	}
+	return n, err
+}
@@ -0,0 +1,32 @@
+// Package zstd registers a compressor for Zstandard compressed entries,
+// method 93, with zipwrite. Importing it also registers the matching
+// decompressor with zipread, so that the archives written can be read
+// back; import it for its side effect:
+//
+//	import _ "zipper/zipwrite/zstd"
+package zstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	readzstd "zipper/zipread/zstd"
+	"zipper/zipwrite"
+)
+
+// Method is the compression method of Zstandard compressed entries, the
+// one zipread/zstd decompresses.
+const Method = readzstd.Method
+
+func init() {
+	zipwrite.RegisterCompressor(Method, NewWriter)
+}
+
+// NewWriter returns a writer compressing to w as a Zstandard stream at the
+// default level. It is the zipwrite.Compressor registered for Method, and
+// can be registered with a single Writer instead where the package level
+// registration is not wanted.
+func NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+}
@@ -0,0 +1,41 @@
+package zstd
+
+import (
+	"bytes"
+	"testing"
+
+	"zipper/zipread"
+	"zipper/zipwrite"
+)
+
+func TestCompress(t *testing.T) {
+	content := bytes.Repeat([]byte("zstandard in a zip container\n"), 1000)
+
+	var buf bytes.Buffer
+	w := zipwrite.NewWriter(&buf)
+	fw, err := w.CreateHeader(&zipwrite.FileHeader{Name: "a.txt", Method: Method})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zipread.Open(zipread.SourceFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := z.File[0].Method; got != Method {
+		t.Errorf("method %d, want %d", got, Method)
+	}
+	got, err := z.ReadFile("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content differs after a round trip")
+	}
+}